@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// Optional settings checked by --check-config. Unset ones use their defaults,
+// but a value that doesn't parse is silently ignored at runtime, which is
+// almost always a typo worth catching before rollout.
+var (
+	durationSettings = []string{
+		"ANALYTICS_RETENTION", "CLICK_FLUSH_INTERVAL", "EXPIRED_LINK_RETENTION",
+		"EXPIRY_WARNING_INTERVAL", "EXPIRY_WARNING_THRESHOLD", "GEOIP_RELOAD_INTERVAL",
+		"HEALTH_CHECK_INTERVAL", "HTTPS_PROBE_TIMEOUT", "HTTPS_PROBE_TTL", "IDEMPOTENCY_TTL",
+		"REDIRECT_CACHE_TTL", "REDIS_DIAL_TIMEOUT", "REDIS_READ_TIMEOUT", "REQUEST_TIMEOUT",
+		"RESERVATION_TTL", "SLIDING_EXPIRY", "SLIDING_EXPIRY_MAX", "STATS_RETENTION", "TOMBSTONE_TTL",
+	}
+	intSettings = []string{
+		"CLICK_BATCH_SIZE", "CLICK_BUFFER_SIZE", "LINK_EVENTS_LIMIT", "MAX_ALIASES_PER_LINK",
+		"MAX_CONCURRENT_REQUESTS", "MAX_LINKS_PER_IP", "MAX_LINK_SIZE", "MAX_LINK_TARGETS",
+		"MAX_TAGS_PER_LINK", "MAX_TAG_LEN", "MIN_CODE_ENTROPY_BITS", "MIN_CUSTOM_SHORT_LEN",
+		"REDIS_MIN_IDLE_CONNS", "REDIS_POOL_SIZE", "REPORT_DISABLE_THRESHOLD", "REPORT_LIMIT_PER_HOUR",
+		"SECONDARY_STORE_BUFFER", "VERIFY_LIMIT_PER_HOUR",
+	}
+	boolSettings = []string{
+		"ALLOW_URL_CREDENTIALS", "BLOCK_PRIVATE_HOSTS", "CASE_INSENSITIVE_SHORTS", "COUNT_HEAD_REQUESTS",
+		"ENABLE_COMPRESSION", "ENABLE_SEED_ENDPOINT", "HTTPS_ONLY", "LEGACY_SHORTEN_STATUS", "LINK_EVENTS",
+		"LOG_DESTINATIONS", "OTEL_TRACING", "RATE_LIMIT_FAIL_OPEN", "READ_ONLY", "RECLAIM_EXPIRED_CODES",
+		"REQUIRE_HTTPS", "STORE_CREATOR_INFO", "STRICT_URL_VALIDATION", "UPGRADE_INSECURE_ON_RESOLVE",
+	}
+	choiceSettings = map[string][]string{
+		"STORAGE_BACKEND":   {"redis", "memory"},
+		"CLICK_TRACKING":    {"sync", "async"},
+		"BOT_CLICKS":        {"separate", "skip", "count"},
+		"ALIAS_STATS":       {"shared", "separate"},
+		"DEFAULT_SCHEME":    {"http", "https"},
+		"ROBOTS_POLICY":     {"allow", "disallow"},
+		"JSON_NAMING":       {"snake", "camel"},
+		"REDIRECT_BODY":     {"none", "text", "html"},
+		"PURGE_ANALYTICS":   {"delete", "expire", "never"},
+		"RECLAIM_ANALYTICS": {"reset", "carry"},
+	}
+)
+
+// checkConfig validates the environment the server would start with and
+// reports each problem to w. It returns how many problems it found.
+func checkConfig(w io.Writer) int {
+	problems := 0
+	fail := func(key, format string, args ...interface{}) {
+		problems++
+		fmt.Fprintf(w, "FAIL  %s: %s\n", key, fmt.Sprintf(format, args...))
+	}
+
+	for _, key := range []string{"DOMAIN", "APP_PORT"} {
+		if os.Getenv(key) == "" {
+			fail(key, "is required")
+		}
+	}
+	if quota, err := strconv.Atoi(os.Getenv("API_QUOTA")); err != nil || quota <= 0 {
+		fail("API_QUOTA", "must be a positive whole number, got %q", os.Getenv("API_QUOTA"))
+	}
+	if v := os.Getenv("MAX_EXPIRY"); v != "" {
+		if d, err := helpers.ParseExpiry(v); err != nil {
+			fail("MAX_EXPIRY", "%v", err)
+		} else if d == helpers.NeverExpires {
+			fail("MAX_EXPIRY", "must be a duration, not %q", v)
+		}
+	}
+
+	for _, key := range durationSettings {
+		if v := os.Getenv(key); v != "" {
+			if d, err := time.ParseDuration(v); err != nil || d < 0 {
+				fail(key, "must be a duration such as 30s or 5m, got %q", v)
+			}
+		}
+	}
+	for _, key := range intSettings {
+		if v := os.Getenv(key); v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n < 0 {
+				fail(key, "must be a non-negative whole number, got %q", v)
+			}
+		}
+	}
+	for _, key := range boolSettings {
+		if v := os.Getenv(key); v != "" {
+			if _, err := strconv.ParseBool(v); err != nil {
+				fail(key, "must be true or false, got %q", v)
+			}
+		}
+	}
+	for key, choices := range choiceSettings {
+		if v := os.Getenv(key); v != "" && !contains(choices, v) {
+			fail(key, "must be one of %v, got %q", choices, v)
+		}
+	}
+
+	if v := os.Getenv("SHORT_CODE_LENGTH"); v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > helpers.MaxCodeLength {
+			fail("SHORT_CODE_LENGTH", "must be a whole number from 1 to %d, got %q", helpers.MaxCodeLength, v)
+		}
+	}
+	if err := helpers.CheckCodeEntropy(); err != nil {
+		fail("MIN_CODE_ENTROPY_BITS", "%v", err)
+	}
+
+	if v := os.Getenv("ANALYTICS_SAMPLE_RATE"); v != "" {
+		if rate, err := strconv.ParseFloat(v, 64); err != nil || !(rate >= 0 && rate <= 1) {
+			fail("ANALYTICS_SAMPLE_RATE", "must be a number from 0 to 1, got %q", v)
+		}
+	}
+
+	if v := os.Getenv("GEOIP_DB_PATH"); v != "" {
+		if _, err := helpers.LoadGeoDB(v); err != nil {
+			fail("GEOIP_DB_PATH", "%v", err)
+		}
+	}
+
+	if v := os.Getenv("SECONDARY_STORE"); v != "" {
+		if _, err := redis.ParseURL(v); err != nil {
+			fail("SECONDARY_STORE", "%v", err)
+		}
+	}
+
+	if config.String("STORAGE_BACKEND", "redis") == "redis" {
+		rdb := redis.NewClient(database.LoadConfig().Options(0))
+		defer rdb.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			fail("DB_ADD", "cannot reach Redis at %q: %v", os.Getenv("DB_ADD"), err)
+		}
+	}
+
+	if problems == 0 {
+		fmt.Fprintln(w, "configuration OK")
+	} else {
+		fmt.Fprintf(w, "%d configuration problem(s) found\n", problems)
+	}
+	return problems
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,40 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestValues(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		got  func() interface{}
+		want interface{}
+	}{
+		{"String set", "value", func() interface{} { return String("K", "def") }, "value"},
+		{"String unset", "", func() interface{} { return String("K", "def") }, "def"},
+		{"Bool set", "true", func() interface{} { return Bool("K", false) }, true},
+		{"Bool numeric", "0", func() interface{} { return Bool("K", true) }, false},
+		{"Bool invalid", "yes", func() interface{} { return Bool("K", true) }, true},
+		{"Int set", "42", func() interface{} { return Int("K", 7) }, 42},
+		{"Int negative", "-3", func() interface{} { return Int("K", 7) }, -3},
+		{"Int invalid", "4.2", func() interface{} { return Int("K", 7) }, 7},
+		{"Float set", "0.25", func() interface{} { return Float("K", 1) }, 0.25},
+		{"Float invalid", "quarter", func() interface{} { return Float("K", 1) }, 1.0},
+		{"Duration set", "90s", func() interface{} { return Duration("K", time.Hour) }, 90 * time.Second},
+		{"Duration without unit", "90", func() interface{} { return Duration("K", time.Hour) }, time.Hour},
+		{"Duration unset", "", func() interface{} { return Duration("K", time.Hour) }, time.Hour},
+		{"List", " a, b ,,c ", func() interface{} { return List("K") }, []string{"a", "b", "c"}},
+		{"List unset", "", func() interface{} { return List("K") }, []string(nil)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("K", tt.env)
+			if got := tt.got(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// aliasField marks a link hash as an alias: instead of a link of its own it
+// holds the key of the link it points at, which GetLink loads in its place.
+const aliasField = "alias_of"
+
+// AliasesKey is the DB 0 set of alias keys pointing at the link at key.
+func AliasesKey(key string) string {
+	return "aliases:" + key
+}
+
+// SaveAlias stores alias as another key for the link at key. The alias expires
+// along with the link. It returns redis.Nil when there is no link at key.
+func SaveAlias(ctx context.Context, rdb Store, alias, key string) error {
+	ttl, err := rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if ttl == -2 {
+		return redis.Nil
+	}
+	return rdb.Atomic(ctx, func(tx Store) error {
+		tx.HSet(ctx, alias, aliasField, key)
+		tx.SAdd(ctx, AliasesKey(key), alias)
+		if ttl > 0 {
+			tx.Expire(ctx, alias, ttl)
+			tx.Expire(ctx, AliasesKey(key), ttl)
+		}
+		return nil
+	})
+}
+
+// DeleteAlias removes alias, which points at the link at key. The link itself
+// is kept.
+func DeleteAlias(ctx context.Context, rdb Store, alias, key string) error {
+	return rdb.Atomic(ctx, func(tx Store) error {
+		tx.Del(ctx, alias)
+		tx.SRem(ctx, AliasesKey(key), alias)
+		return nil
+	})
+}
+
+// LinkAliases returns the keys of the aliases pointing at the link at key.
+func LinkAliases(ctx context.Context, rdb Store, key string) ([]string, error) {
+	return rdb.SMembers(ctx, AliasesKey(key)).Result()
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// announcementKey is the DB 0 hash holding the current announcement. It
+// carries the announcement's TTL, so an expired announcement is simply gone.
+const announcementKey = "announcement"
+
+// Announcement is a message operators show in front-ends' banners, such as
+// upcoming maintenance.
+type Announcement struct {
+	Message  string
+	Severity string
+	// ExpiresAt is when the announcement is taken down; zero if it stays
+	// until it's cleared.
+	ExpiresAt time.Time
+}
+
+// SetAnnouncement replaces the current announcement with a, expiring after
+// ttl, or never when ttl is 0.
+func SetAnnouncement(ctx context.Context, rdb Store, a *Announcement, ttl time.Duration) error {
+	f := map[string]interface{}{
+		"message":  a.Message,
+		"severity": a.Severity,
+	}
+	if !a.ExpiresAt.IsZero() {
+		f["expires_at"] = strconv.FormatInt(a.ExpiresAt.Unix(), 10)
+	}
+	return rdb.Atomic(ctx, func(tx Store) error {
+		tx.Del(ctx, announcementKey)
+		tx.HSet(ctx, announcementKey, f)
+		if ttl > 0 {
+			tx.Expire(ctx, announcementKey, ttl)
+		}
+		return nil
+	})
+}
+
+// GetAnnouncement loads the current announcement. It returns redis.Nil when
+// there is none.
+func GetAnnouncement(ctx context.Context, rdb Store) (*Announcement, error) {
+	f, err := rdb.HGetAll(ctx, announcementKey).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(f) == 0 {
+		return nil, redis.Nil
+	}
+
+	a := &Announcement{Message: f["message"], Severity: f["severity"]}
+	if v, err := strconv.ParseInt(f["expires_at"], 10, 64); err == nil {
+		a.ExpiresAt = time.Unix(v, 0)
+	}
+	return a, nil
+}
+
+// ClearAnnouncement takes the current announcement down.
+func ClearAnnouncement(ctx context.Context, rdb Store) error {
+	return rdb.Del(ctx, announcementKey).Err()
+}
@@ -0,0 +1,37 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestAnnouncementExpires(t *testing.T) {
+	ctx := context.Background()
+	r, clock := newTestStore()
+
+	a := &Announcement{Message: "Maintenance tonight", Severity: "warning", ExpiresAt: clock.Now().Add(time.Hour)}
+	if err := SetAnnouncement(ctx, r, a, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	got, err := GetAnnouncement(ctx, r)
+	if err != nil || got.Message != a.Message || got.Severity != a.Severity || !got.ExpiresAt.Equal(a.ExpiresAt) {
+		t.Fatalf("GetAnnouncement = %+v, %v, want %+v", got, err, a)
+	}
+
+	clock.Advance(time.Hour)
+	if _, err := GetAnnouncement(ctx, r); err != redis.Nil {
+		t.Errorf("after its expiry: err = %v, want redis.Nil", err)
+	}
+
+	// without an expiry it stays
+	if err := SetAnnouncement(ctx, r, &Announcement{Message: "Welcome", Severity: "info"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(365 * 24 * time.Hour)
+	if got, err := GetAnnouncement(ctx, r); err != nil || !got.ExpiresAt.IsZero() {
+		t.Errorf("GetAnnouncement = %+v, %v, want it kept with no expiry", got, err)
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// APIKey is an API key's grants. Keys are stored in DB 0 as hashes under the
+// SHA-256 of the key, so the key itself is never kept.
+type APIKey struct {
+	// ID is the hash the key is stored under. It isn't stored itself.
+	ID        string
+	Scopes    []string
+	CreatedAt time.Time
+	// Namespace prefixes every code created with the key, so teams sharing a
+	// deployment can't collide. Empty means the default namespace.
+	Namespace string
+	// RateLimit is the key's own shorten quota per window, counted across
+	// everyone using the key instead of per IP. 0 means the usual per-IP
+	// API_QUOTA; Unlimited means no limit at all.
+	RateLimit int
+}
+
+// Unlimited is the RateLimit of keys that are never rate limited.
+const Unlimited = -1
+
+// Access scopes, each allowing what the ones before it do: read may resolve
+// and look up links, write may also create and manage them, and admin may
+// call the admin API too.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+	ScopeAdmin = "admin"
+)
+
+var accessLevels = map[string]int{ScopeRead: 1, ScopeWrite: 2, ScopeAdmin: 3}
+
+// Allows reports whether the key's access scope covers access. Keys granted
+// none of read, write and admin, as keys made before they existed were, have
+// write access.
+func (k *APIKey) Allows(access string) bool {
+	level := 0
+	for _, s := range k.Scopes {
+		level = max(level, accessLevels[s])
+	}
+	if level == 0 {
+		level = accessLevels[ScopeWrite]
+	}
+	return level >= accessLevels[access]
+}
+
+// HasScope reports whether the key was granted scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyKey is where the API key hashing to hash is stored.
+func APIKeyKey(hash string) string {
+	return "apikey:" + hash
+}
+
+// SaveAPIKey stores key under the hash of the API key.
+func SaveAPIKey(ctx context.Context, rdb Store, hash string, key *APIKey) error {
+	return rdb.HSet(ctx, APIKeyKey(hash), map[string]interface{}{
+		"scopes":     strings.Join(key.Scopes, ","),
+		"created_at": strconv.FormatInt(key.CreatedAt.Unix(), 10),
+		"namespace":  key.Namespace,
+		"rate_limit": formatRateLimit(key.RateLimit),
+	}).Err()
+}
+
+// GetAPIKey loads the API key hashing to hash. It returns redis.Nil for an
+// unknown or revoked key.
+func GetAPIKey(ctx context.Context, rdb Store, hash string) (*APIKey, error) {
+	f, err := rdb.HGetAll(ctx, APIKeyKey(hash)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(f) == 0 {
+		return nil, redis.Nil
+	}
+
+	key := &APIKey{ID: hash, Namespace: f["namespace"]}
+	if f["scopes"] != "" {
+		key.Scopes = strings.Split(f["scopes"], ",")
+	}
+	if v, err := strconv.ParseInt(f["created_at"], 10, 64); err == nil {
+		key.CreatedAt = time.Unix(v, 0)
+	}
+	if f["rate_limit"] == "unlimited" {
+		key.RateLimit = Unlimited
+	} else {
+		key.RateLimit, _ = strconv.Atoi(f["rate_limit"])
+	}
+	return key, nil
+}
+
+// formatRateLimit is how a key's RateLimit is stored: "unlimited", a
+// number, or "" for the default.
+func formatRateLimit(n int) string {
+	switch {
+	case n == Unlimited:
+		return "unlimited"
+	case n > 0:
+		return strconv.Itoa(n)
+	}
+	return ""
+}
+
+// DeleteAPIKey revokes the API key hashing to hash.
+func DeleteAPIKey(ctx context.Context, rdb Store, hash string) error {
+	return rdb.Del(ctx, APIKeyKey(hash)).Err()
+}
+
+// NamespaceKey marks ns as claimed by an API key. Namespaces match
+// case-insensitively and stay claimed after their keys are revoked, since
+// their links live on.
+func NamespaceKey(ns string) string {
+	return "namespace:" + strings.ToLower(ns)
+}
+
+// ClaimNamespace records that an API key uses namespace ns.
+func ClaimNamespace(ctx context.Context, rdb Store, ns string) error {
+	return rdb.Set(ctx, NamespaceKey(ns), 1, 0).Err()
+}
+
+// IsNamespace reports whether ns is some API key's namespace.
+func IsNamespace(ctx context.Context, rdb Store, ns string) (bool, error) {
+	n, err := rdb.Exists(ctx, NamespaceKey(ns)).Result()
+	return n > 0, err
+}
@@ -0,0 +1,48 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestAPIKeyAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		read   bool
+		write  bool
+		admin  bool
+	}{
+		{"read", []string{ScopeRead}, true, false, false},
+		{"write", []string{ScopeWrite}, true, true, false},
+		{"admin", []string{ScopeAdmin}, true, true, true},
+		{"legacy, no scopes", nil, true, true, false},
+		{"legacy, premium only", []string{"premium"}, true, true, false},
+		{"read and premium", []string{"premium", ScopeRead}, true, false, false},
+		{"highest wins", []string{ScopeRead, ScopeAdmin}, true, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &APIKey{Scopes: tt.scopes}
+			for access, want := range map[string]bool{ScopeRead: tt.read, ScopeWrite: tt.write, ScopeAdmin: tt.admin} {
+				if got := k.Allows(access); got != want {
+					t.Errorf("Allows(%q) = %v, want %v", access, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAPIKeyRateLimitRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestStore()
+	for _, limit := range []int{0, 1, 500, Unlimited} {
+		if err := SaveAPIKey(ctx, m, "hash", &APIKey{RateLimit: limit}); err != nil {
+			t.Fatal(err)
+		}
+		key, err := GetAPIKey(ctx, m, "hash")
+		if err != nil || key.RateLimit != limit {
+			t.Errorf("RateLimit %d came back as %v, %v", limit, key, err)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"log"
+	"math"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// clickRecorder counts clicks in DB 1: the global "counter", a per-link
+// counter and the leaderboard of most-clicked links. A link's counters expire
+// with it, after AnalyticsTTL. In sync mode every click is written on the
+// redirect path; in async mode clicks are queued and a background flusher
+// batches them into a pipeline.
+type clickRecorder struct {
+	rdb   Store
+	queue chan click
+	wg    sync.WaitGroup
+}
+
+// click is a click on the link at key, bucketed by the hour it happened in.
+// Only sampled clicks are counted in the hourly buckets. expires is when the
+// link's analytics expire, zero for never, rounded up to the minute so clicks
+// on one link still batch together.
+type click struct {
+	key     string
+	hour    time.Time
+	sampled bool
+	expires time.Time
+}
+
+var (
+	clicksOnce sync.Once
+	clicks     *clickRecorder
+)
+
+func recorder() *clickRecorder {
+	clicksOnce.Do(func() {
+		clicks = &clickRecorder{rdb: CreateClient(1)}
+	})
+	return clicks
+}
+
+// StartClickTracking starts the background flusher when CLICK_TRACKING=async.
+// It must be called before the server starts handling requests.
+func StartClickTracking() {
+	if config.String("CLICK_TRACKING", "sync") != "async" {
+		return
+	}
+	cr := recorder()
+	cr.queue = make(chan click, config.Int("CLICK_BUFFER_SIZE", 10000))
+	cr.wg.Add(1)
+	go cr.run(config.Duration("CLICK_FLUSH_INTERVAL", time.Second), config.Int("CLICK_BATCH_SIZE", 500))
+}
+
+// StopClickTracking flushes any queued clicks and stops the flusher; later
+// clicks are written synchronously. Call it once the server has stopped
+// accepting requests.
+func StopClickTracking() {
+	cr := recorder()
+	if cr.queue != nil {
+		close(cr.queue)
+		cr.wg.Wait()
+		cr.queue = nil
+	}
+}
+
+// leaderboardKey is the DB 1 sorted set of link keys scored by clicks.
+const leaderboardKey = "leaderboard"
+
+// ClicksKey is the DB 1 counter of clicks on the link at key.
+func ClicksKey(key string) string {
+	return "clicks:" + key
+}
+
+// HourlyClicksKey is the DB 1 counter of clicks on the link at key during the
+// hour starting at hour.
+func HourlyClicksKey(key string, hour time.Time) string {
+	return ClicksKey(key) + ":" + hour.UTC().Format("2006010215")
+}
+
+// statsRetention is how long hourly click buckets are kept.
+func statsRetention() time.Duration {
+	return config.Duration("STATS_RETENTION", 7*24*time.Hour)
+}
+
+// AnalyticsTTL is how long the analytics of a link expiring after linkTTL
+// are kept: as long as the link, plus ANALYTICS_RETENTION. It's 0, for no
+// expiry, when the link never expires. Every analytics write uses it, so
+// nothing outlives its link for good.
+func AnalyticsTTL(linkTTL time.Duration) time.Duration {
+	if linkTTL <= 0 {
+		return 0
+	}
+	return linkTTL + config.Duration("ANALYTICS_RETENTION", 0)
+}
+
+// bucketTTL is how long an hourly bucket is kept: STATS_RETENTION, or until
+// its link's analytics expire if that's sooner.
+func bucketTTL(analyticsTTL time.Duration) time.Duration {
+	if retention := statsRetention(); analyticsTTL <= 0 || retention < analyticsTTL {
+		return retention
+	}
+	return analyticsTTL
+}
+
+// AlignClicks makes the analytics of the links at keys, which now expire
+// after linkTTL, expire with them, or keeps them for good if the links no
+// longer expire. Hourly buckets still go at STATS_RETENTION if that's sooner.
+func AlignClicks(ctx context.Context, rdb Store, linkTTL time.Duration, keys ...string) error {
+	ttl := AnalyticsTTL(linkTTL)
+	buckets := map[string]time.Duration{}
+	for _, key := range keys {
+		err := ScanKeys(ctx, rdb, ClicksKey(key)+":??????????", func(k string) error {
+			hour, err := time.Parse("2006010215", k[len(k)-10:])
+			if err != nil {
+				return nil
+			}
+			if left := time.Until(hour.Add(statsRetention())); left > 0 {
+				buckets[k] = left
+				if ttl > 0 && ttl < left {
+					buckets[k] = ttl
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return rdb.Atomic(ctx, func(tx Store) error {
+		for _, key := range keys {
+			for _, k := range []string{ClicksKey(key), BotClicksKey(key), VisitorsKey(key)} {
+				if ttl > 0 {
+					tx.Expire(ctx, k, ttl)
+				} else {
+					tx.Persist(ctx, k)
+				}
+			}
+		}
+		for k, left := range buckets {
+			tx.Expire(ctx, k, left)
+		}
+		return nil
+	})
+}
+
+// SampleRate is the fraction of clicks, from ANALYTICS_SAMPLE_RATE, recorded
+// in the detailed analytics: hourly buckets and unique visitors. Totals are
+// always exact.
+func SampleRate() float64 {
+	rate := config.Float("ANALYTICS_SAMPLE_RATE", 1)
+	if rate < 0 || rate > 1 || math.IsNaN(rate) {
+		return 1
+	}
+	return rate
+}
+
+// scaleSampled estimates the full count from n sampled ones, rounding to the
+// nearest whole number. Nothing is sampled at a rate of 0.
+func scaleSampled(n int64, rate float64) int64 {
+	if rate == 1 || rate == 0 {
+		return n
+	}
+	return int64(math.Round(float64(n) / rate))
+}
+
+// BotClicksKey is the DB 1 counter of bot clicks on the link at key, kept
+// apart from the human clicks.
+func BotClicksKey(key string) string {
+	return "bot_clicks:" + key
+}
+
+// RecordBotClick counts a click from a bot on the link at key, which expires
+// after linkTTL. Bot clicks are rare enough to always be written
+// synchronously.
+func RecordBotClick(ctx context.Context, key string, linkTTL time.Duration) error {
+	return recorder().rdb.Atomic(ctx, func(tx Store) error {
+		tx.Incr(ctx, BotClicksKey(key))
+		if ttl := AnalyticsTTL(linkTTL); ttl > 0 {
+			tx.Expire(ctx, BotClicksKey(key), ttl)
+		}
+		return nil
+	})
+}
+
+// LinkBotClicks returns the bot clicks counted on the link at key.
+func LinkBotClicks(ctx context.Context, rdb Store, key string) (int64, error) {
+	n, err := rdb.Get(ctx, BotClicksKey(key)).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+// VisitorsKey is the DB 1 HyperLogLog of the visitors to the link at key.
+func VisitorsKey(key string) string {
+	return "visitors:" + key
+}
+
+// RecordVisitor adds visitor, an opaque identifier, to the unique visitors of
+// the link at key, which expires after linkTTL. When sampling, visitors
+// rather than clicks are sampled: the same visitor is always in or out, so
+// the sampled count scales up to an estimate of the whole.
+func RecordVisitor(ctx context.Context, key, visitor string, linkTTL time.Duration) error {
+	if !visitorSampled(visitor, SampleRate()) {
+		return nil
+	}
+	return recorder().rdb.Atomic(ctx, func(tx Store) error {
+		tx.PFAdd(ctx, VisitorsKey(key), visitor)
+		if ttl := AnalyticsTTL(linkTTL); ttl > 0 {
+			tx.Expire(ctx, VisitorsKey(key), ttl)
+		}
+		return nil
+	})
+}
+
+// visitorSampled reports whether visitor falls in the sample at rate.
+func visitorSampled(visitor string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	// a cryptographic hash spreads even near-identical identifiers across
+	// the range
+	sum := sha256.Sum256([]byte(visitor))
+	// the top 53 bits as a fraction in [0, 1)
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11)/(1<<53) < rate
+}
+
+// LinkVisitors returns the estimated number of unique visitors to the link at
+// key, scaled up from the sample when ANALYTICS_SAMPLE_RATE is below 1.
+func LinkVisitors(ctx context.Context, rdb Store, key string) (int64, error) {
+	n, err := rdb.PFCount(ctx, VisitorsKey(key)).Result()
+	return scaleSampled(n, SampleRate()), err
+}
+
+// UniqueVisitors returns the estimated number of distinct visitors across
+// the links at keys; someone who visited several of them counts once.
+func UniqueVisitors(ctx context.Context, rdb Store, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	hlls := make([]string, len(keys))
+	for i, key := range keys {
+		hlls[i] = VisitorsKey(key)
+	}
+	n, err := rdb.PFCount(ctx, hlls...).Result()
+	return scaleSampled(n, SampleRate()), err
+}
+
+// RecordClick counts a click on the link at key, which expires after
+// linkTTL, and with probability ANALYTICS_SAMPLE_RATE in its hourly bucket.
+// In async mode it returns without waiting for Redis; if the queue is full the
+// click is counted synchronously rather than dropped.
+func RecordClick(ctx context.Context, key string, linkTTL time.Duration) error {
+	cr := recorder()
+	now := time.Now().UTC()
+	cl := click{
+		key:     key,
+		hour:    now.Truncate(time.Hour),
+		sampled: rand.Float64() < SampleRate(),
+	}
+	if ttl := AnalyticsTTL(linkTTL); ttl > 0 {
+		cl.expires = now.Add(ttl + time.Minute - 1).Truncate(time.Minute)
+	}
+	if cr.queue != nil {
+		select {
+		case cr.queue <- cl:
+			return nil
+		default:
+		}
+	}
+	return cr.write(ctx, map[click]int64{cl: 1})
+}
+
+// write applies pending click counts in one pipeline.
+func (cr *clickRecorder) write(ctx context.Context, pending map[click]int64) error {
+	return cr.rdb.Atomic(ctx, func(pipe Store) error {
+		var total int64
+		for cl, by := range pending {
+			// queued clicks are written a little later than they were made
+			var ttl time.Duration
+			if !cl.expires.IsZero() {
+				ttl = time.Until(cl.expires)
+				if ttl < time.Second {
+					ttl = time.Second
+				}
+			}
+			pipe.IncrBy(ctx, ClicksKey(cl.key), by)
+			if ttl > 0 {
+				pipe.Expire(ctx, ClicksKey(cl.key), ttl)
+			}
+			if cl.sampled {
+				bucket := HourlyClicksKey(cl.key, cl.hour)
+				pipe.IncrBy(ctx, bucket, by)
+				pipe.Expire(ctx, bucket, bucketTTL(ttl))
+			}
+			pipe.ZIncrBy(ctx, leaderboardKey, float64(by), cl.key)
+			total += by
+		}
+		pipe.IncrBy(ctx, "counter", total)
+		return nil
+	})
+}
+
+// HourCount is the number of clicks in the hour starting at Hour.
+type HourCount struct {
+	Hour   time.Time `json:"hour"`
+	Clicks int64     `json:"clicks"`
+}
+
+// LinkClicks returns the total clicks on the link at key and its hourly
+// clicks for the last hours hours, oldest first. The total is exact; hourly
+// counts are scaled up from the sample when ANALYTICS_SAMPLE_RATE is below 1.
+func LinkClicks(ctx context.Context, rdb Store, key string, hours int) (int64, []HourCount, error) {
+	now := time.Now().UTC().Truncate(time.Hour)
+	keys := []string{ClicksKey(key)}
+	series := make([]HourCount, hours)
+	for i := range series {
+		series[i].Hour = now.Add(-time.Duration(hours-1-i) * time.Hour)
+		keys = append(keys, HourlyClicksKey(key, series[i].Hour))
+	}
+
+	values, err := rdb.MGet(ctx, keys...).Result()
+	if err != nil {
+		return 0, nil, err
+	}
+	counts := make([]int64, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			counts[i], _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	rate := SampleRate()
+	for i := range series {
+		series[i].Clicks = scaleSampled(counts[i+1], rate)
+	}
+	return counts[0], series, nil
+}
+
+// RankLink adds the link at key to the leaderboard with no clicks, so it's
+// listed when links are ordered by clicks before it's first clicked. A link
+// already ranked keeps its count.
+func RankLink(ctx context.Context, rdb Store, key string) error {
+	return rdb.ZIncrBy(ctx, leaderboardKey, 0, key).Err()
+}
+
+// UnrankLinks takes the links at keys off the leaderboard. Entries can't
+// expire like other analytics, so readers drop those of links that are gone.
+func UnrankLinks(ctx context.Context, rdb Store, keys ...string) error {
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	return rdb.ZRem(ctx, leaderboardKey, members...).Err()
+}
+
+// TopLinks returns up to n link keys with the most clicks and their counts.
+func TopLinks(ctx context.Context, rdb Store, n int) ([]redis.Z, error) {
+	return rdb.ZRevRangeWithScores(ctx, leaderboardKey, 0, int64(n)-1).Result()
+}
+
+// clickKeys lists the DB 1 keys holding clicks on the links at keys: their
+// totals, their unique visitors and their hourly buckets.
+func clickKeys(ctx context.Context, rdb Store, keys []string) ([]string, error) {
+	var out []string
+	for _, key := range keys {
+		out = append(out, ClicksKey(key), BotClicksKey(key), VisitorsKey(key))
+		// hourly buckets end in a YYYYMMDDHH suffix
+		err := ScanKeys(ctx, rdb, ClicksKey(key)+":??????????", func(k string) error {
+			out = append(out, k)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// DeleteClicks removes everything recorded in DB 1 about clicks on the links
+// at keys: their totals, their unique visitors, their hourly buckets and
+// their leaderboard entries, in one transaction.
+func DeleteClicks(ctx context.Context, rdb Store, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	stats, err := clickKeys(ctx, rdb, keys)
+	if err != nil {
+		return err
+	}
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	return rdb.Atomic(ctx, func(tx Store) error {
+		tx.Del(ctx, stats...)
+		tx.ZRem(ctx, leaderboardKey, members...)
+		return nil
+	})
+}
+
+// ExpireClicks makes the click totals and unique visitors of the links at
+// keys expire after ttl, along with the links, and takes them off the
+// leaderboard now. Hourly buckets already expire on their own.
+func ExpireClicks(ctx context.Context, rdb Store, ttl time.Duration, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	return rdb.Atomic(ctx, func(tx Store) error {
+		for _, key := range keys {
+			tx.Expire(ctx, ClicksKey(key), ttl)
+			tx.Expire(ctx, BotClicksKey(key), ttl)
+			tx.Expire(ctx, VisitorsKey(key), ttl)
+		}
+		tx.ZRem(ctx, leaderboardKey, members...)
+		return nil
+	})
+}
+
+func (cr *clickRecorder) run(interval time.Duration, batchSize int) {
+	defer cr.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	pending := map[click]int64{}
+	n := 0
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		// queued clicks outlive the requests that made them
+		if err := cr.write(context.Background(), pending); err != nil {
+			log.Printf("click tracking: flushing %d clicks: %v", n, err)
+		}
+		pending = map[click]int64{}
+		n = 0
+	}
+
+	for {
+		select {
+		case cl, ok := <-cr.queue:
+			if !ok {
+				flush()
+				return
+			}
+			pending[cl]++
+			n++
+			if n >= batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
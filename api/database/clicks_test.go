@@ -0,0 +1,351 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// useRecorder makes clicks count in a fresh memory store for the rest of t.
+func useRecorder(t *testing.T) *MemoryStore {
+	store, _ := newTestStore()
+	clicksOnce.Do(func() {})
+	prev := clicks
+	clicks = &clickRecorder{rdb: store}
+	t.Cleanup(func() { clicks = prev })
+	return store
+}
+
+func clickCount(t *testing.T, rdb Store, key string) int64 {
+	t.Helper()
+	n, _, err := LinkClicks(context.Background(), rdb, key, 1)
+	if err != nil {
+		t.Fatalf("LinkClicks(%s): %v", key, err)
+	}
+	return n
+}
+
+func TestRecordClickSync(t *testing.T) {
+	t.Setenv("CLICK_TRACKING", "sync")
+	store := useRecorder(t)
+	StartClickTracking()
+	defer StopClickTracking()
+
+	ctx := context.Background()
+	for i := int64(1); i <= 3; i++ {
+		if err := RecordClick(ctx, "link:abc", time.Hour); err != nil {
+			t.Fatalf("RecordClick: %v", err)
+		}
+		if got := clickCount(t, store, "link:abc"); got != i {
+			t.Errorf("after %d clicks, counted %d", i, got)
+		}
+	}
+	if got, _ := store.Get(ctx, "counter").Int64(); got != 3 {
+		t.Errorf("counter = %d, want 3", got)
+	}
+}
+
+func TestRecordClickAsync(t *testing.T) {
+	t.Setenv("CLICK_TRACKING", "async")
+	// only the final drain writes anything
+	t.Setenv("CLICK_FLUSH_INTERVAL", "1h")
+	t.Setenv("CLICK_BATCH_SIZE", "1000")
+	store := useRecorder(t)
+	StartClickTracking()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		if err := RecordClick(ctx, "link:abc", time.Hour); err != nil {
+			t.Fatalf("RecordClick: %v", err)
+		}
+	}
+	if err := RecordClick(ctx, "link:xyz", 0); err != nil {
+		t.Fatalf("RecordClick: %v", err)
+	}
+	if got := clickCount(t, store, "link:abc"); got != 0 {
+		t.Errorf("counted %d clicks before the flush, want them queued", got)
+	}
+
+	StopClickTracking()
+	if got := clickCount(t, store, "link:abc"); got != 5 {
+		t.Errorf("link:abc: counted %d clicks after the drain, want 5", got)
+	}
+	if got := clickCount(t, store, "link:xyz"); got != 1 {
+		t.Errorf("link:xyz: counted %d clicks after the drain, want 1", got)
+	}
+	if got, _ := store.Get(ctx, "counter").Int64(); got != 6 {
+		t.Errorf("counter = %d, want 6", got)
+	}
+
+	// once stopped, clicks are counted as they happen
+	if err := RecordClick(ctx, "link:abc", time.Hour); err != nil {
+		t.Fatalf("RecordClick after stopping: %v", err)
+	}
+	if got := clickCount(t, store, "link:abc"); got != 6 {
+		t.Errorf("after stopping: counted %d clicks, want 6", got)
+	}
+}
+
+func TestRecordClickAsyncBatches(t *testing.T) {
+	t.Setenv("CLICK_TRACKING", "async")
+	t.Setenv("CLICK_FLUSH_INTERVAL", "1h")
+	t.Setenv("CLICK_BATCH_SIZE", "2")
+	store := useRecorder(t)
+	StartClickTracking()
+	defer StopClickTracking()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_ = RecordClick(ctx, "link:abc", time.Hour)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for clickCount(t, store, "link:abc") != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("a full batch wasn't flushed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAnalyticsSampling(t *testing.T) {
+	t.Setenv("CLICK_TRACKING", "sync")
+	ctx := context.Background()
+	tests := []struct {
+		rate             string
+		hourly, visitors func(int64) bool
+	}{
+		{"0", func(n int64) bool { return n == 0 }, func(n int64) bool { return n == 0 }},
+		{"1", func(n int64) bool { return n == 200 }, func(n int64) bool { return n >= 190 && n <= 210 }},
+		// scaled back up to about the full counts
+		{"0.5", func(n int64) bool { return n >= 140 && n <= 260 }, func(n int64) bool { return n >= 150 && n <= 250 }},
+		{"", func(n int64) bool { return n == 200 }, func(n int64) bool { return n >= 190 && n <= 210 }},
+		{"2", func(n int64) bool { return n == 200 }, func(n int64) bool { return n >= 190 && n <= 210 }},
+	}
+	for _, tt := range tests {
+		t.Run("rate "+tt.rate, func(t *testing.T) {
+			t.Setenv("ANALYTICS_SAMPLE_RATE", tt.rate)
+			store := useRecorder(t)
+			StartClickTracking()
+			defer StopClickTracking()
+
+			for i := 0; i < 200; i++ {
+				if err := RecordClick(ctx, "link:sampled", time.Hour); err != nil {
+					t.Fatal(err)
+				}
+				if err := RecordVisitor(ctx, "link:sampled", fmt.Sprintf("visitor-%d", i), time.Hour); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			total, series, err := LinkClicks(ctx, store, "link:sampled", 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 200 {
+				t.Errorf("total = %d, want 200 whatever the rate", total)
+			}
+			if hourly := series[0].Clicks; !tt.hourly(hourly) {
+				t.Errorf("hourly clicks = %d", hourly)
+			}
+			if got, _ := store.Get(ctx, "counter").Int64(); got != 200 {
+				t.Errorf("counter = %d, want 200", got)
+			}
+			visitors, err := LinkVisitors(ctx, store, "link:sampled")
+			if err != nil || !tt.visitors(visitors) {
+				t.Errorf("visitors = %d, %v", visitors, err)
+			}
+		})
+	}
+}
+
+func TestVisitorSampled(t *testing.T) {
+	// a visitor is always in or always out of the sample
+	for i := 0; i < 100; i++ {
+		v := fmt.Sprintf("visitor-%d", i)
+		if first := visitorSampled(v, 0.3); visitorSampled(v, 0.3) != first {
+			t.Fatalf("%s sampled one time and not the next", v)
+		}
+		if visitorSampled(v, 0) {
+			t.Errorf("%s sampled at rate 0", v)
+		}
+		if !visitorSampled(v, 1) {
+			t.Errorf("%s left out at rate 1", v)
+		}
+	}
+}
+
+func TestDeleteClicks(t *testing.T) {
+	t.Setenv("CLICK_TRACKING", "sync")
+	ctx := context.Background()
+	store := useRecorder(t)
+	StartClickTracking()
+	defer StopClickTracking()
+	for _, key := range []string{"link:gone", "link:alias", "link:kept"} {
+		if err := RecordClick(ctx, key, 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := RecordVisitor(ctx, key, "visitor", 0); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.Incr(ctx, BotClicksKey(key)).Err(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := DeleteClicks(ctx, store, "link:gone", "link:alias"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"link:gone", "link:alias", "link:kept"} {
+		var left []string
+		if err := ScanKeys(ctx, store, "*"+key+"*", func(k string) error {
+			left = append(left, k)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		if key == "link:kept" {
+			// the total, the bot clicks, the visitors and the hourly bucket
+			if len(left) != 4 {
+				t.Errorf("%s: keys = %v, want all 4 kept", key, left)
+			}
+		} else if len(left) > 0 {
+			t.Errorf("%s: keys left = %v", key, left)
+		}
+	}
+	top, err := TopLinks(ctx, store, 10)
+	if err != nil || len(top) != 1 || top[0].Member != "link:kept" {
+		t.Errorf("TopLinks = %v, %v, want only link:kept", top, err)
+	}
+}
+
+func TestAnalyticsTTL(t *testing.T) {
+	tests := []struct {
+		retention string
+		linkTTL   time.Duration
+		want      time.Duration
+	}{
+		{"", 0, 0},
+		{"", 2 * time.Hour, 2 * time.Hour},
+		{"24h", 0, 0},
+		{"24h", 2 * time.Hour, 26 * time.Hour},
+		{"bogus", 2 * time.Hour, 2 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Setenv("ANALYTICS_RETENTION", tt.retention)
+		if got := AnalyticsTTL(tt.linkTTL); got != tt.want {
+			t.Errorf("ANALYTICS_RETENTION=%q: AnalyticsTTL(%v) = %v, want %v", tt.retention, tt.linkTTL, got, tt.want)
+		}
+	}
+}
+
+// analyticsTTLs returns the TTL of each kind of analytics key of the link at
+// key, or -1 for one kept for good.
+func analyticsTTLs(t *testing.T, rdb Store, key string) map[string]time.Duration {
+	t.Helper()
+	ctx := context.Background()
+	ttls := map[string]time.Duration{}
+	for kind, k := range map[string]string{
+		"clicks":   ClicksKey(key),
+		"bots":     BotClicksKey(key),
+		"visitors": VisitorsKey(key),
+		"hourly":   HourlyClicksKey(key, time.Now()),
+	} {
+		ttl, err := rdb.TTL(ctx, k).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ttls[kind] = ttl
+	}
+	return ttls
+}
+
+func TestAnalyticsExpireWithLink(t *testing.T) {
+	t.Setenv("CLICK_TRACKING", "sync")
+	t.Setenv("ANALYTICS_RETENTION", "1h")
+	t.Setenv("STATS_RETENTION", "24h")
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		linkTTL time.Duration
+		// want is every key's TTL but the hourly bucket's
+		want, hourly time.Duration
+	}{
+		{"expiring", 2 * time.Hour, 3 * time.Hour, 3 * time.Hour},
+		// buckets go at STATS_RETENTION if the link lasts longer
+		{"long-lived", 72 * time.Hour, 73 * time.Hour, 24 * time.Hour},
+		{"permanent", 0, -1, 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := useRecorder(t)
+			StartClickTracking()
+			defer StopClickTracking()
+			key := "link:" + tt.name
+			if err := RecordClick(ctx, key, tt.linkTTL); err != nil {
+				t.Fatal(err)
+			}
+			if err := RecordBotClick(ctx, key, tt.linkTTL); err != nil {
+				t.Fatal(err)
+			}
+			if err := RecordVisitor(ctx, key, "visitor", tt.linkTTL); err != nil {
+				t.Fatal(err)
+			}
+
+			for kind, ttl := range analyticsTTLs(t, store, key) {
+				want := tt.want
+				if kind == "hourly" {
+					want = tt.hourly
+				}
+				// clicks are rounded up to the minute
+				if (want < 0 && ttl != want) || (want > 0 && (ttl < want-time.Second || ttl > want+time.Minute)) {
+					t.Errorf("%s TTL = %v, want %v", kind, ttl, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAlignClicks(t *testing.T) {
+	t.Setenv("CLICK_TRACKING", "sync")
+	t.Setenv("STATS_RETENTION", "24h")
+	ctx := context.Background()
+	store := useRecorder(t)
+	StartClickTracking()
+	defer StopClickTracking()
+	for _, key := range []string{"link:moved", "link:alias"} {
+		RecordClick(ctx, key, 0)
+		RecordBotClick(ctx, key, 0)
+		RecordVisitor(ctx, key, "visitor", 0)
+	}
+
+	// the link now expires in 5h
+	if err := AlignClicks(ctx, store, 5*time.Hour, "link:moved", "link:alias"); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"link:moved", "link:alias"} {
+		for kind, ttl := range analyticsTTLs(t, store, key) {
+			if ttl != 5*time.Hour {
+				t.Errorf("%s %s TTL = %v, want 5h", key, kind, ttl)
+			}
+		}
+	}
+
+	// and then never
+	if err := AlignClicks(ctx, store, 0, "link:moved"); err != nil {
+		t.Fatal(err)
+	}
+	for kind, ttl := range analyticsTTLs(t, store, "link:moved") {
+		want := time.Duration(-1)
+		if kind == "hourly" {
+			// what's left of the bucket's STATS_RETENTION
+			if ttl <= 23*time.Hour || ttl > 24*time.Hour {
+				t.Errorf("hourly TTL = %v, want under 24h", ttl)
+			}
+			continue
+		}
+		if ttl != want {
+			t.Errorf("%s TTL = %v, want it kept for good", kind, ttl)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Collection is a named group of links, such as a campaign's, whose stats can
+// be fetched together. It lives in DB 0 as a hash, with its members in a set.
+type Collection struct {
+	// ID is the collection's key suffix. It isn't stored itself.
+	ID        string
+	Name      string
+	CreatedAt time.Time
+	// EditTokenHash is the SHA-256 of the token required to add links.
+	EditTokenHash string
+	// Owner is the ID of the API key that created the collection, if any.
+	Owner string
+}
+
+// CollectionKey is where the collection with the given ID is stored.
+func CollectionKey(id string) string {
+	return "collection:" + id
+}
+
+// CollectionLinksKey is the set of link keys in the collection with the
+// given ID.
+func CollectionLinksKey(id string) string {
+	return "collection:" + id + ":links"
+}
+
+// SaveCollection stores col. Collections don't expire; their links do, and
+// are dropped from the collection as they're found gone.
+func SaveCollection(ctx context.Context, rdb Store, col *Collection) error {
+	return rdb.HSet(ctx, CollectionKey(col.ID), map[string]interface{}{
+		"name":            col.Name,
+		"created_at":      strconv.FormatInt(col.CreatedAt.Unix(), 10),
+		"edit_token_hash": col.EditTokenHash,
+		"owner":           col.Owner,
+	}).Err()
+}
+
+// GetCollection loads the collection with the given ID. It returns redis.Nil
+// for an unknown collection.
+func GetCollection(ctx context.Context, rdb Store, id string) (*Collection, error) {
+	f, err := rdb.HGetAll(ctx, CollectionKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+	if len(f) == 0 {
+		return nil, redis.Nil
+	}
+
+	col := &Collection{
+		ID:            id,
+		Name:          f["name"],
+		EditTokenHash: f["edit_token_hash"],
+		Owner:         f["owner"],
+	}
+	if v, err := strconv.ParseInt(f["created_at"], 10, 64); err == nil {
+		col.CreatedAt = time.Unix(v, 0)
+	}
+	return col, nil
+}
+
+// AddToCollection adds the links at keys to the collection with the given ID.
+func AddToCollection(ctx context.Context, rdb Store, id string, keys ...string) error {
+	members := make([]interface{}, len(keys))
+	for i, key := range keys {
+		members[i] = key
+	}
+	return rdb.SAdd(ctx, CollectionLinksKey(id), members...).Err()
+}
+
+// CollectionLinks returns the keys of the links in the collection with the
+// given ID, including any that have since expired.
+func CollectionLinks(ctx context.Context, rdb Store, id string) ([]string, error) {
+	return rdb.SMembers(ctx, CollectionLinksKey(id)).Result()
+}
+
+// RemoveFromCollection drops the link at key from the collection with the
+// given ID.
+func RemoveFromCollection(ctx context.Context, rdb Store, id, key string) error {
+	return rdb.SRem(ctx, CollectionLinksKey(id), key).Err()
+}
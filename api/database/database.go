@@ -3,19 +3,184 @@ package database
 import (
 	"context"
 	"os"
+	"runtime"
+	"sync"
+	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/tracing"
 )
 
-var Ctx = context.Background()
+// Config holds the Redis connection settings.
+type Config struct {
+	// Addr is the Redis server address (DB_ADD).
+	Addr string
+	// Password is the Redis password (DB_PASS).
+	Password string
+	// PoolSize is the maximum number of connections per client
+	// (REDIS_POOL_SIZE). Defaults to 10 per CPU, go-redis' own default.
+	PoolSize int
+	// MinIdleConns keeps this many idle connections open so bursts don't pay
+	// for dialing (REDIS_MIN_IDLE_CONNS). Defaults to 0.
+	MinIdleConns int
+	// DialTimeout bounds establishing a connection (REDIS_DIAL_TIMEOUT).
+	// Defaults to 5s.
+	DialTimeout time.Duration
+	// ReadTimeout bounds each command's reply (REDIS_READ_TIMEOUT). Defaults
+	// to 3s.
+	ReadTimeout time.Duration
+}
 
+// LoadConfig reads the Redis settings from the environment.
+func LoadConfig() Config {
+	return Config{
+		Addr:         os.Getenv("DB_ADD"),
+		Password:     os.Getenv("DB_PASS"),
+		PoolSize:     config.Int("REDIS_POOL_SIZE", 10*runtime.GOMAXPROCS(0)),
+		MinIdleConns: config.Int("REDIS_MIN_IDLE_CONNS", 0),
+		DialTimeout:  config.Duration("REDIS_DIAL_TIMEOUT", 5*time.Second),
+		ReadTimeout:  config.Duration("REDIS_READ_TIMEOUT", 3*time.Second),
+	}
+}
 
-func CreateClient(dbNo int) *redis.Client {
-	rdb := redis.NewClient(&redis.Options{
-		Addr: os.Getenv("DB_ADD"),
-		Password: os.Getenv("DB_PASS"),
-		DB: dbNo,
-	})
+// Options builds the client options for database dbNo.
+func (cfg Config) Options(dbNo int) *redis.Options {
+	return &redis.Options{
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           dbNo,
+		PoolSize:     cfg.PoolSize,
+		MinIdleConns: cfg.MinIdleConns,
+		DialTimeout:  cfg.DialTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
+	}
+}
 
+var (
+	clientsMu sync.Mutex
+	clients   = map[int]*redis.Client{}
+)
+
+// client returns the long-lived Redis client for database dbNo, connecting it
+// on first use. Every store for dbNo shares its pool, so REDIS_POOL_SIZE and
+// REDIS_MIN_IDLE_CONNS apply to the process rather than to each request.
+func client(dbNo int) *redis.Client {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	rdb, ok := clients[dbNo]
+	if !ok {
+		rdb = redis.NewClient(LoadConfig().Options(dbNo))
+		clients[dbNo] = rdb
+	}
 	return rdb
-}
\ No newline at end of file
+}
+
+// Connect creates the clients for the links and stats databases, so the
+// first requests don't wait on them. Call it at startup.
+func Connect() {
+	if config.String("STORAGE_BACKEND", "redis") == "memory" {
+		return
+	}
+	client(0)
+	client(1)
+}
+
+// CloseClients closes the Redis clients. Call it once nothing uses the stores
+// anymore.
+func CloseClients() error {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+
+	var first error
+	for dbNo, rdb := range clients {
+		if err := rdb.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(clients, dbNo)
+	}
+	return first
+}
+
+// CreateClient returns the store for database dbNo: DB 0 holds links, DB 1
+// holds counters and rate limits. STORAGE_BACKEND=memory keeps everything in
+// process memory instead of Redis, for local development. Stores share one
+// client per database and need no closing.
+func CreateClient(dbNo int) Store {
+	return CreateClientContext(context.Background(), dbNo)
+}
+
+// CreateClientContext is CreateClient for work done on behalf of ctx, usually
+// a request: with tracing on, the store's Redis commands are recorded as
+// spans under ctx's span even when they're issued with a context that carries
+// none.
+func CreateClientContext(ctx context.Context, dbNo int) Store {
+	if config.String("STORAGE_BACKEND", "redis") == "memory" {
+		return memoryStore(dbNo)
+	}
+
+	rdb := client(dbNo)
+	if tracing.Enabled() {
+		// the copy shares the client's pool, but not its hooks
+		rdb = rdb.WithContext(ctx)
+		rdb.AddHook(tracingHook{parent: ctx, db: dbNo})
+	}
+
+	return redisStore{rdb}
+}
+
+// ReverseKey is the set of link keys in tenant pointing at a destination URL.
+func ReverseKey(tenant, url string) string {
+	if tenant == "" {
+		return "reverse:" + url
+	}
+	return "tenant:" + tenant + ":reverse:" + url
+}
+
+// TagKey is the set of link codes in tenant carrying tag.
+func TagKey(tenant, tag string) string {
+	if tenant == "" {
+		return "tag:" + tag
+	}
+	return "tenant:" + tenant + ":tag:" + tag
+}
+
+// IndexDestination records id in tenant's reverse index for url, keeping the
+// index alive for at least as long as the link itself. A ttl <= 0 keeps the
+// index forever.
+func IndexDestination(ctx context.Context, rdb Store, tenant, url, id string, ttl time.Duration) error {
+	return indexMember(ctx, rdb, ReverseKey(tenant, url), id, ttl)
+}
+
+// IndexTags adds id to the tag index of each of tags, with the same lifetime
+// rules as IndexDestination.
+func IndexTags(ctx context.Context, rdb Store, tenant string, tags []string, id string, ttl time.Duration) error {
+	for _, tag := range tags {
+		if err := indexMember(ctx, rdb, TagKey(tenant, tag), id, ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indexMember adds id to the set at key, extending the set's TTL so it
+// outlives a member that expires after ttl.
+func indexMember(ctx context.Context, rdb Store, key, id string, ttl time.Duration) error {
+	current, err := rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if err := rdb.SAdd(ctx, key, id).Err(); err != nil {
+		return err
+	}
+
+	switch {
+	case ttl <= 0:
+		return rdb.Persist(ctx, key).Err()
+	// -2 is a brand new index; -1 one that already never expires
+	case current == -2 || (current > 0 && current < ttl):
+		return rdb.Expire(ctx, key, ttl).Err()
+	}
+	return nil
+}
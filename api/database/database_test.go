@@ -0,0 +1,115 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestConfigOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want Config
+	}{
+		{"defaults", nil, Config{
+			Addr:        "localhost:6379",
+			PoolSize:    10 * runtime.GOMAXPROCS(0),
+			DialTimeout: 5 * time.Second,
+			ReadTimeout: 3 * time.Second,
+		}},
+		{"tuned", map[string]string{
+			"DB_PASS":              "secret",
+			"REDIS_POOL_SIZE":      "50",
+			"REDIS_MIN_IDLE_CONNS": "5",
+			"REDIS_DIAL_TIMEOUT":   "250ms",
+			"REDIS_READ_TIMEOUT":   "1s",
+		}, Config{
+			Addr:         "localhost:6379",
+			Password:     "secret",
+			PoolSize:     50,
+			MinIdleConns: 5,
+			DialTimeout:  250 * time.Millisecond,
+			ReadTimeout:  time.Second,
+		}},
+		{"malformed", map[string]string{
+			"REDIS_POOL_SIZE":    "lots",
+			"REDIS_DIAL_TIMEOUT": "5",
+		}, Config{
+			Addr:        "localhost:6379",
+			PoolSize:    10 * runtime.GOMAXPROCS(0),
+			DialTimeout: 5 * time.Second,
+			ReadTimeout: 3 * time.Second,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"DB_PASS", "REDIS_POOL_SIZE", "REDIS_MIN_IDLE_CONNS", "REDIS_DIAL_TIMEOUT", "REDIS_READ_TIMEOUT"} {
+				t.Setenv(name, tt.env[name])
+			}
+			t.Setenv("DB_ADD", "localhost:6379")
+
+			cfg := LoadConfig()
+			if cfg != tt.want {
+				t.Errorf("LoadConfig() = %+v, want %+v", cfg, tt.want)
+			}
+			opt := cfg.Options(1)
+			if opt.Addr != tt.want.Addr || opt.Password != tt.want.Password || opt.DB != 1 ||
+				opt.PoolSize != tt.want.PoolSize || opt.MinIdleConns != tt.want.MinIdleConns ||
+				opt.DialTimeout != tt.want.DialTimeout || opt.ReadTimeout != tt.want.ReadTimeout {
+				t.Errorf("Options(1) = %+v, want %+v in DB 1", opt, tt.want)
+			}
+
+			// clients are built from the same options, without dialing
+			rdb := client(1)
+			defer CloseClients()
+			if got := rdb.Options(); got.PoolSize != tt.want.PoolSize || got.MinIdleConns != tt.want.MinIdleConns ||
+				got.DialTimeout != tt.want.DialTimeout || got.ReadTimeout != tt.want.ReadTimeout {
+				t.Errorf("client options = %+v, want %+v", got, tt.want)
+			}
+			if client(1) != rdb {
+				t.Error("client(1) made a second client")
+			}
+		})
+	}
+}
+
+func TestRequestContextCancels(t *testing.T) {
+	// a server that accepts connections and never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	cfg := Config{Addr: ln.Addr().String(), PoolSize: 1, DialTimeout: time.Second, ReadTimeout: 10 * time.Second}
+	rdb := redis.NewClient(cfg.Options(0))
+	defer rdb.Close()
+	store := redisStore{rdb}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err = GetLink(ctx, store, LinkKey("", "hung"))
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("GetLink error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("GetLink returned after %v, want it aborted with the context", elapsed)
+	}
+}
@@ -0,0 +1,68 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// LinkEvent is something significant that happened to a link, such as its
+// creation, an update or its expiry.
+type LinkEvent struct {
+	Type string
+	At   time.Time
+	// Detail says more about the event, e.g. why the link expired; empty
+	// when there's nothing to add.
+	Detail string
+}
+
+// storedEvent is how a LinkEvent is kept in its list.
+type storedEvent struct {
+	Type   string `json:"type"`
+	At     int64  `json:"at"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// EventsKey is the DB 0 list of events of the link at key, newest first. It
+// expires and is deleted with the link.
+func EventsKey(key string) string {
+	return "events:" + key
+}
+
+// RecordEvent adds ev to the events of the link at key and drops all but the
+// newest limit of them. The list takes on the link's TTL.
+func RecordEvent(ctx context.Context, rdb Store, key string, ev LinkEvent, limit int) error {
+	b, err := json.Marshal(storedEvent{Type: ev.Type, At: ev.At.Unix(), Detail: ev.Detail})
+	if err != nil {
+		return err
+	}
+	ttl, err := rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	return rdb.Atomic(ctx, func(tx Store) error {
+		tx.LPush(ctx, EventsKey(key), b)
+		tx.LTrim(ctx, EventsKey(key), 0, int64(limit)-1)
+		if ttl > 0 {
+			tx.Expire(ctx, EventsKey(key), ttl)
+		}
+		return nil
+	})
+}
+
+// LinkEvents returns the events recorded for the link at key, newest first.
+func LinkEvents(ctx context.Context, rdb Store, key string) ([]LinkEvent, error) {
+	items, err := rdb.LRange(ctx, EventsKey(key), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	events := make([]LinkEvent, 0, len(items))
+	for _, item := range items {
+		var ev storedEvent
+		if json.Unmarshal([]byte(item), &ev) != nil {
+			continue
+		}
+		events = append(events, LinkEvent{Type: ev.Type, At: time.Unix(ev.At, 0), Detail: ev.Detail})
+	}
+	return events, nil
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRecordEvent(t *testing.T) {
+	ctx := context.Background()
+	r, clock := newTestStore()
+	key := LinkKey("", "eventful")
+	if err := SaveLink(ctx, r, key, &Link{URL: "https://example.com/"}, 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, typ := range []string{"created", "updated", "updated", "expired"} {
+		ev := LinkEvent{Type: typ, At: clock.Now(), Detail: fmt.Sprint(i)}
+		if err := RecordEvent(ctx, r, key, ev, 3); err != nil {
+			t.Fatal(err)
+		}
+		clock.Advance(time.Minute)
+	}
+
+	events, err := LinkEvents(ctx, r, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the newest 3, newest first
+	var got []string
+	for _, ev := range events {
+		got = append(got, ev.Type+"/"+ev.Detail)
+	}
+	if fmt.Sprint(got) != "[expired/3 updated/2 updated/1]" {
+		t.Errorf("events = %v, want the newest 3", got)
+	}
+	if want := time.Date(2024, 1, 1, 0, 3, 0, 0, time.UTC); !events[0].At.Equal(want) {
+		t.Errorf("newest event at %v, want %v", events[0].At, want)
+	}
+	// the history goes when the link does
+	if ttl := r.TTL(ctx, EventsKey(key)).Val(); ttl <= time.Hour || ttl > 2*time.Hour {
+		t.Errorf("events TTL = %v, want the link's", ttl)
+	}
+
+	if events, err := LinkEvents(ctx, r, LinkKey("", "quiet")); err != nil || len(events) != 0 {
+		t.Errorf("LinkEvents of a link without any = %v, %v", events, err)
+	}
+}
@@ -0,0 +1,28 @@
+package database
+
+import (
+	"context"
+	"time"
+)
+
+// UnhealthyKey marks a destination URL the health checker found failing. It
+// lives in DB 1 and expires on its own, so a stopped checker can't leave a
+// destination marked down forever.
+func UnhealthyKey(url string) string {
+	return "unhealthy:" + url
+}
+
+// SetDestinationHealth records the outcome of checking url. An unhealthy mark
+// lasts for ttl unless a later check clears it.
+func SetDestinationHealth(ctx context.Context, rdb Store, url string, healthy bool, ttl time.Duration) error {
+	if healthy {
+		return rdb.Del(ctx, UnhealthyKey(url)).Err()
+	}
+	return rdb.Set(ctx, UnhealthyKey(url), time.Now().Unix(), ttl).Err()
+}
+
+// DestinationUnhealthy reports whether url is currently marked as failing.
+func DestinationUnhealthy(ctx context.Context, rdb Store, url string) (bool, error) {
+	n, err := rdb.Exists(ctx, UnhealthyKey(url)).Result()
+	return n > 0, err
+}
@@ -0,0 +1,618 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// Link is a stored short link. It lives in DB 0 as a hash keyed by its code.
+type Link struct {
+	URL       string
+	Permanent bool
+	CreatedAt time.Time
+	// CacheTTLSeconds overrides the redirect's Cache-Control max-age when set.
+	CacheTTLSeconds *int
+	// ResolveLimitPerMinute caps redirects per minute for this link; 0 is unlimited.
+	ResolveLimitPerMinute int
+	// Display is the code as its creator cased it, when it's stored
+	// lowercased under CASE_INSENSITIVE_SHORTS. Empty means the stored code.
+	Display string
+	// MaxClicks expires the link once it has redirected this many times, or
+	// at its TTL if that comes first; 0 is unlimited.
+	MaxClicks int
+	// HideDestination stops the destination from being revealed without a click.
+	HideDestination bool
+	// Note is the owner's free-form description. It never affects redirects.
+	Note string
+	// EditTokenHash is the SHA-256 of the token required to modify the link.
+	EditTokenHash string
+	// Owner is the ID of the API key that owns the link, if any. Requests
+	// made with that key may manage the link without the edit token.
+	Owner string
+	// AllowedReferrers restricts redirects to visitors referred by these hosts
+	// (or their subdomains). Empty means unrestricted.
+	AllowedReferrers []string
+	// Tags group links, e.g. by campaign, for bulk operations.
+	Tags []string
+	// Locales maps language tags to locale-specific destinations, picked by
+	// the visitor's Accept-Language. The "default" entry, or URL when there is
+	// none, serves everyone else.
+	Locales map[string]string
+	// Headers are extra response headers set on the link's redirects, e.g. a
+	// campaign ID for the destination's analytics.
+	Headers map[string]string
+	// FallbackURL is used instead of the destination while the health checker
+	// has it marked as failing.
+	FallbackURL string
+	// NoReferrer redirects through a page that tells the browser not to send
+	// a Referer, so the destination doesn't learn the short domain.
+	NoReferrer bool
+	// AppendPath sends visitors of code/extra/segments to the destination with
+	// /extra/segments appended.
+	AppendPath bool
+	// Disabled links stop redirecting, e.g. after too many abuse reports,
+	// until an admin re-enables them.
+	Disabled bool
+	// Expired links were expired early by their owner: they answer 410 like
+	// a link whose TTL ran out, but are kept, with their stats, for a while.
+	Expired bool
+	// CreatorIP and CreatorUserAgent record who created the link, for abuse
+	// investigations, when STORE_CREATOR_INFO is on. CreatorIP is hashed like
+	// analytics visitor IDs.
+	CreatorIP        string
+	CreatorUserAgent string
+
+	// PrimaryKey is set when the link was loaded through an alias: it's the
+	// key the link is actually stored under. It isn't stored.
+	PrimaryKey string
+}
+
+func (l *Link) fields() map[string]interface{} {
+	f := map[string]interface{}{
+		"url":       l.URL,
+		"permanent": strconv.FormatBool(l.Permanent),
+	}
+	if !l.CreatedAt.IsZero() {
+		f["created_at"] = strconv.FormatInt(l.CreatedAt.Unix(), 10)
+	}
+	if l.CacheTTLSeconds != nil {
+		f["cache_ttl_seconds"] = strconv.Itoa(*l.CacheTTLSeconds)
+	}
+	if l.HideDestination {
+		f["hide_destination"] = "true"
+	}
+	if l.ResolveLimitPerMinute > 0 {
+		f["resolve_limit_per_minute"] = strconv.Itoa(l.ResolveLimitPerMinute)
+	}
+	if l.MaxClicks > 0 {
+		f["max_clicks"] = strconv.Itoa(l.MaxClicks)
+	}
+	if l.Display != "" {
+		f["display"] = l.Display
+	}
+	if l.Note != "" {
+		f["note"] = l.Note
+	}
+	if l.EditTokenHash != "" {
+		f["edit_token_hash"] = l.EditTokenHash
+	}
+	if l.Owner != "" {
+		f["owner"] = l.Owner
+	}
+	if len(l.AllowedReferrers) > 0 {
+		f["allowed_referrers"] = strings.Join(l.AllowedReferrers, ",")
+	}
+	if len(l.Tags) > 0 {
+		f["tags"] = strings.Join(l.Tags, ",")
+	}
+	if len(l.Locales) > 0 {
+		b, _ := json.Marshal(l.Locales)
+		f["locales"] = string(b)
+	}
+	if len(l.Headers) > 0 {
+		b, _ := json.Marshal(l.Headers)
+		f["headers"] = string(b)
+	}
+	if l.FallbackURL != "" {
+		f["fallback_url"] = l.FallbackURL
+	}
+	if l.NoReferrer {
+		f["no_referrer"] = "true"
+	}
+	if l.AppendPath {
+		f["append_path"] = "true"
+	}
+	if l.Disabled {
+		f["disabled"] = "true"
+	}
+	if l.Expired {
+		f["expired"] = "true"
+	}
+	if l.CreatorIP != "" {
+		f["creator_ip"] = l.CreatorIP
+	}
+	if l.CreatorUserAgent != "" {
+		f["creator_user_agent"] = l.CreatorUserAgent
+	}
+	return f
+}
+
+func linkFromFields(f map[string]string) *Link {
+	l := &Link{URL: f["url"]}
+	l.Permanent, _ = strconv.ParseBool(f["permanent"])
+	if v, err := strconv.ParseInt(f["created_at"], 10, 64); err == nil {
+		l.CreatedAt = time.Unix(v, 0)
+	}
+	if v, err := strconv.Atoi(f["cache_ttl_seconds"]); err == nil {
+		l.CacheTTLSeconds = &v
+	}
+	l.ResolveLimitPerMinute, _ = strconv.Atoi(f["resolve_limit_per_minute"])
+	l.MaxClicks, _ = strconv.Atoi(f["max_clicks"])
+	l.Display = f["display"]
+	l.HideDestination, _ = strconv.ParseBool(f["hide_destination"])
+	l.Note = f["note"]
+	l.EditTokenHash = f["edit_token_hash"]
+	l.Owner = f["owner"]
+	if v := f["allowed_referrers"]; v != "" {
+		l.AllowedReferrers = strings.Split(v, ",")
+	}
+	if v := f["tags"]; v != "" {
+		l.Tags = strings.Split(v, ",")
+	}
+	if v := f["locales"]; v != "" {
+		_ = json.Unmarshal([]byte(v), &l.Locales)
+	}
+	if v := f["headers"]; v != "" {
+		_ = json.Unmarshal([]byte(v), &l.Headers)
+	}
+	l.FallbackURL = f["fallback_url"]
+	l.NoReferrer, _ = strconv.ParseBool(f["no_referrer"])
+	l.AppendPath, _ = strconv.ParseBool(f["append_path"])
+	l.Disabled, _ = strconv.ParseBool(f["disabled"])
+	l.Expired, _ = strconv.ParseBool(f["expired"])
+	l.CreatorIP = f["creator_ip"]
+	l.CreatorUserAgent = f["creator_user_agent"]
+	return l
+}
+
+// createdKey is the DB 0 sorted set of link keys scored by creation time.
+const createdKey = "links:created"
+
+// TombstoneKey outlives the expiring link at key by TOMBSTONE_TTL, so a
+// recently expired link can be told apart from one that never existed.
+func TombstoneKey(key string) string {
+	return "gone:" + key
+}
+
+// claimKey is held by the request creating a link or alias at key.
+func claimKey(key string) string {
+	return "claim:" + key
+}
+
+// claimTTL bounds how long a claim outlives a request that died holding it.
+const claimTTL = 30 * time.Second
+
+// ClaimLink takes the claim on creating a link or alias at key with SET NX,
+// making the usual exists check and save atomic: of two requests for the same
+// code, only one gets the claim. It reports false when another request holds
+// it. The holder releases it with ReleaseLink once the code is saved or
+// given up on.
+func ClaimLink(ctx context.Context, rdb Store, key string) (bool, error) {
+	return rdb.SetNX(ctx, claimKey(key), 1, claimTTL).Result()
+}
+
+// ReleaseLink gives up the claim on key taken with ClaimLink.
+func ReleaseLink(ctx context.Context, rdb Store, key string) {
+	rdb.Del(ctx, claimKey(key))
+}
+
+// LinkSize is how many bytes link takes up stored, field names included.
+func LinkSize(l *Link) int {
+	n := 0
+	for k, v := range l.fields() {
+		if s, ok := v.(string); ok {
+			n += len(k) + len(s)
+		}
+	}
+	return n
+}
+
+// SaveLink stores link under id, expiring after ttl. A ttl <= 0 means the
+// link never expires.
+func SaveLink(ctx context.Context, rdb Store, id string, link *Link, ttl time.Duration) error {
+	fields := link.fields()
+	err := rdb.Atomic(ctx, func(tx Store) error {
+		// a new link under a deleted one's code starts with no uses or events
+		tx.Del(ctx, UsesKey(id), EventsKey(id))
+		tx.HSet(ctx, id, fields)
+		if ttl > 0 {
+			tx.Expire(ctx, id, ttl)
+			tx.Set(ctx, TombstoneKey(id), 1, ttl+config.Duration("TOMBSTONE_TTL", 7*24*time.Hour))
+		} else {
+			tx.Persist(ctx, id)
+			tx.Del(ctx, TombstoneKey(id))
+		}
+		if !link.CreatedAt.IsZero() {
+			tx.ZAdd(ctx, createdKey, &redis.Z{Score: float64(link.CreatedAt.Unix()), Member: id})
+		}
+		return nil
+	})
+	if err == nil {
+		mirrorLink(mirrorWrite{key: id, fields: fields, expiresAt: expiresAt(ttl)})
+	}
+	return err
+}
+
+// UpdateLink replaces the link stored under key, keeping its remaining TTL.
+func UpdateLink(ctx context.Context, rdb Store, key string, link *Link) error {
+	ttl, err := rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if ttl == -2 {
+		return redis.Nil
+	}
+	fields := link.fields()
+	err = rdb.Atomic(ctx, func(tx Store) error {
+		tx.Del(ctx, key)
+		tx.HSet(ctx, key, fields)
+		if ttl > 0 {
+			tx.Expire(ctx, key, ttl)
+		}
+		return nil
+	})
+	if err == nil {
+		mirrorLink(mirrorWrite{key: key, fields: fields, expiresAt: expiresAt(ttl)})
+	}
+	return err
+}
+
+// ExtendLink pushes the expiry of the link at key, and of its aliases, back by
+// grace, but never to more than limit from now. It returns the link's new TTL;
+// links that never expire are left alone and report a TTL of 0.
+func ExtendLink(ctx context.Context, rdb Store, key string, grace, limit time.Duration) (time.Duration, error) {
+	ttl, err := rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl == -2 {
+		return 0, redis.Nil
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	extended := ttl + grace
+	if extended > limit {
+		extended = limit
+	}
+	if extended <= ttl {
+		return ttl, nil
+	}
+
+	if err := ExpireLinks(ctx, rdb, []string{key}, extended); err != nil {
+		return 0, err
+	}
+	return extended, nil
+}
+
+// ExpireLinks sets the links at keys, and their aliases, to expire after ttl,
+// all in one transaction.
+func ExpireLinks(ctx context.Context, rdb Store, keys []string, ttl time.Duration) error {
+	aliases := make(map[string][]string, len(keys))
+	for _, key := range keys {
+		a, err := LinkAliases(ctx, rdb, key)
+		if err != nil {
+			return err
+		}
+		aliases[key] = a
+	}
+	err := rdb.Atomic(ctx, func(tx Store) error {
+		for _, key := range keys {
+			tx.Expire(ctx, key, ttl)
+			tx.Expire(ctx, UsesKey(key), ttl)
+			tx.Expire(ctx, EventsKey(key), ttl)
+			tx.Set(ctx, TombstoneKey(key), 1, ttl+config.Duration("TOMBSTONE_TTL", 7*24*time.Hour))
+			for _, alias := range aliases[key] {
+				tx.Expire(ctx, alias, ttl)
+			}
+			if len(aliases[key]) > 0 {
+				tx.Expire(ctx, AliasesKey(key), ttl)
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		for _, key := range keys {
+			mirrorLink(mirrorWrite{key: key, expiresAt: expiresAt(ttl)})
+		}
+	}
+	return err
+}
+
+// DeleteLink removes link, stored under key in tenant, its aliases and its
+// entries in the creation, destination and tag indexes. Deleting a link that
+// is already gone is not an error. When key is an alias, only the alias is
+// removed.
+func DeleteLink(ctx context.Context, rdb Store, tenant, key string, link *Link) error {
+	if link.PrimaryKey != "" {
+		return DeleteAlias(ctx, rdb, key, link.PrimaryKey)
+	}
+	id := LinkCode(key)
+	aliases, err := LinkAliases(ctx, rdb, key)
+	if err != nil {
+		return err
+	}
+	err = rdb.Atomic(ctx, func(tx Store) error {
+		// deleted isn't expired, so don't leave a tombstone
+		tx.Del(ctx, append(aliases, key, TombstoneKey(key), AliasesKey(key), UsesKey(key), EventsKey(key))...)
+		tx.ZRem(ctx, createdKey, key)
+		tx.SRem(ctx, ReverseKey(tenant, link.URL), id)
+		for _, tag := range link.Tags {
+			tx.SRem(ctx, TagKey(tenant, tag), id)
+		}
+		if link.Owner != "" {
+			tx.SRem(ctx, OwnerLinksKey(link.Owner), key)
+		}
+		return nil
+	})
+	if err == nil {
+		unmirrorLink(ctx, key)
+	}
+	return err
+}
+
+// UsesKey is the DB 0 counter of redirects served by the link at key, for
+// links with MaxClicks. It expires and is deleted with the link.
+func UsesKey(key string) string {
+	return "uses:" + key
+}
+
+// UseLink counts a redirect of the link at key against its MaxClicks and
+// returns how many redirects it has served, this one included. Concurrent
+// visitors each get a different count from the one INCR, so exactly
+// MaxClicks of them get through however close they arrive.
+func UseLink(ctx context.Context, rdb Store, key string) (int64, error) {
+	n, err := rdb.Incr(ctx, UsesKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if n == 1 {
+		if ttl, err := rdb.TTL(ctx, key).Result(); err == nil && ttl > 0 {
+			rdb.Expire(ctx, UsesKey(key), ttl)
+		}
+	}
+	return n, nil
+}
+
+// OwnerLinksKey is the DB 0 set of link keys owned by the API key with the
+// given ID.
+func OwnerLinksKey(owner string) string {
+	return "owner:" + owner + ":links"
+}
+
+// IndexOwnerLink records that the link at key, expiring after ttl, is owned
+// by the API key with the given ID.
+func IndexOwnerLink(ctx context.Context, rdb Store, owner, key string, ttl time.Duration) error {
+	return indexMember(ctx, rdb, OwnerLinksKey(owner), key, ttl)
+}
+
+// IPLinksKey is the DB 0 set of link keys created from ip.
+func IPLinksKey(ip string) string {
+	return "ip:" + ip + ":links"
+}
+
+// IndexIPLink records that the link at key, expiring after ttl, was created
+// from ip.
+func IndexIPLink(ctx context.Context, rdb Store, ip, key string, ttl time.Duration) error {
+	return indexMember(ctx, rdb, IPLinksKey(ip), key, ttl)
+}
+
+// ActiveIPLinks returns how many links created from ip still exist. Links that
+// have expired or been deleted are pruned from the set as they're found, and
+// so are links their owner expired, which are only kept to be looked at.
+func ActiveIPLinks(ctx context.Context, rdb Store, ip string) (int, error) {
+	keys, err := rdb.SMembers(ctx, IPLinksKey(ip)).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	active := 0
+	for _, key := range keys {
+		link, err := GetLink(ctx, rdb, key)
+		if err != nil && err != redis.Nil {
+			return 0, err
+		}
+		if err == redis.Nil || link.Expired {
+			rdb.SRem(ctx, IPLinksKey(ip), key)
+			continue
+		}
+		active++
+	}
+	return active, nil
+}
+
+// Expired reports whether a link missing from key existed and expired within
+// the last TOMBSTONE_TTL.
+func Expired(ctx context.Context, rdb Store, key string) (bool, error) {
+	n, err := rdb.Exists(ctx, TombstoneKey(key)).Result()
+	return n > 0, err
+}
+
+// StoredLink is a link together with its key.
+type StoredLink struct {
+	Key string
+	*Link
+}
+
+// RecentLinks returns up to n of the most recently created links that still
+// exist, newest first. Expired links are pruned from the index as they're
+// found.
+func RecentLinks(ctx context.Context, rdb Store, n int) ([]StoredLink, error) {
+	links, _, err := LinksPage(ctx, rdb, 0, n)
+	return links, err
+}
+
+// LinksPage returns up to n links that still exist, newest first, starting
+// offset links into the creation index, and the offset the next page starts
+// at, or -1 when this is the last page. Expired links are pruned from the
+// index as they're found, which the returned offset accounts for.
+func LinksPage(ctx context.Context, rdb Store, offset, n int) ([]StoredLink, int, error) {
+	return SortedLinksPage(ctx, rdb, nil, LinkOrder{}, offset, n)
+}
+
+// LinkOrder is an order links can be listed in. The zero value is newest
+// first.
+type LinkOrder struct {
+	// ByClicks orders by clicks instead of creation time.
+	ByClicks bool
+	// Ascending puts the oldest or least-clicked links first.
+	Ascending bool
+}
+
+// SortedLinksPage is LinksPage in the given order. Ordering by clicks walks
+// the DB 1 leaderboard in stats, which only holds links that have been
+// clicked or were created after it started being seeded; aliases counted
+// apart from their links and links that no longer exist are skipped there,
+// since the leaderboard also backs their stats.
+func SortedLinksPage(ctx context.Context, rdb, stats Store, order LinkOrder, offset, n int) ([]StoredLink, int, error) {
+	idx, index := rdb, createdKey
+	if order.ByClicks {
+		idx, index = stats, leaderboardKey
+	}
+	read := idx.ZRevRange
+	if order.Ascending {
+		read = idx.ZRange
+	}
+
+	var out []StoredLink
+	pos := int64(offset)
+	for len(out) < n {
+		keys, err := read(ctx, index, pos, pos+int64(n)-1).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(keys) == 0 {
+			return out, -1, nil
+		}
+		for _, key := range keys {
+			link, err := GetLink(ctx, rdb, key)
+			if err == redis.Nil && !order.ByClicks {
+				// pruning moves everything after it up by one
+				rdb.ZRem(ctx, createdKey, key)
+				continue
+			} else if err != nil && err != redis.Nil {
+				return nil, 0, err
+			}
+			pos++
+			if err == redis.Nil || link.PrimaryKey != "" {
+				continue
+			}
+			out = append(out, StoredLink{Key: key, Link: link})
+			if len(out) == n {
+				break
+			}
+		}
+	}
+
+	total, err := idx.ZCard(ctx, index).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	if pos >= total {
+		return out, -1, nil
+	}
+	return out, int(pos), nil
+}
+
+// LinksCreatedSince returns up to n links created at or after since that
+// still exist, oldest first, starting offset links into those, and the offset
+// the next page starts at, or -1 when this is the last page. It reads only
+// that window of the creation index; expired links are pruned from it as
+// they're found, which the returned offset accounts for.
+func LinksCreatedSince(ctx context.Context, rdb Store, since time.Time, offset, n int) ([]StoredLink, int, error) {
+	window := &redis.ZRangeBy{Min: strconv.FormatInt(since.Unix(), 10), Max: "+inf"}
+
+	var out []StoredLink
+	pos := int64(offset)
+	for len(out) < n {
+		window.Offset, window.Count = pos, int64(n-len(out))
+		keys, err := rdb.ZRangeByScore(ctx, createdKey, window).Result()
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(keys) == 0 {
+			return out, -1, nil
+		}
+		for _, key := range keys {
+			link, err := GetLink(ctx, rdb, key)
+			if err == redis.Nil {
+				// pruning moves everything after it up by one
+				rdb.ZRem(ctx, createdKey, key)
+				continue
+			} else if err != nil {
+				return nil, 0, err
+			}
+			pos++
+			if link.PrimaryKey != "" {
+				continue
+			}
+			out = append(out, StoredLink{Key: key, Link: link})
+		}
+	}
+
+	// one more lookup tells a full last page from one with more after it
+	window.Offset, window.Count = pos, 1
+	more, err := rdb.ZRangeByScore(ctx, createdKey, window).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(more) == 0 {
+		return out, -1, nil
+	}
+	return out, int(pos), nil
+}
+
+// CountLinks returns the number of indexed links. It may include links that
+// expired since they were last pruned.
+func CountLinks(ctx context.Context, rdb Store) (int64, error) {
+	return rdb.ZCard(ctx, createdKey).Result()
+}
+
+// GetLink loads the link stored under id, following it when id is an alias.
+// It returns redis.Nil when there is no such link.
+func GetLink(ctx context.Context, rdb Store, id string) (*Link, error) {
+	f, err := rdb.HGetAll(ctx, id).Result()
+	if err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		// links created before links were hashes are plain URL strings
+		url, err := rdb.Get(ctx, id).Result()
+		if err != nil {
+			return nil, err
+		}
+		return &Link{URL: url, Permanent: true}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(f) == 0 {
+		// a durable copy outlives the primary losing it
+		if f, err = rehydrateLink(ctx, rdb, id); err != nil {
+			return nil, err
+		}
+	}
+	if target := f[aliasField]; target != "" {
+		// aliases always point at a link, never at another alias
+		link, err := GetLink(ctx, rdb, target)
+		if err != nil {
+			return nil, err
+		}
+		link.PrimaryKey = target
+		return link, nil
+	}
+	return linkFromFields(f), nil
+}
@@ -0,0 +1,151 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestExtendLink(t *testing.T) {
+	ctx := context.Background()
+	rdb, clock := newTestStore()
+	key := LinkKey("", "busy")
+	if err := SaveLink(ctx, rdb, key, &Link{URL: "https://example.com/"}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	alias := LinkKey("", "busy-alias")
+	if err := SaveAlias(ctx, rdb, alias, key); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    time.Duration
+	}{
+		{"first visit", 0, 90 * time.Minute},
+		{"second visit", 0, 2 * time.Hour},
+		// the cap is counted from now, not from when the link was made
+		{"at the cap", 0, 2 * time.Hour},
+		{"an hour later", time.Hour, 90 * time.Minute},
+	}
+	for _, s := range steps {
+		clock.Advance(s.advance)
+		ttl, err := ExtendLink(ctx, rdb, key, 30*time.Minute, 2*time.Hour)
+		if err != nil || ttl != s.want {
+			t.Fatalf("%s: ExtendLink = %v, %v; want %v", s.name, ttl, err, s.want)
+		}
+		for _, k := range []string{key, alias} {
+			if got := rdb.TTL(ctx, k).Val(); got != s.want {
+				t.Errorf("%s: TTL of %s = %v, want %v", s.name, k, got, s.want)
+			}
+		}
+		if got := rdb.TTL(ctx, TombstoneKey(key)).Val(); got <= s.want {
+			t.Errorf("%s: the tombstone expires in %v, before the link", s.name, got)
+		}
+	}
+
+	// a link that never expires is left that way
+	forever := LinkKey("", "forever")
+	if err := SaveLink(ctx, rdb, forever, &Link{URL: "https://example.com/"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := ExtendLink(ctx, rdb, forever, time.Hour, 2*time.Hour); err != nil || ttl != 0 {
+		t.Errorf("never expiring: ExtendLink = %v, %v; want 0", ttl, err)
+	}
+	if got := rdb.TTL(ctx, forever).Val(); got != -1 {
+		t.Errorf("never expiring: TTL = %v, want none", got)
+	}
+	if _, err := ExtendLink(ctx, rdb, LinkKey("", "missing"), time.Hour, 2*time.Hour); err != redis.Nil {
+		t.Errorf("missing link: err = %v, want redis.Nil", err)
+	}
+}
+
+func TestLinksCreatedSince(t *testing.T) {
+	ctx := context.Background()
+	r, clock := newTestStore()
+	start := clock.Now()
+	// one link an hour, the last expiring after two hours
+	for i, code := range []string{"h0", "h1", "h2", "h3", "h4"} {
+		var ttl time.Duration
+		if code == "h4" {
+			ttl = 2 * time.Hour
+		}
+		link := &Link{URL: "https://example.com/" + code, CreatedAt: clock.Now()}
+		if err := SaveLink(ctx, r, LinkKey("", code), link, ttl); err != nil {
+			t.Fatalf("saving %s: %v", code, err)
+		}
+		if i < 4 {
+			clock.Advance(time.Hour)
+		}
+	}
+
+	codes := func(links []StoredLink) []string {
+		var out []string
+		for _, l := range links {
+			out = append(out, LinkCode(l.Key))
+		}
+		return out
+	}
+	tests := []struct {
+		name      string
+		since     time.Time
+		offset, n int
+		want      string
+		next      int
+	}{
+		{"all", start, 0, 10, "[h0 h1 h2 h3 h4]", -1},
+		{"last two hours", start.Add(2 * time.Hour), 0, 10, "[h2 h3 h4]", -1},
+		{"first page", start.Add(time.Hour), 0, 2, "[h1 h2]", 2},
+		{"second page", start.Add(time.Hour), 2, 2, "[h3 h4]", -1},
+		{"none yet", start.Add(5 * time.Hour), 0, 10, "[]", -1},
+	}
+	for _, tt := range tests {
+		links, next, err := LinksCreatedSince(ctx, r, tt.since, tt.offset, tt.n)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := fmt.Sprint(codes(links)); got != tt.want || next != tt.next {
+			t.Errorf("%s: %s, next %d, want %s, next %d", tt.name, got, next, tt.want, tt.next)
+		}
+	}
+
+	// an expired link is pruned from the index and left out
+	clock.Advance(2 * time.Hour)
+	links, next, err := LinksCreatedSince(ctx, r, start.Add(3*time.Hour), 0, 10)
+	if got := fmt.Sprint(codes(links)); err != nil || got != "[h3]" || next != -1 {
+		t.Errorf("after h4 expired: %s, next %d, %v, want [h3]", got, next, err)
+	}
+	if n, _ := r.ZCard(ctx, createdKey).Result(); n != 4 {
+		t.Errorf("creation index holds %d links, want 4", n)
+	}
+}
+
+func TestClaimLink(t *testing.T) {
+	ctx := context.Background()
+	r, clock := newTestStore()
+	key := LinkKey("", "claimed")
+
+	if ok, err := ClaimLink(ctx, r, key); !ok || err != nil {
+		t.Fatalf("first claim = %v, %v, want it taken", ok, err)
+	}
+	if ok, _ := ClaimLink(ctx, r, key); ok {
+		t.Error("second claim taken while the first is held")
+	}
+	if ok, _ := ClaimLink(ctx, r, LinkKey("", "other")); !ok {
+		t.Error("claim on another code refused")
+	}
+	ReleaseLink(ctx, r, key)
+	if ok, _ := ClaimLink(ctx, r, key); !ok {
+		t.Error("claim refused after the release")
+	}
+
+	// a claim left by a request that died lapses
+	clock.Advance(claimTTL)
+	if ok, _ := ClaimLink(ctx, r, key); !ok {
+		t.Error("claim refused after claimTTL")
+	}
+}
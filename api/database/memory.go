@@ -0,0 +1,761 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var errWrongType = errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+var errNotInteger = errors.New("ERR value is not an integer or out of range")
+
+type memoryKind int
+
+const (
+	kindString memoryKind = iota
+	kindHash
+	kindSet
+	kindZSet
+	// kindHLL is a HyperLogLog, kept as the exact set of its members; Redis
+	// only estimates the count, so the memory store is never less accurate.
+	kindHLL
+	kindList
+)
+
+type memoryEntry struct {
+	kind      memoryKind
+	str       string
+	hash      map[string]string
+	set       map[string]struct{}
+	zset      map[string]float64
+	list      []string
+	expiresAt time.Time
+}
+
+// memoryDB is one logical database of the in-memory store.
+type memoryDB struct {
+	mu   sync.Mutex
+	now  func() time.Time
+	data map[string]*memoryEntry
+}
+
+// MemoryStore is a Store that keeps everything in process memory. It honors
+// TTLs and is safe for concurrent use, which makes it suitable for local
+// development and handler tests. It is not shared between processes.
+type MemoryStore struct {
+	db *memoryDB
+	// locked is set inside Atomic, where db.mu is already held.
+	locked bool
+}
+
+// NewMemoryStore returns an empty in-memory store. now is the clock used for
+// expiry; nil means time.Now.
+func NewMemoryStore(now func() time.Time) *MemoryStore {
+	if now == nil {
+		now = time.Now
+	}
+	return &MemoryStore{db: &memoryDB{now: now, data: map[string]*memoryEntry{}}}
+}
+
+var (
+	memoryMu  sync.Mutex
+	memoryDBs = map[int]*MemoryStore{}
+)
+
+// memoryStore returns the process-wide in-memory store for database dbNo,
+// creating it (and its reaper) on first use.
+func memoryStore(dbNo int) *MemoryStore {
+	memoryMu.Lock()
+	defer memoryMu.Unlock()
+
+	m, ok := memoryDBs[dbNo]
+	if !ok {
+		m = NewMemoryStore(nil)
+		memoryDBs[dbNo] = m
+		go m.reap(time.Minute)
+	}
+	return m
+}
+
+// reap drops expired keys every interval so unread keys don't pile up.
+// Expired keys are also dropped lazily whenever they're accessed.
+func (m *MemoryStore) reap(interval time.Duration) {
+	for range time.Tick(interval) {
+		m.Reap()
+	}
+}
+
+// Reap drops every expired key.
+func (m *MemoryStore) Reap() {
+	defer m.lock()()
+	for key := range m.db.data {
+		m.entry(key)
+	}
+}
+
+// Flush drops every key, expired or not.
+func (m *MemoryStore) Flush() {
+	defer m.lock()()
+	m.db.data = map[string]*memoryEntry{}
+}
+
+func (m *MemoryStore) lock() func() {
+	if m.locked {
+		return func() {}
+	}
+	m.db.mu.Lock()
+	return m.db.mu.Unlock
+}
+
+// entry returns the live entry at key, dropping it if it has expired.
+func (m *MemoryStore) entry(key string) *memoryEntry {
+	e, ok := m.db.data[key]
+	if !ok {
+		return nil
+	}
+	if !e.expiresAt.IsZero() && !m.db.now().Before(e.expiresAt) {
+		delete(m.db.data, key)
+		return nil
+	}
+	return e
+}
+
+// entryOf returns the live entry at key, creating one of kind if there is
+// none. It fails when the key holds a different kind of value.
+func (m *MemoryStore) entryOf(key string, kind memoryKind) (*memoryEntry, error) {
+	e := m.entry(key)
+	if e == nil {
+		e = &memoryEntry{kind: kind}
+		switch kind {
+		case kindHash:
+			e.hash = map[string]string{}
+		case kindSet, kindHLL:
+			e.set = map[string]struct{}{}
+		case kindZSet:
+			e.zset = map[string]float64{}
+		}
+		m.db.data[strings.Clone(key)] = e
+	}
+	if e.kind != kind {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// lookup returns the live entry at key when it holds kind, nil when there is
+// no such key.
+func (m *MemoryStore) lookup(key string, kind memoryKind) (*memoryEntry, error) {
+	e := m.entry(key)
+	if e != nil && e.kind != kind {
+		return nil, errWrongType
+	}
+	return e, nil
+}
+
+// toString is v as Redis would store it. Strings are copied: Fiber hands out
+// strings that share the request's buffer, which Redis never keeps but the
+// memory store would.
+func toString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.Clone(v)
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, key string) *redis.StringCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindString)
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	if e == nil {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(e.str, nil)
+}
+
+func (m *MemoryStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	defer m.lock()()
+	e := &memoryEntry{kind: kindString, str: toString(value)}
+	if expiration > 0 {
+		e.expiresAt = m.db.now().Add(expiration)
+	}
+	m.db.data[strings.Clone(key)] = e
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *MemoryStore) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd {
+	defer m.lock()()
+	if m.entry(key) != nil {
+		return redis.NewBoolResult(false, nil)
+	}
+	e := &memoryEntry{kind: kindString, str: toString(value)}
+	if expiration > 0 {
+		e.expiresAt = m.db.now().Add(expiration)
+	}
+	m.db.data[strings.Clone(key)] = e
+	return redis.NewBoolResult(true, nil)
+}
+
+func (m *MemoryStore) GetDel(ctx context.Context, key string) *redis.StringCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindString)
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	if e == nil {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	delete(m.db.data, key)
+	return redis.NewStringResult(e.str, nil)
+}
+
+func (m *MemoryStore) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	defer m.lock()()
+	out := make([]interface{}, len(keys))
+	for i, key := range keys {
+		if e := m.entry(key); e != nil && e.kind == kindString {
+			out[i] = e.str
+		}
+	}
+	return redis.NewSliceResult(out, nil)
+}
+
+func (m *MemoryStore) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	defer m.lock()()
+	var n int64
+	for _, key := range keys {
+		if m.entry(key) != nil {
+			delete(m.db.data, key)
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryStore) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	defer m.lock()()
+	var n int64
+	for _, key := range keys {
+		if m.entry(key) != nil {
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryStore) Incr(ctx context.Context, key string) *redis.IntCmd {
+	return m.IncrBy(ctx, key, 1)
+}
+
+func (m *MemoryStore) Decr(ctx context.Context, key string) *redis.IntCmd {
+	return m.IncrBy(ctx, key, -1)
+}
+
+func (m *MemoryStore) IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.entryOf(key, kindString)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	n := int64(0)
+	if e.str != "" {
+		if n, err = strconv.ParseInt(e.str, 10, 64); err != nil {
+			return redis.NewIntResult(0, errNotInteger)
+		}
+	}
+	n += value
+	e.str = strconv.FormatInt(n, 10)
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryStore) Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd {
+	defer m.lock()()
+	e := m.entry(key)
+	if e == nil {
+		return redis.NewBoolResult(false, nil)
+	}
+	if expiration <= 0 {
+		delete(m.db.data, key)
+		return redis.NewBoolResult(true, nil)
+	}
+	e.expiresAt = m.db.now().Add(expiration)
+	return redis.NewBoolResult(true, nil)
+}
+
+func (m *MemoryStore) Persist(ctx context.Context, key string) *redis.BoolCmd {
+	defer m.lock()()
+	e := m.entry(key)
+	if e == nil || e.expiresAt.IsZero() {
+		return redis.NewBoolResult(false, nil)
+	}
+	e.expiresAt = time.Time{}
+	return redis.NewBoolResult(true, nil)
+}
+
+// Scan returns every key matching match in one pass, sorted, with a zero
+// cursor; count is ignored.
+func (m *MemoryStore) Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd {
+	defer m.lock()()
+	keys := []string{}
+	for key := range m.db.data {
+		if m.entry(key) != nil && (match == "" || globMatch(match, key)) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return redis.NewScanCmdResult(keys, 0, nil)
+}
+
+// globMatch reports whether s matches a Redis-style pattern using * and ?.
+// Unlike path.Match, * also matches "/", which path-style codes contain.
+func globMatch(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := len(s); i >= 0; i-- {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// TTL mirrors go-redis: -2 for a missing key, -1 for a key without expiry.
+func (m *MemoryStore) TTL(ctx context.Context, key string) *redis.DurationCmd {
+	defer m.lock()()
+	e := m.entry(key)
+	if e == nil {
+		return redis.NewDurationResult(-2, nil)
+	}
+	if e.expiresAt.IsZero() {
+		return redis.NewDurationResult(-1, nil)
+	}
+	return redis.NewDurationResult(e.expiresAt.Sub(m.db.now()).Truncate(time.Second), nil)
+}
+
+func (m *MemoryStore) HGet(ctx context.Context, key, field string) *redis.StringCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindHash)
+	if err != nil {
+		return redis.NewStringResult("", err)
+	}
+	if e == nil {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	v, ok := e.hash[field]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(v, nil)
+}
+
+func (m *MemoryStore) HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindHash)
+	if err != nil {
+		return redis.NewStringStringMapResult(nil, err)
+	}
+	out := map[string]string{}
+	if e != nil {
+		for k, v := range e.hash {
+			out[k] = v
+		}
+	}
+	return redis.NewStringStringMapResult(out, nil)
+}
+
+// HSet accepts field/value pairs or a single map, like go-redis.
+func (m *MemoryStore) HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.entryOf(key, kindHash)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+
+	pairs := map[string]string{}
+	if len(values) == 1 {
+		switch v := values[0].(type) {
+		case map[string]interface{}:
+			for k, val := range v {
+				pairs[strings.Clone(k)] = toString(val)
+			}
+		case map[string]string:
+			for k, val := range v {
+				pairs[strings.Clone(k)] = strings.Clone(val)
+			}
+		}
+	} else {
+		for i := 0; i+1 < len(values); i += 2 {
+			pairs[toString(values[i])] = toString(values[i+1])
+		}
+	}
+
+	var added int64
+	for k, v := range pairs {
+		if _, ok := e.hash[k]; !ok {
+			added++
+		}
+		e.hash[k] = v
+	}
+	return redis.NewIntResult(added, nil)
+}
+
+func (m *MemoryStore) HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindHash)
+	if err != nil || e == nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, f := range fields {
+		if _, ok := e.hash[f]; ok {
+			delete(e.hash, f)
+			n++
+		}
+	}
+	if len(e.hash) == 0 {
+		delete(m.db.data, key)
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryStore) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.entryOf(key, kindSet)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, member := range members {
+		s := toString(member)
+		if _, ok := e.set[s]; !ok {
+			e.set[s] = struct{}{}
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryStore) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindSet)
+	if err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+	out := []string{}
+	if e != nil {
+		for s := range e.set {
+			out = append(out, s)
+		}
+	}
+	return redis.NewStringSliceResult(out, nil)
+}
+
+func (m *MemoryStore) SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindSet)
+	if err != nil || e == nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, member := range members {
+		s := toString(member)
+		if _, ok := e.set[s]; ok {
+			delete(e.set, s)
+			n++
+		}
+	}
+	if len(e.set) == 0 {
+		delete(m.db.data, key)
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryStore) LPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.entryOf(key, kindList)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	// each value goes on the head in turn, so the last ends up first
+	pushed := make([]string, len(values))
+	for i, v := range values {
+		pushed[len(values)-1-i] = toString(v)
+	}
+	e.list = append(pushed, e.list...)
+	return redis.NewIntResult(int64(len(e.list)), nil)
+}
+
+func (m *MemoryStore) LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindList)
+	if err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+	if e == nil {
+		return redis.NewStringSliceResult([]string{}, nil)
+	}
+	lo, hi := rangeBounds(len(e.list), start, stop)
+	return redis.NewStringSliceResult(append([]string{}, e.list[lo:hi]...), nil)
+}
+
+func (m *MemoryStore) LTrim(ctx context.Context, key string, start, stop int64) *redis.StatusCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindList)
+	if err != nil || e == nil {
+		return redis.NewStatusResult("OK", err)
+	}
+	lo, hi := rangeBounds(len(e.list), start, stop)
+	e.list = e.list[lo:hi]
+	if len(e.list) == 0 {
+		delete(m.db.data, key)
+	}
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (m *MemoryStore) PFAdd(ctx context.Context, key string, els ...interface{}) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.entryOf(key, kindHLL)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	var changed int64
+	for _, el := range els {
+		s := toString(el)
+		if _, ok := e.set[s]; !ok {
+			e.set[s] = struct{}{}
+			changed = 1
+		}
+	}
+	return redis.NewIntResult(changed, nil)
+}
+
+func (m *MemoryStore) PFCount(ctx context.Context, keys ...string) *redis.IntCmd {
+	defer m.lock()()
+	union := map[string]struct{}{}
+	for _, key := range keys {
+		e, err := m.lookup(key, kindHLL)
+		if err != nil {
+			return redis.NewIntResult(0, err)
+		}
+		if e != nil {
+			for s := range e.set {
+				union[s] = struct{}{}
+			}
+		}
+	}
+	return redis.NewIntResult(int64(len(union)), nil)
+}
+
+func (m *MemoryStore) ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.entryOf(key, kindZSet)
+	if err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, z := range members {
+		s := toString(z.Member)
+		if _, ok := e.zset[s]; !ok {
+			n++
+		}
+		e.zset[s] = z.Score
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryStore) ZIncrBy(ctx context.Context, key string, increment float64, member string) *redis.FloatCmd {
+	defer m.lock()()
+	e, err := m.entryOf(key, kindZSet)
+	if err != nil {
+		return redis.NewFloatResult(0, err)
+	}
+	e.zset[strings.Clone(member)] += increment
+	return redis.NewFloatResult(e.zset[member], nil)
+}
+
+func (m *MemoryStore) ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindZSet)
+	if err != nil || e == nil {
+		return redis.NewIntResult(0, err)
+	}
+	var n int64
+	for _, member := range members {
+		s := toString(member)
+		if _, ok := e.zset[s]; ok {
+			delete(e.zset, s)
+			n++
+		}
+	}
+	if len(e.zset) == 0 {
+		delete(m.db.data, key)
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func (m *MemoryStore) ZCard(ctx context.Context, key string) *redis.IntCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindZSet)
+	if err != nil || e == nil {
+		return redis.NewIntResult(0, err)
+	}
+	return redis.NewIntResult(int64(len(e.zset)), nil)
+}
+
+func (m *MemoryStore) ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	// ascending order is descending order reversed, ties included
+	zs, err := m.ZRevRangeWithScores(ctx, key, 0, -1).Result()
+	if err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+	out := make([]string, len(zs))
+	for i, z := range zs {
+		out[len(zs)-1-i] = z.Member.(string)
+	}
+	lo, hi := rangeBounds(len(out), start, stop)
+	return redis.NewStringSliceResult(out[lo:hi], nil)
+}
+
+func (m *MemoryStore) ZRevRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd {
+	zs, err := m.ZRevRangeWithScores(ctx, key, start, stop).Result()
+	if err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+	out := make([]string, len(zs))
+	for i, z := range zs {
+		out[i] = z.Member.(string)
+	}
+	return redis.NewStringSliceResult(out, nil)
+}
+
+func (m *MemoryStore) ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd {
+	min, minExcl, err := scoreBound(opt.Min)
+	if err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+	max, maxExcl, err := scoreBound(opt.Max)
+	if err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+	zs, err := m.ZRevRangeWithScores(ctx, key, 0, -1).Result()
+	if err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+
+	out := []string{}
+	for i := len(zs) - 1; i >= 0; i-- {
+		s := zs[i].Score
+		if s < min || (minExcl && s == min) || s > max || (maxExcl && s == max) {
+			continue
+		}
+		out = append(out, zs[i].Member.(string))
+	}
+	// like go-redis, a zero Offset and Count mean no LIMIT, and a negative
+	// Count means everything after Offset
+	if opt.Offset == 0 && opt.Count == 0 {
+		return redis.NewStringSliceResult(out, nil)
+	}
+	if opt.Offset >= int64(len(out)) {
+		return redis.NewStringSliceResult([]string{}, nil)
+	}
+	out = out[opt.Offset:]
+	if opt.Count >= 0 && opt.Count < int64(len(out)) {
+		out = out[:opt.Count]
+	}
+	return redis.NewStringSliceResult(out, nil)
+}
+
+// scoreBound parses a ZRANGEBYSCORE bound: a score, -inf or +inf, prefixed
+// with ( when it's exclusive.
+func scoreBound(s string) (score float64, exclusive bool, err error) {
+	if len(s) > 0 && s[0] == '(' {
+		exclusive, s = true, s[1:]
+	}
+	score, err = strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, errors.New("ERR min or max is not a float")
+	}
+	return score, exclusive, nil
+}
+
+func (m *MemoryStore) ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd {
+	defer m.lock()()
+	e, err := m.lookup(key, kindZSet)
+	if err != nil {
+		return redis.NewZSliceCmdResult(nil, err)
+	}
+	if e == nil {
+		return redis.NewZSliceCmdResult([]redis.Z{}, nil)
+	}
+
+	zs := make([]redis.Z, 0, len(e.zset))
+	for member, score := range e.zset {
+		zs = append(zs, redis.Z{Score: score, Member: member})
+	}
+	sort.Slice(zs, func(i, j int) bool {
+		if zs[i].Score != zs[j].Score {
+			return zs[i].Score > zs[j].Score
+		}
+		return zs[i].Member.(string) > zs[j].Member.(string)
+	})
+	lo, hi := rangeBounds(len(zs), start, stop)
+	return redis.NewZSliceCmdResult(zs[lo:hi], nil)
+}
+
+// rangeBounds converts Redis-style inclusive, possibly negative, start/stop
+// indexes into a slice range over n items.
+func rangeBounds(n int, start, stop int64) (lo, hi int) {
+	if start < 0 {
+		start += int64(n)
+	}
+	if stop < 0 {
+		stop += int64(n)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if stop >= int64(n) {
+		stop = int64(n) - 1
+	}
+	if start > stop {
+		return 0, 0
+	}
+	return int(start), int(stop) + 1
+}
+
+// Atomic runs fn with the store locked, so no other command interleaves.
+func (m *MemoryStore) Atomic(ctx context.Context, fn func(Store) error) error {
+	defer m.lock()()
+	return fn(&MemoryStore{db: m.db, locked: true})
+}
+
+// Close is a no-op; the data lives as long as the process.
+func (m *MemoryStore) Close() error {
+	return nil
+}
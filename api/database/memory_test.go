@@ -0,0 +1,276 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// fakeClock is a clock for NewMemoryStore that only moves when told to.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestStore() (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return NewMemoryStore(clock.Now), clock
+}
+
+func TestMemoryStoreSetGetDel(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		value interface{}
+		want  string
+	}{
+		{"string", "https://example.com", "https://example.com"},
+		{"bytes", []byte("abc"), "abc"},
+		{"int", 42, "42"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, _ := newTestStore()
+			if err := m.Set(ctx, "k", tt.value, 0).Err(); err != nil {
+				t.Fatalf("Set: %v", err)
+			}
+			got, err := m.Get(ctx, "k").Result()
+			if err != nil || got != tt.want {
+				t.Fatalf("Get = %q, %v; want %q", got, err, tt.want)
+			}
+			if n := m.Del(ctx, "k", "missing").Val(); n != 1 {
+				t.Errorf("Del removed %d keys, want 1", n)
+			}
+			if _, err := m.Get(ctx, "k").Result(); err != redis.Nil {
+				t.Errorf("Get after Del: err = %v, want redis.Nil", err)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreWrongType(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestStore()
+	m.SAdd(ctx, "set", "a")
+	if err := m.Get(ctx, "set").Err(); err != errWrongType {
+		t.Errorf("Get on a set: err = %v, want %v", err, errWrongType)
+	}
+	if err := m.HSet(ctx, "set", "f", "v").Err(); err != errWrongType {
+		t.Errorf("HSet on a set: err = %v, want %v", err, errWrongType)
+	}
+}
+
+func TestMemoryStoreTTL(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		ttl     time.Duration
+		advance time.Duration
+		live    bool
+		wantTTL time.Duration
+	}{
+		{"before expiry", time.Minute, 30 * time.Second, true, 30 * time.Second},
+		{"at expiry", time.Minute, time.Minute, false, -2},
+		{"after expiry", time.Minute, time.Hour, false, -2},
+		{"no expiry", 0, 24 * time.Hour, true, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, clock := newTestStore()
+			m.Set(ctx, "k", "v", tt.ttl)
+			clock.Advance(tt.advance)
+
+			_, err := m.Get(ctx, "k").Result()
+			if live := err == nil; live != tt.live {
+				t.Errorf("live = %v, want %v (err %v)", live, tt.live, err)
+			}
+			if got := m.TTL(ctx, "k").Val(); got != tt.wantTTL {
+				t.Errorf("TTL = %v, want %v", got, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreExpirePersist(t *testing.T) {
+	ctx := context.Background()
+	m, clock := newTestStore()
+	m.HSet(ctx, "h", "url", "https://example.com")
+	if !m.Expire(ctx, "h", time.Minute).Val() {
+		t.Fatal("Expire on an existing key returned false")
+	}
+	if !m.Persist(ctx, "h").Val() {
+		t.Fatal("Persist on an expiring key returned false")
+	}
+	clock.Advance(time.Hour)
+	if got := m.HGet(ctx, "h", "url").Val(); got != "https://example.com" {
+		t.Errorf("HGet after Persist = %q, want the value kept", got)
+	}
+
+	m.Expire(ctx, "h", time.Minute)
+	clock.Advance(time.Minute)
+	m.Reap()
+	if n := m.Exists(ctx, "h").Val(); n != 0 {
+		t.Errorf("Exists after expiry and Reap = %d, want 0", n)
+	}
+}
+
+func TestMemoryStoreFlush(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestStore()
+	m.Set(ctx, "a", 1, 0)
+	m.SAdd(ctx, "b", "x")
+	m.Flush()
+	if n := m.Exists(ctx, "a", "b").Val(); n != 0 {
+		t.Errorf("Exists after Flush = %d, want 0", n)
+	}
+	m.Set(ctx, "a", 2, 0)
+	if got := m.Get(ctx, "a").Val(); got != "2" {
+		t.Errorf("Get after Flush and Set = %q, want 2", got)
+	}
+}
+
+func TestMemoryStoreCopiesStrings(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestStore()
+	// Fiber hands out strings backed by a buffer it reuses
+	buf := []byte("code1")
+	key := unsafe.String(&buf[0], len(buf))
+	m.SAdd(ctx, "reverse:"+key, key)
+	m.Set(ctx, key, key, 0)
+	copy(buf, "XXXXX")
+
+	if got := m.Get(ctx, "code1").Val(); got != "code1" {
+		t.Errorf("Get = %q, want code1", got)
+	}
+	if got := m.SMembers(ctx, "reverse:code1").Val(); len(got) != 1 || got[0] != "code1" {
+		t.Errorf("SMembers = %q, want [code1]", got)
+	}
+}
+
+func TestMemoryStoreAtomic(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestStore()
+	err := m.Atomic(ctx, func(tx Store) error {
+		tx.Set(ctx, "a", "1", 0)
+		tx.Incr(ctx, "a")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Atomic: %v", err)
+	}
+	if got := m.Get(ctx, "a").Val(); got != "2" {
+		t.Errorf("a = %q, want 2", got)
+	}
+}
+
+func TestMemoryStoreConcurrent(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestStore()
+	const workers, each = 8, 200
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				m.Incr(ctx, "counter")
+				m.SAdd(ctx, "set", fmt.Sprintf("%d-%d", w, i))
+				m.HSet(ctx, "hash", fmt.Sprint(w), i)
+				m.Get(ctx, "counter")
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if got := m.Get(ctx, "counter").Val(); got != fmt.Sprint(workers*each) {
+		t.Errorf("counter = %s, want %d", got, workers*each)
+	}
+	if got := len(m.SMembers(ctx, "set").Val()); got != workers*each {
+		t.Errorf("set has %d members, want %d", got, workers*each)
+	}
+	if got := len(m.HGetAll(ctx, "hash").Val()); got != workers {
+		t.Errorf("hash has %d fields, want %d", got, workers)
+	}
+}
+
+func TestMemoryStoreZRange(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestStore()
+	m.ZAdd(ctx, "z", &redis.Z{Score: 1, Member: "b"}, &redis.Z{Score: 2, Member: "c"}, &redis.Z{Score: 1, Member: "a"}, &redis.Z{Score: 0, Member: "d"})
+
+	tests := []struct {
+		start, stop int64
+		want        string
+	}{
+		// ties are ordered by member, as in Redis
+		{0, -1, "[d a b c]"},
+		{1, 2, "[a b]"},
+		{-2, -1, "[b c]"},
+		{3, 10, "[c]"},
+		{4, 10, "[]"},
+	}
+	for _, tt := range tests {
+		got, err := m.ZRange(ctx, "z", tt.start, tt.stop).Result()
+		if err != nil || fmt.Sprint(got) != tt.want {
+			t.Errorf("ZRange(%d, %d) = %v, %v; want %s", tt.start, tt.stop, got, err, tt.want)
+		}
+	}
+	if got := fmt.Sprint(m.ZRevRange(ctx, "z", 0, -1).Val()); got != "[c b a d]" {
+		t.Errorf("ZRevRange = %s, want [c b a d]", got)
+	}
+	if got, err := m.ZRange(ctx, "missing", 0, -1).Result(); err != nil || len(got) != 0 {
+		t.Errorf("ZRange of a missing key = %v, %v; want empty", got, err)
+	}
+}
+
+func TestMemoryStoreLists(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestStore()
+	m.LPush(ctx, "l", "a", "b")
+	if n := m.LPush(ctx, "l", "c").Val(); n != 3 {
+		t.Errorf("LPush = %d, want the new length 3", n)
+	}
+	if got := fmt.Sprint(m.LRange(ctx, "l", 0, -1).Val()); got != "[c b a]" {
+		t.Errorf("LRange = %s, want [c b a]", got)
+	}
+	if got := fmt.Sprint(m.LRange(ctx, "l", 1, 5).Val()); got != "[b a]" {
+		t.Errorf("LRange(1, 5) = %s, want [b a]", got)
+	}
+
+	m.LTrim(ctx, "l", 0, 1)
+	if got := fmt.Sprint(m.LRange(ctx, "l", 0, -1).Val()); got != "[c b]" {
+		t.Errorf("after LTrim(0, 1): %s, want [c b]", got)
+	}
+	// trimming to nothing removes the key, as in Redis
+	m.LTrim(ctx, "l", 5, 10)
+	if n := m.Exists(ctx, "l").Val(); n != 0 {
+		t.Error("an emptied list still exists")
+	}
+	if got, err := m.LRange(ctx, "missing", 0, -1).Result(); err != nil || len(got) != 0 {
+		t.Errorf("LRange of a missing key = %v, %v; want empty", got, err)
+	}
+
+	m.Set(ctx, "s", "v", 0)
+	if err := m.LPush(ctx, "s", "x").Err(); err == nil {
+		t.Error("LPush on a string succeeded")
+	}
+}
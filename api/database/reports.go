@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// reportsKey is the DB 1 sorted set of reported link keys scored by how many
+// reports they've had.
+const reportsKey = "reports"
+
+// LinkReportsKey is the DB 1 sorted set of the reasons the link at key was
+// reported for, scored by how often each was given.
+func LinkReportsKey(key string) string {
+	return "reports:" + key
+}
+
+// reporterKey marks that reporter has already reported the link at key.
+func reporterKey(key, reporter string) string {
+	return "reported:" + key + ":" + reporter
+}
+
+// FileReport records a report of the link at key by reporter, an opaque
+// identifier, and returns the link's report count. A reporter counts once per
+// link within window; repeats return 0 and record nothing.
+func FileReport(ctx context.Context, rdb Store, key, reporter, reason string, window time.Duration) (int64, error) {
+	first, err := rdb.SetNX(ctx, reporterKey(key, reporter), 1, window).Result()
+	if err != nil || !first {
+		return 0, err
+	}
+
+	var total *redis.FloatCmd
+	err = rdb.Atomic(ctx, func(tx Store) error {
+		tx.ZIncrBy(ctx, LinkReportsKey(key), 1, reason)
+		total = tx.ZIncrBy(ctx, reportsKey, 1, key)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(total.Val()), nil
+}
+
+// LinkReport is the reports filed against one link.
+type LinkReport struct {
+	Key     string
+	Reports int64
+	// Reasons are the most common reasons given, most common first.
+	Reasons []redis.Z
+}
+
+// TopReports returns up to n of the most reported links, skipping the first
+// offset, with up to reasons of their most common reasons each.
+func TopReports(ctx context.Context, rdb Store, offset, n, reasons int) ([]LinkReport, error) {
+	top, err := rdb.ZRevRangeWithScores(ctx, reportsKey, int64(offset), int64(offset+n)-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]LinkReport, 0, len(top))
+	for _, z := range top {
+		key := z.Member.(string)
+		rs, err := rdb.ZRevRangeWithScores(ctx, LinkReportsKey(key), 0, int64(reasons)-1).Result()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, LinkReport{Key: key, Reports: int64(z.Score), Reasons: rs})
+	}
+	return out, nil
+}
+
+// CountReported returns how many links have open reports.
+func CountReported(ctx context.Context, rdb Store) (int64, error) {
+	return rdb.ZCard(ctx, reportsKey).Result()
+}
+
+// ClearReports removes the reports filed against the link at key, e.g. once
+// they've been reviewed.
+func ClearReports(ctx context.Context, rdb Store, key string) error {
+	return rdb.Atomic(ctx, func(tx Store) error {
+		tx.Del(ctx, LinkReportsKey(key))
+		tx.ZRem(ctx, reportsKey, key)
+		return nil
+	})
+}
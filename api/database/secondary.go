@@ -0,0 +1,187 @@
+package database
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// linkMirror copies link records to SECONDARY_STORE, the redis:// URL of a
+// durable store that speaks the Redis protocol (Kvrocks, or a Redis with
+// appendfsync always), so links survive losing the primary's memory. Writes
+// are queued and applied in the background, off the request path; deletes
+// are applied right away so a lookup can't bring a deleted link back. Only
+// link records are mirrored: aliases, indexes and counters are not.
+type linkMirror struct {
+	rdb   Store
+	queue chan mirrorWrite
+	wg    sync.WaitGroup
+}
+
+// mirrorWrite replaces the link record at key with fields, expiring at
+// expiresAt (never when zero). With nil fields only the expiry is changed.
+// The expiry is absolute so that the copy expires with the primary's, however
+// long the write sat in the queue.
+type mirrorWrite struct {
+	key       string
+	fields    map[string]interface{}
+	expiresAt time.Time
+}
+
+// expiresAt is when a key given ttl now expires; zero when ttl is 0, never.
+func expiresAt(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+var (
+	mirrorOnce sync.Once
+	mirror     *linkMirror
+)
+
+// secondary returns the link mirror, or nil when SECONDARY_STORE is unset or
+// isn't a valid URL.
+func secondary() *linkMirror {
+	mirrorOnce.Do(func() {
+		url := config.String("SECONDARY_STORE", "")
+		if url == "" {
+			return
+		}
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			log.Printf("secondary store disabled: %v", err)
+			return
+		}
+		mirror = &linkMirror{rdb: redisStore{redis.NewClient(opts)}}
+	})
+	return mirror
+}
+
+// StartSecondaryStore starts the background writer when SECONDARY_STORE is
+// set. It must be called before the server starts handling requests; until
+// then, writes are mirrored synchronously.
+func StartSecondaryStore() {
+	m := secondary()
+	if m == nil {
+		return
+	}
+	m.queue = make(chan mirrorWrite, config.Int("SECONDARY_STORE_BUFFER", 10000))
+	m.wg.Add(1)
+	go m.run()
+}
+
+// StopSecondaryStore applies any queued writes and stops the writer. Call it
+// once the server has stopped accepting requests.
+func StopSecondaryStore() {
+	m := secondary()
+	if m != nil && m.queue != nil {
+		close(m.queue)
+		m.wg.Wait()
+	}
+}
+
+func (m *linkMirror) run() {
+	defer m.wg.Done()
+	for w := range m.queue {
+		m.apply(w)
+	}
+}
+
+// mirrorLink queues a write to the secondary store, if there is one. When the
+// queue is full the write is applied synchronously rather than dropped.
+func mirrorLink(w mirrorWrite) {
+	m := secondary()
+	if m == nil {
+		return
+	}
+	if m.queue != nil {
+		select {
+		case m.queue <- w:
+			return
+		default:
+		}
+	}
+	m.apply(w)
+}
+
+// apply writes w to the secondary store. Writes are queued past the request
+// that made them, so they don't run under its context.
+func (m *linkMirror) apply(w mirrorWrite) {
+	ctx := context.Background()
+	err := m.rdb.Atomic(ctx, func(tx Store) error {
+		if w.fields != nil {
+			tx.Del(ctx, w.key)
+			tx.HSet(ctx, w.key, w.fields)
+		}
+		switch {
+		case w.expiresAt.IsZero():
+			tx.Persist(ctx, w.key)
+		case time.Until(w.expiresAt) <= 0:
+			tx.Del(ctx, w.key)
+		default:
+			tx.Expire(ctx, w.key, time.Until(w.expiresAt))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("secondary store: writing %s: %v", w.key, err)
+	}
+}
+
+// unmirrorLink removes the link record at key from the secondary store.
+func unmirrorLink(ctx context.Context, key string) {
+	if m := secondary(); m != nil {
+		if err := m.rdb.Del(ctx, key).Err(); err != nil {
+			log.Printf("secondary store: deleting %s: %v", key, err)
+		}
+	}
+}
+
+// rehydrateLink looks for the link record at key in the secondary store
+// after the primary, rdb, didn't have it, and puts it back into rdb with its
+// remaining TTL. It returns redis.Nil when the secondary doesn't have it
+// either, or there is no secondary store.
+func rehydrateLink(ctx context.Context, rdb Store, key string) (map[string]string, error) {
+	m := secondary()
+	if m == nil {
+		return nil, redis.Nil
+	}
+	f, err := m.rdb.HGetAll(ctx, key).Result()
+	if err != nil {
+		log.Printf("secondary store: reading %s: %v", key, err)
+		return nil, redis.Nil
+	}
+	if len(f) == 0 {
+		return nil, redis.Nil
+	}
+	ttl, err := m.rdb.TTL(ctx, key).Result()
+	if err != nil {
+		return nil, redis.Nil
+	}
+
+	values := make(map[string]interface{}, len(f))
+	for k, v := range f {
+		values[k] = v
+	}
+	link := linkFromFields(f)
+	err = rdb.Atomic(ctx, func(tx Store) error {
+		tx.HSet(ctx, key, values)
+		if ttl > 0 {
+			tx.Expire(ctx, key, ttl)
+		}
+		if !link.CreatedAt.IsZero() {
+			tx.ZAdd(ctx, createdKey, &redis.Z{Score: float64(link.CreatedAt.Unix()), Member: key})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// useSecondary points SECONDARY_STORE at a fresh miniredis for the rest of t.
+func useSecondary(t *testing.T) *miniredis.Miniredis {
+	t.Helper()
+	srv := miniredis.RunT(t)
+	t.Setenv("SECONDARY_STORE", "redis://"+srv.Addr())
+	mirrorOnce, mirror = sync.Once{}, nil
+	t.Cleanup(func() { mirrorOnce, mirror = sync.Once{}, nil })
+	return srv
+}
+
+func TestSecondaryStore(t *testing.T) {
+	ctx := context.Background()
+	srv := useSecondary(t)
+	primary, _ := newTestStore()
+	key := LinkKey("", "durable")
+	link := &Link{URL: "https://example.com/", CreatedAt: time.Unix(1700000000, 0)}
+
+	if err := SaveLink(ctx, primary, key, link, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.HGet(key, "url"); got != link.URL {
+		t.Fatalf("secondary url = %q, want %q", got, link.URL)
+	}
+	if ttl := srv.TTL(key); ttl <= time.Hour-time.Minute || ttl > time.Hour {
+		t.Errorf("secondary TTL = %v, want 1h", ttl)
+	}
+
+	// the primary loses it, and the lookup brings it back
+	primary.Flush()
+	got, err := GetLink(ctx, primary, key)
+	if err != nil || got.URL != link.URL {
+		t.Fatalf("GetLink after the primary lost it = %v, %v", got, err)
+	}
+	if ttl, _ := primary.TTL(ctx, key).Result(); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("rehydrated TTL = %v, want what the copy had left", ttl)
+	}
+	if page, _, err := LinksPage(ctx, primary, 0, 10); err != nil || len(page) != 1 || page[0].Key != key {
+		t.Errorf("rehydrated link isn't listed: %v, %v", page, err)
+	}
+
+	link.URL = "https://example.com/updated"
+	if err := UpdateLink(ctx, primary, key, link); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.HGet(key, "url"); got != link.URL {
+		t.Errorf("after the update, secondary url = %q, want %q", got, link.URL)
+	}
+
+	// deleted links stay deleted
+	if err := DeleteLink(ctx, primary, "", key, link); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Exists(key) {
+		t.Error("the secondary kept a deleted link")
+	}
+	if _, err := GetLink(ctx, primary, key); err != redis.Nil {
+		t.Errorf("GetLink of a deleted link: err = %v, want redis.Nil", err)
+	}
+	if _, err := GetLink(ctx, primary, LinkKey("", "never")); err != redis.Nil {
+		t.Errorf("GetLink of a link neither store has: err = %v, want redis.Nil", err)
+	}
+}
+
+func TestSecondaryStoreQueue(t *testing.T) {
+	ctx := context.Background()
+	srv := useSecondary(t)
+	primary, _ := newTestStore()
+	StartSecondaryStore()
+
+	keep, gone := LinkKey("", "queued"), LinkKey("", "lapsed")
+	if err := SaveLink(ctx, primary, keep, &Link{URL: "https://example.com/"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	// a write that sat in the queue past its expiry isn't applied
+	mirrorLink(mirrorWrite{key: gone, fields: map[string]interface{}{"url": "https://example.com/"}, expiresAt: time.Now().Add(-time.Second)})
+	StopSecondaryStore()
+
+	if got := srv.HGet(keep, "url"); got != "https://example.com/" {
+		t.Errorf("queued write: secondary url = %q", got)
+	}
+	if ttl := srv.TTL(keep); ttl != 0 {
+		t.Errorf("a link that never expires has a secondary TTL of %v", ttl)
+	}
+	if srv.Exists(gone) {
+		t.Error("an expired write was applied")
+	}
+}
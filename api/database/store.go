@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Store is the subset of Redis commands the service uses. *redis.Client
+// provides all of them; the in-memory store (STORAGE_BACKEND=memory)
+// implements the same semantics without a Redis server.
+type Store interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.BoolCmd
+	GetDel(ctx context.Context, key string) *redis.StringCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	Exists(ctx context.Context, keys ...string) *redis.IntCmd
+	Incr(ctx context.Context, key string) *redis.IntCmd
+	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
+	Decr(ctx context.Context, key string) *redis.IntCmd
+	Expire(ctx context.Context, key string, expiration time.Duration) *redis.BoolCmd
+	TTL(ctx context.Context, key string) *redis.DurationCmd
+	Persist(ctx context.Context, key string) *redis.BoolCmd
+	Scan(ctx context.Context, cursor uint64, match string, count int64) *redis.ScanCmd
+
+	HGet(ctx context.Context, key, field string) *redis.StringCmd
+	HGetAll(ctx context.Context, key string) *redis.StringStringMapCmd
+	HSet(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	HDel(ctx context.Context, key string, fields ...string) *redis.IntCmd
+
+	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	SMembers(ctx context.Context, key string) *redis.StringSliceCmd
+	SRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+
+	ZAdd(ctx context.Context, key string, members ...*redis.Z) *redis.IntCmd
+	ZIncrBy(ctx context.Context, key string, increment float64, member string) *redis.FloatCmd
+	ZRem(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	ZCard(ctx context.Context, key string) *redis.IntCmd
+	ZRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	ZRevRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	ZRangeByScore(ctx context.Context, key string, opt *redis.ZRangeBy) *redis.StringSliceCmd
+	ZRevRangeWithScores(ctx context.Context, key string, start, stop int64) *redis.ZSliceCmd
+
+	LPush(ctx context.Context, key string, values ...interface{}) *redis.IntCmd
+	LRange(ctx context.Context, key string, start, stop int64) *redis.StringSliceCmd
+	LTrim(ctx context.Context, key string, start, stop int64) *redis.StatusCmd
+
+	PFAdd(ctx context.Context, key string, els ...interface{}) *redis.IntCmd
+	PFCount(ctx context.Context, keys ...string) *redis.IntCmd
+
+	// Atomic runs fn as one unit: a MULTI/EXEC pipeline on Redis. Commands
+	// issued inside fn are queued, so their results aren't available until
+	// Atomic returns.
+	Atomic(ctx context.Context, fn func(Store) error) error
+
+	Close() error
+}
+
+// redisStore is a Store backed by a Redis server.
+type redisStore struct {
+	*redis.Client
+}
+
+// Close leaves the client open: it's shared with every other store for its
+// database, and CloseClients closes it.
+func (s redisStore) Close() error {
+	return nil
+}
+
+func (s redisStore) Atomic(ctx context.Context, fn func(Store) error) error {
+	_, err := s.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		return fn(pipeStore{pipe})
+	})
+	return err
+}
+
+// pipeStore queues commands on a pipeline that's already atomic.
+type pipeStore struct {
+	redis.Pipeliner
+}
+
+func (s pipeStore) Atomic(ctx context.Context, fn func(Store) error) error {
+	return fn(s)
+}
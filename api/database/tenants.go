@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// domainsKey is the DB 0 hash mapping a custom domain to its tenant id.
+const domainsKey = "domains"
+
+// LinkKey is the DB 0 key of the link with code id in tenant (tenant == ""
+// for links outside any tenant). Link keys are namespaced under "link:" so a
+// custom short can never collide with internal keys like "domains" or
+// "reverse:...".
+func LinkKey(tenant, id string) string {
+	if tenant == "" {
+		return "link:" + id
+	}
+	return "tenant:" + tenant + ":link:" + id
+}
+
+// LinkCode is the short code a link key was built from.
+func LinkCode(key string) string {
+	if i := strings.LastIndex(key, "link:"); i >= 0 {
+		return key[i+len("link:"):]
+	}
+	return key
+}
+
+// TenantForHost returns the tenant that owns host, or "" when host isn't a
+// registered custom domain.
+func TenantForHost(ctx context.Context, rdb Store, host string) (string, error) {
+	tenant, err := rdb.HGet(ctx, domainsKey, strings.ToLower(host)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return tenant, err
+}
+
+// SetDomainTenant registers domain as belonging to tenant.
+func SetDomainTenant(ctx context.Context, rdb Store, domain, tenant string) error {
+	return rdb.HSet(ctx, domainsKey, strings.ToLower(domain), tenant).Err()
+}
+
+// RemoveDomain unregisters domain.
+func RemoveDomain(ctx context.Context, rdb Store, domain string) error {
+	return rdb.HDel(ctx, domainsKey, strings.ToLower(domain)).Err()
+}
+
+// Domains returns every registered custom domain and its tenant.
+func Domains(ctx context.Context, rdb Store) (map[string]string, error) {
+	return rdb.HGetAll(ctx, domainsKey).Result()
+}
+
+// LinkKeyPatterns match every link key, with and without a tenant, for SCAN.
+var LinkKeyPatterns = []string{"link:*", "tenant:*:link:*"}
+
+// ScanLinkKeys calls fn with every link key, using SCAN so the server is never
+// blocked on a large keyspace. It stops at the first error fn returns.
+func ScanLinkKeys(ctx context.Context, rdb Store, fn func(key string) error) error {
+	for _, pattern := range LinkKeyPatterns {
+		if err := ScanKeys(ctx, rdb, pattern, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ScanKeys calls fn with every key matching the glob pattern, using SCAN. It
+// stops at the first error fn returns.
+func ScanKeys(ctx context.Context, rdb Store, pattern string, fn func(key string) error) error {
+	var cursor uint64
+	for {
+		keys, next, err := rdb.Scan(ctx, cursor, pattern, 500).Result()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
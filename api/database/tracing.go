@@ -0,0 +1,73 @@
+package database
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/karthikbhandary2/url-shortener/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracingHook records a client span for every Redis command. Commands whose
+// context carries no span, such as background jobs' and async click flushes,
+// are recorded under parent, the context the client was created for.
+type tracingHook struct {
+	parent context.Context
+	db     int
+}
+
+type spanKey struct{}
+
+func (h tracingHook) start(ctx context.Context, name string, attrs ...attribute.KeyValue) context.Context {
+	parent := ctx
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		parent = h.parent
+	}
+	attrs = append(attrs, attribute.String("db.system", "redis"), attribute.Int("db.redis.database_index", h.db))
+	_, span := tracing.Tracer().Start(parent, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+func (h tracingHook) end(ctx context.Context, err error) {
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+	if err != nil && err != redis.Nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+func (h tracingHook) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	return h.start(ctx, "redis "+cmd.Name(), attribute.String("db.operation", cmd.Name())), nil
+}
+
+func (h tracingHook) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	h.end(ctx, cmd.Err())
+	return nil
+}
+
+func (h tracingHook) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	names := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		names[i] = cmd.Name()
+	}
+	return h.start(ctx, "redis pipeline", attribute.String("db.operation", strings.Join(names, " "))), nil
+}
+
+func (h tracingHook) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	var err error
+	for _, cmd := range cmds {
+		if cmd.Err() != nil && cmd.Err() != redis.Nil {
+			err = cmd.Err()
+			break
+		}
+	}
+	h.end(ctx, err)
+	return nil
+}
@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"strings"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// defaultBotPatterns match the User-Agents of common crawlers and the preview
+// bots of chat apps and social networks.
+var defaultBotPatterns = []string{
+	"bot", "crawler", "spider", "slurp", "facebookexternalhit", "embedly",
+	"whatsapp", "telegram", "preview", "curl", "wget", "python-requests",
+	"go-http-client", "headlesschrome",
+}
+
+// IsBot reports whether a User-Agent looks like a crawler or preview bot. It
+// matches case-insensitive substrings: BOT_USER_AGENTS when set, otherwise a
+// built-in list. An empty User-Agent counts as a bot.
+func IsBot(ua string) bool {
+	ua = strings.ToLower(strings.TrimSpace(ua))
+	if ua == "" {
+		return true
+	}
+
+	patterns := config.List("BOT_USER_AGENTS")
+	if len(patterns) == 0 {
+		patterns = defaultBotPatterns
+	}
+	for _, p := range patterns {
+		if strings.Contains(ua, strings.ToLower(p)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,28 @@
+package helpers
+
+import "testing"
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		patterns string
+		ua       string
+		want     bool
+	}{
+		{"", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36", false},
+		{"", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"", "facebookexternalhit/1.1", true},
+		{"", "WhatsApp/2.23.20.0", true},
+		{"", "curl/8.4.0", true},
+		{"", "", true},
+		{"", "   ", true},
+		{"MyMonitor, uptime", "Mozilla/5.0 (compatible; Googlebot/2.1)", false},
+		{"MyMonitor, uptime", "mymonitor/1.0", true},
+		{"MyMonitor, uptime", "", true},
+	}
+	for _, tt := range tests {
+		t.Setenv("BOT_USER_AGENTS", tt.patterns)
+		if got := IsBot(tt.ua); got != tt.want {
+			t.Errorf("BOT_USER_AGENTS=%q: IsBot(%q) = %v, want %v", tt.patterns, tt.ua, got, tt.want)
+		}
+	}
+}
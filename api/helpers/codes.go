@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// CodeAlphabet is how many characters generated codes are drawn from:
+// lowercase hex digits.
+const CodeAlphabet = 16
+
+// DefaultCodeLength is the length of generated codes when SHORT_CODE_LENGTH
+// is unset; MaxCodeLength is the most it can be.
+const (
+	DefaultCodeLength = 6
+	MaxCodeLength     = 32
+)
+
+// CodeLength is the length of generated codes, SHORT_CODE_LENGTH.
+func CodeLength() int {
+	n := config.Int("SHORT_CODE_LENGTH", DefaultCodeLength)
+	if n < 1 || n > MaxCodeLength {
+		return DefaultCodeLength
+	}
+	return n
+}
+
+// GenerateCode returns a random code of CodeLength characters.
+func GenerateCode() string {
+	n := CodeLength()
+	b := make([]byte, (n+1)/2)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)[:n]
+}
+
+// CodeEntropyBits is how many bits of entropy a random code of length
+// characters drawn from an alphabet of that many characters has, which is how
+// hard it is to guess.
+func CodeEntropyBits(length int, alphabet int) float64 {
+	if length <= 0 || alphabet <= 1 {
+		return 0
+	}
+	return float64(length) * math.Log2(float64(alphabet))
+}
+
+// CheckCodeEntropy reports an error when generated codes have less entropy
+// than MIN_CODE_ENTROPY_BITS requires (no minimum when 0, the default).
+func CheckCodeEntropy() error {
+	min := config.Int("MIN_CODE_ENTROPY_BITS", 0)
+	if bits := CodeEntropyBits(CodeLength(), CodeAlphabet); bits < float64(min) {
+		need := int(math.Ceil(float64(min) / math.Log2(CodeAlphabet)))
+		return fmt.Errorf("SHORT_CODE_LENGTH %d gives %.0f bits of entropy, below MIN_CODE_ENTROPY_BITS %d; use at least %d", CodeLength(), bits, min, need)
+	}
+	return nil
+}
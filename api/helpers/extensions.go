@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// ErrBlockedExtension is returned for destinations that point straight at a
+// file type listed in BLOCKED_EXTENSIONS.
+var ErrBlockedExtension = errors.New("links to this file type are not allowed")
+
+// CheckExtension rejects a destination whose path ends in one of the
+// BLOCKED_EXTENSIONS (e.g. ".exe,.sh,.apk"), ignoring case, the query string
+// and the fragment. Nothing is blocked when the list is empty.
+func CheckExtension(rawURL string) error {
+	blocked := config.List("BLOCKED_EXTENSIONS")
+	if len(blocked) == 0 {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if ext == "" {
+		return nil
+	}
+	for _, b := range blocked {
+		b = strings.ToLower(b)
+		if !strings.HasPrefix(b, ".") {
+			b = "." + b
+		}
+		if ext == b {
+			return fmt.Errorf("%w: %s", ErrBlockedExtension, ext)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package helpers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckExtension(t *testing.T) {
+	tests := []struct {
+		blocked string
+		url     string
+		want    bool
+	}{
+		{"", "https://example.com/setup.exe", false},
+		{".exe, sh,.APK", "https://example.com/setup.exe", true},
+		{".exe, sh,.APK", "https://example.com/Setup.EXE", true},
+		{".exe, sh,.APK", "https://example.com/install.sh?v=2", true},
+		{".exe, sh,.APK", "https://example.com/app.apk#download", true},
+		{".exe, sh,.APK", "https://example.com/dl/tool.exe/", false},
+		{".exe, sh,.APK", "https://example.com/readme.pdf", false},
+		{".exe, sh,.APK", "https://example.com/page?file=setup.exe", false},
+		{".exe, sh,.APK", "https://example.com/#setup.exe", false},
+		{".exe, sh,.APK", "https://example.com/setup.exe.txt", false},
+		{".exe, sh,.APK", "https://example.com/", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("BLOCKED_EXTENSIONS", tt.blocked)
+		err := CheckExtension(tt.url)
+		if got := errors.Is(err, ErrBlockedExtension); got != tt.want {
+			t.Errorf("BLOCKED_EXTENSIONS=%q: CheckExtension(%q) = %v, want blocked %v", tt.blocked, tt.url, err, tt.want)
+		}
+	}
+}
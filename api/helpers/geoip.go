@@ -0,0 +1,129 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/karthikbhandary2/url-shortener/metrics"
+)
+
+// ErrNoCountry is returned by GeoDB.Country for an address no range covers.
+var ErrNoCountry = errors.New("no country for address")
+
+// GeoDB maps IP address ranges to the ISO 3166 country they're in.
+type GeoDB struct {
+	ranges []geoRange
+}
+
+type geoRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// LoadGeoDB reads a GeoIP country database: a CSV file of start_ip,end_ip,country
+// rows, the layout of the DB-IP and IP2Location "lite" country downloads.
+// Both IPv4 and IPv6 ranges may be listed. A header row, and rows for "ZZ"
+// (unassigned), are skipped.
+func LoadGeoDB(path string) (*GeoDB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+
+	db := &GeoDB{}
+	for line := 1; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if len(row) < 3 {
+			return nil, fmt.Errorf("%s:%d: expected start_ip,end_ip,country", path, line)
+		}
+		start, err1 := netip.ParseAddr(strings.TrimSpace(row[0]))
+		end, err2 := netip.ParseAddr(strings.TrimSpace(row[1]))
+		if err1 != nil || err2 != nil {
+			if line == 1 {
+				// a header row
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: invalid address range", path, line)
+		}
+		country := strings.ToUpper(strings.TrimSpace(row[2]))
+		if country == "" || country == "ZZ" || country == "-" {
+			continue
+		}
+		start, end = start.Unmap(), end.Unmap()
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("%s:%d: invalid address range", path, line)
+		}
+		db.ranges = append(db.ranges, geoRange{start: start, end: end, country: country})
+	}
+	sort.Slice(db.ranges, func(i, j int) bool { return db.ranges[i].start.Less(db.ranges[j].start) })
+	return db, nil
+}
+
+// Country returns the country ip is in.
+func (db *GeoDB) Country(ip string) (string, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return "", err
+	}
+	addr = addr.Unmap()
+	// the last range starting at or before addr is the only one that can
+	// hold it
+	i := sort.Search(len(db.ranges), func(i int) bool { return addr.Less(db.ranges[i].start) }) - 1
+	if i < 0 || db.ranges[i].end.Less(addr) {
+		return "", ErrNoCountry
+	}
+	return db.ranges[i].country, nil
+}
+
+// Len is the number of ranges in the database.
+func (db *GeoDB) Len() int {
+	return len(db.ranges)
+}
+
+var (
+	geoDB atomic.Pointer[GeoDB]
+	// GeoLookupFailures counts visitor lookups that fell back to the default
+	// destination, by reason.
+	GeoLookupFailures = metrics.NewCounter("geoip_lookup_failures_total", "Visitor country lookups that failed, so the default destination was served, by reason.", "reason")
+)
+
+// SetGeoDB makes db the database VisitorCountry looks visitors up in; nil
+// turns lookups off.
+func SetGeoDB(db *GeoDB) {
+	geoDB.Store(db)
+}
+
+// VisitorCountry is the country the visitor at ip is in, or "" when it can't
+// be told: there's no GeoIP database loaded, ip isn't an address, or no range
+// covers it. Failed lookups are counted in GeoLookupFailures.
+func VisitorCountry(ip string) string {
+	db := geoDB.Load()
+	if db == nil {
+		GeoLookupFailures.Inc("no_database")
+		return ""
+	}
+	country, err := db.Country(ip)
+	if errors.Is(err, ErrNoCountry) {
+		GeoLookupFailures.Inc("not_found")
+		return ""
+	} else if err != nil {
+		GeoLookupFailures.Inc("invalid_address")
+		return ""
+	}
+	return country
+}
@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeGeoDB writes a GeoIP CSV to a file in a temporary directory and
+// returns its path.
+func writeGeoDB(t *testing.T, csv string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadGeoDB(t *testing.T) {
+	db, err := LoadGeoDB(writeGeoDB(t, "ip_start,ip_end,country\n"+
+		"5.0.0.0,5.255.255.255,at\n"+
+		"1.0.0.0,1.0.0.255,AU\n"+
+		"2.0.0.0,2.0.0.255,ZZ\n"+
+		"2001:db8::,2001:db8::ffff,CH\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the header and the unassigned range are skipped
+	if db.Len() != 3 {
+		t.Errorf("Len = %d, want 3", db.Len())
+	}
+	tests := []struct {
+		ip, country string
+		err         bool
+	}{
+		{"1.0.0.1", "AU", false},
+		{"5.6.7.8", "AT", false},
+		{"::ffff:5.6.7.8", "AT", false},
+		{"2001:db8::1", "CH", false},
+		{"2.0.0.1", "", true},
+		{"0.0.0.1", "", true},
+		{"9.9.9.9", "", true},
+		{"not an ip", "", true},
+	}
+	for _, tt := range tests {
+		got, err := db.Country(tt.ip)
+		if got != tt.country || (err != nil) != tt.err {
+			t.Errorf("Country(%q) = %q, %v; want %q", tt.ip, got, err, tt.country)
+		}
+	}
+
+	for name, csv := range map[string]string{
+		"short row":      "1.0.0.0,1.0.0.255\n",
+		"bad address":    "1.0.0.0,1.0.0.255,AU\nnope,1.0.1.255,AU\n",
+		"reversed":       "1.0.0.255,1.0.0.0,AU\n",
+		"mixed families": "1.0.0.0,2001:db8::1,AU\n",
+	} {
+		if _, err := LoadGeoDB(writeGeoDB(t, csv)); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+	if _, err := LoadGeoDB(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("missing file: loaded")
+	}
+}
+
+func TestVisitorCountry(t *testing.T) {
+	t.Cleanup(func() { SetGeoDB(nil) })
+	failures := func(reason string) uint64 { return GeoLookupFailures.Value(reason) }
+
+	SetGeoDB(nil)
+	before := failures("no_database")
+	if got := VisitorCountry("5.6.7.8"); got != "" || failures("no_database") != before+1 {
+		t.Errorf("without a database: %q, failures counted %d, want \"\" and 1", got, failures("no_database")-before)
+	}
+
+	db, err := LoadGeoDB(writeGeoDB(t, "5.0.0.0,5.255.255.255,AT\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetGeoDB(db)
+	if got := VisitorCountry("5.6.7.8"); got != "AT" {
+		t.Errorf("VisitorCountry(5.6.7.8) = %q, want AT", got)
+	}
+	for ip, reason := range map[string]string{"9.9.9.9": "not_found", "garbage": "invalid_address"} {
+		before := failures(reason)
+		if got := VisitorCountry(ip); got != "" || failures(reason) != before+1 {
+			t.Errorf("VisitorCountry(%q) = %q, %s failures counted %d, want \"\" and 1", ip, got, reason, failures(reason)-before)
+		}
+	}
+}
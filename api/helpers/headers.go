@@ -0,0 +1,72 @@
+package helpers
+
+import (
+	"fmt"
+	"net/textproto"
+	"path"
+	"strings"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// Limits on the custom response headers a link can carry.
+const (
+	MaxLinkHeaders        = 10
+	MaxLinkHeaderNameLen  = 64
+	MaxLinkHeaderValueLen = 256
+)
+
+// deniedHeaders can't be set by a link whatever LINK_HEADER_PATTERNS allows:
+// proxies, other services or the browser's security model trust them, they
+// are hop-by-hop, or the redirect itself depends on them.
+var deniedHeaders = []string{
+	"x-forwarded-*", "x-real-ip", "x-api-key", "x-admin-key", "x-edit-token",
+	"x-frame-options", "x-content-type-options", "x-xss-protection",
+	"connection", "keep-alive", "proxy-*", "te", "trailer", "transfer-encoding", "upgrade",
+	"location", "set-cookie", "content-*", "cache-control", "etag", "vary",
+	"strict-transport-security", "access-control-*", "www-authenticate",
+}
+
+// NormalizeHeaders canonicalizes the names of a link's custom response
+// headers. Names must match one of the LINK_HEADER_PATTERNS glob patterns
+// ("X-*" when unset), case-insensitively, and not be a header proxies or
+// browsers rely on; values must be short and on one line.
+func NormalizeHeaders(headers map[string]string) (map[string]string, error) {
+	if len(headers) > MaxLinkHeaders {
+		return nil, fmt.Errorf("a link can have at most %d headers", MaxLinkHeaders)
+	}
+
+	out := make(map[string]string, len(headers))
+	for name, value := range headers {
+		name = strings.TrimSpace(name)
+		if name == "" || len(name) > MaxLinkHeaderNameLen || strings.Trim(name, "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return nil, fmt.Errorf("invalid header name %q", name)
+		}
+		if !headerAllowed(strings.ToLower(name)) {
+			return nil, fmt.Errorf("header %q is not allowed", name)
+		}
+		if len(value) > MaxLinkHeaderValueLen || strings.ContainsAny(value, "\r\n\x00") {
+			return nil, fmt.Errorf("invalid value for header %q", name)
+		}
+		out[textproto.CanonicalMIMEHeaderKey(name)] = value
+	}
+	return out, nil
+}
+
+func headerAllowed(name string) bool {
+	for _, pattern := range deniedHeaders {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	patterns := config.List("LINK_HEADER_PATTERNS")
+	if len(patterns) == 0 {
+		patterns = []string{"X-*"}
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
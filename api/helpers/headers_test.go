@@ -0,0 +1,58 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeHeaders(t *testing.T) {
+	got, err := NormalizeHeaders(map[string]string{"x-campaign-id": "spring", " X-Source ": "newsletter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got["X-Campaign-Id"] != "spring" || got["X-Source"] != "newsletter" {
+		t.Errorf("NormalizeHeaders = %v, want canonical names", got)
+	}
+
+	tooMany := map[string]string{}
+	for i := 0; i <= MaxLinkHeaders; i++ {
+		tooMany[fmt.Sprintf("X-H%d", i)] = "v"
+	}
+	tests := []struct {
+		patterns string
+		headers  map[string]string
+	}{
+		{"", map[string]string{"Campaign": "spring"}},
+		{"", map[string]string{"X-Forwarded-For": "1.2.3.4"}},
+		{"", map[string]string{"x-admin-key": "secret"}},
+		{"", map[string]string{"X-Bad Name": "v"}},
+		{"", map[string]string{"X-Bad:Name": "v"}},
+		{"", map[string]string{"": "v"}},
+		{"", map[string]string{"X-" + strings.Repeat("a", MaxLinkHeaderNameLen): "v"}},
+		{"", map[string]string{"X-Campaign": "spring\r\nSet-Cookie: a=1"}},
+		{"", map[string]string{"X-Campaign": strings.Repeat("v", MaxLinkHeaderValueLen+1)}},
+		{"", tooMany},
+		// even a pattern that allows everything can't set these
+		{"*", map[string]string{"Location": "https://evil.example/"}},
+		{"*", map[string]string{"Set-Cookie": "session=1"}},
+		{"*", map[string]string{"Connection": "close"}},
+		{"*", map[string]string{"Transfer-Encoding": "chunked"}},
+		{"*", map[string]string{"Content-Type": "text/html"}},
+		{"*", map[string]string{"Strict-Transport-Security": "max-age=0"}},
+	}
+	for _, tt := range tests {
+		t.Setenv("LINK_HEADER_PATTERNS", tt.patterns)
+		if got, err := NormalizeHeaders(tt.headers); err == nil {
+			t.Errorf("LINK_HEADER_PATTERNS=%q: NormalizeHeaders(%v) = %v, want an error", tt.patterns, tt.headers, got)
+		}
+	}
+
+	t.Setenv("LINK_HEADER_PATTERNS", "Campaign-*,Link")
+	if _, err := NormalizeHeaders(map[string]string{"campaign-id": "spring", "Link": "<https://example.com/>; rel=preload"}); err != nil {
+		t.Errorf("headers matching LINK_HEADER_PATTERNS: %v", err)
+	}
+	if _, err := NormalizeHeaders(map[string]string{"X-Campaign": "spring"}); err == nil {
+		t.Error("X-Campaign is allowed once LINK_HEADER_PATTERNS replaces X-*")
+	}
+}
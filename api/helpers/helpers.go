@@ -1,8 +1,23 @@
 package helpers
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/karthikbhandary2/url-shortener/config"
 )
 
 func RemoveDomainError(url string) bool {
@@ -14,17 +29,274 @@ func RemoveDomainError(url string) bool {
 	newURL = strings.Replace(newURL, "https://", "", 1)
 	newURL = strings.Replace(newURL, "www.", "", 1)
 	newURL = strings.Split(newURL, "/")[0]
+	// user@host still goes to host
+	newURL = newURL[strings.LastIndex(newURL, "@")+1:]
 
 	if newURL == os.Getenv("DOMAIN") {
 		return false
 	}
+	// links to any of our other hostnames would loop just the same
+	for _, host := range config.List("ALLOWED_HOSTS") {
+		if strings.EqualFold(newURL, host) {
+			return false
+		}
+	}
 	return true
 }
 
+// ShortDomain returns the domain short URLs should be built on for a request
+// that arrived on host. Hosts listed in ALLOWED_HOSTS are used as-is; anything
+// else falls back to DOMAIN.
+func ShortDomain(host string) string {
+	for _, allowed := range config.List("ALLOWED_HOSTS") {
+		if strings.EqualFold(host, allowed) {
+			return allowed
+		}
+	}
+	return os.Getenv("DOMAIN")
+}
+
+// ValidateURL reports why raw isn't a usable destination URL. By default it's
+// govalidator's lenient IsURL check. STRICT_URL_VALIDATION=true parses raw with
+// net/url instead and says exactly what's wrong with it. Either way a URL
+// without a scheme is accepted, since EnforceHTTP adds one.
+func ValidateURL(raw string) error {
+	if !config.Bool("STRICT_URL_VALIDATION", false) {
+		if !govalidator.IsURL(raw) {
+			return errors.New("invalid URL")
+		}
+		return nil
+	}
+
+	if raw == "" {
+		return errors.New("invalid URL: it is empty")
+	}
+	for _, r := range raw {
+		if unicode.IsSpace(r) || unicode.IsControl(r) {
+			return errors.New("invalid URL: it contains whitespace or control characters")
+		}
+	}
+	// an explicit scheme must be one we redirect to; EnforceHTTP would
+	// otherwise turn ftp://host into a URL on a host named "ftp"
+	if i := strings.Index(raw, "://"); i > 0 && !strings.ContainsAny(raw[:i], "/?#") {
+		if scheme := strings.ToLower(raw[:i]); scheme != "http" && scheme != "https" {
+			return fmt.Errorf("invalid URL: scheme %q is not http or https", raw[:i])
+		}
+	}
+
+	lower := strings.ToLower(raw)
+	if (strings.HasPrefix(lower, "http:") && !strings.HasPrefix(lower, "http://")) ||
+		(strings.HasPrefix(lower, "https:") && !strings.HasPrefix(lower, "https://")) {
+		return errors.New("invalid URL: the scheme must be followed by //")
+	}
+
+	u, err := url.Parse(EnforceHTTP(raw))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("invalid URL: %v", err)
+	}
+	// with no scheme given, "mailto:a@example.com" would parse as a user
+	// "mailto:a" on example.com
+	if u.User != nil && !strings.Contains(raw, "://") {
+		return errors.New("invalid URL: user info needs an explicit http or https scheme")
+	}
+	host := u.Hostname()
+	if host == "" {
+		return errors.New("invalid URL: it has no host")
+	}
+	if net.ParseIP(host) == nil && !govalidator.IsDNSName(host) {
+		return fmt.Errorf("invalid URL: %q is not a valid host name", host)
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid URL: port %s is out of range", port)
+		}
+	}
+	return nil
+}
+
+// BuildShortURL returns both written forms of code on domain: path without
+// a scheme ("localhost:3000/abc123") and url with one
+// ("https://localhost:3000/abc123").
+func BuildShortURL(scheme, domain, code string) (path, url string) {
+	path = domain + "/" + code
+	return path, scheme + "://" + path
+}
+
+// EnforceHTTP gives a URL without an http or https scheme the DEFAULT_SCHEME,
+// http unless configured otherwise. URLs that already have one are left as
+// given, so an explicit https:// is never downgraded.
 func EnforceHTTP(url string) string {
-	if url[:4] != "http" {
-		return "http://" + url
+	lower := strings.ToLower(url)
+	if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+		return url
+	}
+	return config.String("DEFAULT_SCHEME", "http") + "://" + url
+}
+
+// CheckHTTPS rejects destinations that don't use https when HTTPS_ONLY is set,
+// rather than upgrading them and breaking sites without TLS. It runs after
+// EnforceHTTP, so a URL given without a scheme is rejected as well unless
+// DEFAULT_SCHEME is https.
+func CheckHTTPS(url string) error {
+	if config.Bool("HTTPS_ONLY", false) && !strings.HasPrefix(strings.ToLower(url), "https://") {
+		return errors.New("destination must use https")
+	}
+	return nil
+}
+
+// StripCredentials removes the user:pass@ part of a destination, so
+// credentials someone pasted along with a URL aren't stored and handed to
+// every visitor. ALLOW_URL_CREDENTIALS keeps them. Fragments and everything
+// else are left as they are.
+func StripCredentials(rawURL string) string {
+	if config.Bool("ALLOW_URL_CREDENTIALS", false) {
+		return rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.User == nil {
+		return rawURL
+	}
+	u.User = nil
+	return u.String()
+}
+
+// NeverExpires is what ParseExpiry returns for "never".
+const NeverExpires time.Duration = -1
+
+// ParseExpiry parses an expiry such as "30m", "48h", "7d" or "2w". It extends
+// time.ParseDuration with day and week units, defaults to 24 hours when s is
+// empty, and returns NeverExpires for "never".
+func ParseExpiry(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 24 * time.Hour, nil
+	}
+	if s == "never" {
+		return NeverExpires, nil
+	}
+
+	units := map[string]time.Duration{
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	// summed as a float so an overflow is caught rather than wrapping
+	var total float64
+	rest := s
+	for rest != "" {
+		i := 0
+		for i < len(rest) && (rest[i] == '.' || (rest[i] >= '0' && rest[i] <= '9')) {
+			i++
+		}
+		j := i
+		for j < len(rest) && rest[j] >= 'a' && rest[j] <= 'z' {
+			j++
+		}
+		if i == 0 || j == i {
+			return 0, fmt.Errorf("invalid expiry %q", s)
+		}
+		unit, ok := units[rest[i:j]]
+		if !ok {
+			return 0, fmt.Errorf("invalid expiry unit %q in %q", rest[i:j], s)
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid expiry %q", s)
+		}
+		total += n * float64(unit)
+		rest = rest[j:]
+	}
+
+	if total >= math.MaxInt64 {
+		return 0, fmt.Errorf("expiry %q is too large", s)
+	}
+	if time.Duration(total) <= 0 {
+		return 0, fmt.Errorf("expiry must be positive, got %q", s)
+	}
+	return time.Duration(total), nil
+}
+
+// DefaultMaxExpiry is the longest lifetime a link can have when MAX_EXPIRY
+// isn't set.
+const DefaultMaxExpiry = 10 * 365 * 24 * time.Hour
+
+// MaxExpiry is the longest lifetime a link can be given: MAX_EXPIRY, in any
+// form ParseExpiry accepts, or DefaultMaxExpiry. Longer expiries are clamped
+// to it.
+func MaxExpiry() time.Duration {
+	v := config.String("MAX_EXPIRY", "")
+	if v == "" {
+		return DefaultMaxExpiry
 	}
-	return url
+	limit, err := ParseExpiry(v)
+	if err != nil || limit == NeverExpires {
+		return DefaultMaxExpiry
+	}
+	return limit
+}
 
-}
\ No newline at end of file
+// HashToken returns the hex SHA-256 of a secret token, which is what gets
+// stored instead of the token itself.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenMatches reports whether token hashes to hash, in constant time.
+func TokenMatches(token, hash string) bool {
+	if token == "" || hash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(HashToken(token)), []byte(hash)) == 1
+}
+
+// VisitorID returns the identifier analytics stores for a visitor from ip: an
+// HMAC-SHA256 keyed with ANALYTICS_SALT, so raw addresses never reach Redis
+// and can't be recovered by hashing the address space without the salt.
+func VisitorID(ip string) string {
+	mac := hmac.New(sha256.New, []byte(config.String("ANALYTICS_SALT", "")))
+	mac.Write([]byte(ip))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NormalizeHosts lowercases and trims a list of hostnames, dropping
+// duplicates. It rejects entries that are empty or look like URLs rather than
+// bare hosts.
+func NormalizeHosts(hosts []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		h = strings.ToLower(strings.TrimSpace(h))
+		if h == "" || strings.ContainsAny(h, ":/, ") {
+			return nil, fmt.Errorf("invalid host %q", h)
+		}
+		if !seen[h] {
+			seen[h] = true
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+// ReferrerAllowed reports whether a Referer header comes from one of the
+// allowed hosts or their subdomains. A missing or unparsable Referer is never
+// allowed.
+func ReferrerAllowed(referer string, allowed []string) bool {
+	u, err := url.Parse(referer)
+	if err != nil || u.Hostname() == "" {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, a := range allowed {
+		if host == a || strings.HasSuffix(host, "."+a) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// ErrPrivateHost is returned for destinations whose host is, or resolves to,
+// a private, loopback or link-local address while BLOCK_PRIVATE_HOSTS is set.
+var ErrPrivateHost = errors.New("destinations on private or loopback addresses are not allowed")
+
+// hostLookupTimeout bounds the DNS lookup IsPrivateHost makes for a host
+// name, so a slow resolver can't hold a shorten request open.
+const hostLookupTimeout = 2 * time.Second
+
+// privateRanges are the ranges, besides those net.IP already classifies,
+// that aren't reachable on the public internet: "this network", carrier-grade
+// NAT (where some clouds keep their metadata service) and benchmarking.
+var privateRanges = func() []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range []string{"0.0.0.0/8", "100.64.0.0/10", "198.18.0.0/15"} {
+		_, n, _ := net.ParseCIDR(cidr)
+		nets = append(nets, n)
+	}
+	return nets
+}()
+
+// privateIP reports whether ip is loopback, private, link-local (which takes
+// in the 169.254.169.254 metadata address), unspecified or one of the
+// privateRanges.
+func privateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, n := range privateRanges {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivateHost reports whether host, an IP literal (bracketed or not) or a
+// host name, points at a private address. A host name counts as private when
+// any address it resolves to is; the lookup gives up after
+// hostLookupTimeout, and a host that can't be resolved is an error.
+func IsPrivateHost(host string) (bool, error) {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		// a zone only appears on link-local IPv6 addresses
+		host = host[:i]
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return privateIP(ip), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hostLookupTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return false, err
+	}
+	for _, addr := range addrs {
+		if privateIP(addr.IP) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// CheckPrivateHost rejects a destination whose host is private, per
+// IsPrivateHost, when BLOCK_PRIVATE_HOSTS is set, so links can't be used to
+// steer previews and other fetches at internal services. Hosts that don't
+// resolve are rejected too, since where they'd point can't be checked.
+func CheckPrivateHost(rawURL string) error {
+	if !config.Bool("BLOCK_PRIVATE_HOSTS", false) {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil
+	}
+	host := u.Hostname()
+	private, err := IsPrivateHost(host)
+	if err != nil {
+		return fmt.Errorf("cannot resolve destination host %q", host)
+	}
+	if private {
+		return ErrPrivateHost
+	}
+	return nil
+}
+
+// maxFetchRedirects is how many redirects a PublicClient follows.
+const maxFetchRedirects = 5
+
+// PublicClient returns an HTTP client for fetching destinations from the
+// server, which can't be pointed at internal services whatever
+// BLOCK_PRIVATE_HOSTS says: it refuses to connect to a private address, as
+// privateIP sees it, at dial time, so a host name that resolves elsewhere
+// after the link was made is caught too, and refuses redirects to private
+// hosts. Requests give up after timeout, or only when their context is done if
+// it's 0, and response headers are capped.
+func PublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: dialPublic}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// a proxy would do the dialing, and could reach what we can't
+			Proxy:                  nil,
+			DialContext:            dialer.DialContext,
+			TLSHandshakeTimeout:    timeout,
+			MaxResponseHeaderBytes: 64 << 10,
+			IdleConnTimeout:        90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+			}
+			if private, err := IsPrivateHost(req.URL.Hostname()); err != nil || private {
+				return ErrPrivateHost
+			}
+			return nil
+		},
+	}
+}
+
+// dialPublic is a net.Dialer Control refusing connections to private
+// addresses. It sees the address being dialed, after any lookup.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || privateIP(ip) {
+		return ErrPrivateHost
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIsPrivateHost(t *testing.T) {
+	tests := []struct {
+		host    string
+		private bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"[::1]", true},
+		{"10.1.2.3", true},
+		{"172.16.0.1", true},
+		{"192.168.1.1", true},
+		{"169.254.169.254", true},
+		{"fe80::1%eth0", true},
+		{"100.64.0.1", true},
+		{"0.0.0.0", true},
+		{"198.18.0.1", true},
+		{"::ffff:127.0.0.1", true},
+		{"93.184.216.34", false},
+		{"2606:4700::1111", false},
+	}
+	for _, tt := range tests {
+		got, err := IsPrivateHost(tt.host)
+		if err != nil {
+			t.Errorf("IsPrivateHost(%q): %v", tt.host, err)
+		} else if got != tt.private {
+			t.Errorf("IsPrivateHost(%q) = %v, want %v", tt.host, got, tt.private)
+		}
+	}
+}
+
+func TestIsPrivateHostNames(t *testing.T) {
+	// localhost is in /etc/hosts, so this needs no DNS
+	if got, err := IsPrivateHost("localhost"); err != nil || !got {
+		t.Errorf("IsPrivateHost(localhost) = %v, %v, want private", got, err)
+	}
+	// .invalid names never resolve
+	if _, err := IsPrivateHost("nowhere.invalid"); err == nil {
+		t.Error("IsPrivateHost(nowhere.invalid): no error for a host that doesn't resolve")
+	}
+}
+
+func TestCheckPrivateHost(t *testing.T) {
+	tests := []struct {
+		block, url string
+		want       error
+	}{
+		{"", "http://169.254.169.254/latest/meta-data/", nil},
+		{"true", "http://169.254.169.254/latest/meta-data/", ErrPrivateHost},
+		{"true", "http://user@127.0.0.1:8080/admin", ErrPrivateHost},
+		{"true", "https://[fd00::1]/", ErrPrivateHost},
+		{"true", "http://localhost/", ErrPrivateHost},
+		{"true", "https://93.184.216.34/", nil},
+	}
+	for _, tt := range tests {
+		t.Setenv("BLOCK_PRIVATE_HOSTS", tt.block)
+		if err := CheckPrivateHost(tt.url); err != tt.want {
+			t.Errorf("BLOCK_PRIVATE_HOSTS=%q: CheckPrivateHost(%q) = %v, want %v", tt.block, tt.url, err, tt.want)
+		}
+	}
+	t.Setenv("BLOCK_PRIVATE_HOSTS", "true")
+	if err := CheckPrivateHost("https://nowhere.invalid/"); err == nil || errors.Is(err, ErrPrivateHost) {
+		t.Errorf("CheckPrivateHost of an unresolvable host = %v, want a resolve error", err)
+	}
+}
+
+func TestPublicClientRefusesPrivate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<title>internal</title>")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"loopback", srv.URL},
+		{"metadata", "http://169.254.169.254/latest/meta-data/"},
+		{"private", "http://10.0.0.1/"},
+	}
+	client := PublicClient(time.Second)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.Get(tt.url)
+			if err == nil {
+				resp.Body.Close()
+				t.Fatalf("GET %s succeeded", tt.url)
+			}
+			if !errors.Is(err, ErrPrivateHost) {
+				t.Errorf("GET %s: err = %v, want %v", tt.url, err, ErrPrivateHost)
+			}
+		})
+	}
+}
+
+func TestPublicClientRefusesPrivateRedirects(t *testing.T) {
+	client := PublicClient(time.Second)
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/", nil)
+	if err := client.CheckRedirect(req, []*http.Request{{}}); !errors.Is(err, ErrPrivateHost) {
+		t.Errorf("redirect to loopback: err = %v, want %v", err, ErrPrivateHost)
+	}
+	req, _ = http.NewRequest(http.MethodGet, "http://93.184.216.34/", nil)
+	if err := client.CheckRedirect(req, []*http.Request{{}}); err != nil {
+		t.Errorf("redirect to a public address: %v", err)
+	}
+	if err := client.CheckRedirect(req, make([]*http.Request, maxFetchRedirects)); err == nil {
+		t.Errorf("redirect %d wasn't refused", maxFetchRedirects+1)
+	}
+}
@@ -0,0 +1,189 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrImportFormat is returned by ParseImport for a format it doesn't know.
+var ErrImportFormat = errors.New("format must be generic, bitly or tinyurl")
+
+// ImportedLink is one link read from an export of this or another
+// shortener.
+type ImportedLink struct {
+	URL string
+	// Short is the link's code in the export, "" when it has none.
+	Short     string
+	Note      string
+	Tags      []string
+	CreatedAt time.Time
+}
+
+// importColumns are the CSV columns, or JSON keys, each format keeps a
+// link's fields under, lowercased with spaces as underscores. The first one
+// present wins.
+var importColumns = map[string]map[string][]string{
+	"generic": {
+		"url":        {"url"},
+		"short":      {"short", "code"},
+		"note":       {"note", "title"},
+		"tags":       {"tags"},
+		"created_at": {"created_at"},
+	},
+	// Bitly's CSV export and its v4 API's link listings
+	"bitly": {
+		"url":        {"long_url"},
+		"short":      {"link", "bitlink", "id"},
+		"note":       {"title"},
+		"tags":       {"tags"},
+		"created_at": {"created_at", "created"},
+	},
+	// TinyURL's CSV export and its API's link listings
+	"tinyurl": {
+		"url":        {"url", "long_url"},
+		"short":      {"alias", "tiny_url", "tinyurl"},
+		"tags":       {"tags"},
+		"created_at": {"created_at", "created"},
+	},
+}
+
+// importTimeLayouts are the timestamp formats exports are seen to use.
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseImport reads the links in an export in format: CSV with a header row,
+// or JSON, either an array of link objects or an object listing them under
+// "links" (Bitly) or "data" (TinyURL). Rows are returned in order and as they
+// are; checking their URLs and codes is up to the caller.
+func ParseImport(format string, body []byte) ([]ImportedLink, error) {
+	columns, ok := importColumns[format]
+	if !ok {
+		return nil, ErrImportFormat
+	}
+
+	var records []map[string]string
+	var err error
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		records, err = jsonRecords(trimmed)
+	} else {
+		records, err = csvRecords(body)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	links := make([]ImportedLink, 0, len(records))
+	for _, rec := range records {
+		field := func(name string) string {
+			for _, col := range columns[name] {
+				if v := strings.TrimSpace(rec[col]); v != "" {
+					return v
+				}
+			}
+			return ""
+		}
+		link := ImportedLink{
+			URL:   field("url"),
+			Short: importedCode(field("short")),
+			Note:  field("note"),
+		}
+		if tags := field("tags"); tags != "" {
+			link.Tags = strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ';' || r == '|' })
+		}
+		if created := field("created_at"); created != "" {
+			for _, layout := range importTimeLayouts {
+				if t, err := time.Parse(layout, created); err == nil {
+					link.CreatedAt = t
+					break
+				}
+			}
+		}
+		links = append(links, link)
+	}
+	return links, nil
+}
+
+// importedCode is the code of a short link given as a whole link, such as
+// bit.ly/abc or https://tinyurl.com/abc, or as the code alone.
+func importedCode(short string) string {
+	short = strings.TrimRight(short, "/")
+	if i := strings.LastIndex(short, "/"); i >= 0 {
+		return short[i+1:]
+	}
+	return short
+}
+
+// importColumn is how a CSV header or JSON key is matched to importColumns.
+func importColumn(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), " ", "_")
+}
+
+func csvRecords(body []byte) ([]map[string]string, error) {
+	rows, err := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(body, []byte("\xef\xbb\xbf")))).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse CSV: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	header := rows[0]
+	records := make([]map[string]string, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		rec := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(row) {
+				rec[importColumn(name)] = row[i]
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+func jsonRecords(body []byte) ([]map[string]string, error) {
+	var items []map[string]interface{}
+	if body[0] == '{' {
+		var listing struct {
+			Links []map[string]interface{} `json:"links"`
+			Data  []map[string]interface{} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &listing); err != nil {
+			return nil, errors.New("cannot parse JSON")
+		}
+		items = append(listing.Links, listing.Data...)
+	} else if err := json.Unmarshal(body, &items); err != nil {
+		return nil, errors.New("cannot parse JSON")
+	}
+
+	records := make([]map[string]string, 0, len(items))
+	for _, item := range items {
+		rec := make(map[string]string, len(item))
+		for k, v := range item {
+			switch v := v.(type) {
+			case string:
+				rec[importColumn(k)] = v
+			case []interface{}:
+				// tags come as an array of strings
+				var parts []string
+				for _, p := range v {
+					if s, ok := p.(string); ok {
+						parts = append(parts, s)
+					}
+				}
+				rec[importColumn(k)] = strings.Join(parts, ",")
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
@@ -0,0 +1,81 @@
+package helpers
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestParseImport(t *testing.T) {
+	created := time.Date(2023, 5, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name, format, body string
+		want               []ImportedLink
+	}{
+		{"generic CSV", "generic",
+			"url,short,note,tags,created_at\n" +
+				"https://example.com/a,promo,Spring sale,\"sale,spring\",2023-05-01T12:30:00Z\n" +
+				"https://example.com/b,,,,\n",
+			[]ImportedLink{
+				{URL: "https://example.com/a", Short: "promo", Note: "Spring sale", Tags: []string{"sale", "spring"}, CreatedAt: created},
+				{URL: "https://example.com/b"},
+			}},
+		{"generic JSON", "generic",
+			`[{"url":"https://example.com/a","code":"promo","title":"Spring sale","tags":["sale","spring"],"created_at":"2023-05-01"}]`,
+			[]ImportedLink{
+				{URL: "https://example.com/a", Short: "promo", Note: "Spring sale", Tags: []string{"sale", "spring"}, CreatedAt: time.Date(2023, 5, 1, 0, 0, 0, 0, time.UTC)},
+			}},
+		{"Bitly CSV", "bitly",
+			"\xef\xbb\xbfTitle,Long URL,Bitlink,Created,Tags\n" +
+				"Spring sale,https://example.com/a,bit.ly/3xYzAb,2023-05-01 12:30:00,sale|spring\n",
+			[]ImportedLink{
+				{URL: "https://example.com/a", Short: "3xYzAb", Note: "Spring sale", Tags: []string{"sale", "spring"}, CreatedAt: created},
+			}},
+		{"Bitly API listing", "bitly",
+			`{"links":[{"id":"bit.ly/3xYzAb","link":"https://bit.ly/3xYzAb","long_url":"https://example.com/a","title":"Spring sale","tags":["sale"],"created_at":"2023-05-01T12:30:00+0000"}],"pagination":{}}`,
+			[]ImportedLink{
+				{URL: "https://example.com/a", Short: "3xYzAb", Note: "Spring sale", Tags: []string{"sale"}, CreatedAt: created},
+			}},
+		{"TinyURL CSV", "tinyurl",
+			"Alias,URL,Tiny URL,Created At\n" +
+				"spring-sale,https://example.com/a,https://tinyurl.com/spring-sale,2023-05-01 12:30:00\n" +
+				",https://example.com/b,https://tinyurl.com/2p8abcd/,\n",
+			[]ImportedLink{
+				{URL: "https://example.com/a", Short: "spring-sale", CreatedAt: created},
+				{URL: "https://example.com/b", Short: "2p8abcd"},
+			}},
+		{"TinyURL API listing", "tinyurl",
+			`{"data":[{"alias":"spring-sale","url":"https://example.com/a","tiny_url":"https://tinyurl.com/spring-sale","tags":["sale"],"created_at":"2023-05-01T12:30:00+00:00"}],"code":0}`,
+			[]ImportedLink{
+				{URL: "https://example.com/a", Short: "spring-sale", Tags: []string{"sale"}, CreatedAt: created},
+			}},
+		{"header only", "generic", "url,short\n", []ImportedLink{}},
+	}
+	for _, tt := range tests {
+		got, err := ParseImport(tt.format, []byte(tt.body))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: %d links, want %d: %+v", tt.name, len(got), len(tt.want), got)
+			continue
+		}
+		for i := range got {
+			g, w := got[i], tt.want[i]
+			if g.URL != w.URL || g.Short != w.Short || g.Note != w.Note || fmt.Sprint(g.Tags) != fmt.Sprint(w.Tags) || !g.CreatedAt.Equal(w.CreatedAt) {
+				t.Errorf("%s: link %d = %+v, want %+v", tt.name, i, g, w)
+			}
+		}
+	}
+
+	for _, tt := range []struct{ name, format, body string }{
+		{"unknown format", "rebrandly", "url\nhttps://example.com/\n"},
+		{"bad JSON", "generic", `[{"url":`},
+		{"bad CSV", "generic", "url,short\n\"https://example.com/,a\n"},
+	} {
+		if _, err := ParseImport(tt.format, []byte(tt.body)); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
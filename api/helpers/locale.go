@@ -0,0 +1,78 @@
+package helpers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BestLocale picks the entry of available that best matches an
+// Accept-Language header. Languages are tried from the highest quality value
+// down, and a regional tag such as de-AT also matches a plain de. Matching is
+// case-insensitive; it returns "" when nothing matches.
+func BestLocale(header string, available []string) string {
+	type pref struct {
+		tag string
+		q   float64
+	}
+
+	var prefs []pref
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > 0 {
+			prefs = append(prefs, pref{tag, q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	find := func(tag string) string {
+		for _, a := range available {
+			if strings.EqualFold(a, tag) {
+				return a
+			}
+		}
+		return ""
+	}
+	for _, p := range prefs {
+		if match := find(p.tag); match != "" {
+			return match
+		}
+		if base, _, ok := strings.Cut(p.tag, "-"); ok {
+			if match := find(base); match != "" {
+				return match
+			}
+		}
+	}
+	return ""
+}
+
+// RegionLocale picks the entry of available whose region subtag is country,
+// e.g. de-AT for a visitor in AT, for visitors whose Accept-Language matched
+// nothing. Matching is case-insensitive and the first such tag in
+// alphabetical order wins; it returns "" when none has that region.
+func RegionLocale(country string, available []string) string {
+	sorted := append([]string(nil), available...)
+	sort.Strings(sorted)
+	for _, tag := range sorted {
+		parts := strings.Split(tag, "-")
+		if len(parts) < 2 {
+			continue
+		}
+		if region := parts[len(parts)-1]; len(region) == 2 && strings.EqualFold(region, country) {
+			return tag
+		}
+	}
+	return ""
+}
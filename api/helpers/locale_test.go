@@ -0,0 +1,55 @@
+package helpers
+
+import "testing"
+
+func TestBestLocale(t *testing.T) {
+	available := []string{"en", "de", "fr-ca", "pt-BR"}
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"de", "de"},
+		{"DE", "de"},
+		{"fr;q=0.5, de;q=0.9, en;q=0.7", "de"},
+		{"es, en;q=0.1", "en"},
+		{"en;q=0.5, de", "de"},
+		{"de-AT", "de"},
+		{"de-AT, en;q=0.9", "de"},
+		{"fr-CA", "fr-ca"},
+		{"pt-br", "pt-BR"},
+		// the region a tag is for only matches that region
+		{"fr", ""},
+		{"fr-FR", ""},
+		{"es, it", ""},
+		{"*", ""},
+		{"de;q=0, en;q=0.1", "en"},
+		{"de;q=bad, en;q=0.2", "en"},
+		{"en;q=0.8, de;q=0.8", "en"},
+		{" de-AT ; q=0.9 ,en;q=0.3", "de"},
+	}
+	for _, tt := range tests {
+		if got := BestLocale(tt.header, available); got != tt.want {
+			t.Errorf("BestLocale(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRegionLocale(t *testing.T) {
+	available := []string{"en", "de-CH", "de-AT", "fr-ch", "zh-Hant-TW"}
+	tests := []struct {
+		country string
+		want    string
+	}{
+		{"AT", "de-AT"},
+		{"ch", "de-CH"},
+		{"TW", "zh-Hant-TW"},
+		{"US", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := RegionLocale(tt.country, available); got != tt.want {
+			t.Errorf("RegionLocale(%q) = %q, want %q", tt.country, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// CamelCaseJSON reports whether responses use camelCase field names, with
+// JSON_NAMING=camel, instead of the default snake_case.
+func CamelCaseJSON() bool {
+	return config.String("JSON_NAMING", "snake") == "camel"
+}
+
+// MarshalJSON encodes v like json.Marshal, with the field naming JSON_NAMING
+// asks for. It's the app's JSON encoder, so it applies to every c.JSON.
+func MarshalJSON(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil || !CamelCaseJSON() {
+		return b, err
+	}
+	return CamelCaseKeys(b)
+}
+
+// jsonLevel is an object or array CamelCaseKeys is inside of.
+type jsonLevel struct {
+	object bool
+	// first is set until the level's first member is written; key is set
+	// while an object expects a key rather than a value.
+	first, key bool
+}
+
+// CamelCaseKeys rewrites every object key in the JSON document b from
+// snake_case to camelCase, so short_url becomes shortUrl. Key order and
+// values are kept as they are.
+func CamelCaseKeys(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var out bytes.Buffer
+	var stack []jsonLevel
+	// next writes the separator before a key or value and reports whether
+	// it's a key
+	next := func() bool {
+		if len(stack) == 0 {
+			return false
+		}
+		top := &stack[len(stack)-1]
+		if top.object && !top.key {
+			out.WriteByte(':')
+			top.key = true
+			return false
+		}
+		if !top.first {
+			out.WriteByte(',')
+		}
+		top.first = false
+		if top.object {
+			top.key = false
+			return true
+		}
+		return false
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF && len(stack) > 0 {
+			// the decoder's tokens don't catch a document cut off mid-way
+			return nil, io.ErrUnexpectedEOF
+		} else if err == io.EOF {
+			return out.Bytes(), nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case json.Delim:
+			if tok == '}' || tok == ']' {
+				stack = stack[:len(stack)-1]
+			} else {
+				next()
+				stack = append(stack, jsonLevel{object: tok == '{', first: true, key: true})
+			}
+			out.WriteString(tok.String())
+		case string:
+			if next() {
+				tok = CamelCase(tok)
+			}
+			s, _ := json.Marshal(tok)
+			out.Write(s)
+		case json.Number:
+			next()
+			out.WriteString(tok.String())
+		case bool:
+			next()
+			if tok {
+				out.WriteString("true")
+			} else {
+				out.WriteString("false")
+			}
+		case nil:
+			next()
+			out.WriteString("null")
+		}
+	}
+}
+
+// CamelCase turns a snake_case name into camelCase.
+func CamelCase(name string) string {
+	if !strings.Contains(name, "_") {
+		return name
+	}
+	parts := strings.Split(name, "_")
+	var b strings.Builder
+	b.WriteString(parts[0])
+	for _, p := range parts[1:] {
+		if p != "" {
+			b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package helpers
+
+import "testing"
+
+func TestCamelCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"short":                  "short",
+		"short_url":              "shortUrl",
+		"rate_limit_reset":       "rateLimitReset",
+		"x_rate_remaining":       "xRateRemaining",
+		"trailing_":              "trailing",
+		"double__underscore":     "doubleUnderscore",
+		"alreadyCamel":           "alreadyCamel",
+		"max_clicks_per_visitor": "maxClicksPerVisitor",
+	} {
+		if got := CamelCase(name); got != want {
+			t.Errorf("CamelCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCamelCaseKeys(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"flat", `{"short_url":"http://sho.rt/a","max_clicks":3}`, `{"shortUrl":"http://sho.rt/a","maxClicks":3}`},
+		{"values kept", `{"note":"snake_case stays","big":12345678901234567890,"ok":true,"gone":null}`,
+			`{"note":"snake_case stays","big":12345678901234567890,"ok":true,"gone":null}`},
+		{"nested", `{"data":[{"created_at":"x","tags":["a_b"]},{"edit_token":"t"}],"next_cursor":""}`,
+			`{"data":[{"createdAt":"x","tags":["a_b"]},{"editToken":"t"}],"nextCursor":""}`},
+		{"empty", `{"empty_obj":{},"empty_arr":[]}`, `{"emptyObj":{},"emptyArr":[]}`},
+		{"array at the top", `[{"short_url":1},2]`, `[{"shortUrl":1},2]`},
+	}
+	for _, tt := range tests {
+		got, err := CamelCaseKeys([]byte(tt.in))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: CamelCaseKeys = %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+	if _, err := CamelCaseKeys([]byte(`{"short_url":`)); err == nil {
+		t.Error("CamelCaseKeys accepted truncated JSON")
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	v := map[string]interface{}{"short_url": "http://sho.rt/a"}
+	for naming, want := range map[string]string{
+		"":      `{"short_url":"http://sho.rt/a"}`,
+		"snake": `{"short_url":"http://sho.rt/a"}`,
+		"camel": `{"shortUrl":"http://sho.rt/a"}`,
+	} {
+		t.Setenv("JSON_NAMING", naming)
+		if got, err := MarshalJSON(v); err != nil || string(got) != want {
+			t.Errorf("JSON_NAMING=%q: MarshalJSON = %s, %v, want %s", naming, got, err, want)
+		}
+	}
+}
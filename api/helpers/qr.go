@@ -0,0 +1,504 @@
+package helpers
+
+import (
+	"errors"
+	"image"
+	"image/color"
+)
+
+// ErrQRTooLong is returned by EncodeQR for text that doesn't fit the largest
+// QR version it makes.
+var ErrQRTooLong = errors.New("text is too long for a QR code")
+
+// QR codes are made at error correction level M, which survives about 15%
+// of the symbol being damaged, in versions 1 to 10: up to 213 bytes, plenty
+// for a short URL.
+const qrMaxVersion = 10
+
+// qrBlocks and qrECLen are, for each version from 1, how many error
+// correction blocks a level M symbol is split into and how many error
+// correction codewords each block gets.
+var (
+	qrBlocks = [qrMaxVersion + 1]int{0, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5}
+	qrECLen  = [qrMaxVersion + 1]int{0, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26}
+)
+
+// QRCode is an encoded QR symbol, one bool per module with true for dark.
+type QRCode struct {
+	Size    int
+	modules [][]bool
+}
+
+// Dark reports whether the module at column x, row y is dark.
+func (q *QRCode) Dark(x, y int) bool {
+	return q.modules[y][x]
+}
+
+// Image renders q with each module scale pixels square and the four module
+// quiet zone scanners need around it.
+func (q *QRCode) Image(scale int) image.Image {
+	const quiet = 4
+	side := (q.Size + 2*quiet) * scale
+	img := image.NewPaletted(image.Rect(0, 0, side, side), color.Palette{color.White, color.Black})
+	for y := 0; y < q.Size; y++ {
+		for x := 0; x < q.Size; x++ {
+			if !q.modules[y][x] {
+				continue
+			}
+			for dy := 0; dy < scale; dy++ {
+				row := img.Pix[((y+quiet)*scale+dy)*img.Stride:]
+				for dx := 0; dx < scale; dx++ {
+					row[(x+quiet)*scale+dx] = 1
+				}
+			}
+		}
+	}
+	return img
+}
+
+// EncodeQR encodes text in byte mode as the smallest QR code that holds it,
+// with whichever mask scores best by the standard's penalty rules.
+func EncodeQR(text string) (*QRCode, error) {
+	data := []byte(text)
+	version := 0
+	for v := 1; v <= qrMaxVersion; v++ {
+		// mode indicator, 8 bit length (16 from version 10), then the bytes
+		lenBits := 8
+		if v >= 10 {
+			lenBits = 16
+		}
+		if 4+lenBits+8*len(data) <= 8*qrDataCodewords(v) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, ErrQRTooLong
+	}
+
+	var bits qrBits
+	bits.append(0x4, 4)
+	if version >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := 8 * qrDataCodewords(version)
+	terminator := capacity - len(bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+	bits.append(0, terminator)
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	q := newQRSymbol(version)
+	q.drawCodewords(qrInterleave(version, bits.bytes()))
+
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		// masking twice undoes it
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return &QRCode{Size: q.size, modules: q.modules}, nil
+}
+
+type qrBits []bool
+
+func (b *qrBits) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 == 1)
+	}
+}
+
+func (b qrBits) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return out
+}
+
+// qrRawCodewords is how many codewords fit in a version's symbol once its
+// function patterns are drawn.
+func qrRawCodewords(version int) int {
+	bits := (16*version+128)*version + 64
+	if version >= 2 {
+		align := version/7 + 2
+		bits -= (25*align-10)*align - 55
+		if version >= 7 {
+			bits -= 36
+		}
+	}
+	return bits / 8
+}
+
+// qrDataCodewords is how many of a version's codewords carry data rather
+// than error correction.
+func qrDataCodewords(version int) int {
+	return qrRawCodewords(version) - qrBlocks[version]*qrECLen[version]
+}
+
+// qrInterleave splits data into the version's blocks, adds each block's
+// error correction and interleaves them in the order they're placed.
+func qrInterleave(version int, data []byte) []byte {
+	numBlocks, ecLen := qrBlocks[version], qrECLen[version]
+	raw := qrRawCodewords(version)
+	// the last raw%numBlocks blocks are one data codeword longer
+	numShort := numBlocks - raw%numBlocks
+	shortLen := raw/numBlocks - ecLen
+
+	divisor := qrDivisor(ecLen)
+	var blocks, ecs [][]byte
+	for i, off := 0, 0; i < numBlocks; i++ {
+		n := shortLen
+		if i >= numShort {
+			n++
+		}
+		block := data[off : off+n]
+		off += n
+		blocks = append(blocks, block)
+		ecs = append(ecs, qrRemainder(block, divisor))
+	}
+
+	out := make([]byte, 0, raw)
+	for i := 0; i <= shortLen; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < ecLen; i++ {
+		for _, ec := range ecs {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// qrMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func qrMultiply(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// qrDivisor is the Reed-Solomon generator polynomial of the given degree,
+// highest coefficient first, leaving out the leading 1.
+func qrDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = qrMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = qrMultiply(root, 2)
+	}
+	return result
+}
+
+// qrRemainder is the error correction of data: its remainder divided by
+// divisor.
+func qrRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= qrMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// qrSymbol is a QR code being built. function marks the modules of finder,
+// timing, alignment, format and version patterns, which data and masks skip.
+type qrSymbol struct {
+	version, size int
+	modules       [][]bool
+	function      [][]bool
+}
+
+func newQRSymbol(version int) *qrSymbol {
+	size := 4*version + 17
+	q := &qrSymbol{version: version, size: size}
+	q.modules = make([][]bool, size)
+	q.function = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.function[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	q.drawFinder(3, 3)
+	q.drawFinder(size-4, 3)
+	q.drawFinder(3, size-4)
+
+	align := qrAlignment(version)
+	for i, x := range align {
+		for j, y := range align {
+			// the corners with finder patterns
+			if i == 0 && j == 0 || i == 0 && j == len(align)-1 || i == len(align)-1 && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format areas; drawFormat fills them in per mask
+	q.drawFormat(0)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := bits>>i&1 == 1
+			a, b := size-11+i%3, i/3
+			q.set(a, b, dark)
+			q.set(b, a, dark)
+		}
+	}
+	return q
+}
+
+// qrAlignment is the row and column centers of a version's alignment
+// patterns.
+func qrAlignment(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	size := 4*version + 17
+	step := (version*4 + count*2 + 1) / (count*2 - 2) * 2
+	pos := make([]int, count)
+	pos[0] = 6
+	for i := count - 1; i > 0; i-- {
+		pos[i] = size - 7 - (count-1-i)*step
+	}
+	return pos
+}
+
+// set draws a function module.
+func (q *qrSymbol) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.function[y][x] = true
+}
+
+func (q *qrSymbol) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || x >= q.size || y < 0 || y >= q.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			q.set(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawFormat writes the level M format information for mask, twice.
+func (q *qrSymbol) drawFormat(mask int) {
+	// level M's indicator is 00
+	data := mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// drawCodewords places data in the zigzag order: up and down two-column
+// strips from the right, skipping the vertical timing pattern.
+func (q *qrSymbol) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < q.size; vert++ {
+			y := vert
+			if upward {
+				y = q.size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if q.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				q.modules[y][x] = data[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask flips the data modules mask selects.
+func (q *qrSymbol) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.function[y][x] {
+				continue
+			}
+			var flip bool
+			switch mask {
+			case 0:
+				flip = (x+y)%2 == 0
+			case 1:
+				flip = y%2 == 0
+			case 2:
+				flip = x%3 == 0
+			case 3:
+				flip = (x+y)%3 == 0
+			case 4:
+				flip = (x/3+y/2)%2 == 0
+			case 5:
+				flip = x*y%2+x*y%3 == 0
+			case 6:
+				flip = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				flip = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if flip {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol by the standard's four rules: long runs of one
+// color, 2x2 blocks, finder-like patterns and an unbalanced dark ratio.
+func (q *qrSymbol) penalty() int {
+	n := q.size
+	score := 0
+	line := make([]bool, n)
+	for pass := 0; pass < 2; pass++ {
+		for a := 0; a < n; a++ {
+			for b := 0; b < n; b++ {
+				if pass == 0 {
+					line[b] = q.modules[a][b]
+				} else {
+					line[b] = q.modules[b][a]
+				}
+			}
+			score += qrLinePenalty(line)
+		}
+	}
+
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			c := q.modules[y][x]
+			if c {
+				dark++
+			}
+			if x+1 < n && y+1 < n && c == q.modules[y][x+1] && c == q.modules[y+1][x] && c == q.modules[y+1][x+1] {
+				score += 3
+			}
+		}
+	}
+	total := n * n
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return score + k*10
+}
+
+// qrFinderLike is the 1:1:3:1:1 pattern, with four light modules on one
+// side, that rule 3 penalizes.
+var qrFinderLike = []bool{true, false, true, true, true, false, true}
+
+func qrLinePenalty(line []bool) int {
+	score := 0
+	run := 1
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+			continue
+		}
+		if run >= 5 {
+			score += 3 + run - 5
+		}
+		run = 1
+	}
+
+	light := func(from, to int) bool {
+		for i := from; i < to; i++ {
+			// outside the symbol is the light quiet zone
+			if i >= 0 && i < len(line) && line[i] {
+				return false
+			}
+		}
+		return true
+	}
+	for i := 0; i+len(qrFinderLike) <= len(line); i++ {
+		match := true
+		for j, dark := range qrFinderLike {
+			if line[i+j] != dark {
+				match = false
+				break
+			}
+		}
+		if match && (light(i-4, i) || light(i+7, i+11)) {
+			score += 40
+		}
+	}
+	return score
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeQR(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		size int
+		err  error
+	}{
+		{"empty", "", 21, nil},
+		{"version 1 full", strings.Repeat("a", 14), 21, nil},
+		{"version 2", strings.Repeat("a", 15), 25, nil},
+		{"short URL", "https://sho.rt/abc123", 25, nil},
+		{"version 10 full", strings.Repeat("a", 213), 57, nil},
+		{"too long", strings.Repeat("a", 214), 0, ErrQRTooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qr, err := EncodeQR(tt.text)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if qr.Size != tt.size {
+				t.Errorf("Size = %d, want %d", qr.Size, tt.size)
+			}
+			// the finder patterns' corners, and the dark module beside the
+			// bottom left one, are always dark
+			for _, at := range [][2]int{{0, 0}, {qr.Size - 1, 0}, {0, qr.Size - 1}, {8, qr.Size - 8}} {
+				if !qr.Dark(at[0], at[1]) {
+					t.Errorf("module %v is light", at)
+				}
+			}
+			if qr.Dark(7, 7) {
+				t.Error("the separator at (7, 7) is dark")
+			}
+			if got := qr.Image(2).Bounds().Dx(); got != (qr.Size+8)*2 {
+				t.Errorf("image is %d pixels wide, want %d", got, (qr.Size+8)*2)
+			}
+		})
+	}
+}
+
+func TestQRRemainder(t *testing.T) {
+	// "HELLO WORLD" as a version 1-M symbol
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := qrRemainder(data, qrDivisor(len(want))); !bytes.Equal(got, want) {
+		t.Errorf("error correction = %v, want %v", got, want)
+	}
+}
+
+func TestQRAlignment(t *testing.T) {
+	tests := []struct {
+		version int
+		want    []int
+	}{
+		{1, nil},
+		{2, []int{6, 18}},
+		{6, []int{6, 34}},
+		{7, []int{6, 22, 38}},
+		{10, []int{6, 28, 50}},
+	}
+	for _, tt := range tests {
+		got := qrAlignment(tt.version)
+		if len(got) != len(tt.want) {
+			t.Errorf("version %d: %v, want %v", tt.version, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("version %d: %v, want %v", tt.version, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package helpers
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// Rules for custom shorts. They are enforced by ShortenURL and published by
+// GET /api/v1/rules so clients can validate with the same constraints.
+//
+// A custom short may be path-style ("docs/getting-started"): each
+// "/"-separated segment must use the charset and be at most CustomShortMaxLen
+// long, and the whole code at most CustomShortMaxPathLen.
+const (
+	CustomShortCharset    = "A-Za-z0-9_-"
+	CustomShortMinLen     = 3
+	CustomShortMaxLen     = 32
+	CustomShortSeparator  = "/"
+	CustomShortMaxPathLen = 128
+)
+
+// CustomShortMinLength is the shortest custom short allowed: MIN_CUSTOM_SHORT_LEN,
+// or CustomShortMinLen when unset. It protects the namespace of very short
+// codes and doesn't apply to generated codes.
+func CustomShortMinLength() int {
+	n := config.Int("MIN_CUSTOM_SHORT_LEN", CustomShortMinLen)
+	if n < 1 {
+		return 1
+	}
+	if n > CustomShortMaxPathLen {
+		return CustomShortMaxPathLen
+	}
+	return n
+}
+
+// NormalizeCode turns the code in a request path into the form codes are
+// stored in. Percent-escapes are decoded exactly once, so %61bc is abc but a
+// double-encoded %2561bc stays %61bc and matches nothing; runs of slashes
+// collapse to one, leading and trailing slashes are dropped, and the code is
+// put in CanonicalCode form.
+func NormalizeCode(raw string) string {
+	code := raw
+	if decoded, err := url.PathUnescape(raw); err == nil {
+		code = decoded
+	}
+	for strings.Contains(code, "//") {
+		code = strings.ReplaceAll(code, "//", "/")
+	}
+	return CanonicalCode(strings.Trim(code, CustomShortSeparator))
+}
+
+// CanonicalCode is the form code is stored and looked up in: lowercased when
+// CASE_INSENSITIVE_SHORTS is on, so MyLink and mylink are the same code, and
+// unchanged otherwise. Generated codes and signatures are lowercase already.
+func CanonicalCode(code string) string {
+	if config.Bool("CASE_INSENSITIVE_SHORTS", false) {
+		return strings.ToLower(code)
+	}
+	return code
+}
+
+// defaultReserved are words that would collide with the service's own routes.
+var defaultReserved = []string{"api", "admin"}
+
+// ReservedShorts returns the built-in reserved words plus any listed in
+// RESERVED_SHORTS, lowercased and without duplicates.
+func ReservedShorts() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, w := range append(append([]string{}, defaultReserved...), config.List("RESERVED_SHORTS")...) {
+		w = strings.ToLower(w)
+		if !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// ValidateCustomShort reports why s can't be used as a custom short, if at all.
+func ValidateCustomShort(s string) error {
+	if minLen := CustomShortMinLength(); len(s) < minLen {
+		return fmt.Errorf("custom short must be at least %d characters", minLen)
+	}
+	if len(s) > CustomShortMaxPathLen {
+		return fmt.Errorf("custom short must be at most %d characters", CustomShortMaxPathLen)
+	}
+
+	segments := strings.Split(s, CustomShortSeparator)
+	for _, seg := range segments {
+		if seg == "" {
+			return fmt.Errorf("custom short can't have empty path segments")
+		}
+		if len(seg) > CustomShortMaxLen {
+			return fmt.Errorf("each custom short segment must be at most %d characters", CustomShortMaxLen)
+		}
+		for _, r := range seg {
+			if !isShortChar(r) {
+				return fmt.Errorf("custom short may only contain %s and %q between segments", CustomShortCharset, CustomShortSeparator)
+			}
+		}
+	}
+
+	// the first segment is what would collide with routes like /api/...
+	for _, w := range ReservedShorts() {
+		if strings.EqualFold(segments[0], w) {
+			return fmt.Errorf("custom short %q is reserved", segments[0])
+		}
+	}
+	return nil
+}
+
+// WellFormedCode reports whether code, in NormalizeCode form, could be a
+// short code at all. Its first segment must be a non-empty run of the custom
+// short charset no longer than the longest code, signature included; later
+// segments may be a path appended to an append_path link, so anything goes.
+func WellFormedCode(code string) bool {
+	first := code
+	if i := strings.Index(code, CustomShortSeparator); i >= 0 {
+		first = code[:i]
+	}
+	if first == "" || len(first) > CustomShortMaxLen+1+codeSignatureLen {
+		return false
+	}
+	for _, r := range first {
+		if !isShortChar(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateNamespace reports why ns can't be an API key's short-code
+// namespace. A namespace becomes the first segment of its codes, so it follows
+// the rules for one segment of a custom short and can't be a reserved word.
+func ValidateNamespace(ns string) error {
+	if ns == "" || len(ns) > CustomShortMaxLen {
+		return fmt.Errorf("namespace must be between 1 and %d characters", CustomShortMaxLen)
+	}
+	for _, r := range ns {
+		if !isShortChar(r) {
+			return fmt.Errorf("namespace may only contain %s", CustomShortCharset)
+		}
+	}
+	for _, w := range ReservedShorts() {
+		if strings.EqualFold(ns, w) {
+			return fmt.Errorf("namespace %q is reserved", ns)
+		}
+	}
+	return nil
+}
+
+// Namespaced puts code in namespace ns, as "ns/code". Codes in the default
+// namespace, ns "", are returned unchanged.
+func Namespaced(ns, code string) string {
+	if ns == "" {
+		return code
+	}
+	return ns + CustomShortSeparator + code
+}
+
+// IsPremiumShort reports whether s matches one of the PREMIUM_SHORTS glob
+// patterns (e.g. "?", "??" or "brand-*"), case-insensitively. Premium shorts
+// can only be claimed by admins and API keys with the premium scope.
+func IsPremiumShort(s string) bool {
+	s = strings.ToLower(s)
+	for _, pattern := range config.List("PREMIUM_SHORTS") {
+		if ok, _ := path.Match(strings.ToLower(pattern), s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateShortPrefix reports why p can't be used to match custom shorts by
+// prefix. A prefix uses the same characters as a short, including the
+// separator, so it can never contain glob metacharacters.
+func ValidateShortPrefix(p string) error {
+	if p == "" || len(p) > CustomShortMaxPathLen {
+		return fmt.Errorf("prefix must be between 1 and %d characters", CustomShortMaxPathLen)
+	}
+	for _, r := range p {
+		if !isShortChar(r) && string(r) != CustomShortSeparator {
+			return fmt.Errorf("prefix may only contain %s and %q", CustomShortCharset, CustomShortSeparator)
+		}
+	}
+	return nil
+}
+
+func isShortChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == '-'
+}
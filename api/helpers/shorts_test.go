@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCustomShortMinLength(t *testing.T) {
+	tests := []struct {
+		env   string
+		short string
+		ok    bool
+	}{
+		{"", "ab", false},
+		{"", "abc", true},
+		{"5", "abcd", false},
+		{"5", "abcde", true},
+		{"1", "a", true},
+		{"0", "a", true},
+		// segments count too, separators included
+		{"6", "ab/cd", false},
+		{"6", "ab/cde", true},
+		{"lots", "ab", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("MIN_CUSTOM_SHORT_LEN", tt.env)
+		err := ValidateCustomShort(tt.short)
+		if (err == nil) != tt.ok {
+			t.Errorf("MIN_CUSTOM_SHORT_LEN=%q: ValidateCustomShort(%q) = %v, want ok = %v", tt.env, tt.short, err, tt.ok)
+		}
+		if err != nil && !strings.Contains(err.Error(), "at least") {
+			t.Errorf("MIN_CUSTOM_SHORT_LEN=%q: error %q doesn't give the minimum", tt.env, err)
+		}
+	}
+
+	t.Setenv("MIN_CUSTOM_SHORT_LEN", "500")
+	if got := CustomShortMinLength(); got != CustomShortMaxPathLen {
+		t.Errorf("MIN_CUSTOM_SHORT_LEN=500: CustomShortMinLength() = %d, want %d", got, CustomShortMaxPathLen)
+	}
+}
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"abc", "abc"},
+		{"abc/", "abc"},
+		{"%61bc", "abc"},
+		{"%2561bc", "%61bc"},
+		{"docs//api", "docs/api"},
+		{"/docs///api/", "docs/api"},
+		{"docs%2Fapi", "docs/api"},
+		{"%2F%2Fabc", "abc"},
+		// an invalid escape is left as it is
+		{"ab%zzc", "ab%zzc"},
+	}
+	for _, tt := range tests {
+		if got := NormalizeCode(tt.in); got != tt.want {
+			t.Errorf("NormalizeCode(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	for _, ns := range []string{"team-a", "Team_B", "x"} {
+		if err := ValidateNamespace(ns); err != nil {
+			t.Errorf("ValidateNamespace(%q) = %v, want nil", ns, err)
+		}
+	}
+	for _, ns := range []string{"", "team/a", "team a", "API", strings.Repeat("n", CustomShortMaxLen+1)} {
+		if err := ValidateNamespace(ns); err == nil {
+			t.Errorf("ValidateNamespace(%q) = nil, want an error", ns)
+		}
+	}
+	if got := Namespaced("team-a", "promo"); got != "team-a/promo" {
+		t.Errorf("Namespaced(team-a, promo) = %q", got)
+	}
+	if got := Namespaced("", "promo"); got != "promo" {
+		t.Errorf("Namespaced(\"\", promo) = %q, want promo", got)
+	}
+}
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"strings"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// codeSignatureLen is how many characters of HMAC a signed code carries: 30
+// bits, so guessing a valid code takes around a billion tries per code.
+const codeSignatureLen = 6
+
+var signatureEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// SigningEnabled reports whether codes are signed, which they are when
+// CODE_SIGNING_KEY is set.
+func SigningEnabled() bool {
+	return config.String("CODE_SIGNING_KEY", "") != ""
+}
+
+// SignCode appends a check suffix to code, e.g. "abc123-x7fq2m", when code
+// signing is enabled. Otherwise it returns code unchanged.
+func SignCode(code string) string {
+	key := config.String("CODE_SIGNING_KEY", "")
+	if key == "" {
+		return code
+	}
+	return code + "-" + codeSignature(key, code)
+}
+
+// VerifyCode reports whether code carries a valid check suffix. Every code is
+// valid when signing is disabled.
+func VerifyCode(code string) bool {
+	key := config.String("CODE_SIGNING_KEY", "")
+	if key == "" {
+		return true
+	}
+	i := strings.LastIndex(code, "-")
+	if i <= 0 {
+		return false
+	}
+	return hmac.Equal([]byte(code[i+1:]), []byte(codeSignature(key, code[:i])))
+}
+
+func codeSignature(key, code string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(code))
+	return signatureEncoding.EncodeToString(mac.Sum(nil))[:codeSignatureLen]
+}
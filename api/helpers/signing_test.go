@@ -0,0 +1,59 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSignCodeDisabled(t *testing.T) {
+	t.Setenv("CODE_SIGNING_KEY", "")
+	if got := SignCode("abc123"); got != "abc123" {
+		t.Errorf("SignCode = %q, want the code unchanged", got)
+	}
+	if !VerifyCode("abc123") || !VerifyCode("abc123-zzzzzz") {
+		t.Error("VerifyCode refused a code with signing disabled")
+	}
+}
+
+func TestVerifyCode(t *testing.T) {
+	t.Setenv("CODE_SIGNING_KEY", "secret")
+	signed := SignCode("abc123")
+	sig := signed[strings.LastIndex(signed, "-")+1:]
+	if !strings.HasPrefix(signed, "abc123-") || len(sig) != codeSignatureLen {
+		t.Fatalf("SignCode = %q, want abc123 with a %d character suffix", signed, codeSignatureLen)
+	}
+	flipped := []byte(signed)
+	if flipped[len(flipped)-1] == 'a' {
+		flipped[len(flipped)-1] = 'b'
+	} else {
+		flipped[len(flipped)-1] = 'a'
+	}
+
+	tests := []struct {
+		name string
+		code string
+		ok   bool
+	}{
+		{"signed", signed, true},
+		{"hyphenated code", SignCode("my-promo"), true},
+		{"path-style code", SignCode("docs/start"), true},
+		{"unsigned", "abc123", false},
+		{"wrong signature", string(flipped), false},
+		{"truncated signature", signed[:len(signed)-1], false},
+		{"empty signature", "abc123-", false},
+		{"signature alone", "-" + sig, false},
+		{"extended signature", signed + "a", false},
+		{"another code's signature", "abc124-" + sig, false},
+		{"uppercased signature", "abc123-" + strings.ToUpper(sig), false},
+	}
+	for _, tt := range tests {
+		if got := VerifyCode(tt.code); got != tt.ok {
+			t.Errorf("%s: VerifyCode(%q) = %v, want %v", tt.name, tt.code, got, tt.ok)
+		}
+	}
+
+	t.Setenv("CODE_SIGNING_KEY", "another secret")
+	if VerifyCode(signed) {
+		t.Error("a code signed with another key verified")
+	}
+}
@@ -0,0 +1,45 @@
+package helpers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+// Default limits on the tags a link can carry, overridden by
+// MAX_TAGS_PER_LINK and MAX_TAG_LEN.
+const (
+	MaxTags   = 10
+	MaxTagLen = 32
+)
+
+// NormalizeTags trims and lowercases tags and drops duplicates, rejecting any
+// that are empty, longer than MAX_TAG_LEN or outside the custom short charset,
+// and more than MAX_TAGS_PER_LINK distinct tags.
+func NormalizeTags(tags []string) ([]string, error) {
+	maxTags := config.Int("MAX_TAGS_PER_LINK", MaxTags)
+	maxLen := config.Int("MAX_TAG_LEN", MaxTagLen)
+
+	seen := map[string]bool{}
+	out := make([]string, 0, len(tags))
+	for _, t := range tags {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" || len(t) > maxLen {
+			return nil, fmt.Errorf("tags must be between 1 and %d characters", maxLen)
+		}
+		for _, r := range t {
+			if !isShortChar(r) {
+				return nil, fmt.Errorf("tag %q may only contain %s", t, CustomShortCharset)
+			}
+		}
+		if !seen[t] {
+			seen[t] = true
+			out = append(out, t)
+		}
+	}
+	if len(out) > maxTags {
+		return nil, fmt.Errorf("a link can have at most %d tags", maxTags)
+	}
+	return out, nil
+}
@@ -0,0 +1,41 @@
+package helpers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		in   []string
+		want []string
+		ok   bool
+	}{
+		{nil, nil, []string{}, true},
+		{nil, []string{" Spring ", "SPRING", "sale", "spring"}, []string{"spring", "sale"}, true},
+		{nil, []string{strings.Repeat("a", MaxTagLen)}, []string{strings.Repeat("a", MaxTagLen)}, true},
+		{nil, []string{strings.Repeat("a", MaxTagLen+1)}, nil, false},
+		{nil, []string{"ok", "  "}, nil, false},
+		{nil, []string{"a:b"}, nil, false},
+		{nil, []string{"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7", "t8", "t9"}, []string{"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7", "t8", "t9"}, true},
+		{nil, []string{"t0", "t1", "t2", "t3", "t4", "t5", "t6", "t7", "t8", "t9", "t10"}, nil, false},
+		// duplicates count once against the limit
+		{map[string]string{"MAX_TAGS_PER_LINK": "2"}, []string{"a", "b", "A", " b"}, []string{"a", "b"}, true},
+		{map[string]string{"MAX_TAGS_PER_LINK": "2"}, []string{"a", "b", "c"}, nil, false},
+		{map[string]string{"MAX_TAG_LEN": "3"}, []string{"abc"}, []string{"abc"}, true},
+		{map[string]string{"MAX_TAG_LEN": "3"}, []string{"abcd"}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Setenv("MAX_TAGS_PER_LINK", tt.env["MAX_TAGS_PER_LINK"])
+		t.Setenv("MAX_TAG_LEN", tt.env["MAX_TAG_LEN"])
+		got, err := NormalizeTags(tt.in)
+		if tt.ok && (err != nil || !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("%v: NormalizeTags(%q) = %q, %v; want %q", tt.env, tt.in, got, err, tt.want)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%v: NormalizeTags(%q) = %q, want an error", tt.env, tt.in, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package jobs runs the service's periodic background work.
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+var webhookClient = &http.Client{Timeout: 5 * time.Second}
+
+// ExpiryWarning is the webhook payload sent once for a link about to expire.
+type ExpiryWarning struct {
+	Event     string    `json:"event"`
+	Short     string    `json:"short"`
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StartExpiryWarnings scans for links expiring within
+// EXPIRY_WARNING_THRESHOLD every EXPIRY_WARNING_INTERVAL and posts an
+// ExpiryWarning to EXPIRY_WARNING_WEBHOOK for each, until ctx is done. It does
+// nothing when no webhook is configured.
+func StartExpiryWarnings(ctx context.Context) {
+	webhook := config.String("EXPIRY_WARNING_WEBHOOK", "")
+	if webhook == "" {
+		return
+	}
+	threshold := config.Duration("EXPIRY_WARNING_THRESHOLD", 24*time.Hour)
+	interval := config.Duration("EXPIRY_WARNING_INTERVAL", 5*time.Minute)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := WarnExpiring(ctx, webhook, threshold); err != nil {
+				log.Printf("expiry warnings: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// WarnExpiring posts a warning to webhook for every link expiring within
+// threshold that hasn't been warned about yet.
+func WarnExpiring(ctx context.Context, webhook string, threshold time.Duration) error {
+	r := database.CreateClientContext(ctx, 0)
+	rState := database.CreateClientContext(ctx, 1)
+
+	return database.ScanLinkKeys(ctx, r, func(key string) error {
+		ttl, err := r.TTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		// -1 never expires, -2 expired since the scan saw it
+		if ttl <= 0 || ttl > threshold {
+			return nil
+		}
+
+		// the marker lives exactly as long as the link, so every instance
+		// agrees it has been warned about
+		warned := "warned:" + key
+		first, err := rState.SetNX(ctx, warned, 1, ttl).Result()
+		if err != nil || !first {
+			return err
+		}
+
+		link, err := database.GetLink(ctx, r, key)
+		if err == redis.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// aliases expire with their link, which is warned about on its own,
+		// and a link expired early by its owner is already gone
+		if link.PrimaryKey != "" || link.Expired {
+			return nil
+		}
+
+		warning := ExpiryWarning{
+			Event:     "link.expiring",
+			Short:     database.LinkCode(key),
+			URL:       link.URL,
+			ExpiresAt: time.Now().Add(ttl).UTC(),
+		}
+		if err := post(webhook, warning); err != nil {
+			// try again on the next scan
+			rState.Del(ctx, warned)
+			log.Printf("expiry warnings: %s: %v", key, err)
+		}
+		return nil
+	})
+}
+
+func post(webhook string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(webhook, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
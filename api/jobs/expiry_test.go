@@ -0,0 +1,118 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("STORAGE_BACKEND", "memory")
+	os.Exit(m.Run())
+}
+
+// resetStores empties the links and stats stores.
+func resetStores() {
+	database.CreateClient(0).(*database.MemoryStore).Flush()
+	database.CreateClient(1).(*database.MemoryStore).Flush()
+}
+
+// webhook records the expiry warnings posted to it, answering with status.
+type webhook struct {
+	mu       sync.Mutex
+	status   int
+	warnings []ExpiryWarning
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	var warning ExpiryWarning
+	_ = json.NewDecoder(r.Body).Decode(&warning)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.warnings = append(w.warnings, warning)
+	rw.WriteHeader(w.status)
+}
+
+func (w *webhook) shorts() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var out []string
+	for _, warning := range w.warnings {
+		out = append(out, warning.Short)
+	}
+	return out
+}
+
+func TestWarnExpiring(t *testing.T) {
+	resetStores()
+	ctx := context.Background()
+	r := database.CreateClient(0)
+	for short, ttl := range map[string]time.Duration{
+		"soon":  time.Hour,
+		"later": 48 * time.Hour,
+		"never": 0,
+	} {
+		link := &database.Link{URL: "https://example.com/" + short}
+		if err := database.SaveLink(ctx, r, database.LinkKey("", short), link, ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := database.SaveAlias(ctx, r, database.LinkKey("", "soon-alias"), database.LinkKey("", "soon")); err != nil {
+		t.Fatal(err)
+	}
+
+	hook := &webhook{status: http.StatusNoContent}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	for scan := 1; scan <= 3; scan++ {
+		if err := WarnExpiring(ctx, srv.URL, 24*time.Hour); err != nil {
+			t.Fatalf("scan %d: %v", scan, err)
+		}
+	}
+	if got := hook.shorts(); len(got) != 1 || got[0] != "soon" {
+		t.Fatalf("warned about %v, want soon exactly once", got)
+	}
+	warning := hook.warnings[0]
+	if warning.Event != "link.expiring" || warning.URL != "https://example.com/soon" {
+		t.Errorf("warning = %+v", warning)
+	}
+	if d := time.Until(warning.ExpiresAt); d > time.Hour || d < time.Hour-time.Minute {
+		t.Errorf("expires_at is %v away, want an hour", d)
+	}
+}
+
+func TestWarnExpiringRetriesFailedWebhooks(t *testing.T) {
+	resetStores()
+	ctx := context.Background()
+	link := &database.Link{URL: "https://example.com/soon"}
+	if err := database.SaveLink(ctx, database.CreateClient(0), database.LinkKey("", "soon"), link, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	hook := &webhook{status: http.StatusInternalServerError}
+	srv := httptest.NewServer(hook)
+	defer srv.Close()
+
+	if err := WarnExpiring(ctx, srv.URL, 24*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	hook.mu.Lock()
+	hook.status = http.StatusOK
+	hook.mu.Unlock()
+	for scan := 0; scan < 2; scan++ {
+		if err := WarnExpiring(ctx, srv.URL, 24*time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := hook.shorts(); len(got) != 2 {
+		t.Errorf("warned %d times, want a failed attempt and one retry", len(got))
+	}
+}
@@ -0,0 +1,53 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// StartGeoIP loads the GeoIP country database at GEOIP_DB_PATH and, with
+// GEOIP_RELOAD_INTERVAL set, reloads it that often so an updated download is
+// picked up without a restart. A database that's missing or can't be read is
+// logged and leaves the one already loaded, if any, in use; until one loads,
+// visitors get the default destination. It does nothing when the path is
+// unset.
+func StartGeoIP(ctx context.Context) {
+	path := config.String("GEOIP_DB_PATH", "")
+	if path == "" {
+		return
+	}
+	LoadGeoIP(path)
+
+	interval := config.Duration("GEOIP_RELOAD_INTERVAL", 0)
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				LoadGeoIP(path)
+			}
+		}
+	}()
+}
+
+// LoadGeoIP replaces the GeoIP database with the one at path, keeping the
+// current one if it can't be loaded.
+func LoadGeoIP(path string) {
+	db, err := helpers.LoadGeoDB(path)
+	if err != nil {
+		log.Printf("geoip: %v; visitors that can't be placed get the default destination", err)
+		return
+	}
+	helpers.SetGeoDB(db)
+	log.Printf("geoip: loaded %d ranges from %s", db.Len(), path)
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestStartGeoIP(t *testing.T) {
+	t.Cleanup(func() { helpers.SetGeoDB(nil) })
+	path := filepath.Join(t.TempDir(), "geoip.csv")
+	write := func(country string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("5.0.0.0,5.255.255.255,"+country+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a missing database leaves visitors unplaced
+	helpers.SetGeoDB(nil)
+	t.Setenv("GEOIP_DB_PATH", path)
+	t.Setenv("GEOIP_RELOAD_INTERVAL", "10ms")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	StartGeoIP(ctx)
+	if got := helpers.VisitorCountry("5.6.7.8"); got != "" {
+		t.Errorf("without a database: VisitorCountry = %q, want \"\"", got)
+	}
+
+	// the reload picks the download up, and then its update
+	for _, country := range []string{"AT", "CH"} {
+		write(country)
+		deadline := time.Now().Add(2 * time.Second)
+		for helpers.VisitorCountry("5.6.7.8") != country {
+			if time.Now().After(deadline) {
+				t.Fatalf("database with %s not reloaded", country)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// a broken update keeps the database already loaded
+	if err := os.WriteFile(path, []byte("garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := helpers.VisitorCountry("5.6.7.8"); got != "CH" {
+		t.Errorf("after a broken update: VisitorCountry = %q, want CH", got)
+	}
+}
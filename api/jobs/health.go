@@ -0,0 +1,106 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+var healthClient = &http.Client{
+	Timeout: 10 * time.Second,
+	// a redirect is a live destination; don't follow it
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// StartHealthChecks checks every link destination every HEALTH_CHECK_INTERVAL
+// and marks the failing ones, so links with a fallback_url can route around
+// them. It does nothing when the interval is unset or zero.
+func StartHealthChecks(ctx context.Context) {
+	interval := config.Duration("HEALTH_CHECK_INTERVAL", 0)
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			// marks outlive one missed run, not more
+			if err := CheckDestinations(ctx, 2*interval); err != nil {
+				log.Printf("health checks: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// CheckDestinations checks each distinct link destination once, marking
+// failures as unhealthy for ttl and clearing the mark on success.
+func CheckDestinations(ctx context.Context, ttl time.Duration) error {
+	r := database.CreateClientContext(ctx, 0)
+	rState := database.CreateClientContext(ctx, 1)
+
+	checked := map[string]bool{}
+	return database.ScanLinkKeys(ctx, r, func(key string) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		link, err := database.GetLink(ctx, r, key)
+		if err == redis.Nil {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// an alias shares its link's destinations, which are checked on their
+		// own; an expired link no longer redirects anywhere
+		if link.PrimaryKey != "" || link.Expired {
+			return nil
+		}
+
+		urls := []string{link.URL}
+		for _, dest := range link.Locales {
+			urls = append(urls, dest)
+		}
+		for _, url := range urls {
+			if checked[url] {
+				continue
+			}
+			checked[url] = true
+			if err := database.SetDestinationHealth(ctx, rState, url, healthy(ctx, url), ttl); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// healthy reports whether url answers without an error status. Servers that
+// don't support HEAD are retried with GET.
+func healthy(ctx context.Context, url string) bool {
+	status := 0
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequestWithContext(ctx, method, url, nil)
+		if err != nil {
+			return false
+		}
+		resp, err := healthClient.Do(req)
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+		if status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented {
+			break
+		}
+	}
+	return status < 400
+}
@@ -0,0 +1,55 @@
+package jobs
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestCheckDestinations(t *testing.T) {
+	resetStores()
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/down":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/moved":
+			http.Redirect(w, r, "/elsewhere", http.StatusFound)
+		case "/get-only":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	r := database.CreateClient(0)
+	rState := database.CreateClient(1)
+	for short, path := range map[string]string{"up": "/up", "down": "/down", "moved": "/moved", "get-only": "/get-only"} {
+		link := &database.Link{URL: srv.URL + path}
+		if err := database.SaveLink(ctx, r, database.LinkKey("", short), link, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// marked down earlier, and answering again now
+	if err := database.SetDestinationHealth(ctx, rState, srv.URL+"/up", false, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckDestinations(ctx, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{"/up": false, "/down": true, "/moved": false, "/get-only": false} {
+		unhealthy, err := database.DestinationUnhealthy(ctx, rState, srv.URL+path)
+		if err != nil || unhealthy != want {
+			t.Errorf("%s: unhealthy = %v, %v; want %v", path, unhealthy, err, want)
+		}
+	}
+	if ttl := rState.TTL(ctx, database.UnhealthyKey(srv.URL+"/down")).Val(); ttl <= 0 || ttl > time.Hour {
+		t.Errorf("unhealthy mark TTL = %v, want up to an hour", ttl)
+	}
+}
@@ -1,29 +1,186 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/compress"
+	"github.com/gofiber/fiber/v2/middleware/etag"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/joho/godotenv"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/jobs"
+	"github.com/karthikbhandary2/url-shortener/middleware"
 	"github.com/karthikbhandary2/url-shortener/routes"
+	"github.com/karthikbhandary2/url-shortener/tracing"
 )
 
 func setupRoutes(app *fiber.App) {
-	app.Get("/:url", routes.ResolveURL)
-	app.Post("/api/v1", routes.ShortenURL)
+	app.Use(middleware.Recover)
+	app.Use(middleware.RequireHTTPS)
+	app.Use(middleware.APIKeyAuth)
+	app.Get("/admin", middleware.AdminOnly, routes.Dashboard)
+	app.Get("/metrics", middleware.AdminOnly, routes.Metrics)
+	app.Get("/api/v1/admin/reverse", middleware.AdminOnly, routes.ReverseLookup)
+	app.Get("/api/v1/admin/domains", middleware.AdminOnly, routes.ListDomains)
+	app.Put("/api/v1/admin/domains/:domain", middleware.AdminOnly, middleware.RequireJSON, routes.SetDomain)
+	app.Delete("/api/v1/admin/domains/:domain", middleware.AdminOnly, routes.DeleteDomain)
+	app.Post("/api/v1/admin/keys", middleware.AdminOnly, middleware.RequireJSON, routes.CreateAPIKey)
+	app.Delete("/api/v1/admin/keys/:id", middleware.AdminOnly, routes.DeleteAPIKey)
+	app.Get("/api/v1/admin/reports", middleware.AdminOnly, routes.ListReports)
+	app.Delete("/api/v1/admin/reports/*", middleware.AdminOnly, routes.ClearReports)
+	app.Get("/api/v1/admin/ratelimit/:ip", middleware.AdminOnly, routes.GetRateLimit)
+	app.Post("/api/v1/admin/ratelimit/:ip", middleware.AdminOnly, routes.SetRateLimit)
+	app.Get("/api/v1/admin/read-only", middleware.AdminOnly, routes.GetReadOnly)
+	app.Put("/api/v1/admin/read-only", middleware.AdminOnly, middleware.RequireJSON, routes.SetReadOnly)
+	app.Put("/api/v1/admin/announcement", middleware.AdminOnly, middleware.RequireJSON, routes.SetAnnouncement)
+	app.Delete("/api/v1/admin/announcement", middleware.AdminOnly, routes.ClearAnnouncement)
+	app.Get("/api/v1/announcement", routes.Announcement)
+	app.Get("/api/v1/rules", routes.Rules)
+	app.Get("/api/v1/schema/shorten", routes.ShortenSchema)
+	app.Get("/api/v1/features", routes.Features)
+	app.Get("/api/v1/quota", routes.Quota)
+	app.Post("/api/v1/collections", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, routes.CreateCollection)
+	app.Post("/api/v1/collections/:id/links", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, routes.AddCollectionLinks)
+	app.Get("/api/v1/collections/:id/stats", routes.CollectionStats)
+	app.Get("/api/v1/admin/links/recent", middleware.AdminOnly, routes.RecentLinks)
+	app.Post("/api/v1/admin/import", middleware.AdminOnly, middleware.BlockWrites, routes.ImportLinks)
+	app.Get("/api/v1/links", middleware.AdminOnly, routes.ListLinks)
+	app.Delete("/api/v1/links", middleware.AdminOnly, middleware.BlockWrites, routes.DeleteLinks)
+	app.Patch("/api/v1/links/expiry", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, routes.UpdateLinksExpiry)
+	// pollers send the ETag back in If-None-Match and get 304 until the
+	// link or its clicks change
+	conditional := etag.New()
+	app.Get("/api/v1/links/*/events", middleware.SignedCode, routes.LinkEvents)
+	app.Get("/api/v1/links/*", middleware.SignedCode, conditional, routes.LinkInfo)
+	app.Get("/api/v1/stats/*", middleware.SignedCode, conditional, routes.LinkStats)
+	app.Get("/api/v1/peek/*", middleware.SignedCode, routes.PeekURL)
+	app.Get("/api/v1/card/*", middleware.SignedCode, routes.LinkCard)
+	app.Get("/api/v1/oembed", routes.OEmbed)
+	app.Post("/api/v1/qr/bulk", middleware.RequireKey(database.ScopeRead), middleware.RequireJSON, routes.BulkQR)
+	app.Get("/api/v1/preview/*/continue", middleware.SignedCode, routes.ContinuePreview)
+	app.Get("/api/v1/preview/*", middleware.SignedCode, routes.PreviewURL)
+	app.Get("/", routes.Root)
+	app.Get("/favicon.ico", routes.Favicon)
+	app.Get("/robots.txt", routes.Robots)
+	// Get also registers HEAD, which link checkers use. The wildcard lets
+	// path-style codes like docs/getting-started resolve, so it has to stay
+	// behind every other GET route.
+	app.Get("/*", middleware.SignedCode, routes.ResolveURL)
+	app.Patch("/*", middleware.SignedCode, middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, routes.UpdateLink)
+	app.Options("/*", routes.ResolveOptions)
+	app.Post("/api/v1", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, routes.ShortenURL)
+	// test data for benchmarks; absent unless explicitly enabled
+	if config.Bool("ENABLE_SEED_ENDPOINT", false) {
+		app.Post("/api/v1/_seed", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, routes.Seed)
+	}
+	app.Post("/api/v1/resolve", middleware.RequireJSON, routes.ResolveShort)
+	app.Post("/api/v1/reserve/*", middleware.BlockWrites, middleware.RequireWrite, routes.ReserveShort)
+	app.Post("/api/v1/report/*", middleware.BlockWrites, middleware.SignedCode, middleware.RequireJSON, routes.ReportLink)
+	app.Post("/*/alias", middleware.BlockWrites, middleware.RequireWrite, middleware.SignedCode, middleware.RequireJSON, routes.CreateAlias)
+	app.Delete("/*/alias/:alias", middleware.BlockWrites, middleware.RequireWrite, middleware.SignedCode, routes.DeleteAlias)
+	app.Post("/*/transfer", middleware.BlockWrites, middleware.RequireWrite, middleware.SignedCode, middleware.RequireJSON, routes.TransferLink)
+	app.Post("/*/expire", middleware.BlockWrites, middleware.RequireWrite, middleware.SignedCode, routes.ExpireLink)
+	app.Post("/*/verify-token", middleware.SignedCode, middleware.RequireJSON, routes.VerifyEditToken)
+}
+
+// loggerConfig is Fiber's default request log line, plus the destination a
+// short link resolved to when LOG_DESTINATIONS is on. It's off by default
+// because destinations can carry sensitive URLs; the path already shows the
+// short code.
+func loggerConfig() logger.Config {
+	// unset fields get logger's defaults; copying ConfigDefault would also
+	// copy its colored stdout, whatever Output is set to
+	var cfg logger.Config
+	if config.Bool("LOG_DESTINATIONS", false) {
+		cfg.Format = "${time} | ${status} | ${latency} | ${ip} | ${method} | ${path} | ${locals:destination} | ${error}\n"
+	}
+	return cfg
+}
+
+// setupCompression compresses responses for clients that accept it when
+// ENABLE_COMPRESSION is set. Bodies under 200 bytes, like redirects, and
+// already compressed content such as PNG cards are sent as they are.
+func setupCompression(app *fiber.App) {
+	if config.Bool("ENABLE_COMPRESSION", false) {
+		app.Use(compress.New())
+	}
 }
 
 func main() {
+	checkOnly := flag.Bool("check-config", false, "validate the configuration and exit without starting the server")
+	flag.Parse()
+
 	err := godotenv.Load()
 	if err != nil {
 		fmt.Println(err)
 	}
-	app := fiber.New()
-	app.Use(logger.New())
+	if *checkOnly {
+		if checkConfig(os.Stdout) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	// guessable codes are refused before anything is served
+	if err := helpers.CheckCodeEntropy(); err != nil {
+		log.Fatal(err)
+	}
+	database.Connect()
+	database.StartClickTracking()
+	database.StartSecondaryStore()
+
+	stopTracing, err := tracing.Start(context.Background())
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobs.StartExpiryWarnings(ctx)
+	jobs.StartHealthChecks(ctx)
+	jobs.StartGeoIP(ctx)
+
+	app := fiber.New(fiber.Config{JSONEncoder: helpers.MarshalJSON})
+	app.Use(tracing.Middleware)
+	app.Use(logger.New(loggerConfig()))
+	if n := config.Int("MAX_CONCURRENT_REQUESTS", 0); n > 0 {
+		app.Use(middleware.LimitConcurrency(n))
+	}
+	if d := config.Duration("REQUEST_TIMEOUT", 0); d > 0 {
+		app.Use(middleware.Timeout(d))
+	}
+	setupCompression(app)
 	setupRoutes(app)
 
-	log.Fatal(app.Listen(os.Getenv("APP_PORT")))
-}
\ No newline at end of file
+	go func() {
+		if err := app.Listen(os.Getenv("APP_PORT")); err != nil {
+			log.Fatal(err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	// stop taking requests first so no clicks arrive after the final flush
+	if err := app.Shutdown(); err != nil {
+		log.Println(err)
+	}
+	stopJobs()
+	database.StopClickTracking()
+	database.StopSecondaryStore()
+	if err := database.CloseClients(); err != nil {
+		log.Println(err)
+	}
+	if err := stopTracing(context.Background()); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/logger"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/middleware"
+	"github.com/karthikbhandary2/url-shortener/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLogDestinations(t *testing.T) {
+	t.Setenv("STORAGE_BACKEND", "memory")
+	link := &database.Link{URL: "https://example.com/private?token=s3cret"}
+	if err := database.SaveLink(context.Background(), database.CreateClient(0), database.LinkKey("", "logged"), link, 0); err != nil {
+		t.Fatalf("seeding: %v", err)
+	}
+
+	for _, enabled := range []bool{false, true} {
+		if enabled {
+			t.Setenv("LOG_DESTINATIONS", "true")
+		} else {
+			t.Setenv("LOG_DESTINATIONS", "")
+		}
+		var out bytes.Buffer
+		cfg := loggerConfig()
+		cfg.Output = &out
+		app := fiber.New()
+		app.Use(logger.New(cfg))
+		setupRoutes(app)
+
+		resp, err := app.Test(httptest.NewRequest("GET", "/logged", nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != fiber.StatusFound {
+			t.Fatalf("LOG_DESTINATIONS=%v: status = %d, want %d", enabled, resp.StatusCode, fiber.StatusFound)
+		}
+		line := out.String()
+		if !strings.Contains(line, "/logged") {
+			t.Errorf("LOG_DESTINATIONS=%v: the short code isn't logged: %q", enabled, line)
+		}
+		if got := strings.Contains(line, link.URL); got != enabled {
+			t.Errorf("LOG_DESTINATIONS=%v: destination logged = %v: %q", enabled, got, line)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	t.Setenv("STORAGE_BACKEND", "memory")
+	link := &database.Link{URL: "https://example.com/compressed"}
+	if err := database.SaveLink(context.Background(), database.CreateClient(0), database.LinkKey("", "squeezed"), link, 0); err != nil {
+		t.Fatalf("seeding: %v", err)
+	}
+	get := func(app *fiber.App, path, encoding string) *http.Response {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		if encoding != "" {
+			req.Header.Set(fiber.HeaderAcceptEncoding, encoding)
+		}
+		resp, err := app.Test(req, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		enabled  string
+		encoding string
+		want     string
+	}{
+		{"", "gzip", ""},
+		{"true", "", ""},
+		{"true", "gzip", "gzip"},
+		{"true", "deflate", "deflate"},
+		{"true", "br", "br"},
+	}
+	for _, tt := range tests {
+		t.Setenv("ENABLE_COMPRESSION", tt.enabled)
+		app := fiber.New()
+		setupCompression(app)
+		setupRoutes(app)
+
+		resp := get(app, "/api/v1/schema/shorten", tt.encoding)
+		if got := resp.Header.Get(fiber.HeaderContentEncoding); got != tt.want {
+			t.Errorf("ENABLE_COMPRESSION=%q, Accept-Encoding %q: Content-Encoding = %q, want %q", tt.enabled, tt.encoding, got, tt.want)
+		}
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tt.want == "gzip" {
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			if body, err = io.ReadAll(zr); err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+		}
+		if tt.want == "" || tt.want == "gzip" {
+			if !json.Valid(body) {
+				t.Errorf("ENABLE_COMPRESSION=%q, Accept-Encoding %q: the schema isn't JSON once decoded", tt.enabled, tt.encoding)
+			}
+		}
+
+		// redirects are too small to be worth it
+		resp = get(app, "/squeezed", tt.encoding)
+		if resp.StatusCode != fiber.StatusFound || resp.Header.Get(fiber.HeaderContentEncoding) != "" {
+			t.Errorf("ENABLE_COMPRESSION=%q: redirect %d with Content-Encoding %q, want %d uncompressed", tt.enabled, resp.StatusCode, resp.Header.Get(fiber.HeaderContentEncoding), fiber.StatusFound)
+		}
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	// a Redis that accepts connections and never answers
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	t.Setenv("DB_ADD", ln.Addr().String())
+	t.Setenv("REDIS_READ_TIMEOUT", "10s")
+	database.CloseClients()
+	t.Cleanup(func() { database.CloseClients() })
+
+	app := fiber.New()
+	app.Use(middleware.Timeout(50 * time.Millisecond))
+	setupRoutes(app)
+
+	start := time.Now()
+	resp, err := app.Test(httptest.NewRequest("GET", "/hung", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusGatewayTimeout {
+		t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusGatewayTimeout)
+	}
+	if took := time.Since(start); took > 2*time.Second {
+		t.Errorf("the request took %v; the Redis command wasn't cancelled at the timeout", took)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	t.Setenv("OTEL_TRACING", "true")
+	t.Setenv("DB_ADD", miniredis.RunT(t).Addr())
+	// the clients are connected on first use, so drop any for another server
+	database.CloseClients()
+	t.Cleanup(func() { database.CloseClients() })
+
+	spans := tracetest.NewSpanRecorder()
+	tracing.Install(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	t.Cleanup(func() { tracing.Install(trace.NewNoopTracerProvider()) })
+
+	app := fiber.New()
+	app.Use(tracing.Middleware)
+	setupRoutes(app)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("POST", "/api/v1", strings.NewReader(`{"url":"https://example.com/traced"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d, want %d", resp.StatusCode, fiber.StatusCreated)
+	}
+
+	var server trace.SpanContext
+	var redisSpans int
+	for _, span := range spans.Ended() {
+		if span.SpanKind() == trace.SpanKindServer {
+			if span.Name() != "POST /api/v1" {
+				t.Errorf("server span named %q, want POST /api/v1", span.Name())
+			}
+			if span.Parent().SpanID().String() != "00f067aa0ba902b7" {
+				t.Errorf("server span's parent = %v, want the caller's span", span.Parent().SpanID())
+			}
+			server = span.SpanContext()
+		}
+	}
+	if !server.IsValid() {
+		t.Fatalf("no server span among %d spans", len(spans.Ended()))
+	}
+	if server.TraceID().String() != traceID {
+		t.Errorf("trace ID = %v, want the caller's %s", server.TraceID(), traceID)
+	}
+	for _, span := range spans.Ended() {
+		if span.SpanKind() != trace.SpanKindClient || !strings.HasPrefix(span.Name(), "redis ") {
+			continue
+		}
+		redisSpans++
+		if span.Parent().SpanID() != server.SpanID() {
+			t.Errorf("%s: parent = %v, want the server span", span.Name(), span.Parent().SpanID())
+		}
+	}
+	if redisSpans == 0 {
+		t.Errorf("no Redis spans among %d spans", len(spans.Ended()))
+	}
+
+	// switched off, nothing is recorded even with a provider installed
+	t.Setenv("OTEL_TRACING", "")
+	before := len(spans.Ended())
+	req = httptest.NewRequest("POST", "/api/v1", strings.NewReader(`{"url":"https://example.com/untraced"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if _, err := app.Test(req, -1); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(spans.Ended()) - before; n != 0 {
+		t.Errorf("tracing off: %d spans recorded, want none", n)
+	}
+}
+
+func TestCheckConfig(t *testing.T) {
+	valid := map[string]string{
+		"DOMAIN":    "sho.rt",
+		"APP_PORT":  ":3000",
+		"API_QUOTA": "10",
+		"DB_ADD":    miniredis.RunT(t).Addr(),
+	}
+	tests := []struct {
+		name string
+		env  map[string]string
+		// fields are the settings reported as problems
+		fields []string
+	}{
+		{"valid", nil, nil},
+		{"optional settings", map[string]string{"MAX_EXPIRY": "30d", "TOMBSTONE_TTL": "1h", "BOT_CLICKS": "skip", "HTTPS_ONLY": "true"}, nil},
+		{"missing domain", map[string]string{"DOMAIN": ""}, []string{"DOMAIN"}},
+		{"bad quota", map[string]string{"API_QUOTA": "lots"}, []string{"API_QUOTA"}},
+		{"never expiring maximum", map[string]string{"MAX_EXPIRY": "never"}, []string{"MAX_EXPIRY"}},
+		{"typos", map[string]string{"REQUEST_TIMEOUT": "5", "MAX_TAGS_PER_LINK": "-1", "READ_ONLY": "yes", "CLICK_TRACKING": "later"}, []string{"REQUEST_TIMEOUT", "MAX_TAGS_PER_LINK", "READ_ONLY", "CLICK_TRACKING"}},
+		{"code length", map[string]string{"SHORT_CODE_LENGTH": "0"}, []string{"SHORT_CODE_LENGTH"}},
+		{"sample rate", map[string]string{"ANALYTICS_SAMPLE_RATE": "1.5"}, []string{"ANALYTICS_SAMPLE_RATE"}},
+		{"unreachable Redis", map[string]string{"DB_ADD": "127.0.0.1:1", "REDIS_DIAL_TIMEOUT": "100ms"}, []string{"DB_ADD"}},
+		{"memory backend", map[string]string{"DB_ADD": "127.0.0.1:1", "STORAGE_BACKEND": "memory"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range valid {
+				t.Setenv(key, value)
+			}
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			var out bytes.Buffer
+			if got := checkConfig(&out); got != len(tt.fields) {
+				t.Errorf("checkConfig = %d problems, want %d:\n%s", got, len(tt.fields), out.String())
+			}
+			for _, field := range tt.fields {
+				if !strings.Contains(out.String(), "FAIL  "+field+": ") {
+					t.Errorf("report doesn't name %s:\n%s", field, out.String())
+				}
+			}
+			if len(tt.fields) == 0 && !strings.Contains(out.String(), "configuration OK") {
+				t.Errorf("report = %q, want configuration OK", out.String())
+			}
+		})
+	}
+}
+
+func TestCheckConfigExitStatus(t *testing.T) {
+	if os.Getenv("CHECK_CONFIG_SUBPROCESS") == "1" {
+		os.Args = []string{os.Args[0], "--check-config"}
+		main()
+		return
+	}
+	addr := miniredis.RunT(t).Addr()
+	for _, tt := range []struct {
+		quota  string
+		failed bool
+	}{
+		{"10", false},
+		{"none", true},
+	} {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestCheckConfigExitStatus$")
+		cmd.Env = append(os.Environ(), "CHECK_CONFIG_SUBPROCESS=1", "DOMAIN=sho.rt", "APP_PORT=:3000", "DB_ADD="+addr, "API_QUOTA="+tt.quota)
+		out, err := cmd.CombinedOutput()
+		var exit *exec.ExitError
+		if failed := errors.As(err, &exit) && exit.ExitCode() == 1; failed != tt.failed || (!tt.failed && err != nil) {
+			t.Errorf("API_QUOTA=%s: %v, want failed %v:\n%s", tt.quota, err, tt.failed, out)
+		}
+		if tt.failed && !strings.Contains(string(out), "FAIL  API_QUOTA") {
+			t.Errorf("API_QUOTA=%s: output doesn't name API_QUOTA:\n%s", tt.quota, out)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Package metrics keeps the service's counters and renders them in the
+// Prometheus text format for GET /metrics.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Counters the service exports.
+var (
+	ShortenBadRequests = NewCounter("shorten_bad_request_total", "Shorten requests rejected as invalid, by reason.", "reason")
+	Panics             = NewCounter("panics_total", "Handler panics recovered into a 500.", "")
+	RateLimitFailOpen  = NewCounter("rate_limit_fail_open_total", "Rate-limit store errors that shorten requests were let through despite.", "")
+	ResolveMisses      = NewCounter("resolve_miss_total", "Requests for short codes that don't exist.", "")
+)
+
+var (
+	registryMu sync.Mutex
+	registry   []*Counter
+)
+
+// Counter is a monotonically increasing count, optionally split by the values
+// of one label.
+type Counter struct {
+	name, help, label string
+
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+// NewCounter registers a counter. label names the label its values are split
+// by; "" makes a single, unlabelled count.
+func NewCounter(name, help, label string) *Counter {
+	c := &Counter{name: name, help: help, label: label, values: map[string]uint64{}}
+	registryMu.Lock()
+	registry = append(registry, c)
+	registryMu.Unlock()
+	return c
+}
+
+// Inc adds one to the count for the label value; unlabelled counters take "".
+func (c *Counter) Inc(value string) {
+	c.mu.Lock()
+	c.values[value]++
+	c.mu.Unlock()
+}
+
+// Value returns the count for the label value.
+func (c *Counter) Value(value string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[value]
+}
+
+// Write renders every registered counter to w in the Prometheus text format.
+func Write(w io.Writer) error {
+	registryMu.Lock()
+	counters := append([]*Counter(nil), registry...)
+	registryMu.Unlock()
+
+	for _, c := range counters {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name); err != nil {
+			return err
+		}
+		c.mu.Lock()
+		values := make([]string, 0, len(c.values))
+		for v := range c.values {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		var err error
+		switch {
+		case c.label == "":
+			_, err = fmt.Fprintf(w, "%s %d\n", c.name, c.values[""])
+		default:
+			for _, v := range values {
+				if _, err = fmt.Fprintf(w, "%s{%s=%s} %d\n", c.name, c.label, strconv.Quote(v), c.values[v]); err != nil {
+					break
+				}
+			}
+		}
+		c.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	labelled := NewCounter("test_labelled_total", "A labelled test counter.", "reason")
+	plain := NewCounter("test_plain_total", "An unlabelled test counter.", "")
+	labelled.Inc("syntax")
+	labelled.Inc("type")
+	labelled.Inc("syntax")
+	plain.Inc("")
+
+	if got := labelled.Value("syntax"); got != 2 {
+		t.Errorf("Value(syntax) = %d, want 2", got)
+	}
+	if got := labelled.Value("missing"); got != 0 {
+		t.Errorf("Value(missing) = %d, want 0", got)
+	}
+
+	var out strings.Builder
+	if err := Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# HELP test_labelled_total A labelled test counter.\n# TYPE test_labelled_total counter\n" +
+			"test_labelled_total{reason=\"syntax\"} 2\ntest_labelled_total{reason=\"type\"} 1\n",
+		"# TYPE test_plain_total counter\ntest_plain_total 1\n",
+		// registered counters are reported before anything is counted
+		"# TYPE panics_total counter\npanics_total 0\n",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output is missing %q:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// AdminOnly rejects requests that don't carry the ADMIN_API_KEY, either in the
+// X-Admin-Key header, as a bearer token, or as the basic auth password (so the
+// dashboard works from a browser), or an API key with the admin scope. Admin
+// routes are disabled entirely when ADMIN_API_KEY is unset.
+func AdminOnly(c *fiber.Ctx) error {
+	if os.Getenv("ADMIN_API_KEY") == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "admin API is disabled"})
+	}
+
+	if !IsAdmin(c) {
+		c.Set(fiber.HeaderWWWAuthenticate, `Basic realm="admin"`)
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid admin key"})
+	}
+	return c.Next()
+}
+
+// IsAdmin reports whether the request carries the ADMIN_API_KEY, or an API
+// key with the admin scope.
+func IsAdmin(c *fiber.Ctx) bool {
+	want := os.Getenv("ADMIN_API_KEY")
+	if want == "" {
+		return false
+	}
+	if key := APIKey(c); key != nil && key.Allows(database.ScopeAdmin) {
+		return true
+	}
+	return subtle.ConstantTimeCompare([]byte(adminKey(c)), []byte(want)) == 1
+}
+
+func adminKey(c *fiber.Ctx) string {
+	if key := c.Get("X-Admin-Key"); key != "" {
+		return key
+	}
+
+	auth := c.Get(fiber.HeaderAuthorization)
+	if strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimPrefix(auth, "Bearer ")
+	}
+	if strings.HasPrefix(auth, "Basic ") {
+		raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(auth, "Basic "))
+		if err != nil {
+			return ""
+		}
+		if _, password, ok := strings.Cut(string(raw), ":"); ok {
+			return password
+		}
+	}
+	return ""
+}
@@ -0,0 +1,107 @@
+package middleware
+
+import (
+	"context"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("STORAGE_BACKEND", "memory")
+	os.Exit(m.Run())
+}
+
+// saveKey stores an API key with scopes under raw, failing t if it can't.
+func saveKey(t *testing.T, raw string, scopes ...string) {
+	t.Helper()
+	key := &database.APIKey{ID: helpers.HashToken(raw), Scopes: scopes}
+	if err := database.SaveAPIKey(context.Background(), database.CreateClient(0), key.ID, key); err != nil {
+		t.Fatalf("saving %s: %v", raw, err)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "admin-secret")
+	saveKey(t, "key-read", database.ScopeRead)
+	saveKey(t, "key-write", database.ScopeWrite)
+	saveKey(t, "key-admin", database.ScopeAdmin)
+	saveKey(t, "key-legacy", "premium")
+
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	app := fiber.New()
+	app.Use(APIKeyAuth)
+	app.Get("/read", ok)
+	app.Post("/write", RequireWrite, ok)
+	app.Post("/costly", RequireKey(database.ScopeRead), ok)
+	app.Get("/admin", AdminOnly, ok)
+
+	tests := []struct {
+		key    string
+		path   string
+		status int
+	}{
+		{"", "/read", fiber.StatusOK},
+		{"", "/write", fiber.StatusOK},
+		{"", "/costly", fiber.StatusUnauthorized},
+		{"", "/admin", fiber.StatusUnauthorized},
+		{"unknown", "/read", fiber.StatusUnauthorized},
+
+		{"key-read", "/read", fiber.StatusOK},
+		{"key-read", "/write", fiber.StatusForbidden},
+		{"key-read", "/costly", fiber.StatusOK},
+		{"key-read", "/admin", fiber.StatusUnauthorized},
+
+		{"key-write", "/write", fiber.StatusOK},
+		{"key-write", "/costly", fiber.StatusOK},
+		{"key-write", "/admin", fiber.StatusUnauthorized},
+
+		{"key-admin", "/write", fiber.StatusOK},
+		{"key-admin", "/admin", fiber.StatusOK},
+
+		{"key-legacy", "/write", fiber.StatusOK},
+		{"key-legacy", "/admin", fiber.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		method := fiber.MethodGet
+		if tt.path == "/write" || tt.path == "/costly" {
+			method = fiber.MethodPost
+		}
+		t.Run(tt.key+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(method, tt.path, nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
+
+func TestAdminScopeNeedsAdminAPI(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "")
+	saveKey(t, "key-admin-off", database.ScopeAdmin)
+
+	app := fiber.New()
+	app.Use(APIKeyAuth)
+	app.Get("/admin", AdminOnly, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	req := httptest.NewRequest(fiber.MethodGet, "/admin", nil)
+	req.Header.Set("X-API-Key", "key-admin-off")
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("status = %d, want %d with the admin API disabled", resp.StatusCode, fiber.StatusForbidden)
+	}
+}
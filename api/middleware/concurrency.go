@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// LimitConcurrency sheds requests with 503 Service Unavailable and a
+// Retry-After while n requests are already being handled, so a traffic spike
+// can't pile unbounded load onto Redis.
+func LimitConcurrency(n int) fiber.Handler {
+	slots := make(chan struct{}, n)
+	return func(c *fiber.Ctx) error {
+		select {
+		case slots <- struct{}{}:
+		default:
+			c.Set(fiber.HeaderRetryAfter, "1")
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "server is busy, try again shortly"})
+		}
+		defer func() { <-slots }()
+		return c.Next()
+	}
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestLimitConcurrency(t *testing.T) {
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	app := fiber.New()
+	app.Use(LimitConcurrency(2))
+	app.Get("/", func(c *fiber.Ctx) error {
+		entered <- struct{}{}
+		<-release
+		return c.SendStatus(fiber.StatusOK)
+	})
+	app.Get("/quick", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+	get := func(path string) int {
+		resp, err := app.Test(httptest.NewRequest("GET", path, nil), -1)
+		if err != nil {
+			t.Error(err)
+			return 0
+		}
+		if resp.StatusCode == fiber.StatusServiceUnavailable && resp.Header.Get(fiber.HeaderRetryAfter) != "1" {
+			t.Errorf("shed request: Retry-After = %q, want 1", resp.Header.Get(fiber.HeaderRetryAfter))
+		}
+		return resp.StatusCode
+	}
+
+	statuses := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { statuses <- get("/") }()
+	}
+	<-entered
+	<-entered
+	// both slots are taken, whichever route the next request is for
+	if status := get("/quick"); status != fiber.StatusServiceUnavailable {
+		t.Errorf("over the cap: status = %d, want %d", status, fiber.StatusServiceUnavailable)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if status := <-statuses; status != fiber.StatusOK {
+			t.Errorf("within the cap: status = %d, want %d", status, fiber.StatusOK)
+		}
+	}
+	// the slots are given back
+	if status := get("/quick"); status != fiber.StatusOK {
+		t.Errorf("after the others finished: status = %d, want %d", status, fiber.StatusOK)
+	}
+}
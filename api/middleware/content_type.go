@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// bodyTypes are the request Content-Types the write endpoints can parse.
+var bodyTypes = []string{fiber.MIMEApplicationJSON}
+
+// RequireJSON rejects write requests whose Content-Type isn't one the handlers
+// parse with 415 Unsupported Media Type, instead of letting the body parser
+// fail with a confusing error. Parameters such as charset are ignored.
+func RequireJSON(c *fiber.Ctx) error {
+	ct, _, _ := strings.Cut(c.Get(fiber.HeaderContentType), ";")
+	ct = strings.TrimSpace(ct)
+	for _, t := range bodyTypes {
+		if strings.EqualFold(ct, t) {
+			return c.Next()
+		}
+	}
+
+	if ct == "" {
+		ct = "none"
+	}
+	return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": "unsupported Content-Type " + ct + ", expected application/json"})
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireJSON(t *testing.T) {
+	app := fiber.New()
+	app.Post("/", RequireJSON, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	tests := []struct {
+		contentType string
+		status      int
+	}{
+		{"application/json", fiber.StatusOK},
+		{"application/json; charset=utf-8", fiber.StatusOK},
+		{"Application/JSON", fiber.StatusOK},
+		{" application/json ;charset=UTF-8", fiber.StatusOK},
+		{"", fiber.StatusUnsupportedMediaType},
+		{"text/plain", fiber.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", fiber.StatusUnsupportedMediaType},
+		{"application/xml", fiber.StatusUnsupportedMediaType},
+		{"application/jsonp", fiber.StatusUnsupportedMediaType},
+		{"application/json-patch+json", fiber.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"url":"https://example.com/"}`))
+		if tt.contentType != "" {
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("Content-Type %q: status = %d, want %d", tt.contentType, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireHTTPS(t *testing.T) {
+	app := fiber.New()
+	app.Use(RequireHTTPS)
+	app.All("/", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	tests := []struct {
+		enabled string
+		method  string
+		proto   string
+		status  int
+	}{
+		{"", "POST", "http", fiber.StatusOK},
+		{"true", "POST", "http", fiber.StatusForbidden},
+		{"true", "POST", "", fiber.StatusForbidden},
+		{"true", "PATCH", "http", fiber.StatusForbidden},
+		{"true", "DELETE", "http", fiber.StatusForbidden},
+		{"true", "POST", "https", fiber.StatusOK},
+		{"true", "DELETE", "https", fiber.StatusOK},
+		// reads, health checks among them, are served either way
+		{"true", "GET", "http", fiber.StatusOK},
+		{"true", "HEAD", "http", fiber.StatusOK},
+		{"true", "OPTIONS", "http", fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Setenv("REQUIRE_HTTPS", tt.enabled)
+		req := httptest.NewRequest(tt.method, "/", nil)
+		if tt.proto != "" {
+			req.Header.Set(fiber.HeaderXForwardedProto, tt.proto)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("REQUIRE_HTTPS=%q, %s over %q: status = %d, want %d", tt.enabled, tt.method, tt.proto, resp.StatusCode, tt.status)
+		}
+	}
+}
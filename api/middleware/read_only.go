@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+var (
+	readOnlyOnce sync.Once
+	readOnly     atomic.Bool
+)
+
+// ReadOnly reports whether the service is refusing writes. It starts out as
+// READ_ONLY and can be changed at runtime with SetReadOnly.
+func ReadOnly() bool {
+	readOnlyOnce.Do(func() {
+		readOnly.Store(config.Bool("READ_ONLY", false))
+	})
+	return readOnly.Load()
+}
+
+// SetReadOnly turns read-only mode on or off for this process.
+func SetReadOnly(on bool) {
+	readOnlyOnce.Do(func() {})
+	readOnly.Store(on)
+}
+
+// BlockWrites rejects requests with 503 Service Unavailable while the service
+// is read-only, so redirects keep working during a migration but nothing new
+// is written.
+func BlockWrites(c *fiber.Ctx) error {
+	if ReadOnly() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "service is read-only"})
+	}
+	return c.Next()
+}
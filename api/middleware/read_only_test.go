@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBlockWrites(t *testing.T) {
+	// as if the process had just started with READ_ONLY set
+	t.Setenv("READ_ONLY", "true")
+	readOnlyOnce = sync.Once{}
+	t.Cleanup(func() { SetReadOnly(false) })
+	app := fiber.New()
+	app.Post("/", BlockWrites, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusCreated) })
+
+	tests := []struct {
+		name   string
+		toggle func()
+		status int
+	}{
+		{"READ_ONLY at startup", func() {}, fiber.StatusServiceUnavailable},
+		// the environment isn't read again once the mode is known
+		{"READ_ONLY unset later", func() { t.Setenv("READ_ONLY", "") }, fiber.StatusServiceUnavailable},
+		{"switched off", func() { SetReadOnly(false) }, fiber.StatusCreated},
+		{"switched back on", func() { SetReadOnly(true) }, fiber.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		tt.toggle()
+		resp, err := app.Test(httptest.NewRequest("POST", "/", nil))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"log"
+	"runtime/debug"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/metrics"
+)
+
+// Recover turns a panicking handler into a 500 with the usual error body,
+// instead of a dropped connection, and counts it in panics_total.
+func Recover(c *fiber.Ctx) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			metrics.Panics.Inc("")
+			log.Printf("panic serving %s %s: %v\n%s", c.Method(), c.OriginalURL(), r, debug.Stack())
+			err = c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "internal server error"})
+		}
+	}()
+	return c.Next()
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/metrics"
+)
+
+func TestRecover(t *testing.T) {
+	app := fiber.New()
+	app.Use(Recover)
+	app.Get("/panic", func(c *fiber.Ctx) error { panic("boom") })
+	app.Get("/ok", func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	before := metrics.Panics.Value("")
+	resp, err := app.Test(httptest.NewRequest("GET", "/panic", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusInternalServerError || body["error"] != "internal server error" {
+		t.Errorf("panic: %d %v, want %d with the usual error body", resp.StatusCode, body, fiber.StatusInternalServerError)
+	}
+	if got := metrics.Panics.Value("") - before; got != 1 {
+		t.Errorf("panics_total moved by %d, want 1", got)
+	}
+
+	resp, err = app.Test(httptest.NewRequest("GET", "/ok", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK || metrics.Panics.Value("")-before != 1 {
+		t.Errorf("after a panic: status = %d, panics_total moved by %d; want %d and 1", resp.StatusCode, metrics.Panics.Value("")-before, fiber.StatusOK)
+	}
+}
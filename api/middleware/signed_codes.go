@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// SignedCode answers 404 for short codes without a valid signature when code
+// signing is enabled, before anything is looked up, so guessed codes can't be
+// told apart from missing ones. A code whose leading segments are signed
+// passes too, for append_path links. Codes that can't exist at all get 400
+// first, whether or not signing is on.
+func SignedCode(c *fiber.Ctx) error {
+	code := helpers.NormalizeCode(c.Params("*"))
+	if !helpers.WellFormedCode(code) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "short code is malformed", "code": "invalid_code"})
+	}
+	if helpers.VerifyCode(code) {
+		return c.Next()
+	}
+	for i := strings.LastIndex(code, helpers.CustomShortSeparator); i > 0; i = strings.LastIndex(code[:i], helpers.CustomShortSeparator) {
+		if helpers.VerifyCode(code[:i]) {
+			return c.Next()
+		}
+	}
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database", "code": "link_not_found"})
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestSignedCode(t *testing.T) {
+	t.Setenv("CODE_SIGNING_KEY", "secret")
+	signed := helpers.SignCode("abc123")
+	app := fiber.New()
+	app.Get("/*", SignedCode, func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) })
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"signed", "/" + signed, fiber.StatusOK},
+		{"signed, trailing slash", "/" + signed + "/", fiber.StatusOK},
+		{"signed, appended path", "/" + signed + "/extra/path", fiber.StatusOK},
+		{"unsigned", "/abc123", fiber.StatusNotFound},
+		{"wrong signature", "/abc123-aaaaaa", fiber.StatusNotFound},
+		{"truncated signature", "/" + signed[:len(signed)-2], fiber.StatusNotFound},
+		{"unsigned, appended path", "/abc123/extra", fiber.StatusNotFound},
+		{"malformed", "/bad%20code", fiber.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+			}
+		})
+	}
+
+	t.Setenv("CODE_SIGNING_KEY", "")
+	resp, err := app.Test(httptest.NewRequest("GET", "/abc123", nil), -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("unsigned code with signing disabled: status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+}
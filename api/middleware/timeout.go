@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Timeout gives each request d to finish. Its context, which the request's
+// Redis commands run under, is cancelled at the deadline, so a slow Redis
+// fails the request with 504 Gateway Timeout instead of holding it open.
+// Responses that succeeded just before the deadline are left alone.
+func Timeout(d time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), d)
+		defer cancel()
+		c.SetUserContext(ctx)
+
+		err := c.Next()
+		if ctx.Err() == context.DeadlineExceeded && (err != nil || c.Response().StatusCode() >= fiber.StatusInternalServerError) {
+			c.Response().Reset()
+			return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": "request timed out"})
+		}
+		return err
+	}
+}
@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestTimeout(t *testing.T) {
+	app := fiber.New()
+	app.Use(Timeout(20 * time.Millisecond))
+	// a store call that only returns once the request's context gives up
+	app.Get("/slow", func(c *fiber.Ctx) error {
+		<-c.UserContext().Done()
+		return c.UserContext().Err()
+	})
+	app.Get("/slow-500", func(c *fiber.Ctx) error {
+		<-c.UserContext().Done()
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	})
+	app.Get("/fast", func(c *fiber.Ctx) error {
+		if _, ok := c.UserContext().Deadline(); !ok {
+			t.Error("the request context has no deadline")
+		}
+		return c.SendStatus(fiber.StatusOK)
+	})
+	// finished, if late, without an error
+	app.Get("/late", func(c *fiber.Ctx) error {
+		<-c.UserContext().Done()
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/slow", fiber.StatusGatewayTimeout},
+		{"/slow-500", fiber.StatusGatewayTimeout},
+		{"/fast", fiber.StatusOK},
+		{"/late", fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		start := time.Now()
+		resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil), -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+		if took := time.Since(start); took > time.Second {
+			t.Errorf("GET %s took %v", tt.path, took)
+		}
+	}
+}
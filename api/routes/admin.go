@@ -0,0 +1,218 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/middleware"
+)
+
+// ReverseLookup returns every short code that currently points at the given
+// destination URL.
+func ReverseLookup(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	url := c.Query("url")
+	if url == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "url query parameter is required"})
+	}
+	// normalize the same way shorten does before storing
+	url = canonicalDestination(url)
+	tenant := c.Query("tenant")
+
+	r := database.CreateClientContext(ctx, 0)
+
+	ids, err := r.SMembers(ctx, database.ReverseKey(tenant, url)).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	shorts := []string{}
+	for _, id := range ids {
+		// drop codes that expired or were re-pointed since they were indexed
+		link, err := database.GetLink(ctx, r, database.LinkKey(tenant, id))
+		if err == redis.Nil || (err == nil && link.URL != url) {
+			r.SRem(ctx, database.ReverseKey(tenant, url), id)
+			continue
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		shorts = append(shorts, id)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"url": url, "shorts": shorts})
+}
+
+// ListDomains returns the custom domain registry.
+func ListDomains(c *fiber.Ctx) error {
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	domains, err := database.Domains(c.UserContext(), r)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"domains": domains})
+}
+
+// SetDomain registers a custom domain for a tenant.
+func SetDomain(c *fiber.Ctx) error {
+	body := struct {
+		TenantID string `json:"tenant_id"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cannot parse JSON"})
+	}
+	if body.TenantID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "tenant_id is required"})
+	}
+
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	domain := c.Params("domain")
+	if err := database.SetDomainTenant(c.UserContext(), r, domain, body.TenantID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"domain": domain, "tenant_id": body.TenantID})
+}
+
+// DeleteDomain unregisters a custom domain. Its tenant's links are kept.
+func DeleteDomain(c *fiber.Ctx) error {
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	if err := database.RemoveDomain(c.UserContext(), r, c.Params("domain")); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// apiKeyScopes are the scopes an API key can be granted.
+var apiKeyScopes = map[string]bool{
+	database.ScopeRead:  true,
+	database.ScopeWrite: true,
+	database.ScopeAdmin: true,
+	// premium may claim custom shorts matching PREMIUM_SHORTS
+	"premium": true,
+}
+
+var errKeyRateLimit = errors.New(`rate_limit must be a positive number or "unlimited"`)
+
+// keyRateLimit is an API key's own shorten quota: a positive number per
+// window, or "unlimited". Left out, the key gets the usual per-IP API_QUOTA.
+type keyRateLimit int
+
+func (l *keyRateLimit) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		if s != "unlimited" {
+			return errKeyRateLimit
+		}
+		*l = database.Unlimited
+		return nil
+	}
+	var n int
+	if err := json.Unmarshal(b, &n); err != nil || n <= 0 {
+		return errKeyRateLimit
+	}
+	*l = keyRateLimit(n)
+	return nil
+}
+
+func (l keyRateLimit) MarshalJSON() ([]byte, error) {
+	if l == database.Unlimited {
+		return []byte(`"unlimited"`), nil
+	}
+	return json.Marshal(int(l))
+}
+
+// CreateAPIKey issues a new API key with the requested scopes and, optionally,
+// a namespace for the codes it creates and its own rate limit. The key is only
+// returned here; afterwards it's known by its id, the key's hash.
+func CreateAPIKey(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	body := struct {
+		Scopes    []string     `json:"scopes"`
+		Namespace string       `json:"namespace"`
+		RateLimit keyRateLimit `json:"rate_limit"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		if errors.Is(err, errKeyRateLimit) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cannot parse JSON"})
+	}
+	for _, s := range body.Scopes {
+		if !apiKeyScopes[s] {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown scope " + s})
+		}
+	}
+	if body.Namespace != "" {
+		if err := helpers.ValidateNamespace(body.Namespace); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	// several keys may share a team's namespace
+	if body.Namespace != "" {
+		if err := database.ClaimNamespace(ctx, r, body.Namespace); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+	}
+
+	raw := uuid.New().String()
+	id := helpers.HashToken(raw)
+	key := &database.APIKey{
+		Scopes:    body.Scopes,
+		CreatedAt: time.Now(),
+		Namespace: body.Namespace,
+		RateLimit: int(body.RateLimit),
+	}
+	if err := database.SaveAPIKey(ctx, r, id, key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"id":         id,
+		"key":        raw,
+		"scopes":     key.Scopes,
+		"namespace":  key.Namespace,
+		"rate_limit": body.RateLimit,
+	})
+}
+
+// DeleteAPIKey revokes the API key with the given id.
+func DeleteAPIKey(c *fiber.Ctx) error {
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	if err := database.DeleteAPIKey(c.UserContext(), r, c.Params("id")); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// GetReadOnly reports whether this instance is in read-only mode.
+func GetReadOnly(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"read_only": middleware.ReadOnly()})
+}
+
+// SetReadOnly turns read-only mode on or off for this instance until it
+// restarts, when READ_ONLY applies again.
+func SetReadOnly(c *fiber.Ctx) error {
+	body := struct {
+		ReadOnly *bool `json:"read_only"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cannot parse JSON"})
+	}
+	if body.ReadOnly == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "read_only is required"})
+	}
+
+	middleware.SetReadOnly(*body.ReadOnly)
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"read_only": *body.ReadOnly})
+}
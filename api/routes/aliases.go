@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"fmt"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+type aliasRequest struct {
+	Alias            string `json:"alias"`
+	ReservationToken string `json:"reservation_token"`
+}
+
+// CreateAlias gives an existing link another code. The alias shares the
+// link's record, so updates made through either code apply to both, and it
+// expires with the link. Only the link's owner may add aliases, and no more
+// than MAX_ALIASES_PER_LINK of them when that's set.
+func CreateAlias(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	body := new(aliasRequest)
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	if body.Alias == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "alias is required"})
+	}
+	short := body.Alias
+	body.Alias = helpers.Namespaced(namespace(c), short)
+	if err := helpers.ValidateCustomShort(body.Alias); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if helpers.IsPremiumShort(short) && !canClaimPremium(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "custom short is reserved for premium use", "code": "custom_short_premium"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	tenant, err := database.TenantForHost(ctx, r, c.Hostname())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	key := database.LinkKey(tenant, id)
+
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !isOwner(c, link) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid edit token"})
+	}
+	taken, err := intoNamespace(c, r, short)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if taken {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "custom short is in another namespace", "code": "custom_short_namespace"})
+	}
+	// an alias of an alias points at the link itself
+	if link.PrimaryKey != "" {
+		key = link.PrimaryKey
+	}
+	if maxAliases := config.Int("MAX_ALIASES_PER_LINK", 0); maxAliases > 0 {
+		aliases, err := database.LinkAliases(ctx, r, key)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if len(aliases) >= maxAliases {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("a link can have at most %d aliases", maxAliases), "code": "alias_limit"})
+		}
+	}
+
+	canonical := helpers.CanonicalCode(body.Alias)
+	alias := helpers.SignCode(canonical)
+	display := body.Alias + alias[len(canonical):]
+	aliasKey := database.LinkKey(tenant, alias)
+	claimed, err := database.ClaimLink(ctx, r, aliasKey)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !claimed {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "URL custom short is already in use", "code": "custom_short_taken"})
+	}
+	defer database.ReleaseLink(ctx, r, aliasKey)
+	exists, err := r.Exists(ctx, aliasKey).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if exists > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "URL custom short is already in use", "code": "custom_short_taken"})
+	}
+	reclaimable, err := checkReclaim(ctx, r, aliasKey)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !reclaimable {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "URL custom short expired recently and can't be reused yet", "code": "custom_short_expired"})
+	}
+	claimable, err := checkReservation(ctx, r, aliasKey, body.ReservationToken)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !claimable {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "URL custom short is reserved", "code": "custom_short_reserved"})
+	}
+
+	if err := database.SaveAlias(ctx, r, aliasKey, key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	r.Del(ctx, reservationKey(aliasKey))
+
+	domain := helpers.ShortDomain(c.Hostname())
+	if tenant != "" {
+		domain = c.Hostname()
+	}
+	path, shortURL := helpers.BuildShortURL(c.Protocol(), domain, display)
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"code":       display,
+		"short_path": path,
+		"short_url":  shortURL,
+		"alias_of":   displayCode(database.LinkCode(key), link),
+	})
+}
+
+// DeleteAlias removes one of a link's aliases, leaving the link and its other
+// codes in place. The alias is named as it was when it was added: without the
+// API key's namespace, and with or without its signature.
+func DeleteAlias(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	r := database.CreateClientContext(ctx, 0)
+
+	tenant, err := database.TenantForHost(ctx, r, c.Hostname())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	key := database.LinkKey(tenant, id)
+
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !isOwner(c, link) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid edit token"})
+	}
+	if link.PrimaryKey != "" {
+		key = link.PrimaryKey
+	}
+
+	aliasKey := database.LinkKey(tenant, aliasCode(c, c.Params("alias")))
+	alias, err := database.GetLink(ctx, r, aliasKey)
+	if err != nil && err != redis.Nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if err == redis.Nil || alias.PrimaryKey != key {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "alias not found"})
+	}
+
+	if err := database.DeleteAlias(ctx, r, aliasKey, key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	// an alias only has analytics of its own with ALIAS_STATS=separate
+	if purgeAnalytics() != "never" {
+		rStats := database.CreateClientContext(ctx, 1)
+		if err := database.DeleteClicks(ctx, rStats, aliasKey); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// aliasCode is the code the alias named raw was stored under by CreateAlias:
+// put in the request's namespace and canonical form, and signed unless raw
+// carries its signature already.
+func aliasCode(c *fiber.Ctx, raw string) string {
+	alias := helpers.CanonicalCode(helpers.Namespaced(namespace(c), helpers.NormalizeCode(raw)))
+	if helpers.SigningEnabled() && !helpers.VerifyCode(alias) {
+		alias = helpers.SignCode(alias)
+	}
+	return alias
+}
+
+// statsKey is the key clicks on the link loaded from key are counted under.
+// Aliases share their link's stats unless ALIAS_STATS=separate.
+func statsKey(key string, link *database.Link) string {
+	if link.PrimaryKey != "" && config.String("ALIAS_STATS", "shared") != "separate" {
+		return link.PrimaryKey
+	}
+	return key
+}
@@ -0,0 +1,239 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestAliasCreateDelete(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		namespace string
+		alias     string
+		display   string
+		// deleteAs is how the alias is named when it's deleted
+		deleteAs string
+	}{
+		{"plain", nil, "", "promo", "promo", "promo"},
+		{"mixed case", nil, "", "MyPromo", "MyPromo", "MyPromo"},
+		{"case-insensitive", map[string]string{"CASE_INSENSITIVE_SHORTS": "true"}, "", "MyPromo", "MyPromo", "MYPROMO"},
+		{"namespaced", nil, "team", "promo", "team/promo", "promo"},
+		{"namespaced, case-insensitive", map[string]string{"CASE_INSENSITIVE_SHORTS": "true"}, "Team", "Promo", "Team/Promo", "pROMO"},
+		{"escaped", nil, "", "docs/start", "docs/start", "docs%2Fstart"},
+		{"signed", map[string]string{"CODE_SIGNING_KEY": "secret"}, "", "promo", "", "promo"},
+		{"signed, with signature", map[string]string{"CODE_SIGNING_KEY": "secret"}, "team", "promo", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DOMAIN", "sho.rt")
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+			app := newTestApp()
+			saveKey(t, "key-team", &database.APIKey{Namespace: tt.namespace})
+			code := helpers.SignCode("base")
+			seedLink(t, code, &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+			header := map[string]string{"X-API-Key": "key-team", "X-Edit-Token": "tok"}
+
+			resp := sendHeaders(t, app, "POST", "/"+code+"/alias", fmt.Sprintf(`{"alias":%q}`, tt.alias), header)
+			body := decode(t, resp)
+			if resp.StatusCode != fiber.StatusCreated {
+				t.Fatalf("create: status = %d (%v), want %d", resp.StatusCode, body, fiber.StatusCreated)
+			}
+			display := fmt.Sprint(body["code"])
+			if tt.display != "" && display != tt.display {
+				t.Errorf("create: code = %q, want %q", display, tt.display)
+			}
+			if body["short_path"] != "sho.rt/"+display || body["short_url"] != "http://sho.rt/"+display {
+				t.Errorf("create: short_path = %v, short_url = %v; want both built from sho.rt/%s", body["short_path"], body["short_url"], display)
+			}
+			if resp := send(t, app, "GET", "/"+display, "", ""); resp.StatusCode != fiber.StatusFound {
+				t.Fatalf("alias resolves with status %d, want %d", resp.StatusCode, fiber.StatusFound)
+			}
+
+			deleteAs := tt.deleteAs
+			if deleteAs == "" {
+				// the signed code, without the namespace it was put in
+				deleteAs = strings.TrimPrefix(display, helpers.Namespaced(tt.namespace, ""))
+			}
+			if resp := sendHeaders(t, app, "DELETE", "/"+code+"/alias/"+deleteAs, "", header); resp.StatusCode != fiber.StatusNoContent {
+				t.Fatalf("delete as %q: status = %d (%v), want %d", deleteAs, resp.StatusCode, decode(t, resp), fiber.StatusNoContent)
+			}
+			if resp := send(t, app, "GET", "/"+display, "", ""); resp.StatusCode != fiber.StatusNotFound {
+				t.Errorf("deleted alias resolves with status %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+			}
+			if resp := send(t, app, "GET", "/"+code, "", ""); resp.StatusCode != fiber.StatusFound {
+				t.Errorf("link resolves with status %d after its alias was deleted, want %d", resp.StatusCode, fiber.StatusFound)
+			}
+		})
+	}
+}
+
+func TestDeleteAliasOfAnotherLink(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "one", &database.Link{URL: "https://example.com/1", EditTokenHash: helpers.HashToken("tok")})
+	seedLink(t, "two", &database.Link{URL: "https://example.com/2", EditTokenHash: helpers.HashToken("tok")})
+	header := map[string]string{"X-Edit-Token": "tok"}
+	if resp := sendHeaders(t, app, "POST", "/one/alias", `{"alias":"uno"}`, header); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("create: status = %d, want %d", resp.StatusCode, fiber.StatusCreated)
+	}
+	if resp := sendHeaders(t, app, "DELETE", "/two/alias/uno", "", header); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("delete through another link: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+	if resp := sendHeaders(t, app, "DELETE", "/one/alias/uno", "", map[string]string{"X-Edit-Token": "wrong"}); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("delete with a wrong edit token: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+}
+
+func TestAliasLimit(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "base", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+	header := map[string]string{"X-Edit-Token": "tok"}
+	create := func(alias string) *http.Response {
+		t.Helper()
+		return sendHeaders(t, app, "POST", "/base/alias", fmt.Sprintf(`{"alias":%q}`, alias), header)
+	}
+
+	// unlimited by default
+	for i := 0; i < 5; i++ {
+		if resp := create(fmt.Sprintf("many-%d", i)); resp.StatusCode != fiber.StatusCreated {
+			t.Fatalf("alias %d without a limit: status = %d", i, resp.StatusCode)
+		}
+	}
+
+	t.Setenv("MAX_ALIASES_PER_LINK", "5")
+	if resp := create("sixth"); resp.StatusCode != fiber.StatusBadRequest {
+		t.Fatalf("past the limit: status = %d, want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+	if resp := sendHeaders(t, app, "DELETE", "/base/alias/many-0", "", header); resp.StatusCode >= 300 {
+		t.Fatalf("delete: status = %d", resp.StatusCode)
+	}
+	// deleting one frees its slot, and only that one
+	if resp := create("sixth"); resp.StatusCode != fiber.StatusCreated {
+		t.Errorf("after deleting an alias: status = %d, want %d", resp.StatusCode, fiber.StatusCreated)
+	}
+	if resp := create("seventh"); resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("past the limit again: status = %d, want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+func TestAliasValidation(t *testing.T) {
+	t.Setenv("MAX_ALIASES_PER_LINK", "2")
+	app := newTestApp()
+	seedLink(t, "base", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+	seedLink(t, "taken", &database.Link{URL: "https://example.com/taken"})
+	header := map[string]string{"X-Edit-Token": "tok"}
+
+	tests := []struct {
+		name   string
+		alias  string
+		status int
+		code   string
+	}{
+		{"empty", "", fiber.StatusBadRequest, ""},
+		{"invalid characters", "no spaces", fiber.StatusBadRequest, ""},
+		{"internal key name", "a:b", fiber.StatusBadRequest, ""},
+		{"an existing link", "taken", fiber.StatusConflict, "custom_short_taken"},
+		{"first", "first", fiber.StatusCreated, ""},
+		{"already an alias", "first", fiber.StatusConflict, "custom_short_taken"},
+		{"second", "second", fiber.StatusCreated, ""},
+		{"over the limit", "third", fiber.StatusBadRequest, "alias_limit"},
+	}
+	for _, tt := range tests {
+		resp := sendHeaders(t, app, "POST", "/base/alias", fmt.Sprintf(`{"alias":%q}`, tt.alias), header)
+		body := decode(t, resp)
+		if resp.StatusCode != tt.status || (tt.code != "" && body["code"] != tt.code) {
+			t.Errorf("%s: %d %v, want %d %s", tt.name, resp.StatusCode, body, tt.status, tt.code)
+		}
+	}
+	if resp := send(t, app, "POST", "/base/alias", `{"alias":"mine"}`, ""); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("without the edit token: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	if resp := sendHeaders(t, app, "POST", "/missing/alias", `{"alias":"mine"}`, header); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("alias of a missing link: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+}
+
+func TestAliasStats(t *testing.T) {
+	tests := []struct {
+		mode       string
+		linkClicks float64
+		aliasStats float64
+	}{
+		{"", 3, 3},
+		{"shared", 3, 3},
+		{"separate", 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv("ALIAS_STATS", tt.mode)
+			app := newTestApp()
+			seedLink(t, "base", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+			if resp := sendHeaders(t, app, "POST", "/base/alias", `{"alias":"promo"}`, map[string]string{"X-Edit-Token": "tok"}); resp.StatusCode != fiber.StatusCreated {
+				t.Fatalf("create: status = %d", resp.StatusCode)
+			}
+			for _, path := range []string{"/base", "/promo", "/promo"} {
+				sendHeaders(t, app, "GET", path, "", map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"})
+			}
+			if got := decode(t, send(t, app, "GET", "/api/v1/stats/base", "", ""))["clicks"]; got != tt.linkClicks {
+				t.Errorf("link clicks = %v, want %v", got, tt.linkClicks)
+			}
+			if got := decode(t, send(t, app, "GET", "/api/v1/stats/promo", "", ""))["clicks"]; got != tt.aliasStats {
+				t.Errorf("alias clicks = %v, want %v", got, tt.aliasStats)
+			}
+		})
+	}
+}
+
+func TestDeleteLinkWithAliases(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"base","tags":["doomed"],"permanent":false}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	owner := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+	for _, alias := range []string{"uno", "dos"} {
+		if resp := sendHeaders(t, app, "POST", "/base/alias", fmt.Sprintf(`{"alias":%q}`, alias), owner); resp.StatusCode != fiber.StatusCreated {
+			t.Fatalf("create %s: status = %d", alias, resp.StatusCode)
+		}
+	}
+
+	// the alias shares the link's record, so an update through it moves both
+	if resp := sendHeaders(t, app, "PATCH", "/uno", `{"url":"https://example.com/moved"}`, owner); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update through the alias: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	for _, path := range []string{"/base", "/uno", "/dos"} {
+		if resp := send(t, app, "GET", path, "", ""); resp.Header.Get(fiber.HeaderLocation) != "https://example.com/moved" {
+			t.Errorf("GET %s after the update: %d to %q", path, resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+		}
+	}
+
+	// deleting an alias leaves the rest; deleting the link takes its aliases
+	if resp := sendHeaders(t, app, "DELETE", "/base/alias/dos", "", owner); resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("delete alias: status = %d", resp.StatusCode)
+	}
+	if resp := send(t, app, "GET", "/uno", "", ""); resp.StatusCode != fiber.StatusFound {
+		t.Errorf("GET /uno after deleting another alias: status = %d, want %d", resp.StatusCode, fiber.StatusFound)
+	}
+	if resp := sendHeaders(t, app, "DELETE", "/api/v1/links?tag=doomed", "", admin); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("delete link: status = %d", resp.StatusCode)
+	}
+	for _, path := range []string{"/base", "/uno", "/dos"} {
+		if resp := send(t, app, "GET", path, "", ""); resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("GET %s after deleting the link: status = %d, want %d", path, resp.StatusCode, fiber.StatusNotFound)
+		}
+	}
+	// and frees their codes
+	if resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"uno"}`, ""); resp.StatusCode != fiber.StatusCreated {
+		t.Errorf("reusing a deleted alias: status = %d, want %d", resp.StatusCode, fiber.StatusCreated)
+	}
+}
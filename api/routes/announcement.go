@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// announcementSeverities are the severities an announcement can have, which
+// front-ends map to banner styles.
+var announcementSeverities = map[string]bool{"info": true, "warning": true, "critical": true}
+
+// announcementInfo is the JSON form of an announcement.
+type announcementInfo struct {
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+	// ExpiresAt is when the announcement is taken down; null if it stays
+	// until it's cleared.
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func newAnnouncementInfo(a *database.Announcement) announcementInfo {
+	info := announcementInfo{Message: a.Message, Severity: a.Severity}
+	if !a.ExpiresAt.IsZero() {
+		t := a.ExpiresAt.UTC()
+		info.ExpiresAt = &t
+	}
+	return info
+}
+
+// Announcement returns the current announcement for front-ends to show as a
+// banner, or 204 when there is none.
+func Announcement(c *fiber.Ctx) error {
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	a, err := database.GetAnnouncement(c.UserContext(), r)
+	if err == redis.Nil {
+		return c.SendStatus(fiber.StatusNoContent)
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.Status(fiber.StatusOK).JSON(newAnnouncementInfo(a))
+}
+
+// SetAnnouncement replaces the current announcement. Its expiry is a duration
+// such as "2h" or "3d" after which it's taken down on its own; left out, or
+// "never", it stays until it's cleared.
+func SetAnnouncement(c *fiber.Ctx) error {
+	body := struct {
+		Message  string `json:"message"`
+		Severity string `json:"severity"`
+		Expiry   string `json:"expiry"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cannot parse JSON"})
+	}
+	body.Message = strings.TrimSpace(body.Message)
+	if body.Message == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "message is required"})
+	}
+	if body.Severity == "" {
+		body.Severity = "info"
+	}
+	if !announcementSeverities[body.Severity] {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "severity must be info, warning or critical"})
+	}
+	var ttl time.Duration
+	if body.Expiry != "" {
+		d, err := helpers.ParseExpiry(body.Expiry)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if d != helpers.NeverExpires {
+			ttl = d
+		}
+	}
+
+	a := &database.Announcement{Message: body.Message, Severity: body.Severity}
+	if ttl > 0 {
+		a.ExpiresAt = time.Now().Add(ttl).Truncate(time.Second)
+	}
+
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	if err := database.SetAnnouncement(c.UserContext(), r, a, ttl); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.Status(fiber.StatusOK).JSON(newAnnouncementInfo(a))
+}
+
+// ClearAnnouncement takes the current announcement down.
+func ClearAnnouncement(c *fiber.Ctx) error {
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	if err := database.ClearAnnouncement(c.UserContext(), r); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
@@ -0,0 +1,66 @@
+package routes
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAnnouncement(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+
+	if resp := send(t, app, "GET", "/api/v1/announcement", "", ""); resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("no announcement: status = %d, want %d", resp.StatusCode, fiber.StatusNoContent)
+	}
+
+	for _, tt := range []struct {
+		name, body string
+		want       int
+	}{
+		{"not admin", `{"message":"hi"}`, fiber.StatusUnauthorized},
+		{"no message", `{"message":"  "}`, fiber.StatusBadRequest},
+		{"bad severity", `{"message":"hi","severity":"loud"}`, fiber.StatusBadRequest},
+		{"bad expiry", `{"message":"hi","expiry":"soon"}`, fiber.StatusBadRequest},
+	} {
+		header := admin
+		if tt.want == fiber.StatusUnauthorized {
+			header = nil
+		}
+		resp := sendHeaders(t, app, "PUT", "/api/v1/admin/announcement", tt.body, header)
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+
+	start := time.Now().Truncate(time.Second)
+	resp := sendHeaders(t, app, "PUT", "/api/v1/admin/announcement", `{"message":"Maintenance at 02:00 UTC","severity":"warning","expiry":"2h"}`, admin)
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("set: %d %v", resp.StatusCode, body)
+	}
+	resp = send(t, app, "GET", "/api/v1/announcement", "", "")
+	got := decode(t, resp)
+	if resp.StatusCode != fiber.StatusOK || got["message"] != "Maintenance at 02:00 UTC" || got["severity"] != "warning" {
+		t.Fatalf("get: %d %v", resp.StatusCode, got)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, fmt.Sprint(got["expires_at"]))
+	if err != nil || expiresAt.Before(start.Add(2*time.Hour)) || expiresAt.After(time.Now().Add(2*time.Hour)) {
+		t.Errorf("expires_at = %v, want 2h from now", got["expires_at"])
+	}
+
+	// a new one replaces it, defaulting to info and no expiry
+	sendHeaders(t, app, "PUT", "/api/v1/admin/announcement", `{"message":"All good"}`, admin)
+	got = decode(t, send(t, app, "GET", "/api/v1/announcement", "", ""))
+	if got["message"] != "All good" || got["severity"] != "info" || got["expires_at"] != nil {
+		t.Errorf("replaced: %v, want All good at info with no expiry", got)
+	}
+
+	if resp := sendHeaders(t, app, "DELETE", "/api/v1/admin/announcement", "", admin); resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("clear: status = %d", resp.StatusCode)
+	}
+	if resp := send(t, app, "GET", "/api/v1/announcement", "", ""); resp.StatusCode != fiber.StatusNoContent {
+		t.Errorf("after clearing: status = %d, want %d", resp.StatusCode, fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,237 @@
+package routes
+
+import (
+	"bytes"
+	"context"
+	"html"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Social cards are rendered at the size Open Graph images are shown at.
+const (
+	cardWidth    = 1200
+	cardHeight   = 630
+	cardMargin   = 80
+	cardMaxLines = 3
+)
+
+// Fetching a destination's title is bounded so a slow or huge page can't tie
+// up the handler.
+const (
+	titleTimeout  = 3 * time.Second
+	titleMaxBytes = 256 << 10
+	// titleTTL is how long a fetched title is reused before fetching it again.
+	titleTTL = time.Hour
+)
+
+var (
+	cardBackground = color.RGBA{0x1f, 0x29, 0x37, 0xff}
+	cardForeground = color.RGBA{0xf9, 0xfa, 0xfb, 0xff}
+	cardMuted      = color.RGBA{0x9c, 0xa3, 0xaf, 0xff}
+	cardAccent     = color.RGBA{0x60, 0xa5, 0xfa, 0xff}
+
+	titleFace = mustFace(gobold.TTF, 56)
+	textFace  = mustFace(goregular.TTF, 36)
+)
+
+// titleClient can't reach private addresses, so a link can't be used to read
+// internal pages back through its card.
+var titleClient = helpers.PublicClient(titleTimeout)
+
+var titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+
+func mustFace(ttf []byte, size float64) font.Face {
+	f, err := opentype.Parse(ttf)
+	if err != nil {
+		panic(err)
+	}
+	face, err := opentype.NewFace(f, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+	if err != nil {
+		panic(err)
+	}
+	return face
+}
+
+// LinkCard renders a PNG social card for a short link, for use as its Open
+// Graph image: the destination's page title and host, and the short URL.
+// Links created with hide_destination are refused, as they are by peek, and
+// so are links that wouldn't resolve, before their destination is fetched.
+func LinkCard(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	r := database.CreateClientContext(ctx, 0)
+
+	tenant, err := database.TenantForHost(ctx, r, c.Hostname())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	key := database.LinkKey(tenant, id)
+
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if link.Disabled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "this link has been disabled", "code": "link_disabled"})
+	}
+	if link.Expired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "short link has expired", "code": "link_expired"})
+	}
+	if link.HideDestination {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "destination is hidden for this link"})
+	}
+
+	rTitles := database.CreateClientContext(ctx, 1)
+
+	host := link.URL
+	if u, err := url.Parse(link.URL); err == nil && u.Host != "" {
+		host = u.Host
+	}
+	title := cachedTitle(ctx, rTitles, link.URL)
+	if title == "" {
+		title = host
+	}
+
+	domain := helpers.ShortDomain(c.Hostname())
+	if tenant != "" {
+		domain = c.Hostname()
+	}
+	path, _ := helpers.BuildShortURL(c.Protocol(), domain, id)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, renderCard(title, host, path)); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot render card"})
+	}
+	c.Set(fiber.HeaderContentType, "image/png")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=3600")
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// cachedTitle returns the page title of dest, fetching it at most once per
+// titleTTL. It returns "" when the page has no title or can't be fetched.
+func cachedTitle(ctx context.Context, rdb database.Store, dest string) string {
+	cacheKey := "card_title:" + dest
+	if title, err := rdb.Get(ctx, cacheKey).Result(); err == nil {
+		return title
+	}
+	title := fetchTitle(ctx, dest)
+	// failures are cached too, so a dead destination isn't fetched every time
+	rdb.Set(ctx, cacheKey, title, titleTTL)
+	return title
+}
+
+// fetchTitle reads the <title> of the page at dest from its first
+// titleMaxBytes.
+func fetchTitle(ctx context.Context, dest string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, dest, nil)
+	if err != nil {
+		return ""
+	}
+	resp, err := titleClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, titleMaxBytes))
+	if err != nil {
+		return ""
+	}
+	m := titlePattern.FindSubmatch(body)
+	if m == nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(html.UnescapeString(string(m[1]))), " ")
+}
+
+// renderCard draws the card: host at the top, the title wrapped to at most
+// cardMaxLines lines, and the short URL at the bottom.
+func renderCard(title, host, shortURL string) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	draw.Draw(img, img.Bounds(), image.NewUniform(cardBackground), image.Point{}, draw.Src)
+
+	width := fixed.I(cardWidth - 2*cardMargin)
+	drawText(img, textFace, cardMuted, cardMargin, cardMargin+36, truncate(textFace, host, width))
+
+	lineHeight := titleFace.Metrics().Height.Ceil() + 8
+	y := cardMargin + 36 + 40 + lineHeight
+	for _, line := range wrap(titleFace, title, width, cardMaxLines) {
+		drawText(img, titleFace, cardForeground, cardMargin, y, line)
+		y += lineHeight
+	}
+
+	drawText(img, textFace, cardAccent, cardMargin, cardHeight-cardMargin, truncate(textFace, shortURL, width))
+	return img
+}
+
+func drawText(img draw.Image, face font.Face, col color.Color, x, y int, s string) {
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(col), Face: face, Dot: fixed.P(x, y)}
+	d.DrawString(s)
+}
+
+// wrap breaks s into lines no wider than width, ending the last of at most
+// maxLines lines with an ellipsis when s doesn't fit.
+func wrap(face font.Face, s string, width fixed.Int26_6, maxLines int) []string {
+	var lines []string
+	line := ""
+	words := strings.Fields(s)
+	for i, w := range words {
+		next := w
+		if line != "" {
+			next = line + " " + w
+		}
+		if font.MeasureString(face, next) <= width {
+			line = next
+			continue
+		}
+		if line != "" {
+			lines = append(lines, truncate(face, line, width))
+		}
+		line = w
+		if len(lines) == maxLines-1 {
+			// everything left goes on the last line
+			lines = append(lines, truncate(face, strings.Join(words[i:], " "), width))
+			return lines
+		}
+	}
+	if line != "" {
+		lines = append(lines, truncate(face, line, width))
+	}
+	return lines
+}
+
+// truncate shortens s with an ellipsis until it is no wider than width.
+func truncate(face font.Face, s string, width fixed.Int26_6) string {
+	if font.MeasureString(face, s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && font.MeasureString(face, string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
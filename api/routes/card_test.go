@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// roundTripFunc is an http.RoundTripper that calls itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// stubTitles makes titleClient answer every fetch with a page titled title
+// for the rest of t, and returns how many fetches were made.
+func stubTitles(t *testing.T, title string) *atomic.Int32 {
+	var fetches atomic.Int32
+	saved := titleClient
+	titleClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		fetches.Add(1)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Body:       io.NopCloser(strings.NewReader("<html><title>" + title + "</title></html>")),
+			Request:    req,
+		}, nil
+	})}
+	t.Cleanup(func() { titleClient = saved })
+	return &fetches
+}
+
+func TestLinkCard(t *testing.T) {
+	app := newTestApp()
+	fetches := stubTitles(t, "Example &amp; Co")
+	seedLink(t, "card", &database.Link{URL: "https://example.com/page"})
+
+	for i := 0; i < 2; i++ {
+		resp := send(t, app, "GET", "/api/v1/card/card", "", "")
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+		}
+		if got := resp.Header.Get(fiber.HeaderContentType); got != "image/png" {
+			t.Errorf("Content-Type = %q, want image/png", got)
+		}
+		img, err := png.Decode(resp.Body)
+		if err != nil {
+			t.Fatalf("not a PNG: %v", err)
+		}
+		if b := img.Bounds(); b.Dx() != cardWidth || b.Dy() != cardHeight {
+			t.Errorf("card is %dx%d, want %dx%d", b.Dx(), b.Dy(), cardWidth, cardHeight)
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("title fetched %d times for two cards, want once", n)
+	}
+}
+
+func TestLinkCardGating(t *testing.T) {
+	app := newTestApp()
+	fetches := stubTitles(t, "Internal")
+	seedLink(t, "card-off", &database.Link{URL: "https://example.com/off", Disabled: true})
+	seedLink(t, "card-gone", &database.Link{URL: "https://example.com/gone", Expired: true})
+	seedLink(t, "card-hide", &database.Link{URL: "https://example.com/hide", HideDestination: true})
+
+	tests := []struct {
+		code   string
+		status int
+	}{
+		{"card-off", fiber.StatusForbidden},
+		{"card-gone", fiber.StatusGone},
+		{"card-hide", fiber.StatusForbidden},
+		{"card-none", fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if resp := send(t, app, "GET", "/api/v1/card/"+tt.code, "", ""); resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.code, resp.StatusCode, tt.status)
+		}
+	}
+	if n := fetches.Load(); n != 0 {
+		t.Errorf("%d titles fetched for refused cards, want none", n)
+	}
+}
+
+func TestFetchTitle(t *testing.T) {
+	stubTitles(t, "  A\n  spaced &lt;title&gt; ")
+	if got := fetchTitle(t.Context(), "https://example.com/"); got != "A spaced <title>" {
+		t.Errorf("fetchTitle = %q, want %q", got, "A spaced <title>")
+	}
+}
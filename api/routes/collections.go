@@ -0,0 +1,212 @@
+package routes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/middleware"
+)
+
+const (
+	maxCollectionName = 100
+	// maxCollectionLinks bounds the work one stats request does.
+	maxCollectionLinks = 1000
+)
+
+// CreateCollection starts an empty, named collection of links. Like a link,
+// it's managed with the returned edit token, which is only shown here, or the
+// API key that created it.
+func CreateCollection(c *fiber.Ctx) error {
+	body := struct {
+		Name string `json:"name"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	if body.Name == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "name is required"})
+	}
+	if len(body.Name) > maxCollectionName {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("name must be at most %d characters", maxCollectionName)})
+	}
+
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	editToken := uuid.New().String()
+	col := &database.Collection{
+		ID:            uuid.New().String()[:8],
+		Name:          body.Name,
+		CreatedAt:     time.Now(),
+		EditTokenHash: helpers.HashToken(editToken),
+	}
+	if key := middleware.APIKey(c); key != nil {
+		col.Owner = key.ID
+	}
+	if err := database.SaveCollection(c.UserContext(), r, col); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"id":         col.ID,
+		"name":       col.Name,
+		"edit_token": editToken,
+	})
+}
+
+// AddCollectionLinks adds links, by code, to a collection. Every code must be
+// a live link; none are added otherwise. Adding a link that's already in the
+// collection does nothing.
+func AddCollectionLinks(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	body := struct {
+		Codes []string `json:"codes"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	if len(body.Codes) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "codes is required"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	col, err := database.GetCollection(ctx, r, c.Params("id"))
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "collection not found"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !ownsCollection(c, col) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid edit token"})
+	}
+
+	members, err := database.CollectionLinks(ctx, r, col.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if len(members)+len(body.Codes) > maxCollectionLinks {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("a collection can hold at most %d links", maxCollectionLinks)})
+	}
+
+	keys := make([]string, 0, len(body.Codes))
+	for _, code := range body.Codes {
+		code = helpers.NormalizeCode(code)
+		key, err := tenantKey(c, r, code)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if !helpers.VerifyCode(code) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database", "short": code})
+		}
+		if _, err := database.GetLink(ctx, r, key); err == redis.Nil {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database", "short": code})
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		keys = append(keys, key)
+	}
+
+	if err := database.AddToCollection(ctx, r, col.ID, keys...); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	members, err = database.CollectionLinks(ctx, r, col.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"id": col.ID, "links": len(members)})
+}
+
+type collectionMember struct {
+	Short  string `json:"short"`
+	Clicks int64  `json:"clicks"`
+}
+
+// CollectionStats sums the stats of a collection's links: total and bot
+// clicks, clicks per hour over the last day, and unique visitors across all
+// of them. Links that have expired are dropped from the collection.
+func CollectionStats(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	r := database.CreateClientContext(ctx, 0)
+
+	col, err := database.GetCollection(ctx, r, c.Params("id"))
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "collection not found"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	keys, err := database.CollectionLinks(ctx, r, col.ID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	rStats := database.CreateClientContext(ctx, 1)
+
+	var clicks, botClicks int64
+	series := make([]database.HourCount, statsHours)
+	members := []collectionMember{}
+	// an alias and its link share stats unless ALIAS_STATS=separate, and
+	// must only be counted once
+	counted := map[string]bool{}
+	var statsKeys []string
+	for _, key := range keys {
+		link, err := database.GetLink(ctx, r, key)
+		if err == redis.Nil {
+			_ = database.RemoveFromCollection(ctx, r, col.ID, key)
+			continue
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+
+		sk := statsKey(key, link)
+		linkClicks, linkSeries, err := database.LinkClicks(ctx, rStats, sk, statsHours)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		members = append(members, collectionMember{Short: database.LinkCode(key), Clicks: linkClicks})
+		if counted[sk] {
+			continue
+		}
+		counted[sk] = true
+		statsKeys = append(statsKeys, sk)
+
+		linkBots, err := database.LinkBotClicks(ctx, rStats, sk)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		clicks += linkClicks
+		botClicks += linkBots
+		for i, h := range linkSeries {
+			series[i].Hour = h.Hour
+			series[i].Clicks += h.Clicks
+		}
+	}
+	visitors, err := database.UniqueVisitors(ctx, rStats, statsKeys)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"id":              col.ID,
+		"name":            col.Name,
+		"created_at":      col.CreatedAt,
+		"links":           members,
+		"clicks":          clicks,
+		"bot_clicks":      botClicks,
+		"unique_visitors": visitors,
+		"series":          series,
+	})
+}
+
+// ownsCollection reports whether the request may change col: it carries the
+// collection's edit token, was made with the API key that created it, or is
+// an admin's.
+func ownsCollection(c *fiber.Ctx, col *database.Collection) bool {
+	if key := middleware.APIKey(c); key != nil && col.Owner != "" && key.ID == col.Owner {
+		return true
+	}
+	return helpers.TokenMatches(c.Get("X-Edit-Token"), col.EditTokenHash) || middleware.IsAdmin(c)
+}
@@ -0,0 +1,98 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestCollections(t *testing.T) {
+	app := newTestApp()
+	browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+	clicks := map[string]int{"col-a": 2, "col-b": 1, "col-c": 3}
+	for short, n := range clicks {
+		seedLink(t, short, &database.Link{URL: "https://example.com/" + short, EditTokenHash: helpers.HashToken("tok")})
+		for i := 0; i < n; i++ {
+			sendHeaders(t, app, "GET", "/"+short, "", browser)
+		}
+	}
+
+	resp := send(t, app, "POST", "/api/v1/collections", `{"name":"Spring campaign"}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated || created["name"] != "Spring campaign" {
+		t.Fatalf("create: %d %v", resp.StatusCode, created)
+	}
+	id := fmt.Sprint(created["id"])
+	owner := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+	add := func(codes string, header map[string]string) (int, map[string]interface{}) {
+		t.Helper()
+		resp := sendHeaders(t, app, "POST", "/api/v1/collections/"+id+"/links", `{"codes":`+codes+`}`, header)
+		return resp.StatusCode, decode(t, resp)
+	}
+	stats := func() map[string]interface{} {
+		t.Helper()
+		resp := send(t, app, "GET", "/api/v1/collections/"+id+"/stats", "", "")
+		body := decode(t, resp)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("stats: status = %d (%v)", resp.StatusCode, body)
+		}
+		return body
+	}
+
+	if status, body := add(`["col-a","col-b"]`, owner); status != fiber.StatusOK || body["links"] != float64(2) {
+		t.Fatalf("add: %d %v, want %d with 2 links", status, body, fiber.StatusOK)
+	}
+	// adding a member again does nothing
+	if status, body := add(`["col-a"]`, owner); status != fiber.StatusOK || body["links"] != float64(2) {
+		t.Errorf("add again: %d %v, want %d with 2 links", status, body, fiber.StatusOK)
+	}
+	// and one missing code adds none of them
+	if status, body := add(`["col-c","missing"]`, owner); status != fiber.StatusNotFound || body["short"] != "missing" {
+		t.Errorf("add a missing code: %d %v, want %d naming it", status, body, fiber.StatusNotFound)
+	}
+	if status, _ := add(`["col-c"]`, nil); status != fiber.StatusForbidden {
+		t.Errorf("add without the edit token: status = %d, want %d", status, fiber.StatusForbidden)
+	}
+
+	body := stats()
+	if body["name"] != "Spring campaign" || body["clicks"] != float64(3) || body["unique_visitors"] != float64(1) {
+		t.Errorf("stats = %v, want 3 clicks from 1 visitor", body)
+	}
+	if links := fmt.Sprint(body["links"]); !strings.Contains(links, "map[clicks:2 short:col-a]") || !strings.Contains(links, "map[clicks:1 short:col-b]") {
+		t.Errorf("links = %s, want col-a with 2 clicks and col-b with 1", links)
+	}
+
+	// an alias shares its link's clicks, which are only counted once
+	if resp := sendHeaders(t, app, "POST", "/col-a/alias", `{"alias":"col-a2"}`, map[string]string{"X-Edit-Token": "tok"}); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("alias: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	if status, _ := add(`["col-a2","col-c"]`, owner); status != fiber.StatusOK {
+		t.Fatalf("add the alias: status = %d", status)
+	}
+	if got := stats()["clicks"]; got != float64(6) {
+		t.Errorf("with the alias and col-c: clicks = %v, want 6", got)
+	}
+
+	// links that are gone drop out
+	database.CreateClient(0).Del(context.Background(), database.LinkKey("", "col-c"))
+	if got := stats(); got["clicks"] != float64(3) || len(got["links"].([]interface{})) != 3 {
+		t.Errorf("after col-c went: %v, want 3 clicks over col-a, its alias and col-b", got)
+	}
+	if members, _ := database.CollectionLinks(context.Background(), database.CreateClient(0), id); len(members) != 3 {
+		t.Errorf("members = %v, want col-c removed", members)
+	}
+
+	if resp := send(t, app, "GET", "/api/v1/collections/nope/stats", "", ""); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("unknown collection: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+	for _, body := range []string{`{}`, `{"name":""}`, fmt.Sprintf(`{"name":%q}`, strings.Repeat("n", maxCollectionName+1))} {
+		if resp := send(t, app, "POST", "/api/v1/collections", body, ""); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("create %s: status = %d, want %d", body, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
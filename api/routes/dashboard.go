@@ -0,0 +1,78 @@
+package routes
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"strconv"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+//go:embed templates/*.html
+var templates embed.FS
+
+var dashboardTemplate = template.Must(template.New("dashboard.html").Funcs(template.FuncMap{
+	"code": database.LinkCode,
+}).ParseFS(templates, "templates/dashboard.html"))
+
+// dashboardSize is how many links each dashboard table shows.
+const dashboardSize = 10
+
+// Dashboard renders the admin overview: totals, top links and recent links.
+func Dashboard(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	r := database.CreateClientContext(ctx, 0)
+	rStats := database.CreateClientContext(ctx, 1)
+
+	recent, err := database.RecentLinks(ctx, r, dashboardSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("cannot connect to the DB")
+	}
+	totalLinks, err := database.CountLinks(ctx, r)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("cannot connect to the DB")
+	}
+	top, err := database.TopLinks(ctx, rStats, dashboardSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("cannot connect to the DB")
+	}
+	// drop links that expired since they were ranked
+	live := top[:0]
+	var gone []string
+	for _, z := range top {
+		key, _ := z.Member.(string)
+		if n, err := r.Exists(ctx, key).Result(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).SendString("cannot connect to the DB")
+		} else if n == 0 {
+			gone = append(gone, key)
+			continue
+		}
+		live = append(live, z)
+	}
+	if len(gone) > 0 {
+		_ = database.UnrankLinks(ctx, rStats, gone...)
+	}
+	top = live
+	clicks, err := rStats.Get(ctx, "counter").Result()
+	if err != nil && err != redis.Nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("cannot connect to the DB")
+	}
+	totalClicks, _ := strconv.ParseInt(clicks, 10, 64)
+
+	var buf bytes.Buffer
+	err = dashboardTemplate.Execute(&buf, fiber.Map{
+		"TotalLinks":  totalLinks,
+		"TotalClicks": totalClicks,
+		"Top":         top,
+		"Recent":      recent,
+	})
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("cannot render dashboard")
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
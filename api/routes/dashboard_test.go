@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestDashboardAuth(t *testing.T) {
+	basic := func(user, password string) map[string]string {
+		return map[string]string{fiber.HeaderAuthorization: "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))}
+	}
+	tests := []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"no credentials", nil, fiber.StatusUnauthorized},
+		{"wrong key", map[string]string{"X-Admin-Key": "nope"}, fiber.StatusUnauthorized},
+		{"wrong password", basic("admin", "nope"), fiber.StatusUnauthorized},
+		{"admin key", map[string]string{"X-Admin-Key": "admin-secret"}, fiber.StatusOK},
+		{"bearer token", map[string]string{fiber.HeaderAuthorization: "Bearer admin-secret"}, fiber.StatusOK},
+		{"basic auth", basic("anyone", "admin-secret"), fiber.StatusOK},
+	}
+	asAdmin(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestApp()
+			resp := sendHeaders(t, app, "GET", "/admin", "", tt.header)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == fiber.StatusUnauthorized && resp.Header.Get(fiber.HeaderWWWAuthenticate) == "" {
+				t.Error("no WWW-Authenticate challenge for the browser")
+			}
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		t.Setenv("ADMIN_API_KEY", "")
+		app := newTestApp()
+		if resp := sendHeaders(t, app, "GET", "/admin", "", basic("admin", "")); resp.StatusCode != fiber.StatusForbidden {
+			t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+		}
+	})
+}
+
+func TestDashboard(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+
+	resp := sendHeaders(t, app, "GET", "/admin", "", admin)
+	if html := readAll(t, resp); !strings.Contains(html, "No clicks yet") || !strings.Contains(html, "No links yet") {
+		t.Errorf("empty dashboard:\n%s", html)
+	}
+
+	browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+	for i, short := range []string{"popular", "quiet", "fresh", "administrivia"} {
+		if resp := send(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":"https://example.com/%s","short":%q}`, short, short), ""); resp.StatusCode != fiber.StatusCreated {
+			t.Fatalf("shorten %s: status = %d", short, resp.StatusCode)
+		}
+		for j := 0; j < 3-i; j++ {
+			sendHeaders(t, app, "GET", "/"+short, "", browser)
+		}
+	}
+
+	// the dashboard only takes /admin itself
+	if resp := send(t, app, "GET", "/administrivia", "", ""); resp.Header.Get(fiber.HeaderLocation) != "https://example.com/administrivia" {
+		t.Errorf("GET /administrivia: %d to %q, want its link", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+
+	resp = sendHeaders(t, app, "GET", "/admin", "", admin)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	if got := resp.Header.Get(fiber.HeaderContentType); got != fiber.MIMETextHTMLCharsetUTF8 {
+		t.Errorf("Content-Type = %q, want %q", got, fiber.MIMETextHTMLCharsetUTF8)
+	}
+	html := readAll(t, resp)
+	for _, want := range []string{
+		"<b>4</b>links",
+		"<b>6</b>clicks",
+		"<td>popular</td><td>3</td>",
+		"<td>fresh</td><td>1</td>",
+		"<td>fresh</td><td>https://example.com/fresh</td>",
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("dashboard is missing %q:\n%s", want, html)
+		}
+	}
+	top, recent := strings.Index(html, "Top links"), strings.Index(html, "Recent links")
+	if p, q := strings.Index(html, "<td>popular</td><td>3"), strings.Index(html, "<td>quiet</td><td>2"); p < top || q < p || q > recent {
+		t.Errorf("top links aren't ordered by clicks:\n%s", html)
+	}
+}
+
+func TestDashboardDropsExpiredLinks(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+	for _, short := range []string{"lasting", "vanished"} {
+		seedLink(t, short, &database.Link{URL: "https://example.com/" + short})
+		sendHeaders(t, app, "GET", "/"+short, "", browser)
+	}
+	// as if its TTL ran out, leaving its leaderboard entry behind
+	if err := database.CreateClient(0).Del(context.Background(), database.LinkKey("", "vanished")).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	html := readAll(t, sendHeaders(t, app, "GET", "/admin", "", admin))
+	if !strings.Contains(html, "<td>lasting</td><td>1</td>") || strings.Contains(html, "<td>vanished</td>") {
+		t.Errorf("top links should list lasting alone:\n%s", html)
+	}
+	if onLeaderboard(t, database.LinkKey("", "vanished")) {
+		t.Error("the expired link is still on the leaderboard")
+	}
+}
+
+// readAll reads resp's body, failing t if it can't.
+func readAll(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading the response: %v", err)
+	}
+	return string(b)
+}
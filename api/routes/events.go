@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// Link event types.
+const (
+	eventCreated     = "created"
+	eventUpdated     = "updated"
+	eventDisabled    = "disabled"
+	eventEnabled     = "enabled"
+	eventExpired     = "expired"
+	eventTransferred = "transferred"
+	eventThrottled   = "throttled"
+)
+
+// defaultEventsLimit is how many events a link keeps when LINK_EVENTS_LIMIT
+// is unset.
+const defaultEventsLimit = 50
+
+// recordEvent adds an event to the history of the link at key when
+// LINK_EVENTS is on. History is a debugging aid, so failing to record it
+// never fails the request.
+func recordEvent(ctx context.Context, r database.Store, key, typ, detail string) {
+	if !config.Bool("LINK_EVENTS", false) {
+		return
+	}
+	limit := config.Int("LINK_EVENTS_LIMIT", defaultEventsLimit)
+	if limit < 1 {
+		limit = 1
+	}
+	ev := database.LinkEvent{Type: typ, At: time.Now(), Detail: detail}
+	if err := database.RecordEvent(ctx, r, key, ev, limit); err != nil {
+		log.Printf("link events: recording %s for %s: %v", typ, key, err)
+	}
+}
+
+type eventInfo struct {
+	Type   string    `json:"type"`
+	At     time.Time `json:"at"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// LinkEvents returns a link's recorded history, newest first, to its owner
+// or an admin. Through an alias it's the history of the link behind it.
+func LinkEvents(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !isOwner(c, link) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid edit token"})
+	}
+	if link.PrimaryKey != "" {
+		key = link.PrimaryKey
+	}
+
+	events, err := database.LinkEvents(ctx, r, key)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	out := make([]eventInfo, len(events))
+	for i, ev := range events {
+		out[i] = eventInfo{Type: ev.Type, At: ev.At.UTC(), Detail: ev.Detail}
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"short": displayCode(id, link), "events": out})
+}
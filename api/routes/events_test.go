@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// eventTypes fetches the events of the link at code as header's holder, as
+// type/detail pairs, newest first.
+func eventTypes(t *testing.T, app *fiber.App, code string, header map[string]string) []string {
+	t.Helper()
+	resp := sendHeaders(t, app, "GET", "/api/v1/links/"+code+"/events", "", header)
+	body := decode(t, resp)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("events of %s: status = %d (%v)", code, resp.StatusCode, body)
+	}
+	var out []string
+	for _, ev := range body["events"].([]interface{}) {
+		ev := ev.(map[string]interface{})
+		if ev["at"] == nil {
+			t.Errorf("event %v has no time", ev)
+		}
+		out = append(out, fmt.Sprintf("%v/%v", ev["type"], ev["detail"]))
+	}
+	return out
+}
+
+func TestLinkEvents(t *testing.T) {
+	t.Setenv("LINK_EVENTS", "true")
+	admin := asAdmin(t)
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"audited"}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	owner := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+
+	sendHeaders(t, app, "PATCH", "/audited", `{"note":"launch","url":"https://example.com/v2"}`, owner)
+	sendHeaders(t, app, "PATCH", "/audited", `{"disabled":true}`, admin)
+	sendHeaders(t, app, "PATCH", "/audited", `{"disabled":false}`, admin)
+	sendHeaders(t, app, "POST", "/audited/expire", "", owner)
+
+	want := "[expired/owner enabled/admin disabled/admin updated/url,note created/<nil>]"
+	if got := fmt.Sprint(eventTypes(t, app, "audited", owner)); got != want {
+		t.Errorf("events = %s, want %s", got, want)
+	}
+	if got := fmt.Sprint(eventTypes(t, app, "audited", admin)); got != want {
+		t.Errorf("events for an admin = %s, want %s", got, want)
+	}
+	if resp := send(t, app, "GET", "/api/v1/links/audited/events", "", ""); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("events without the edit token: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	if resp := sendHeaders(t, app, "GET", "/api/v1/links/missing/events", "", admin); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("events of a missing link: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+}
+
+func TestLinkEventsThrottled(t *testing.T) {
+	t.Setenv("LINK_EVENTS", "true")
+	admin := asAdmin(t)
+	app := newTestApp()
+	send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"busy","resolve_limit_per_minute":1}`, "")
+	for i := 0; i < 4; i++ {
+		send(t, app, "GET", "/busy", "", "")
+	}
+	// once per minute over the limit, not once per refused redirect
+	if got := fmt.Sprint(eventTypes(t, app, "busy", admin)); got != "[throttled/1/min created/<nil>]" {
+		t.Errorf("events = %s, want one throttled event", got)
+	}
+}
+
+func TestLinkEventsLimit(t *testing.T) {
+	t.Setenv("LINK_EVENTS", "true")
+	t.Setenv("LINK_EVENTS_LIMIT", "3")
+	admin := asAdmin(t)
+	app := newTestApp()
+	send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"capped"}`, "")
+	for i := 0; i < 5; i++ {
+		sendHeaders(t, app, "PATCH", "/capped", fmt.Sprintf(`{"note":"note %d"}`, i), admin)
+	}
+	if got := eventTypes(t, app, "capped", admin); len(got) != 3 {
+		t.Errorf("events = %v, want the newest 3", got)
+	}
+}
+
+func TestLinkEventsOff(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"unaudited"}`, "")
+	sendHeaders(t, app, "PATCH", "/unaudited", `{"note":"quiet"}`, admin)
+	if got := eventTypes(t, app, "unaudited", admin); len(got) != 0 {
+		t.Errorf("events = %v, want none without LINK_EVENTS", got)
+	}
+}
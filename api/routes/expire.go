@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// ExpireLink expires a link right away. Unlike deleting it, the link and its
+// stats stay readable for EXPIRED_LINK_RETENTION while visitors get 410, so
+// the owner can still look at how it did.
+func ExpireLink(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !isOwner(c, link) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid edit token"})
+	}
+	// expiring through an alias expires the link it points at
+	if link.PrimaryKey != "" {
+		key = link.PrimaryKey
+		id = database.LinkCode(key)
+	}
+
+	retention, err := expireNow(ctx, r, key, link, "owner")
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":           id,
+		"expired":        true,
+		"retained_until": time.Now().Add(retention).UTC(),
+	})
+}
+
+// expireNow marks link, stored under key, expired and keeps it for
+// EXPIRED_LINK_RETENTION, but never for longer than it had left. With
+// PURGE_ANALYTICS=expire its click analytics go when it does. reason, such as
+// "max_clicks", is recorded in its events. It returns how long the link is
+// kept.
+func expireNow(ctx context.Context, r database.Store, key string, link *database.Link, reason string) (time.Duration, error) {
+	retention := config.Duration("EXPIRED_LINK_RETENTION", 7*24*time.Hour)
+	ttl, err := r.TTL(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl > 0 && ttl < retention {
+		retention = ttl
+	}
+
+	link.Expired = true
+	if err := database.UpdateLink(ctx, r, key, link); err != nil {
+		return 0, err
+	}
+	if err := database.ExpireLinks(ctx, r, []string{key}, retention); err != nil {
+		return 0, err
+	}
+	recordEvent(ctx, r, key, eventExpired, reason)
+	if purgeAnalytics() != "expire" {
+		alignAnalytics(ctx, r, retention, key)
+		return retention, nil
+	}
+
+	aliases, err := database.LinkAliases(ctx, r, key)
+	if err != nil {
+		return 0, err
+	}
+	rStats := database.CreateClientContext(ctx, 1)
+	return retention, database.ExpireClicks(ctx, rStats, retention, append(aliases, key)...)
+}
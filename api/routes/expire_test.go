@@ -0,0 +1,106 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestExpireLink(t *testing.T) {
+	t.Setenv("EXPIRED_LINK_RETENTION", "2h")
+	app := newTestApp()
+	ctx := context.Background()
+	r := database.CreateClient(0)
+	browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"doomed"}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	owner := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+	for i := 0; i < 2; i++ {
+		sendHeaders(t, app, "GET", "/doomed", "", browser)
+	}
+
+	if resp := send(t, app, "POST", "/doomed/expire", "", ""); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("expire without the edit token: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	if resp := sendHeaders(t, app, "POST", "/missing/expire", "", owner); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("expire a missing link: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+	resp = sendHeaders(t, app, "POST", "/doomed/expire", "", owner)
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK || body["expired"] != true {
+		t.Fatalf("expire: %d %v, want %d expired", resp.StatusCode, body, fiber.StatusOK)
+	}
+
+	if resp := sendHeaders(t, app, "GET", "/doomed", "", browser); resp.StatusCode != fiber.StatusGone {
+		t.Errorf("GET /doomed: status = %d, want %d", resp.StatusCode, fiber.StatusGone)
+	}
+	// the stats, without that last visit, are kept for the retention period
+	resp = send(t, app, "GET", "/api/v1/stats/doomed", "", "")
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK || body["clicks"] != float64(2) {
+		t.Errorf("stats: %d %v, want %d with 2 clicks", resp.StatusCode, body, fiber.StatusOK)
+	}
+	if body := decode(t, send(t, app, "GET", "/api/v1/links/doomed", "", "")); body["expired"] != true {
+		t.Errorf("link info = %v, want it marked expired", body)
+	}
+	if ttl, _ := r.TTL(ctx, database.LinkKey("", "doomed")).Result(); ttl <= 0 || ttl > 2*time.Hour {
+		t.Errorf("TTL = %v, want EXPIRED_LINK_RETENTION", ttl)
+	}
+
+	// a link with less time left than the retention isn't kept any longer
+	seedLink(t, "nearly", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+	r.Expire(ctx, database.LinkKey("", "nearly"), 30*time.Minute)
+	if resp := sendHeaders(t, app, "POST", "/nearly/expire", "", map[string]string{"X-Edit-Token": "tok"}); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("expire nearly: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	if ttl, _ := r.TTL(ctx, database.LinkKey("", "nearly")).Result(); ttl <= 0 || ttl > 30*time.Minute {
+		t.Errorf("nearly: TTL = %v, want the 30m it had left", ttl)
+	}
+}
+
+func TestExpirePurgesAnalytics(t *testing.T) {
+	t.Setenv("EXPIRED_LINK_RETENTION", "2h")
+	browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+	ctx := context.Background()
+	rStats := database.CreateClient(1)
+	for _, tt := range []struct {
+		setting string
+		purged  bool
+	}{
+		{"", false},
+		{"expire", true},
+	} {
+		t.Run(tt.setting, func(t *testing.T) {
+			t.Setenv("PURGE_ANALYTICS", tt.setting)
+			app := newTestApp()
+			key := database.LinkKey("", "capped")
+			// the second click expires it
+			seedLink(t, "capped", &database.Link{URL: "https://example.com/", MaxClicks: 2})
+			sendHeaders(t, app, "GET", "/capped", "", browser)
+			sendHeaders(t, app, "GET", "/capped", "", browser)
+			if resp := sendHeaders(t, app, "GET", "/capped", "", browser); resp.StatusCode != fiber.StatusGone {
+				t.Fatalf("past max_clicks: status = %d, want %d", resp.StatusCode, fiber.StatusGone)
+			}
+
+			for _, k := range []string{database.ClicksKey(key), database.VisitorsKey(key)} {
+				ttl, err := rStats.TTL(ctx, k).Result()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if tt.purged && (ttl <= time.Hour || ttl > 2*time.Hour) {
+					t.Errorf("%s TTL = %v, want the 2h retention", k, ttl)
+				}
+			}
+			if onBoard := onLeaderboard(t, key); onBoard == tt.purged {
+				t.Errorf("on the leaderboard = %v, want %v", onBoard, !tt.purged)
+			}
+		})
+	}
+}
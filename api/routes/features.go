@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"os"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/middleware"
+)
+
+// Features reports which optional capabilities this deployment has turned on,
+// so clients can adapt instead of finding out from errors. It comes from the
+// same settings the handlers and jobs read, so it matches what they do.
+func Features(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"permanent_links":     true,
+		"admin_api":           os.Getenv("ADMIN_API_KEY") != "",
+		"read_only":           middleware.ReadOnly(),
+		"https_only":          config.Bool("HTTPS_ONLY", false),
+		"url_credentials":     config.Bool("ALLOW_URL_CREDENTIALS", false),
+		"block_private_hosts": config.Bool("BLOCK_PRIVATE_HOSTS", false),
+		"signed_codes":        helpers.SigningEnabled(),
+		"premium_shorts":      len(config.List("PREMIUM_SHORTS")) > 0,
+		"sliding_expiry":      config.Duration("SLIDING_EXPIRY", 0) > 0,
+		"health_checks":       config.Duration("HEALTH_CHECK_INTERVAL", 0) > 0,
+		"expiry_warnings":     config.String("EXPIRY_WARNING_WEBHOOK", "") != "",
+		"report_auto_disable": config.Int("REPORT_DISABLE_THRESHOLD", 0) > 0,
+		"link_limit_per_ip":   config.Int("MAX_LINKS_PER_IP", 0) > 0,
+		"compression":         config.Bool("ENABLE_COMPRESSION", false),
+	})
+}
@@ -0,0 +1,60 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestFeatures(t *testing.T) {
+	tests := []struct {
+		feature string
+		env     string
+		value   string
+	}{
+		{"admin_api", "ADMIN_API_KEY", "adm"},
+		{"https_only", "HTTPS_ONLY", "true"},
+		{"url_credentials", "ALLOW_URL_CREDENTIALS", "true"},
+		{"block_private_hosts", "BLOCK_PRIVATE_HOSTS", "true"},
+		{"signed_codes", "CODE_SIGNING_KEY", "secret"},
+		{"premium_shorts", "PREMIUM_SHORTS", "vip,gold"},
+		{"sliding_expiry", "SLIDING_EXPIRY", "1h"},
+		{"health_checks", "HEALTH_CHECK_INTERVAL", "1h"},
+		{"expiry_warnings", "EXPIRY_WARNING_WEBHOOK", "https://hooks.example.com/"},
+		{"report_auto_disable", "REPORT_DISABLE_THRESHOLD", "3"},
+		{"link_limit_per_ip", "MAX_LINKS_PER_IP", "10"},
+		{"compression", "ENABLE_COMPRESSION", "true"},
+	}
+	app := newTestApp()
+	features := func() map[string]interface{} {
+		t.Helper()
+		resp := send(t, app, "GET", "/api/v1/features", "", "")
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+		}
+		return decode(t, resp)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.feature, func(t *testing.T) {
+			t.Setenv(tt.env, "")
+			if got := features()[tt.feature]; got != false {
+				t.Errorf("%s unset: %s = %v, want false", tt.env, tt.feature, got)
+			}
+			t.Setenv(tt.env, tt.value)
+			got := features()
+			if got[tt.feature] != true {
+				t.Errorf("%s=%s: %s = %v, want true", tt.env, tt.value, tt.feature, got[tt.feature])
+			}
+			// nothing else changes
+			for _, other := range tests {
+				if other.feature != tt.feature && got[other.feature] != false {
+					t.Errorf("%s=%s: %s = %v, want false", tt.env, tt.value, other.feature, got[other.feature])
+				}
+			}
+		})
+	}
+	if got := features()["permanent_links"]; got != true {
+		t.Errorf("permanent_links = %v, want true", got)
+	}
+}
@@ -0,0 +1,125 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// maxImportLinks caps one import request; larger exports are imported in
+// parts.
+const maxImportLinks = 10000
+
+// importError is a row of an import that wasn't imported, numbered from 1
+// after any header row.
+type importError struct {
+	Row   int    `json:"row"`
+	Short string `json:"short,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportLinks creates links from an export, in ?format=generic (the default),
+// bitly or tinyurl, sent as CSV or JSON. Links keep their codes where they're
+// free, signed when CODE_SIGNING_KEY is set, and get generated ones
+// otherwise if they had none; they never expire and, having no edit token,
+// are managed by admins. Rows that can't be imported are reported and the
+// rest imported anyway.
+func ImportLinks(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	rows, err := helpers.ParseImport(c.Query("format", "generic"), c.Body())
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if len(rows) > maxImportLinks {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("at most %d links can be imported at once", maxImportLinks)})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+	rStats := database.CreateClientContext(ctx, 1)
+
+	imported := 0
+	failed := []importError{}
+	for i, row := range rows {
+		if err := importLink(ctx, r, rStats, row); err != nil {
+			if !errors.As(err, new(importRowError)) {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+			}
+			failed = append(failed, importError{Row: i + 1, Short: row.Short, Error: err.Error()})
+			continue
+		}
+		imported++
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"imported": imported, "errors": failed})
+}
+
+// importRowError is a problem with one imported row, as opposed to the
+// database. Its message is safe to show the client.
+type importRowError struct{ error }
+
+// importLink saves one imported link under its own code, or a generated one
+// when it has none.
+func importLink(ctx context.Context, r, rStats database.Store, row helpers.ImportedLink) error {
+	url, err := normalizeDestination(row.URL)
+	if err != nil {
+		return importRowError{fmt.Errorf("url: %v", err)}
+	}
+	tags, err := helpers.NormalizeTags(row.Tags)
+	if err != nil {
+		return importRowError{err}
+	}
+	// titles can run longer than notes may
+	note := []rune(row.Note)
+	if len(note) > maxNoteLen {
+		note = note[:maxNoteLen]
+	}
+
+	id := row.Short
+	if id == "" {
+		id = helpers.GenerateCode()
+	} else if err := helpers.ValidateCustomShort(id); err != nil {
+		return importRowError{err}
+	}
+	id = helpers.SignCode(helpers.CanonicalCode(id))
+	key := database.LinkKey("", id)
+
+	exists, err := r.Exists(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return importRowError{errors.New("short is already in use")}
+	}
+	reclaimable, err := checkReclaim(ctx, r, key)
+	if err != nil {
+		return err
+	}
+	if !reclaimable {
+		return importRowError{errors.New("short expired recently and can't be reused yet")}
+	}
+
+	link := &database.Link{
+		URL:       url,
+		Permanent: true,
+		CreatedAt: row.CreatedAt,
+		Note:      string(note),
+		Tags:      tags,
+	}
+	if link.CreatedAt.IsZero() {
+		link.CreatedAt = time.Now()
+	}
+	if err := resetReclaimedStats(ctx, r, rStats, key); err != nil {
+		return err
+	}
+	if err := database.SaveLink(ctx, r, key, link, 0); err != nil {
+		return err
+	}
+	_ = database.IndexDestination(ctx, r, "", url, id, 0)
+	_ = database.IndexTags(ctx, r, "", tags, id, 0)
+	recordEvent(ctx, r, key, eventCreated, "import")
+	return nil
+}
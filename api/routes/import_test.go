@@ -0,0 +1,80 @@
+package routes
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestImportLinks(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	seedLink(t, "taken", &database.Link{URL: "https://example.com/taken"})
+
+	importExport := func(format, body string) map[string]interface{} {
+		t.Helper()
+		resp := sendHeaders(t, app, "POST", "/api/v1/admin/import?format="+format, body, admin)
+		out := decode(t, resp)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("import %s: status = %d (%v)", format, resp.StatusCode, out)
+		}
+		return out
+	}
+
+	// Bitly's CSV export
+	out := importExport("bitly", "Title,Long URL,Bitlink,Created,Tags\n"+
+		"Spring sale,https://example.com/sale,bit.ly/SpringSale,2023-05-01 12:30:00,sale\n"+
+		"Taken,https://example.com/mine,bit.ly/taken,,\n"+
+		"Broken,javascript:alert(1),bit.ly/broken,,\n")
+	if out["imported"] != float64(1) {
+		t.Errorf("bitly: imported = %v, want 1", out["imported"])
+	}
+	if errs := out["errors"].([]interface{}); len(errs) != 2 || errs[0].(map[string]interface{})["row"] != float64(2) || errs[1].(map[string]interface{})["row"] != float64(3) {
+		t.Errorf("bitly: errors = %v, want rows 2 and 3", errs)
+	}
+	resp := send(t, app, "GET", "/api/v1/links/SpringSale", "", "")
+	info := decode(t, resp)
+	if resp.StatusCode != fiber.StatusOK || info["url"] != "https://example.com/sale" || info["note"] != "Spring sale" || fmt.Sprint(info["tags"]) != "[sale]" {
+		t.Errorf("imported Bitly link: %d %v", resp.StatusCode, info)
+	}
+	if info["created_at"] != "2023-05-01T12:30:00Z" {
+		t.Errorf("imported Bitly link: created_at = %v, want the export's", info["created_at"])
+	}
+	if resp := send(t, app, "GET", "/taken", "", ""); resp.Header.Get(fiber.HeaderLocation) != "https://example.com/taken" {
+		t.Errorf("the taken short now leads to %q", resp.Header.Get(fiber.HeaderLocation))
+	}
+
+	// TinyURL's API listing; a link without an alias gets a generated code
+	out = importExport("tinyurl", `{"data":[{"alias":"tiny-promo","url":"https://example.com/tiny"},{"url":"https://example.com/generated"}]}`)
+	if out["imported"] != float64(2) || len(out["errors"].([]interface{})) != 0 {
+		t.Errorf("tinyurl: %v, want both imported", out)
+	}
+	if resp := send(t, app, "GET", "/tiny-promo", "", ""); resp.StatusCode != fiber.StatusMovedPermanently || resp.Header.Get(fiber.HeaderLocation) != "https://example.com/tiny" {
+		t.Errorf("GET /tiny-promo: %d to %q", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+
+	// the default generic format
+	out = importExport("", "url,short\nhttps://example.com/generic,generic-link\n")
+	if out["imported"] != float64(1) {
+		t.Errorf("generic: %v, want 1 imported", out)
+	}
+	if resp := send(t, app, "GET", "/generic-link", "", ""); resp.StatusCode != fiber.StatusMovedPermanently {
+		t.Errorf("GET /generic-link: status = %d, want %d", resp.StatusCode, fiber.StatusMovedPermanently)
+	}
+
+	for _, tt := range []struct {
+		name, path, body string
+		header           map[string]string
+		want             int
+	}{
+		{"unknown format", "/api/v1/admin/import?format=rebrandly", "url\nhttps://example.com/\n", admin, fiber.StatusBadRequest},
+		{"bad JSON", "/api/v1/admin/import", `[{"url":`, admin, fiber.StatusBadRequest},
+		{"not admin", "/api/v1/admin/import", "url\nhttps://example.com/\n", nil, fiber.StatusUnauthorized},
+	} {
+		if resp := sendHeaders(t, app, "POST", tt.path, tt.body, tt.header); resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
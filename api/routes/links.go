@@ -0,0 +1,733 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/middleware"
+)
+
+// maxNoteLen caps a link's note, in characters.
+const maxNoteLen = 280
+
+// listLimit is how many links a page of the list endpoint returns.
+const listLimit = 50
+
+func validateNote(note string) error {
+	if utf8.RuneCountInString(note) > maxNoteLen {
+		return fmt.Errorf("note must be at most %d characters", maxNoteLen)
+	}
+	return nil
+}
+
+// canonicalDestination is dest as it's stored and indexed: with a scheme, and
+// without credentials.
+func canonicalDestination(dest string) string {
+	return helpers.StripCredentials(helpers.EnforceHTTP(dest))
+}
+
+// normalizeDestination checks a destination URL the same way as a link's URL
+// and adds a scheme when it's missing.
+func normalizeDestination(dest string) (string, error) {
+	if err := helpers.ValidateURL(dest); err != nil {
+		return "", err
+	}
+	if !helpers.RemoveDomainError(dest) {
+		return "", errors.New("you cant hack the system")
+	}
+	dest = canonicalDestination(dest)
+	if err := helpers.CheckHTTPS(dest); err != nil {
+		return "", err
+	}
+	if err := helpers.CheckExtension(dest); err != nil {
+		return "", err
+	}
+	if err := helpers.CheckPrivateHost(dest); err != nil {
+		return "", err
+	}
+	return dest, nil
+}
+
+// validationStatus is the status for a rejected destination or field: 403
+// for policy blocks, 400 for anything malformed.
+func validationStatus(err error) int {
+	if errors.Is(err, helpers.ErrBlockedExtension) || errors.Is(err, helpers.ErrPrivateHost) {
+		return fiber.StatusForbidden
+	}
+	return fiber.StatusBadRequest
+}
+
+// Default limits on how much one link can hold, overridden by
+// MAX_LINK_TARGETS and MAX_LINK_SIZE, so a single request can't store an
+// unbounded hash.
+const (
+	maxLinkTargets = 50
+	maxLinkSize    = 16 << 10
+)
+
+// linkTargets is the most locale destinations, or allowed referrers, a link
+// can have.
+func linkTargets() int {
+	return config.Int("MAX_LINK_TARGETS", maxLinkTargets)
+}
+
+// checkLinkSize rejects link when it would take up more than MAX_LINK_SIZE
+// bytes stored.
+func checkLinkSize(link *database.Link) error {
+	if limit := config.Int("MAX_LINK_SIZE", maxLinkSize); database.LinkSize(link) > limit {
+		return fmt.Errorf("link is larger than the %d bytes allowed", limit)
+	}
+	return nil
+}
+
+// normalizeReferrers normalizes a link's allowed referrer hosts and caps how
+// many there are.
+func normalizeReferrers(hosts []string) ([]string, error) {
+	if n := linkTargets(); len(hosts) > n {
+		return nil, fmt.Errorf("allowed_referrers: a link can have at most %d", n)
+	}
+	referrers, err := helpers.NormalizeHosts(hosts)
+	if err != nil {
+		return nil, errors.New("allowed_referrers: " + err.Error())
+	}
+	return referrers, nil
+}
+
+// normalizeLocales lowercases the language tags of a locales map and checks
+// each destination the same way as a link's URL.
+func normalizeLocales(locales map[string]string) (map[string]string, error) {
+	if n := linkTargets(); len(locales) > n {
+		return nil, fmt.Errorf("a link can have at most %d locales", n)
+	}
+	out := make(map[string]string, len(locales))
+	for tag, dest := range locales {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > 35 || strings.Trim(tag, "abcdefghijklmnopqrstuvwxyz0123456789-") != "" {
+			return nil, fmt.Errorf("invalid locale %q", tag)
+		}
+		dest, err := normalizeDestination(dest)
+		if err != nil {
+			return nil, fmt.Errorf("locale %q: %w", tag, err)
+		}
+		out[tag] = dest
+	}
+	return out, nil
+}
+
+type linkInfo struct {
+	Short            string            `json:"short"`
+	URL              string            `json:"url,omitempty"`
+	Note             string            `json:"note,omitempty"`
+	Permanent        bool              `json:"permanent"`
+	HideDestination  bool              `json:"hide_destination"`
+	CreatedAt        *time.Time        `json:"created_at,omitempty"`
+	Tags             []string          `json:"tags,omitempty"`
+	Locales          map[string]string `json:"locales,omitempty"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	FallbackURL      string            `json:"fallback_url,omitempty"`
+	AllowedReferrers []string          `json:"allowed_referrers,omitempty"`
+	NoReferrer       bool              `json:"no_referrer,omitempty"`
+	AppendPath       bool              `json:"append_path,omitempty"`
+	MaxClicks        int               `json:"max_clicks,omitempty"`
+	Disabled         bool              `json:"disabled,omitempty"`
+	Expired          bool              `json:"expired,omitempty"`
+	Capabilities     []string          `json:"capabilities"`
+	Creator          *creatorInfo      `json:"creator,omitempty"`
+}
+
+// linkCapabilities lists the optional behaviors link is configured with, by
+// the request field that sets each, so a UI knows which controls to show.
+// Referrer restrictions and headers are only listed for the owner, who alone
+// sees their values.
+func linkCapabilities(link *database.Link, owner bool) []string {
+	caps := []string{}
+	add := func(name string, on bool) {
+		if on {
+			caps = append(caps, name)
+		}
+	}
+	add("max_clicks", link.MaxClicks > 0)
+	add("resolve_limit_per_minute", link.ResolveLimitPerMinute > 0)
+	add("cache_ttl_seconds", link.CacheTTLSeconds != nil)
+	add("hide_destination", link.HideDestination)
+	add("locales", len(link.Locales) > 0)
+	add("fallback_url", link.FallbackURL != "")
+	add("no_referrer", link.NoReferrer)
+	add("append_path", link.AppendPath)
+	add("tags", len(link.Tags) > 0)
+	if owner {
+		add("allowed_referrers", len(link.AllowedReferrers) > 0)
+		add("headers", len(link.Headers) > 0)
+	}
+	return caps
+}
+
+// creatorInfo is who created a link. It's only ever shown to admins.
+type creatorInfo struct {
+	IP        string `json:"ip_hash,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+}
+
+// withCreator adds link's creator info to info, if any was stored.
+func (info linkInfo) withCreator(link *database.Link) linkInfo {
+	if link.CreatorIP != "" || link.CreatorUserAgent != "" {
+		info.Creator = &creatorInfo{IP: link.CreatorIP, UserAgent: link.CreatorUserAgent}
+	}
+	return info
+}
+
+func newLinkInfo(id string, link *database.Link, owner bool) linkInfo {
+	info := linkInfo{
+		Short:           displayCode(id, link),
+		Note:            link.Note,
+		Permanent:       link.Permanent,
+		HideDestination: link.HideDestination,
+		Tags:            link.Tags,
+		NoReferrer:      link.NoReferrer,
+		AppendPath:      link.AppendPath,
+		MaxClicks:       link.MaxClicks,
+		Disabled:        link.Disabled,
+		Expired:         link.Expired,
+		Capabilities:    linkCapabilities(link, owner),
+	}
+	if owner || !link.HideDestination {
+		info.URL = link.URL
+		info.Locales = link.Locales
+		info.FallbackURL = link.FallbackURL
+	}
+	if owner {
+		info.AllowedReferrers = link.AllowedReferrers
+		info.Headers = link.Headers
+	}
+	if !link.CreatedAt.IsZero() {
+		info.CreatedAt = &link.CreatedAt
+	}
+	return info
+}
+
+// displayCode is id as link's creator cased it, when id is the code link is
+// stored under. Codes of aliases, and of links with no display casing, are
+// shown as they are.
+func displayCode(id string, link *database.Link) string {
+	if link.Display != "" && strings.EqualFold(link.Display, id) {
+		return link.Display
+	}
+	return id
+}
+
+// isOwner reports whether the request may manage link: it carries the link's
+// edit token in X-Edit-Token, the API key that owns the link, or the admin
+// key.
+func isOwner(c *fiber.Ctx, link *database.Link) bool {
+	if key := middleware.APIKey(c); key != nil && link.Owner != "" && key.ID == link.Owner {
+		return true
+	}
+	return helpers.TokenMatches(c.Get("X-Edit-Token"), link.EditTokenHash) || middleware.IsAdmin(c)
+}
+
+// LinkInfo describes a link. The destination of a hide_destination link is
+// only shown to its owner, and creator info only to admins.
+func LinkInfo(c *fiber.Ctx) error {
+	id := shortCode(c)
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	link, err := database.GetLink(c.UserContext(), r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	info := newLinkInfo(id, link, isOwner(c, link))
+	if middleware.IsAdmin(c) {
+		info = info.withCreator(link)
+	}
+	return c.Status(fiber.StatusOK).JSON(info)
+}
+
+// ListLinks returns the links a page at a time, most recently created first
+// unless ?sort=clicks or ?order=asc ask for another order.
+func ListLinks(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	offset, limit, ok := pageParams(c, listLimit)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor or limit"})
+	}
+
+	var order database.LinkOrder
+	switch c.Query("sort", "created_at") {
+	case "created_at":
+	case "clicks":
+		order.ByClicks = true
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "sort must be created_at or clicks"})
+	}
+	switch c.Query("order", "desc") {
+	case "desc":
+	case "asc":
+		order.Ascending = true
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "order must be asc or desc"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+	rStats := database.CreateClientContext(ctx, 1)
+
+	links, next, err := database.SortedLinksPage(ctx, r, rStats, order, offset, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	out := make([]linkInfo, 0, len(links))
+	for _, l := range links {
+		out = append(out, newLinkInfo(database.LinkCode(l.Key), l.Link, true).withCreator(l.Link))
+	}
+	return sendPage(c, out, len(out), nextCursor(next))
+}
+
+// RecentLinks returns the links created within ?since=, a duration such as
+// "24h" or "7d" defaulting to a day, a page at a time, oldest first.
+func RecentLinks(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	offset, limit, ok := pageParams(c, listLimit)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor or limit"})
+	}
+	window, err := helpers.ParseExpiry(c.Query("since"))
+	if err != nil || window == helpers.NeverExpires {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "since must be a duration such as 24h or 7d"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	links, next, err := database.LinksCreatedSince(ctx, r, time.Now().Add(-window), offset, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	out := make([]linkInfo, 0, len(links))
+	for _, l := range links {
+		out = append(out, newLinkInfo(database.LinkCode(l.Key), l.Link, true).withCreator(l.Link))
+	}
+	return sendPage(c, out, len(out), nextCursor(next))
+}
+
+type expiryUpdate struct {
+	Tag         string `json:"tag"`
+	ExpiryHours int    `json:"expiry_hours"`
+}
+
+// UpdateLinksExpiry sets every link carrying a tag to expire expiry_hours from
+// now, capped at MAX_EXPIRY, and reports how many were updated. Admins update
+// all of them; a request with an API key only updates the links that key owns.
+func UpdateLinksExpiry(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	admin, apiKey := middleware.IsAdmin(c), middleware.APIKey(c)
+	if !admin && apiKey == nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "an admin key or API key is required"})
+	}
+
+	body := new(expiryUpdate)
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	if body.Tag == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "tag is required"})
+	}
+	tags, err := helpers.NormalizeTags([]string{body.Tag})
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if body.ExpiryHours <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "expiry_hours must be positive"})
+	}
+	ttl := helpers.MaxExpiry()
+	if int64(body.ExpiryHours) < int64(ttl/time.Hour) {
+		ttl = time.Duration(body.ExpiryHours) * time.Hour
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	tenant, err := database.TenantForHost(ctx, r, c.Hostname())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	ids, err := r.SMembers(ctx, database.TagKey(tenant, tags[0])).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	var keys []string
+	var links []*database.Link
+	for _, id := range ids {
+		key := database.LinkKey(tenant, id)
+		link, err := database.GetLink(ctx, r, key)
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		// expired links are only being kept until their retention ends
+		if link.Expired || (!admin && link.Owner != apiKey.ID) {
+			continue
+		}
+		keys = append(keys, key)
+		links = append(links, link)
+	}
+
+	if err := database.ExpireLinks(ctx, r, keys, ttl); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	// the indexes and analytics have to outlive links whose expiry moved
+	// later
+	for i, link := range links {
+		id := database.LinkCode(keys[i])
+		_ = database.IndexDestination(ctx, r, tenant, link.URL, id, ttl)
+		_ = database.IndexTags(ctx, r, tenant, link.Tags, id, ttl)
+	}
+	alignAnalytics(ctx, r, ttl, keys...)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"updated": len(keys)})
+}
+
+// DeleteLinks deletes every link carrying ?tag=, or whose short starts with
+// ?prefix=, along with its index entries and click analytics, and reports how
+// many were removed. Deleting again is harmless, so it's safe to retry.
+func DeleteLinks(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	tag, prefix := c.Query("tag"), c.Query("prefix")
+	if (tag == "") == (prefix == "") {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "exactly one of tag or prefix is required"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+	rStats := database.CreateClientContext(ctx, 1)
+
+	tenant, err := database.TenantForHost(ctx, r, c.Hostname())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	var keys []string
+	if tag != "" {
+		tags, err := helpers.NormalizeTags([]string{tag})
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		tag = tags[0]
+		ids, err := r.SMembers(ctx, database.TagKey(tenant, tag)).Result()
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		for _, id := range ids {
+			keys = append(keys, database.LinkKey(tenant, id))
+		}
+	} else {
+		if err := helpers.ValidateShortPrefix(prefix); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		err := database.ScanKeys(ctx, r, database.LinkKey(tenant, helpers.CanonicalCode(prefix))+"*", func(key string) error {
+			keys = append(keys, key)
+			return nil
+		})
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		link, err := database.GetLink(ctx, r, key)
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if err := cleanupLink(ctx, r, rStats, tenant, key, link); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		deleted++
+	}
+	if tag != "" {
+		// drop ids of links that had already expired
+		r.Del(ctx, database.TagKey(tenant, tag))
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"deleted": deleted})
+}
+
+// alignAnalytics makes the click analytics of the links at keys, and of their
+// aliases, expire with the links now that they expire after ttl. It's best
+// effort: analytics left behind still expire once the link's next click
+// realigns them.
+func alignAnalytics(ctx context.Context, r database.Store, ttl time.Duration, keys ...string) {
+	all := append([]string(nil), keys...)
+	for _, key := range keys {
+		aliases, err := database.LinkAliases(ctx, r, key)
+		if err != nil {
+			return
+		}
+		all = append(all, aliases...)
+	}
+	rStats := database.CreateClientContext(ctx, 1)
+	_ = database.AlignClicks(ctx, rStats, ttl, all...)
+}
+
+// purgeAnalytics is when a link's click analytics are removed, from
+// PURGE_ANALYTICS: "delete", the default, when it's deleted; "expire" also
+// when it's expired, once its retention ends; "never" keeps them.
+func purgeAnalytics() string {
+	return config.String("PURGE_ANALYTICS", "delete")
+}
+
+// cleanupLink deletes link, stored under key in tenant, with its aliases and
+// index entries, and then the click analytics of it and its aliases unless
+// PURGE_ANALYTICS=never. Each database is changed in one transaction, so
+// neither is left with part of the link; analytics left behind by a failure
+// are removed when the delete is retried.
+func cleanupLink(ctx context.Context, r, rStats database.Store, tenant, key string, link *database.Link) error {
+	keys := []string{key}
+	if link.PrimaryKey == "" {
+		aliases, err := database.LinkAliases(ctx, r, key)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, aliases...)
+	}
+	if err := database.DeleteLink(ctx, r, tenant, key, link); err != nil {
+		return err
+	}
+	if purgeAnalytics() == "never" {
+		return nil
+	}
+	return database.DeleteClicks(ctx, rStats, keys...)
+}
+
+// updateRequest holds the fields an owner can change. Omitted fields are left
+// as they are.
+type updateRequest struct {
+	URL                   *string `json:"url"`
+	Note                  *string `json:"note"`
+	Permanent             *bool   `json:"permanent"`
+	CacheTTLSeconds       *int    `json:"cache_ttl_seconds"`
+	ResolveLimitPerMinute *int    `json:"resolve_limit_per_minute"`
+	HideDestination       *bool   `json:"hide_destination"`
+	// AllowedReferrers replaces the allowlist; an empty list lifts it.
+	AllowedReferrers *[]string `json:"allowed_referrers"`
+	// Locales replaces the locale destinations; an empty map removes them.
+	Locales *map[string]string `json:"locales"`
+	// Headers replaces the redirect headers; an empty map removes them.
+	Headers *map[string]string `json:"headers"`
+	// FallbackURL replaces the fallback destination; "" removes it.
+	FallbackURL *string `json:"fallback_url"`
+	// Tags replaces the link's tags; an empty list removes them.
+	Tags *[]string `json:"tags"`
+	// NoReferrer turns the Referer-stripping redirect page on or off.
+	NoReferrer *bool `json:"no_referrer"`
+	// AppendPath turns appending extra path segments on or off.
+	AppendPath *bool `json:"append_path"`
+	// Disabled stops or resumes redirects. Only admins may change it.
+	Disabled *bool `json:"disabled"`
+}
+
+// UpdateLink changes an existing link. It requires the link's edit token (or
+// the admin key) and keeps the link's expiry.
+func UpdateLink(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	body := new(updateRequest)
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !isOwner(c, link) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid edit token"})
+	}
+	// a link disabled for abuse can't be re-enabled by its owner
+	if body.Disabled != nil && !middleware.IsAdmin(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "only admins can change disabled"})
+	}
+	// updating through an alias updates the link it points at
+	if link.PrimaryKey != "" {
+		key = link.PrimaryKey
+		id = database.LinkCode(key)
+	}
+
+	oldURL, oldTags, wasDisabled := link.URL, link.Tags, link.Disabled
+	if err := applyUpdate(link, body); err != nil {
+		return c.Status(validationStatus(err)).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := database.UpdateLink(ctx, r, key, link); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	// a change to the disabled flag is recorded as its own event
+	var changed []string
+	for _, f := range body.fields() {
+		if f != "disabled" {
+			changed = append(changed, f)
+		}
+	}
+	if len(changed) > 0 {
+		recordEvent(ctx, r, key, eventUpdated, strings.Join(changed, ","))
+	}
+	if link.Disabled != wasDisabled {
+		if link.Disabled {
+			recordEvent(ctx, r, key, eventDisabled, "admin")
+		} else {
+			recordEvent(ctx, r, key, eventEnabled, "admin")
+		}
+	}
+	if body.URL != nil || body.Tags != nil {
+		ttl, _ := r.TTL(ctx, key).Result()
+		tenant, _ := database.TenantForHost(ctx, r, c.Hostname())
+		if body.URL != nil {
+			if link.URL != oldURL {
+				r.SRem(ctx, database.ReverseKey(tenant, oldURL), id)
+			}
+			_ = database.IndexDestination(ctx, r, tenant, link.URL, id, ttl)
+		}
+		if body.Tags != nil {
+			for _, tag := range oldTags {
+				r.SRem(ctx, database.TagKey(tenant, tag), id)
+			}
+			_ = database.IndexTags(ctx, r, tenant, link.Tags, id, ttl)
+		}
+	}
+
+	return c.Status(fiber.StatusOK).JSON(newLinkInfo(id, link, true))
+}
+
+// fields lists the fields body changes, by their JSON names.
+func (body *updateRequest) fields() []string {
+	var names []string
+	v := reflect.ValueOf(body).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Field(i).IsNil() {
+			names = append(names, strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0])
+		}
+	}
+	return names
+}
+
+// applyUpdate validates body and copies its fields onto link.
+func applyUpdate(link *database.Link, body *updateRequest) error {
+	if body.URL != nil {
+		if err := helpers.ValidateURL(*body.URL); err != nil {
+			return err
+		}
+		if !helpers.RemoveDomainError(*body.URL) {
+			return errors.New("you cant hack the system")
+		}
+		link.URL = canonicalDestination(*body.URL)
+		if err := helpers.CheckHTTPS(link.URL); err != nil {
+			return err
+		}
+		if err := helpers.CheckExtension(link.URL); err != nil {
+			return err
+		}
+		if err := helpers.CheckPrivateHost(link.URL); err != nil {
+			return err
+		}
+	}
+	if body.Note != nil {
+		if err := validateNote(*body.Note); err != nil {
+			return err
+		}
+		link.Note = *body.Note
+	}
+	if body.Permanent != nil {
+		link.Permanent = *body.Permanent
+	}
+	if body.CacheTTLSeconds != nil {
+		if *body.CacheTTLSeconds < 0 {
+			return errors.New("cache_ttl_seconds cannot be negative")
+		}
+		link.CacheTTLSeconds = body.CacheTTLSeconds
+	}
+	if body.ResolveLimitPerMinute != nil {
+		if *body.ResolveLimitPerMinute < 0 {
+			return errors.New("resolve_limit_per_minute cannot be negative")
+		}
+		link.ResolveLimitPerMinute = *body.ResolveLimitPerMinute
+	}
+	if body.HideDestination != nil {
+		link.HideDestination = *body.HideDestination
+	}
+	if body.NoReferrer != nil {
+		link.NoReferrer = *body.NoReferrer
+	}
+	if body.AppendPath != nil {
+		link.AppendPath = *body.AppendPath
+	}
+	if body.Disabled != nil {
+		link.Disabled = *body.Disabled
+	}
+	if body.AllowedReferrers != nil {
+		referrers, err := normalizeReferrers(*body.AllowedReferrers)
+		if err != nil {
+			return err
+		}
+		link.AllowedReferrers = referrers
+	}
+	if body.Locales != nil {
+		locales, err := normalizeLocales(*body.Locales)
+		if err != nil {
+			return err
+		}
+		link.Locales = locales
+	}
+	if body.Headers != nil {
+		headers, err := helpers.NormalizeHeaders(*body.Headers)
+		if err != nil {
+			return err
+		}
+		link.Headers = headers
+	}
+	if body.FallbackURL != nil {
+		link.FallbackURL = ""
+		if *body.FallbackURL != "" {
+			fallback, err := normalizeDestination(*body.FallbackURL)
+			if err != nil {
+				return fmt.Errorf("fallback_url: %w", err)
+			}
+			link.FallbackURL = fallback
+		}
+	}
+	if body.Tags != nil {
+		tags, err := helpers.NormalizeTags(*body.Tags)
+		if err != nil {
+			return err
+		}
+		link.Tags = tags
+	}
+	return checkLinkSize(link)
+}
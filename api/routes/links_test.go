@@ -0,0 +1,812 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestDeleteLinksCaseInsensitivePrefix(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY", "adm")
+	t.Setenv("CASE_INSENSITIVE_SHORTS", "true")
+	app := newTestApp()
+	seedLink(t, "promo-1", &database.Link{URL: "https://example.com/1"})
+	seedLink(t, "promo-2", &database.Link{URL: "https://example.com/2"})
+	seedLink(t, "other", &database.Link{URL: "https://example.com/3"})
+
+	resp := sendHeaders(t, app, "DELETE", "/api/v1/links?prefix=PROMO-", "", map[string]string{"X-Admin-Key": "adm"})
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK || body["deleted"] != float64(2) {
+		t.Fatalf("delete: %d %v, want %d with 2 deleted", resp.StatusCode, body, fiber.StatusOK)
+	}
+	if resp := send(t, app, "GET", "/other", "", ""); resp.StatusCode != fiber.StatusFound {
+		t.Errorf("link outside the prefix: status = %d, want %d", resp.StatusCode, fiber.StatusFound)
+	}
+}
+
+func TestLinkNote(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/q1","short":"noted","note":"Q1 landing page"}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	owner := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+
+	note := func() interface{} {
+		t.Helper()
+		resp := send(t, app, "GET", "/api/v1/links/noted", "", "")
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("info: status = %d", resp.StatusCode)
+		}
+		return decode(t, resp)["note"]
+	}
+	if got := note(); got != "Q1 landing page" {
+		t.Errorf("info: note = %v, want Q1 landing page", got)
+	}
+	resp = sendHeaders(t, app, "GET", "/api/v1/links", "", admin)
+	var list struct {
+		Data []struct {
+			Short string `json:"short"`
+			Note  string `json:"note"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil || len(list.Data) != 1 || list.Data[0].Note != "Q1 landing page" {
+		t.Errorf("list: %+v, %v; want the note", list, err)
+	}
+
+	if resp := sendHeaders(t, app, "PATCH", "/noted", `{"note":"Q2 landing page"}`, owner); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	if got := note(); got != "Q2 landing page" {
+		t.Errorf("after the update: note = %v, want Q2 landing page", got)
+	}
+	if resp := sendHeaders(t, app, "PATCH", "/noted", `{"note":""}`, owner); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("clearing: status = %d", resp.StatusCode)
+	}
+	if got := note(); got != nil {
+		t.Errorf("after clearing: note = %v, want none", got)
+	}
+
+	// 280 characters, not bytes
+	long := strings.Repeat("é", 280)
+	for _, tt := range []struct {
+		note   string
+		status int
+	}{
+		{long, fiber.StatusOK},
+		{long + "e", fiber.StatusBadRequest},
+	} {
+		if resp := sendHeaders(t, app, "PATCH", "/noted", fmt.Sprintf(`{"note":%q}`, tt.note), owner); resp.StatusCode != tt.status {
+			t.Errorf("update with a %d character note: status = %d, want %d", len([]rune(tt.note)), resp.StatusCode, tt.status)
+		}
+	}
+	resp = send(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":"https://example.com/","note":%q}`, long+"e"), "")
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("shorten with a 281 character note: %d %v, want %d", resp.StatusCode, body, fiber.StatusBadRequest)
+	}
+	if resp := sendHeaders(t, app, "PATCH", "/noted", `{"note":"mine now"}`, nil); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("update without the edit token: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+
+	// notes never change where the link goes
+	if resp := send(t, app, "GET", "/noted", "", ""); resp.Header.Get(fiber.HeaderLocation) != "https://example.com/q1" {
+		t.Errorf("GET /noted: %d to %q", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+}
+
+func TestDeleteLinksByTag(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	ctx := context.Background()
+	r, rStats := database.CreateClient(0), database.CreateClient(1)
+	browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+	for _, l := range []struct{ short, url, tags string }{
+		{"camp-a", "https://example.com/a", `["campaign-x"]`},
+		{"camp-b", "https://example.com/shared", `["campaign-x","q1"]`},
+		{"keep", "https://example.com/shared", `["q1"]`},
+	} {
+		resp := send(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":%q,"short":%q,"tags":%s}`, l.url, l.short, l.tags), "")
+		if resp.StatusCode != fiber.StatusCreated {
+			t.Fatalf("shorten %s: status = %d (%v)", l.short, resp.StatusCode, decode(t, resp))
+		}
+		sendHeaders(t, app, "GET", "/"+l.short, "", browser)
+	}
+	if resp := sendHeaders(t, app, "POST", "/camp-a/alias", `{"alias":"camp-alias"}`, admin); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("alias: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+
+	if resp := send(t, app, "DELETE", "/api/v1/links?tag=campaign-x", "", ""); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("delete without the admin key: status = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+	resp := sendHeaders(t, app, "DELETE", "/api/v1/links?tag=Campaign-X", "", admin)
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK || body["deleted"] != float64(2) {
+		t.Fatalf("delete: %d %v, want %d with 2 deleted", resp.StatusCode, body, fiber.StatusOK)
+	}
+
+	for _, short := range []string{"camp-a", "camp-b", "camp-alias"} {
+		if resp := send(t, app, "GET", "/"+short, "", ""); resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("GET /%s: status = %d, want %d", short, resp.StatusCode, fiber.StatusNotFound)
+		}
+		key := database.LinkKey("", short)
+		if n, _ := rStats.Exists(ctx, database.ClicksKey(key), database.VisitorsKey(key)).Result(); n != 0 {
+			t.Errorf("%s: %d analytics keys left", short, n)
+		}
+	}
+	if n, _ := r.Exists(ctx, database.TagKey("", "campaign-x"), database.ReverseKey("", "https://example.com/a")).Result(); n != 0 {
+		t.Errorf("%d index keys left for the deleted links", n)
+	}
+	if ids, _ := r.SMembers(ctx, database.ReverseKey("", "https://example.com/shared")).Result(); len(ids) != 1 || ids[0] != "keep" {
+		t.Errorf("reverse index of the shared destination = %v, want [keep]", ids)
+	}
+	if ids, _ := r.SMembers(ctx, database.TagKey("", "q1")).Result(); len(ids) != 1 || ids[0] != "keep" {
+		t.Errorf("q1 tag = %v, want [keep]", ids)
+	}
+	top, _ := database.TopLinks(ctx, rStats, 10)
+	if len(top) != 1 || top[0].Member != database.LinkKey("", "keep") {
+		t.Errorf("leaderboard = %v, want only keep", top)
+	}
+	if resp := send(t, app, "GET", "/keep", "", ""); resp.StatusCode != fiber.StatusMovedPermanently {
+		t.Errorf("GET /keep: status = %d, want %d", resp.StatusCode, fiber.StatusMovedPermanently)
+	}
+
+	// retrying is harmless
+	resp = sendHeaders(t, app, "DELETE", "/api/v1/links?tag=campaign-x", "", admin)
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK || body["deleted"] != float64(0) {
+		t.Errorf("retry: %d %v, want %d with 0 deleted", resp.StatusCode, body, fiber.StatusOK)
+	}
+	for _, query := range []string{"", "?tag=q1&prefix=k", "?prefix=k*"} {
+		if resp := sendHeaders(t, app, "DELETE", "/api/v1/links"+query, "", admin); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("DELETE /api/v1/links%s: status = %d, want %d", query, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
+
+func TestLinkTags(t *testing.T) {
+	t.Setenv("MAX_TAGS_PER_LINK", "3")
+	t.Setenv("MAX_TAG_LEN", "8")
+	app := newTestApp()
+	ctx := context.Background()
+	r := database.CreateClient(0)
+	tagged := func(tag string) []string {
+		t.Helper()
+		ids, err := r.SMembers(ctx, database.TagKey("", tag)).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ids
+	}
+
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"tagged","tags":[" Spring","sale","SPRING"]}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	owner := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+	if got := fmt.Sprint(decode(t, send(t, app, "GET", "/api/v1/links/tagged", "", ""))["tags"]); got != "[spring sale]" {
+		t.Errorf("tags = %s, want [spring sale]", got)
+	}
+	if ids := tagged("spring"); len(ids) != 1 || ids[0] != "tagged" {
+		t.Errorf("spring tags %v, want tagged", ids)
+	}
+
+	// updates replace the tags and move the link between indexes
+	if resp := sendHeaders(t, app, "PATCH", "/tagged", `{"tags":["Summer","summer"]}`, owner); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	if ids := tagged("spring"); len(ids) != 0 {
+		t.Errorf("after the update, spring tags %v, want none", ids)
+	}
+	if ids := tagged("summer"); len(ids) != 1 || ids[0] != "tagged" {
+		t.Errorf("after the update, summer tags %v, want tagged", ids)
+	}
+
+	for _, tags := range []string{
+		`["a","b","c","d"]`,
+		`["a","b","c","d","A"]`,
+		`["toolongtag"]`,
+		`[""]`,
+	} {
+		if resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","tags":`+tags+`}`, ""); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("shorten with tags %s: status = %d, want %d", tags, resp.StatusCode, fiber.StatusBadRequest)
+		}
+		if resp := sendHeaders(t, app, "PATCH", "/tagged", `{"tags":`+tags+`}`, owner); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("update with tags %s: status = %d, want %d", tags, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+	// within the limit once duplicates are dropped
+	if resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","tags":["a","b","c","C"," a"]}`, ""); resp.StatusCode != fiber.StatusCreated {
+		t.Errorf("shorten with duplicate tags: status = %d, want %d", resp.StatusCode, fiber.StatusCreated)
+	}
+}
+
+func TestCreatorInfo(t *testing.T) {
+	t.Setenv("ANALYTICS_SALT", "salt")
+	admin := asAdmin(t)
+	const ua = "AuditBrowser/1.0"
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprint(enabled), func(t *testing.T) {
+			t.Setenv("STORE_CREATOR_INFO", fmt.Sprint(enabled))
+			app := newTestApp()
+			resp := sendHeaders(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"audited"}`, map[string]string{fiber.HeaderUserAgent: ua})
+			created := readAll(t, resp)
+			if resp.StatusCode != fiber.StatusCreated {
+				t.Fatalf("shorten: status = %d (%s)", resp.StatusCode, created)
+			}
+
+			link, err := database.GetLink(context.Background(), database.CreateClient(0), database.LinkKey("", "audited"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ipHash := helpers.VisitorID("0.0.0.0")
+			if enabled && (link.CreatorIP != ipHash || link.CreatorUserAgent != ua) {
+				t.Errorf("stored creator = %q, %q; want %q, %q", link.CreatorIP, link.CreatorUserAgent, ipHash, ua)
+			}
+			if !enabled && (link.CreatorIP != "" || link.CreatorUserAgent != "") {
+				t.Errorf("stored creator = %q, %q; want none", link.CreatorIP, link.CreatorUserAgent)
+			}
+
+			owner := map[string]string{"X-Edit-Token": fmt.Sprint(decodeString(t, created)["edit_token"])}
+			public := map[string]string{
+				"shorten":    created,
+				"info":       readAll(t, send(t, app, "GET", "/api/v1/links/audited", "", "")),
+				"owner info": readAll(t, sendHeaders(t, app, "GET", "/api/v1/links/audited", "", owner)),
+				"stats":      readAll(t, send(t, app, "GET", "/api/v1/stats/audited", "", "")),
+				"peek":       readAll(t, send(t, app, "GET", "/api/v1/peek/audited", "", "")),
+			}
+			for name, body := range public {
+				if strings.Contains(body, "creator") || strings.Contains(body, ipHash) || strings.Contains(body, ua) {
+					t.Errorf("%s shows the creator: %s", name, body)
+				}
+			}
+
+			var info struct {
+				Creator *struct {
+					IP        string `json:"ip_hash"`
+					UserAgent string `json:"user_agent"`
+				} `json:"creator"`
+			}
+			if err := json.NewDecoder(sendHeaders(t, app, "GET", "/api/v1/links/audited", "", admin).Body).Decode(&info); err != nil {
+				t.Fatal(err)
+			}
+			if enabled && (info.Creator == nil || info.Creator.IP != ipHash || info.Creator.UserAgent != ua) {
+				t.Errorf("admin info: creator = %+v, want %s, %s", info.Creator, ipHash, ua)
+			}
+			if !enabled && info.Creator != nil {
+				t.Errorf("admin info: creator = %+v, want none", info.Creator)
+			}
+			list := readAll(t, sendHeaders(t, app, "GET", "/api/v1/links", "", admin))
+			if strings.Contains(list, ipHash) != enabled {
+				t.Errorf("admin list shows the creator = %v, want %v: %s", !enabled, enabled, list)
+			}
+		})
+	}
+}
+
+// decodeString decodes the JSON object in body, failing t if it isn't one.
+func decodeString(t *testing.T, body string) map[string]interface{} {
+	t.Helper()
+	var out map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &out); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+	return out
+}
+
+func TestListPaging(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		// link-1 is the newest
+		seedLink(t, fmt.Sprintf("link-%d", i), &database.Link{URL: "https://example.com/", CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	for _, endpoint := range []string{"/api/v1/links", "/api/v1/admin/links/recent"} {
+		t.Run(endpoint, func(t *testing.T) {
+			var got []string
+			cursor, pages := "", 0
+			for {
+				var env struct {
+					Data []struct {
+						Short string `json:"short"`
+					} `json:"data"`
+					NextCursor *string `json:"next_cursor"`
+					Count      *int    `json:"count"`
+				}
+				path := endpoint + "?limit=2"
+				if cursor != "" {
+					path += "&cursor=" + cursor
+				}
+				resp := sendHeaders(t, app, "GET", path, "", admin)
+				if err := json.NewDecoder(resp.Body).Decode(&env); err != nil || resp.StatusCode != fiber.StatusOK {
+					t.Fatalf("GET %s: status = %d, %v", path, resp.StatusCode, err)
+				}
+				if env.Data == nil || env.NextCursor == nil || env.Count == nil || *env.Count != len(env.Data) {
+					t.Fatalf("GET %s: envelope = %+v, want data, next_cursor and a matching count", path, env)
+				}
+				for _, l := range env.Data {
+					got = append(got, l.Short)
+				}
+				pages++
+				if cursor = *env.NextCursor; cursor == "" {
+					break
+				}
+				if pages > 3 {
+					t.Fatalf("still paging after %d pages", pages)
+				}
+			}
+			want := "[link-1 link-2 link-3 link-4 link-5]"
+			if endpoint == "/api/v1/admin/links/recent" {
+				// oldest first
+				want = "[link-5 link-4 link-3 link-2 link-1]"
+			}
+			if pages != 3 || fmt.Sprint(got) != want {
+				t.Errorf("%d pages of %v, want 3 pages of %s", pages, got, want)
+			}
+
+			// past the end is an empty last page
+			resp := sendHeaders(t, app, "GET", endpoint+"?cursor=10", "", admin)
+			if body := readAll(t, resp); resp.StatusCode != fiber.StatusOK || body != `{"data":[],"next_cursor":"","count":0}` {
+				t.Errorf("past the end: %d %s", resp.StatusCode, body)
+			}
+			for _, query := range []string{"?limit=0", "?limit=x", "?cursor=-1", "?cursor=x"} {
+				if resp := sendHeaders(t, app, "GET", endpoint+query, "", admin); resp.StatusCode != fiber.StatusBadRequest {
+					t.Errorf("GET %s: status = %d, want %d", endpoint+query, resp.StatusCode, fiber.StatusBadRequest)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateLinksExpiry(t *testing.T) {
+	t.Setenv("MAX_EXPIRY", "30d")
+	admin := asAdmin(t)
+	app := newTestApp()
+	ctx := context.Background()
+	r := database.CreateClient(0)
+	write := []string{database.ScopeWrite}
+	saveKey(t, "key-alice", &database.APIKey{Scopes: write})
+	saveKey(t, "key-bob", &database.APIKey{Scopes: write})
+	asAlice := map[string]string{"X-API-Key": "key-alice"}
+	for _, l := range []struct {
+		short, tags string
+		header      map[string]string
+	}{
+		{"camp-1", `["campaign"]`, asAlice},
+		{"camp-2", `["campaign","q1"]`, map[string]string{"X-API-Key": "key-bob"}},
+		{"other", `["q1"]`, asAlice},
+	} {
+		resp := sendHeaders(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":"https://example.com/","short":%q,"tags":%s}`, l.short, l.tags), l.header)
+		if resp.StatusCode != fiber.StatusCreated {
+			t.Fatalf("shorten %s: status = %d (%v)", l.short, resp.StatusCode, decode(t, resp))
+		}
+	}
+	ttl := func(short string) time.Duration {
+		t.Helper()
+		d, err := r.TTL(ctx, database.LinkKey("", short)).Result()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	within := func(got, want time.Duration) bool { return got <= want && got > want-time.Minute }
+
+	// an API key only extends the links it owns
+	resp := sendHeaders(t, app, "PATCH", "/api/v1/links/expiry", `{"tag":"Campaign","expiry_hours":48}`, asAlice)
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK || body["updated"] != float64(1) {
+		t.Fatalf("as alice: %d %v, want %d with 1 updated", resp.StatusCode, body, fiber.StatusOK)
+	}
+	if got := ttl("camp-1"); !within(got, 48*time.Hour) {
+		t.Errorf("camp-1 TTL = %v, want 48h", got)
+	}
+	for _, short := range []string{"camp-2", "other"} {
+		if got := ttl(short); !within(got, 24*time.Hour) {
+			t.Errorf("%s TTL = %v, want the default 24h left alone", short, got)
+		}
+	}
+
+	// admins extend them all, up to MAX_EXPIRY
+	resp = sendHeaders(t, app, "PATCH", "/api/v1/links/expiry", `{"tag":"campaign","expiry_hours":100000}`, admin)
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK || body["updated"] != float64(2) {
+		t.Fatalf("as admin: %d %v, want %d with 2 updated", resp.StatusCode, body, fiber.StatusOK)
+	}
+	for _, short := range []string{"camp-1", "camp-2"} {
+		if got := ttl(short); !within(got, 30*24*time.Hour) {
+			t.Errorf("%s TTL = %v, want MAX_EXPIRY", short, got)
+		}
+	}
+	if resp := send(t, app, "GET", "/camp-2", "", ""); resp.StatusCode != fiber.StatusMovedPermanently {
+		t.Errorf("GET /camp-2 after the update: status = %d, want %d", resp.StatusCode, fiber.StatusMovedPermanently)
+	}
+	if ids, _ := r.SMembers(ctx, database.TagKey("", "campaign")).Result(); len(ids) != 2 {
+		t.Errorf("campaign tag = %v, want both links", ids)
+	}
+
+	refused := []struct {
+		name   string
+		body   string
+		header map[string]string
+		status int
+	}{
+		{"anonymously", `{"tag":"campaign","expiry_hours":1}`, nil, fiber.StatusForbidden},
+		{"without a tag", `{"expiry_hours":1}`, admin, fiber.StatusBadRequest},
+		{"with no hours", `{"tag":"campaign"}`, admin, fiber.StatusBadRequest},
+		{"with negative hours", `{"tag":"campaign","expiry_hours":-1}`, admin, fiber.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		if resp := sendHeaders(t, app, "PATCH", "/api/v1/links/expiry", tt.body, tt.header); resp.StatusCode != tt.status {
+			t.Errorf("update %s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+}
+
+func TestListSorted(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	ctx := context.Background()
+	now := time.Now()
+	browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+	// sort-a is the newest
+	for i, l := range []struct {
+		short  string
+		clicks int
+	}{{"sort-a", 2}, {"sort-b", 0}, {"sort-c", 3}, {"sort-d", 1}} {
+		seedLink(t, l.short, &database.Link{URL: "https://example.com/", CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+		// as shortening does, so unclicked links are listed by clicks too
+		if err := database.RankLink(ctx, database.CreateClient(1), database.LinkKey("", l.short)); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < l.clicks; j++ {
+			sendHeaders(t, app, "GET", "/"+l.short, "", browser)
+		}
+	}
+	list := func(query string) []string {
+		t.Helper()
+		var shorts []string
+		cursor := ""
+		for pages := 0; ; pages++ {
+			if pages > 4 {
+				t.Fatalf("GET ?%s: still paging after %d pages", query, pages)
+			}
+			path := "/api/v1/links?limit=3&" + query
+			if cursor != "" {
+				path += "&cursor=" + cursor
+			}
+			resp := sendHeaders(t, app, "GET", path, "", admin)
+			body := decode(t, resp)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("GET %s: status = %d (%v)", path, resp.StatusCode, body)
+			}
+			for _, l := range body["data"].([]interface{}) {
+				shorts = append(shorts, fmt.Sprint(l.(map[string]interface{})["short"]))
+			}
+			if cursor = fmt.Sprint(body["next_cursor"]); cursor == "" {
+				return shorts
+			}
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "[sort-a sort-b sort-c sort-d]"},
+		{"sort=created_at&order=desc", "[sort-a sort-b sort-c sort-d]"},
+		{"sort=created_at&order=asc", "[sort-d sort-c sort-b sort-a]"},
+		{"order=asc", "[sort-d sort-c sort-b sort-a]"},
+		{"sort=clicks", "[sort-c sort-a sort-d sort-b]"},
+		{"sort=clicks&order=asc", "[sort-b sort-d sort-a sort-c]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(list(tt.query)); got != tt.want {
+			t.Errorf("GET ?%s: %s, want %s", tt.query, got, tt.want)
+		}
+	}
+
+	// links gone since they were ranked are skipped
+	database.CreateClient(0).Del(ctx, database.LinkKey("", "sort-a"))
+	if got := fmt.Sprint(list("sort=clicks")); got != "[sort-c sort-d sort-b]" {
+		t.Errorf("after sort-a went: %s, want [sort-c sort-d sort-b]", got)
+	}
+
+	for _, query := range []string{"sort=name", "order=up", "sort=clicks&order=ASC"} {
+		if resp := sendHeaders(t, app, "GET", "/api/v1/links?"+query, "", admin); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("GET ?%s: status = %d, want %d", query, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
+
+func TestRecentLinks(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	now := time.Now()
+	for code, age := range map[string]time.Duration{
+		"recent-new":  time.Minute,
+		"recent-day":  23 * time.Hour,
+		"recent-week": 6 * 24 * time.Hour,
+		"recent-old":  30 * 24 * time.Hour,
+	} {
+		seedLink(t, code, &database.Link{URL: "https://example.com/" + code, CreatedAt: now.Add(-age)})
+	}
+	recent := func(query string) []string {
+		t.Helper()
+		resp := sendHeaders(t, app, "GET", "/api/v1/admin/links/recent"+query, "", admin)
+		body := decode(t, resp)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("GET %s: status = %d (%v)", query, resp.StatusCode, body)
+		}
+		var shorts []string
+		for _, l := range body["data"].([]interface{}) {
+			shorts = append(shorts, fmt.Sprint(l.(map[string]interface{})["short"]))
+		}
+		return shorts
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "[recent-day recent-new]"},
+		{"?since=1h", "[recent-new]"},
+		{"?since=7d", "[recent-week recent-day recent-new]"},
+		{"?since=365d", "[recent-old recent-week recent-day recent-new]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(recent(tt.query)); got != tt.want {
+			t.Errorf("GET %s: %s, want %s", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"?since=soon", "?since=never", "?since=-1h", "?limit=0"} {
+		if resp := sendHeaders(t, app, "GET", "/api/v1/admin/links/recent"+query, "", admin); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("GET %s: status = %d, want %d", query, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+	if resp := send(t, app, "GET", "/api/v1/admin/links/recent", "", ""); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("without the admin key: status = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+}
+
+// analyticsKeys lists the DB 1 keys about the links at keys, and whether
+// they're on the leaderboard.
+func analyticsKeys(t *testing.T, keys ...string) []string {
+	t.Helper()
+	ctx := context.Background()
+	rStats := database.CreateClient(1)
+	var found []string
+	for _, key := range keys {
+		err := database.ScanKeys(ctx, rStats, "*"+key+"*", func(k string) error {
+			found = append(found, k)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if onLeaderboard(t, key) {
+			found = append(found, "leaderboard "+key)
+		}
+	}
+	return found
+}
+
+// onLeaderboard reports whether the link at key is among the most clicked.
+func onLeaderboard(t *testing.T, key string) bool {
+	t.Helper()
+	members, err := database.CreateClient(1).ZRange(context.Background(), "leaderboard", 0, -1).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		if m == key {
+			return true
+		}
+	}
+	return false
+}
+
+func TestDeleteLinksPurgesAnalytics(t *testing.T) {
+	t.Setenv("ALIAS_STATS", "separate")
+	admin := asAdmin(t)
+	browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+	keys := []string{database.LinkKey("", "purged"), database.LinkKey("", "purged-alias")}
+	for _, tt := range []struct {
+		setting string
+		kept    bool
+	}{
+		{"", false},
+		{"delete", false},
+		{"never", true},
+	} {
+		t.Run(tt.setting, func(t *testing.T) {
+			t.Setenv("PURGE_ANALYTICS", tt.setting)
+			app := newTestApp()
+			seedLink(t, "purged", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+			if resp := sendHeaders(t, app, "POST", "/purged/alias", `{"alias":"purged-alias"}`, map[string]string{"X-Edit-Token": "tok"}); resp.StatusCode != fiber.StatusCreated {
+				t.Fatalf("alias: status = %d", resp.StatusCode)
+			}
+			sendHeaders(t, app, "GET", "/purged", "", browser)
+			sendHeaders(t, app, "GET", "/purged-alias", "", browser)
+			sendHeaders(t, app, "GET", "/purged", "", map[string]string{fiber.HeaderUserAgent: "curl/8.0"})
+			if got := analyticsKeys(t, keys...); len(got) == 0 {
+				t.Fatal("no analytics recorded")
+			}
+
+			resp := sendHeaders(t, app, "DELETE", "/api/v1/links?prefix=purged", "", admin)
+			if body := decode(t, resp); resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("delete: %d %v", resp.StatusCode, body)
+			}
+			got := analyticsKeys(t, keys...)
+			if tt.kept && len(got) == 0 {
+				t.Error("analytics purged with PURGE_ANALYTICS=never")
+			} else if !tt.kept && len(got) > 0 {
+				t.Errorf("analytics left after deleting: %v", got)
+			}
+		})
+	}
+}
+
+func TestDeleteAliasPurgesAnalytics(t *testing.T) {
+	t.Setenv("ALIAS_STATS", "separate")
+	app := newTestApp()
+	owner := map[string]string{"X-Edit-Token": "tok"}
+	seedLink(t, "kept", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+	sendHeaders(t, app, "POST", "/kept/alias", `{"alias":"dropped"}`, owner)
+	sendHeaders(t, app, "GET", "/kept", "", map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"})
+	sendHeaders(t, app, "GET", "/dropped", "", map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"})
+
+	if resp := sendHeaders(t, app, "DELETE", "/kept/alias/dropped", "", owner); resp.StatusCode != fiber.StatusNoContent && resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("delete alias: status = %d", resp.StatusCode)
+	}
+	if got := analyticsKeys(t, database.LinkKey("", "dropped")); len(got) > 0 {
+		t.Errorf("alias analytics left: %v", got)
+	}
+	if got := analyticsKeys(t, database.LinkKey("", "kept")); len(got) == 0 {
+		t.Error("the link's own analytics went with its alias")
+	}
+}
+
+func TestLinkLimits(t *testing.T) {
+	app := newTestApp()
+	hosts := func(n int) string {
+		list := make([]string, n)
+		for i := range list {
+			list[i] = fmt.Sprintf("%q", fmt.Sprintf("r%d.example.org", i))
+		}
+		return "[" + strings.Join(list, ",") + "]"
+	}
+	shorten := func(name, extra string, want int) {
+		t.Helper()
+		body := `{"url":"https://example.com/"` + extra + `}`
+		resp := send(t, app, "POST", "/api/v1", body, "")
+		if got := decode(t, resp); resp.StatusCode != want {
+			t.Errorf("%s: %d %v, want %d", name, resp.StatusCode, got, want)
+		}
+	}
+
+	// 50 targets by default
+	shorten("50 referrers", `,"allowed_referrers":`+hosts(50), fiber.StatusCreated)
+	shorten("51 referrers", `,"allowed_referrers":`+hosts(51), fiber.StatusBadRequest)
+
+	t.Setenv("MAX_LINK_TARGETS", "2")
+	shorten("2 locales", `,"locales":{"en":"https://example.com/en","de":"https://example.de/"}`, fiber.StatusCreated)
+	shorten("3 locales", `,"locales":{"en":"https://example.com/en","de":"https://example.de/","fr":"https://example.fr/"}`, fiber.StatusBadRequest)
+	shorten("3 referrers", `,"allowed_referrers":`+hosts(3), fiber.StatusBadRequest)
+
+	t.Setenv("MAX_LINK_SIZE", "400")
+	shorten("small", `,"note":"short"`, fiber.StatusCreated)
+	shorten("oversized", `,"note":"`+strings.Repeat("x", 270)+`","tags":["a-long-tag-one","a-long-tag-two"]`, fiber.StatusBadRequest)
+	t.Setenv("MAX_LINK_SIZE", "")
+	shorten("the same under the default size", `,"note":"`+strings.Repeat("x", 270)+`","tags":["a-long-tag-one","a-long-tag-two"]`, fiber.StatusCreated)
+	t.Setenv("MAX_LINK_SIZE", "400")
+
+	// updates are held to the same limits
+	seedLink(t, "limited", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+	owner := map[string]string{"X-Edit-Token": "tok"}
+	for _, tt := range []struct {
+		name, body string
+		want       int
+	}{
+		{"2 referrers", `{"allowed_referrers":` + hosts(2) + `}`, fiber.StatusOK},
+		{"3 referrers", `{"allowed_referrers":` + hosts(3) + `}`, fiber.StatusBadRequest},
+		{"3 locales", `{"locales":{"en":"https://example.com/en","de":"https://example.de/","fr":"https://example.fr/"}}`, fiber.StatusBadRequest},
+		{"oversized", `{"note":"` + strings.Repeat("x", 270) + `","tags":["a-long-tag-one","a-long-tag-two"]}`, fiber.StatusBadRequest},
+	} {
+		if resp := sendHeaders(t, app, "PATCH", "/limited", tt.body, owner); resp.StatusCode != tt.want {
+			t.Errorf("update with %s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestAnalyticsFollowLinkExpiry(t *testing.T) {
+	t.Setenv("ANALYTICS_RETENTION", "1h")
+	admin := asAdmin(t)
+	app := newTestApp()
+	ctx := context.Background()
+	rStats := database.CreateClient(1)
+	key := database.LinkKey("", "aligned")
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"aligned","expiry":"2h","tags":["aligned"]}`, "")
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: %d %v", resp.StatusCode, body)
+	}
+	sendHeaders(t, app, "GET", "/aligned", "", map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"})
+	sendHeaders(t, app, "GET", "/aligned", "", map[string]string{fiber.HeaderUserAgent: "Googlebot/2.1"})
+
+	check := func(when string, want time.Duration) {
+		t.Helper()
+		for _, k := range []string{database.ClicksKey(key), database.BotClicksKey(key), database.VisitorsKey(key)} {
+			ttl, err := rStats.TTL(ctx, k).Result()
+			if err != nil || ttl < want-time.Minute || ttl > want+time.Minute {
+				t.Errorf("%s: %s TTL = %v, %v, want about %v", when, k, ttl, err, want)
+			}
+		}
+	}
+	// the link's 2h, plus an hour's retention
+	check("after clicks", 3*time.Hour)
+
+	resp = sendHeaders(t, app, "PATCH", "/api/v1/links/expiry", `{"tag":"aligned","expiry_hours":48}`, admin)
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update expiry: %d %v", resp.StatusCode, body)
+	}
+	check("after moving the expiry", 49*time.Hour)
+}
+
+func TestLinkCapabilities(t *testing.T) {
+	app := newTestApp()
+	capabilities := func(code string, header map[string]string) string {
+		t.Helper()
+		resp := sendHeaders(t, app, "GET", "/api/v1/links/"+code, "", header)
+		body := decode(t, resp)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("info %s: status = %d (%v)", code, resp.StatusCode, body)
+		}
+		return fmt.Sprint(body["capabilities"])
+	}
+
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"plain"}`, "")
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten plain: status = %d", resp.StatusCode)
+	}
+	if got := capabilities("plain", nil); got != "[]" {
+		t.Errorf("plain link: capabilities = %s, want none", got)
+	}
+
+	resp = send(t, app, "POST", "/api/v1", `{
+		"url": "https://example.com/",
+		"short": "loaded",
+		"max_clicks": 10,
+		"resolve_limit_per_minute": 5,
+		"cache_ttl_seconds": 60,
+		"locales": {"de": "https://example.de/"},
+		"fallback_url": "https://example.com/fallback",
+		"append_path": true,
+		"tags": ["promo"],
+		"allowed_referrers": ["example.org"],
+		"headers": {"X-Campaign": "spring"}
+	}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten loaded: status = %d (%v)", resp.StatusCode, created)
+	}
+	public := "[max_clicks resolve_limit_per_minute cache_ttl_seconds locales fallback_url append_path tags]"
+	if got := capabilities("loaded", nil); got != public {
+		t.Errorf("loaded link: capabilities = %s, want %s", got, public)
+	}
+	// what only the owner sees the values of, only the owner is told of
+	owner := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+	if got, want := capabilities("loaded", owner), strings.TrimSuffix(public, "]")+" allowed_referrers headers]"; got != want {
+		t.Errorf("loaded link for its owner: capabilities = %s, want %s", got, want)
+	}
+
+	seedLink(t, "private", &database.Link{URL: "https://example.com/", HideDestination: true, NoReferrer: true})
+	if got := capabilities("private", nil); got != "[hide_destination no_referrer]" {
+		t.Errorf("private link: capabilities = %s, want [hide_destination no_referrer]", got)
+	}
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	"bytes"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/metrics"
+)
+
+// Metrics serves the service's counters in the Prometheus text format.
+func Metrics(c *fiber.Ctx) error {
+	var buf bytes.Buffer
+	if err := metrics.Write(&buf); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot render metrics"})
+	}
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
@@ -0,0 +1,30 @@
+package routes
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/middleware"
+)
+
+// namespace is the short-code namespace of the request's API key, or "" for
+// the default namespace shared by anonymous requests and keys without one.
+func namespace(c *fiber.Ctx) string {
+	if key := middleware.APIKey(c); key != nil {
+		return key.Namespace
+	}
+	return ""
+}
+
+// intoNamespace reports whether short, claimed in the default namespace, would
+// land inside a team's namespace; "team-a/promo" is only for team-a's keys.
+// Codes claimed by a namespaced key are prefixed, so they never do.
+func intoNamespace(c *fiber.Ctx, r database.Store, short string) (bool, error) {
+	if namespace(c) != "" {
+		return false, nil
+	}
+	first, _, _ := strings.Cut(short, helpers.CustomShortSeparator)
+	return database.IsNamespace(c.UserContext(), r, first)
+}
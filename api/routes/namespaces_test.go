@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestShortenNamespaces(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	newKey := func(namespace string) map[string]string {
+		t.Helper()
+		resp := sendHeaders(t, app, "POST", "/api/v1/admin/keys", fmt.Sprintf(`{"scopes":["write"],"namespace":%q}`, namespace), admin)
+		body := decode(t, resp)
+		if resp.StatusCode != fiber.StatusCreated || body["namespace"] != namespace {
+			t.Fatalf("create a key in %s: %d %v", namespace, resp.StatusCode, body)
+		}
+		return map[string]string{"X-API-Key": fmt.Sprint(body["key"])}
+	}
+	teamA, teamB := newKey("team-a"), newKey("team-b")
+	shorten := func(header map[string]string, url, short string) (int, map[string]interface{}) {
+		t.Helper()
+		resp := sendHeaders(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":%q,"short":%q}`, url, short), header)
+		return resp.StatusCode, decode(t, resp)
+	}
+
+	// both teams, and anonymous callers, claim the same code
+	for _, tt := range []struct {
+		header map[string]string
+		url    string
+		code   string
+	}{
+		{teamA, "https://a.example.com/", "team-a/promo"},
+		{teamB, "https://b.example.com/", "team-b/promo"},
+		{nil, "https://example.com/", "promo"},
+	} {
+		if status, body := shorten(tt.header, tt.url, "promo"); status != fiber.StatusCreated || body["code"] != tt.code {
+			t.Errorf("claim promo for %s: %d %v, want %d as %s", tt.url, status, body["code"], fiber.StatusCreated, tt.code)
+		}
+	}
+	for code, want := range map[string]string{
+		"team-a/promo": "https://a.example.com/",
+		"team-b/promo": "https://b.example.com/",
+		"promo":        "https://example.com/",
+	} {
+		if got := send(t, app, "GET", "/"+code, "", "").Header.Get(fiber.HeaderLocation); got != want {
+			t.Errorf("GET /%s: Location = %q, want %q", code, got, want)
+		}
+	}
+
+	// claiming it again in the same namespace collides
+	if status, _ := shorten(teamA, "https://a.example.com/again", "promo"); status == fiber.StatusCreated {
+		t.Error("team-a claimed promo twice")
+	}
+	// generated codes land in the namespace too
+	if status, body := shorten(teamB, "https://b.example.com/gen", ""); status != fiber.StatusCreated || !strings.HasPrefix(fmt.Sprint(body["code"]), "team-b/") {
+		t.Errorf("generated code for team-b: %d %v, want it under team-b/", status, body["code"])
+	}
+	// and nobody else can claim codes inside a team's namespace
+	if status, body := shorten(nil, "https://evil.example/", "Team-A/login"); status != fiber.StatusForbidden || body["code"] != "custom_short_namespace" {
+		t.Errorf("anonymous claim in team-a: %d %v, want %d custom_short_namespace", status, body["code"], fiber.StatusForbidden)
+	}
+	if status, body := shorten(teamB, "https://evil.example/", "team-a/login"); status != fiber.StatusCreated || body["code"] != "team-b/team-a/login" {
+		t.Errorf("team-b claiming team-a/login: %d %v, want it under team-b/", status, body["code"])
+	}
+
+	for _, ns := range []string{"api", "bad/ns", "has space", strings.Repeat("n", 100)} {
+		resp := sendHeaders(t, app, "POST", "/api/v1/admin/keys", fmt.Sprintf(`{"scopes":["write"],"namespace":%q}`, ns), admin)
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("key in namespace %q: status = %d, want %d", ns, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
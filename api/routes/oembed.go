@@ -0,0 +1,97 @@
+package routes
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// oembed is an oEmbed 1.0 "link" response describing a short link.
+type oembed struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	URL          string `json:"url"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+}
+
+// OEmbed describes the short link in ?url= as an oEmbed document so chat apps
+// can render a preview when it's shared. Only the JSON format is supported,
+// and links peek refuses are refused here too. Nothing but the code and
+// tenant is taken from ?url=; the provider is this service.
+func OEmbed(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	if format := c.Query("format", "json"); format != "json" {
+		return c.Status(fiber.StatusNotImplemented).JSON(fiber.Map{"error": "only the json format is supported"})
+	}
+
+	raw := c.Query("url")
+	if raw == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "url is required"})
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	id := ""
+	if err == nil {
+		id = helpers.NormalizeCode(u.EscapedPath())
+	}
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "url is not a short link"})
+	}
+	if !helpers.VerifyCode(id) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	// the short link's own host decides the tenant, not the host asking
+	tenant, err := database.TenantForHost(ctx, r, u.Hostname())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	link, err := database.GetLink(ctx, r, database.LinkKey(tenant, id))
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	if link.Disabled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "this link has been disabled", "code": "link_disabled"})
+	}
+	if link.Expired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "short link has expired", "code": "link_expired"})
+	}
+	if link.HideDestination {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "the destination of this link is hidden"})
+	}
+
+	title := link.Note
+	if title == "" {
+		if dest, err := url.Parse(link.URL); err == nil {
+			title = dest.Hostname()
+		}
+	}
+	// a tenant's domain is the one it was found by
+	provider := strings.ToLower(u.Hostname())
+	if tenant == "" {
+		provider = helpers.ShortDomain(u.Host)
+	}
+
+	return c.Status(fiber.StatusOK).JSON(oembed{
+		Version:      "1.0",
+		Type:         "link",
+		Title:        title,
+		URL:          link.URL,
+		ProviderName: provider,
+		ProviderURL:  c.Protocol() + "://" + provider,
+	})
+}
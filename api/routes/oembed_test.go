@@ -0,0 +1,113 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestOEmbedNormalizesCode(t *testing.T) {
+	t.Setenv("CASE_INSENSITIVE_SHORTS", "true")
+	app := newTestApp()
+	seedLink(t, "docs/start", &database.Link{URL: "https://example.com/docs"})
+	for _, short := range []string{
+		"http://localhost:3000/docs/start",
+		"http://localhost:3000/Docs/Start",
+		"http://localhost:3000/docs/start/",
+		"http://localhost:3000//docs//start",
+		"http://localhost:3000/docs%2Fstart",
+		"localhost:3000/%64ocs/start",
+	} {
+		resp := send(t, app, "GET", "/api/v1/oembed?url="+url.QueryEscape(short), "", "")
+		if resp.StatusCode != fiber.StatusOK {
+			t.Errorf("%s: status = %d, want %d", short, resp.StatusCode, fiber.StatusOK)
+		}
+	}
+}
+
+func TestOEmbed(t *testing.T) {
+	t.Setenv("DOMAIN", "localhost:3000")
+	app := newTestApp()
+	seedLink(t, "noted", &database.Link{URL: "https://example.com/landing", Note: "Q1 landing page"})
+	seedLink(t, "plain", &database.Link{URL: "https://www.example.org/x"})
+	if err := database.SetDomainTenant(context.Background(), database.CreateClient(0), "go.team.example", "team"); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.SaveLink(context.Background(), database.CreateClient(0), database.LinkKey("team", "team-code"), &database.Link{URL: "https://team.example/"}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		short    string
+		proto    string
+		title    string
+		dest     string
+		provider string
+		url      string
+	}{
+		{"note as title", "http://localhost:3000/noted", "", "Q1 landing page", "https://example.com/landing", "localhost:3000", "http://localhost:3000"},
+		{"host as title", "localhost:3000/plain", "", "www.example.org", "https://www.example.org/x", "localhost:3000", "http://localhost:3000"},
+		{"served over https", "http://localhost:3000/plain", "https", "www.example.org", "https://www.example.org/x", "localhost:3000", "https://localhost:3000"},
+		{"scheme not echoed", "javascript://localhost:3000/plain", "", "www.example.org", "https://www.example.org/x", "localhost:3000", "http://localhost:3000"},
+		{"host not echoed", "http://evil.example/plain", "", "www.example.org", "https://www.example.org/x", "localhost:3000", "http://localhost:3000"},
+		{"tenant domain", "https://GO.team.example:8443/team-code", "", "team.example", "https://team.example/", "go.team.example", "http://go.team.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := map[string]string{}
+			if tt.proto != "" {
+				header[fiber.HeaderXForwardedProto] = tt.proto
+			}
+			resp := sendHeaders(t, app, "GET", "/api/v1/oembed?url="+url.QueryEscape(tt.short), "", header)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+			}
+			var got oembed
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			want := oembed{Version: "1.0", Type: "link", Title: tt.title, URL: tt.dest, ProviderName: tt.provider, ProviderURL: tt.url}
+			if got != want {
+				t.Errorf("oEmbed = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestOEmbedRefuses(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "live", &database.Link{URL: "https://example.com/"})
+	seedLink(t, "hide", &database.Link{URL: "https://example.com/hide", HideDestination: true})
+	seedLink(t, "off", &database.Link{URL: "https://example.com/off", Disabled: true})
+	seedLink(t, "gone", &database.Link{URL: "https://example.com/gone", Expired: true})
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"no url", "", fiber.StatusBadRequest},
+		{"no code", "?url=" + url.QueryEscape("http://localhost:3000/"), fiber.StatusBadRequest},
+		{"xml", "?format=xml&url=" + url.QueryEscape("http://localhost:3000/live"), fiber.StatusNotImplemented},
+		{"unknown", "?url=" + url.QueryEscape("http://localhost:3000/none"), fiber.StatusNotFound},
+		{"hidden", "?url=" + url.QueryEscape("http://localhost:3000/hide"), fiber.StatusForbidden},
+		{"disabled", "?url=" + url.QueryEscape("http://localhost:3000/off"), fiber.StatusForbidden},
+		{"expired", "?url=" + url.QueryEscape("http://localhost:3000/gone"), fiber.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := send(t, app, "GET", "/api/v1/oembed"+tt.query, "", "")
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if body := decode(t, resp); body["url"] != nil {
+				t.Errorf("refused oEmbed leaked destination %v", body["url"])
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package routes
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// page is the envelope list endpoints send their results in. NextCursor is
+// passed back as ?cursor= for the next page; it's empty on the last page.
+type page struct {
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor"`
+	Count      int         `json:"count"`
+}
+
+// sendPage sends count items of data as one page of a list.
+func sendPage(c *fiber.Ctx, data interface{}, count int, next string) error {
+	return c.Status(fiber.StatusOK).JSON(page{Data: data, NextCursor: next, Count: count})
+}
+
+// pageParams reads a list request's ?cursor= and ?limit=, with limit
+// defaulting to and capped at max. Cursors are offsets into the list; ok is
+// false when either parameter is malformed.
+func pageParams(c *fiber.Ctx, max int) (offset, limit int, ok bool) {
+	limit = max
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return 0, 0, false
+		}
+		if n < max {
+			limit = n
+		}
+	}
+	if v := c.Query("cursor"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return offset, limit, true
+}
+
+// nextCursor is the cursor of the page after the one ending at offset next,
+// or "" when next is negative because there are no more.
+func nextCursor(next int) string {
+	if next < 0 {
+		return ""
+	}
+	return strconv.Itoa(next)
+}
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"net/url"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// PeekURL reveals where a short link goes without redirecting or counting a
+// click, unless the link was created with hide_destination or wouldn't
+// resolve because it's disabled or expired.
+func PeekURL(c *fiber.Ctx) error {
+	id := shortCode(c)
+	r := database.CreateClientContext(c.UserContext(), 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	link, err := database.GetLink(c.UserContext(), r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	if link.Disabled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "this link has been disabled", "code": "link_disabled"})
+	}
+	if link.Expired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "short link has expired", "code": "link_expired"})
+	}
+	if link.HideDestination {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "the destination of this link is hidden"})
+	}
+
+	host := ""
+	if u, err := url.Parse(link.URL); err == nil {
+		host = u.Hostname()
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"destination": link.URL, "host": host})
+}
@@ -0,0 +1,62 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestPeekGating(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "pk-live", &database.Link{URL: "https://example.com/live"})
+	seedLink(t, "pk-hide", &database.Link{URL: "https://example.com/hide", HideDestination: true})
+	seedLink(t, "pk-off", &database.Link{URL: "https://example.com/off", Disabled: true})
+	seedLink(t, "pk-gone", &database.Link{URL: "https://example.com/gone", Expired: true})
+
+	tests := []struct {
+		code    string
+		status  int
+		errCode string
+	}{
+		{"pk-live", fiber.StatusOK, ""},
+		{"pk-hide", fiber.StatusForbidden, ""},
+		{"pk-off", fiber.StatusForbidden, "link_disabled"},
+		{"pk-gone", fiber.StatusGone, "link_expired"},
+		{"pk-none", fiber.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			resp := send(t, app, "GET", "/api/v1/peek/"+tt.code, "", "")
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			body := decode(t, resp)
+			if tt.status == fiber.StatusOK {
+				if body["destination"] != "https://example.com/live" || body["host"] != "example.com" {
+					t.Errorf("peek = %v", body)
+				}
+				return
+			}
+			if body["destination"] != nil {
+				t.Errorf("refused peek leaked destination %v", body["destination"])
+			}
+			if tt.errCode != "" && body["code"] != tt.errCode {
+				t.Errorf("code = %v, want %s", body["code"], tt.errCode)
+			}
+		})
+	}
+}
+
+func TestPeekCountsNoClick(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "pk-once", &database.Link{URL: "https://example.com/once", MaxClicks: 1})
+	for i := 0; i < 3; i++ {
+		if resp := send(t, app, "GET", "/api/v1/peek/pk-once", "", ""); resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("peek %d: status = %d, want %d", i+1, resp.StatusCode, fiber.StatusOK)
+		}
+	}
+	if resp := send(t, app, "GET", "/pk-once", "", ""); resp.StatusCode != fiber.StatusFound {
+		t.Errorf("visit after peeks: status = %d, want %d", resp.StatusCode, fiber.StatusFound)
+	}
+}
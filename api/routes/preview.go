@@ -0,0 +1,96 @@
+package routes
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// previewNonceTTL bounds how long a visitor can sit on the interstitial before
+// the continue link stops working.
+const previewNonceTTL = 10 * time.Minute
+
+func previewNonceKey(nonce string) string {
+	return "preview:" + nonce
+}
+
+// PreviewURL shows where a short link goes and hands out a single-use nonce
+// for the continue step. The nonce is bound to this short code only. Like
+// peek, it refuses hide_destination links, and it refuses links that
+// wouldn't resolve because they're disabled or expired.
+func PreviewURL(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if link.Disabled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "this link has been disabled", "code": "link_disabled"})
+	}
+	if link.Expired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "short link has expired", "code": "link_expired"})
+	}
+	if link.HideDestination {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "the destination of this link is hidden"})
+	}
+
+	rNonce := database.CreateClientContext(ctx, 1)
+
+	nonce := uuid.New().String()
+	if err := rNonce.Set(ctx, previewNonceKey(nonce), key, previewNonceTTL).Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"short":       id,
+		"destination": link.URL,
+		"continue":    "/api/v1/preview/" + id + "/continue?nonce=" + nonce,
+	})
+}
+
+// ContinuePreview redirects a visitor past the interstitial. The destination
+// is always looked up from storage by short code; nothing the client sends
+// other than the nonce is trusted, and the nonce must have been issued for
+// this exact code. Past the nonce it's a visit like any other, resolved as
+// ResolveURL would, so a preview can't skip click caps, referrer and rate
+// limits, or click counting.
+func ContinuePreview(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	nonce := c.Query("nonce")
+	if nonce == "" {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid or expired preview nonce"})
+	}
+
+	rNonce := database.CreateClientContext(ctx, 1)
+
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	// nonces are single use
+	boundKey, err := rNonce.GetDel(ctx, previewNonceKey(nonce)).Result()
+	if err == redis.Nil || (err == nil && boundKey != key) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid or expired preview nonce"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	return resolveCode(c, id, false)
+}
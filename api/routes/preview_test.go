@@ -0,0 +1,105 @@
+package routes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestPreviewGating(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "pv-live", &database.Link{URL: "https://example.com/live"})
+	seedLink(t, "pv-hide", &database.Link{URL: "https://example.com/hide", HideDestination: true})
+	seedLink(t, "pv-off", &database.Link{URL: "https://example.com/off", Disabled: true})
+	seedLink(t, "pv-gone", &database.Link{URL: "https://example.com/gone", Expired: true})
+
+	tests := []struct {
+		code   string
+		status int
+	}{
+		{"pv-live", fiber.StatusOK},
+		{"pv-hide", fiber.StatusForbidden},
+		{"pv-off", fiber.StatusForbidden},
+		{"pv-gone", fiber.StatusGone},
+		{"pv-none", fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			resp := send(t, app, "GET", "/api/v1/preview/"+tt.code, "", "")
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			var body map[string]string
+			json.NewDecoder(resp.Body).Decode(&body)
+			if tt.status != fiber.StatusOK && body["destination"] != "" {
+				t.Errorf("refused preview leaked destination %q", body["destination"])
+			}
+		})
+	}
+}
+
+func TestContinuePreviewResolves(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "pv-once", &database.Link{URL: "https://example.com/once", MaxClicks: 1})
+	seedLink(t, "pv-refs", &database.Link{URL: "https://example.com/refs", AllowedReferrers: []string{"example.org"}})
+
+	// previews handed out before the last click can't be used past it
+	first, second := previewContinue(t, app, "pv-once"), previewContinue(t, app, "pv-once")
+	if resp := send(t, app, "GET", first, "", ""); resp.StatusCode != fiber.StatusFound {
+		t.Errorf("first continue: status = %d, want %d", resp.StatusCode, fiber.StatusFound)
+	}
+	if resp := send(t, app, "GET", second, "", ""); resp.StatusCode != fiber.StatusGone {
+		t.Errorf("continue past max_clicks: status = %d, want %d", resp.StatusCode, fiber.StatusGone)
+	}
+
+	cont := previewContinue(t, app, "pv-refs")
+	if resp := send(t, app, "GET", cont, "", "https://evil.example.net/"); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("continue from a refused referrer: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	// the nonce was used up by the refused attempt
+	if resp := send(t, app, "GET", cont, "", "https://example.org/"); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("reused nonce: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	if resp := send(t, app, "GET", "/api/v1/preview/pv-refs/continue?nonce=made-up", "", ""); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("made-up nonce: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+}
+
+func TestContinuePreviewBoundToCode(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "pv-one", &database.Link{URL: "https://example.com/one"})
+	seedLink(t, "pv-two", &database.Link{URL: "https://example.com/two"})
+
+	cont := previewContinue(t, app, "pv-one")
+	nonce := cont[strings.Index(cont, "?"):]
+	if resp := send(t, app, "GET", "/api/v1/preview/pv-two/continue"+nonce, "", ""); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("nonce used on another code: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	// rejected nonces are used up too
+	if resp := send(t, app, "GET", cont, "", ""); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("nonce after a rejected use: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	if resp := send(t, app, "GET", "/api/v1/preview/pv-one/continue", "", ""); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("no nonce: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+
+	cont = previewContinue(t, app, "pv-one")
+	resp := send(t, app, "GET", cont, "", "")
+	if resp.StatusCode != fiber.StatusFound || resp.Header.Get(fiber.HeaderLocation) != "https://example.com/one" {
+		t.Errorf("continue: %d to %q, want %d to https://example.com/one", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation), fiber.StatusFound)
+	}
+}
+
+// previewContinue previews code and returns its continue link.
+func previewContinue(t *testing.T, app *fiber.App, code string) string {
+	t.Helper()
+	resp := send(t, app, "GET", "/api/v1/preview/"+code, "", "")
+	var body map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body["continue"] == "" {
+		t.Fatalf("preview %s: status %d, no continue link", code, resp.StatusCode)
+	}
+	return body["continue"]
+}
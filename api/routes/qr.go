@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"image/png"
+	"log"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+const (
+	// maxQRBatch bounds the codes one bulk QR request renders.
+	maxQRBatch = 100
+	// qrScale is how many pixels square each QR module is drawn.
+	qrScale = 8
+)
+
+// qrFile is a QR code in a bulk QR archive.
+type qrFile struct {
+	Short    string `json:"short"`
+	ShortURL string `json:"short_url"`
+	File     string `json:"file"`
+}
+
+// qrSkipped is a code a bulk QR archive has no QR code for, with the error
+// code resolving it would give.
+type qrSkipped struct {
+	Short string `json:"short"`
+	Code  string `json:"code"`
+}
+
+// qrEntry is a QR code waiting to be written to the archive.
+type qrEntry struct {
+	file qrFile
+	qr   *helpers.QRCode
+}
+
+// BulkQR answers POST /api/v1/qr/bulk {"shorts": [...]} with a ZIP archive of
+// one PNG QR code per live link, encoding its short URL, and a manifest.json
+// listing the files and the codes left out. The links are looked up first,
+// then the archive is streamed as each PNG is encoded rather than built in
+// memory. Each request spends one from the caller's shorten quota, whatever
+// it holds.
+func BulkQR(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	body := struct {
+		Shorts []string `json:"shorts"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	if len(body.Shorts) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "shorts is required"})
+	}
+	if len(body.Shorts) > maxQRBatch {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("at most %d QR codes can be made at once", maxQRBatch)})
+	}
+
+	rStats := database.CreateClientContext(ctx, 1)
+	counter, tracked, ok, err := checkQuota(c, rStats)
+	if !ok {
+		return err
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	tenant, err := database.TenantForHost(ctx, r, c.Hostname())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	domain := helpers.ShortDomain(c.Hostname())
+	if tenant != "" {
+		domain = c.Hostname()
+	}
+
+	var entries []qrEntry
+	manifest := struct {
+		Files   []qrFile    `json:"files"`
+		Skipped []qrSkipped `json:"skipped"`
+	}{Files: []qrFile{}, Skipped: []qrSkipped{}}
+	seen := map[string]bool{}
+	for _, short := range body.Shorts {
+		id := helpers.NormalizeCode(short)
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if !helpers.WellFormedCode(id) {
+			manifest.Skipped = append(manifest.Skipped, qrSkipped{Short: short, Code: "invalid_code"})
+			continue
+		}
+
+		var link *database.Link
+		err = redis.Nil
+		if helpers.VerifyCode(id) {
+			link, err = database.GetLink(ctx, r, database.LinkKey(tenant, id))
+		}
+		if err == redis.Nil {
+			manifest.Skipped = append(manifest.Skipped, qrSkipped{Short: short, Code: "link_not_found"})
+			continue
+		} else if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		switch {
+		case link.Disabled:
+			manifest.Skipped = append(manifest.Skipped, qrSkipped{Short: short, Code: "link_disabled"})
+			continue
+		case link.Expired:
+			manifest.Skipped = append(manifest.Skipped, qrSkipped{Short: short, Code: "link_expired"})
+			continue
+		}
+
+		display := displayCode(id, link)
+		_, shortURL := helpers.BuildShortURL(c.Protocol(), domain, display)
+		qr, err := helpers.EncodeQR(shortURL)
+		if err != nil {
+			manifest.Skipped = append(manifest.Skipped, qrSkipped{Short: short, Code: "too_long"})
+			continue
+		}
+		file := qrFile{Short: display, ShortURL: shortURL, File: display + ".png"}
+		entries = append(entries, qrEntry{file: file, qr: qr})
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	if tracked {
+		rStats.Decr(ctx, counter)
+	}
+
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition, `attachment; filename="qr-codes.zip"`)
+	c.Status(fiber.StatusOK).Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		zw := zip.NewWriter(w)
+		if err := writeQRArchive(zw, manifest, entries); err != nil {
+			// the status is already sent; the client sees a truncated archive
+			log.Printf("bulk qr: %v", err)
+			return
+		}
+		if err := zw.Close(); err != nil {
+			log.Printf("bulk qr: %v", err)
+		}
+	})
+	return nil
+}
+
+// writeQRArchive writes the manifest, then each entry's PNG, to zw.
+func writeQRArchive(zw *zip.Writer, manifest interface{}, entries []qrEntry) error {
+	f, err := zw.Create("manifest.json")
+	if err != nil {
+		return err
+	}
+	b, err := helpers.MarshalJSON(manifest)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		// PNGs are compressed already
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: e.file.File, Method: zip.Store})
+		if err != nil {
+			return err
+		}
+		if err := png.Encode(f, e.qr.Image(qrScale)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
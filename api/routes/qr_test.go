@@ -0,0 +1,117 @@
+package routes
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"io"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestBulkQR(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "qr-live", &database.Link{URL: "https://example.com/live"})
+	seedLink(t, "qr-other", &database.Link{URL: "https://example.com/other"})
+	seedLink(t, "qr-off", &database.Link{URL: "https://example.com/off", Disabled: true})
+	seedLink(t, "qr-gone", &database.Link{URL: "https://example.com/gone", Expired: true})
+
+	saveKey(t, "key-read", &database.APIKey{Scopes: []string{database.ScopeRead}})
+	resp := sendHeaders(t, app, "POST", "/api/v1/qr/bulk", `{"shorts":["qr-live","qr-none","qr-off","qr-gone","bad code","qr-other","qr-live"]}`, map[string]string{"X-API-Key": "key-read"})
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("not a ZIP archive: %v", err)
+	}
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	wantNames := []string{"manifest.json", "qr-live.png", "qr-other.png"}
+	if len(names) != len(wantNames) {
+		t.Fatalf("entries = %v, want %v", names, wantNames)
+	}
+	for i := range names {
+		if names[i] != wantNames[i] {
+			t.Fatalf("entries = %v, want %v", names, wantNames)
+		}
+	}
+
+	var manifest struct {
+		Files   []qrFile    `json:"files"`
+		Skipped []qrSkipped `json:"skipped"`
+	}
+	readEntry(t, zr.File[0], func(r io.Reader) error { return json.NewDecoder(r).Decode(&manifest) })
+	skipped := map[string]string{}
+	for _, s := range manifest.Skipped {
+		skipped[s.Short] = s.Code
+	}
+	for short, code := range map[string]string{
+		"qr-none":  "link_not_found",
+		"qr-off":   "link_disabled",
+		"qr-gone":  "link_expired",
+		"bad code": "invalid_code",
+	} {
+		if skipped[short] != code {
+			t.Errorf("%s skipped as %q, want %q", short, skipped[short], code)
+		}
+	}
+	if len(manifest.Files) != 2 || manifest.Files[0].File != "qr-live.png" {
+		t.Errorf("manifest files = %+v", manifest.Files)
+	}
+
+	for _, f := range zr.File[1:] {
+		readEntry(t, f, func(r io.Reader) error {
+			_, err := png.Decode(r)
+			return err
+		})
+	}
+}
+
+func TestBulkQRRejects(t *testing.T) {
+	many, _ := json.Marshal(map[string][]string{"shorts": make([]string, maxQRBatch+1)})
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no shorts", `{"shorts":[]}`},
+		{"too many", string(many)},
+		{"not JSON", `{`},
+	}
+	app := newTestApp()
+	if resp := send(t, app, "POST", "/api/v1/qr/bulk", `{"shorts":["qr-live"]}`, ""); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("without an API key: status = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+	saveKey(t, "key-read", &database.APIKey{Scopes: []string{database.ScopeRead}})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := sendHeaders(t, app, "POST", "/api/v1/qr/bulk", tt.body, map[string]string{"X-API-Key": "key-read"}); resp.StatusCode != fiber.StatusBadRequest {
+				t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusBadRequest)
+			}
+		})
+	}
+}
+
+// readEntry opens the archive entry f and hands it to read, failing t on
+// any error.
+func readEntry(t *testing.T, f *zip.File, read func(io.Reader) error) {
+	t.Helper()
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatalf("%s: %v", f.Name, err)
+	}
+	defer rc.Close()
+	if err := read(rc); err != nil {
+		t.Fatalf("%s: %v", f.Name, err)
+	}
+}
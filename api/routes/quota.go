@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/middleware"
+)
+
+// quotaCounter is the DB 1 key the request's shorten quota is counted under and
+// the quota a new window starts with. Requests made with an API key that has
+// its own rate_limit share that key's quota wherever they come from; everyone
+// else is counted per IP against API_QUOTA. unlimited is true for keys that
+// aren't limited at all.
+func quotaCounter(c *fiber.Ctx) (counter string, quota int, unlimited bool) {
+	if key := middleware.APIKey(c); key != nil && key.RateLimit != 0 {
+		if key.RateLimit == database.Unlimited {
+			return "", 0, true
+		}
+		return "quota:apikey:" + key.ID, key.RateLimit, false
+	}
+	quota, _ = strconv.Atoi(os.Getenv("API_QUOTA"))
+	return c.IP(), quota, false
+}
+
+// checkQuota starts a window of shorten quota for the caller if there's none,
+// and refuses the request with 503 if it has none left, or 500 if the quota
+// couldn't be checked. ok is false once it has refused, with err from sending
+// the response. counter is the key to Decr once the request has done its
+// work, if tracked. tracked is false for unlimited API keys, and when the
+// quota couldn't be read or created and RATE_LIMIT_FAIL_OPEN let the request
+// through. Nothing is decremented then: a key created by Decr would have no
+// TTL and never reset.
+func checkQuota(c *fiber.Ctx, rdb database.Store) (counter string, tracked, ok bool, err error) {
+	ctx := c.UserContext()
+	counter, quota, unlimited := quotaCounter(c)
+	if unlimited {
+		return counter, false, true, nil
+	}
+
+	value, err := rdb.Get(ctx, counter).Result()
+	if err == redis.Nil {
+		if err := rdb.Set(ctx, counter, quota, 30*time.Minute).Err(); err != nil {
+			if !rateLimitFailOpen(c, "starting quota", err) {
+				return counter, false, false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+			}
+			return counter, false, true, nil
+		}
+	} else if err != nil {
+		if !rateLimitFailOpen(c, "reading quota", err) {
+			return counter, false, false, c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		return counter, false, true, nil
+	} else if val, _ := strconv.Atoi(value); val <= 0 {
+		limit, _ := rdb.TTL(ctx, counter).Result()
+		return counter, false, false, c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "rate limit exceeded", "rate_limit_reset": limit / time.Nanosecond / time.Minute})
+	}
+	return counter, true, true, nil
+}
+
+// Quota reports the caller's remaining shorten quota and when it resets,
+// without spending any of it. Callers who haven't shortened anything yet get
+// the full quota; unlimited API keys get -1.
+func Quota(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	counter, quota, unlimited := quotaCounter(c)
+	if unlimited {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{"rate_limit": -1, "rate_limit_reset": 0})
+	}
+
+	r := database.CreateClientContext(ctx, 1)
+
+	value, err := r.Get(ctx, counter).Result()
+	if err == redis.Nil {
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"rate_limit": quota,
+			// a new window lasts 30 minutes, as in ShortenURL
+			"rate_limit_reset": 30,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	remaining, _ := strconv.Atoi(value)
+	reset, _ := r.TTL(ctx, counter).Result()
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"rate_limit":       remaining,
+		"rate_limit_reset": reset / time.Nanosecond / time.Minute,
+	})
+}
@@ -0,0 +1,189 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/metrics"
+)
+
+func TestQuota(t *testing.T) {
+	app := newTestApp()
+	stats := database.CreateClient(1)
+	quota := func(header map[string]string) (interface{}, interface{}) {
+		t.Helper()
+		resp := sendHeaders(t, app, "GET", "/api/v1/quota", "", header)
+		body := decode(t, resp)
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d (%v)", resp.StatusCode, body)
+		}
+		return body["rate_limit"], body["rate_limit_reset"]
+	}
+
+	// a new caller has the whole quota, and checking doesn't start a window
+	for i := 0; i < 3; i++ {
+		if left, reset := quota(nil); left != float64(1000) || reset != float64(30) {
+			t.Errorf("new caller: %v left, reset in %v; want 1000 and 30", left, reset)
+		}
+	}
+	if n, _ := stats.Exists(context.Background(), "0.0.0.0").Result(); n != 0 {
+		t.Error("checking the quota created a counter")
+	}
+
+	if resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/"}`, ""); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d", resp.StatusCode)
+	}
+	for i := 0; i < 3; i++ {
+		if left, _ := quota(nil); left != float64(999) {
+			t.Errorf("after one shorten: %v left, want 999", left)
+		}
+	}
+	if n, _ := stats.Get(context.Background(), "0.0.0.0").Int(); n != 999 {
+		t.Errorf("stored counter = %d, want 999", n)
+	}
+
+	saveKey(t, "key-limited", &database.APIKey{RateLimit: 5})
+	saveKey(t, "key-unlimited", &database.APIKey{RateLimit: database.Unlimited})
+	if left, _ := quota(map[string]string{"X-API-Key": "key-limited"}); left != float64(5) {
+		t.Errorf("API key with its own limit: %v left, want 5", left)
+	}
+	if left, reset := quota(map[string]string{"X-API-Key": "key-unlimited"}); left != float64(-1) || reset != float64(0) {
+		t.Errorf("unlimited API key: %v left, reset in %v; want -1 and 0", left, reset)
+	}
+}
+
+// failingStore is a stats store whose quota reads or writes fail.
+type failingStore struct {
+	database.Store
+	failGet, failSet bool
+}
+
+var errStoreDown = errors.New("connection refused")
+
+func (s failingStore) Get(ctx context.Context, key string) *redis.StringCmd {
+	if s.failGet {
+		return redis.NewStringResult("", errStoreDown)
+	}
+	return s.Store.Get(ctx, key)
+}
+
+func (s failingStore) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	if s.failSet {
+		return redis.NewStatusResult("", errStoreDown)
+	}
+	return s.Store.Set(ctx, key, value, expiration)
+}
+
+func TestCheckQuotaStoreFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		failOpen string
+		failGet  bool
+		failSet  bool
+		status   int
+		tracked  bool
+	}{
+		{"healthy", "", false, false, fiber.StatusOK, true},
+		{"Set fails, closed", "", false, true, fiber.StatusInternalServerError, false},
+		{"Set fails, open", "true", false, true, fiber.StatusOK, false},
+		{"Get fails, closed", "", true, false, fiber.StatusInternalServerError, false},
+		{"Get fails, open", "true", true, false, fiber.StatusOK, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RATE_LIMIT_FAIL_OPEN", tt.failOpen)
+			newTestApp()
+			store := failingStore{Store: database.CreateClient(1), failGet: tt.failGet, failSet: tt.failSet}
+			app := fiber.New()
+			app.Post("/", func(c *fiber.Ctx) error {
+				_, tracked, ok, err := checkQuota(c, store)
+				if !ok {
+					return err
+				}
+				return c.JSON(fiber.Map{"tracked": tracked})
+			})
+
+			failedOpen := metrics.RateLimitFailOpen.Value("")
+			resp := send(t, app, "POST", "/", "", "")
+			body := decode(t, resp)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d (%v), want %d", resp.StatusCode, body, tt.status)
+			}
+			// only errors let through are counted
+			want := uint64(0)
+			if tt.failOpen != "" && (tt.failGet || tt.failSet) {
+				want = 1
+			}
+			if got := metrics.RateLimitFailOpen.Value("") - failedOpen; got != want {
+				t.Errorf("rate_limit_fail_open_total moved by %d, want %d", got, want)
+			}
+			if tt.status == fiber.StatusOK && body["tracked"] != tt.tracked {
+				t.Errorf("tracked = %v, want %v", body["tracked"], tt.tracked)
+			}
+			// a failed Set leaves no untracked counter behind
+			if n, _ := database.CreateClient(1).Exists(context.Background(), "0.0.0.0").Result(); (n == 1) != (!tt.failGet && !tt.failSet) {
+				t.Errorf("quota counter exists = %v", n == 1)
+			}
+		})
+	}
+}
+
+func TestKeyRateLimits(t *testing.T) {
+	t.Setenv("API_QUOTA", "2")
+	admin := asAdmin(t)
+	app := newTestApp()
+	newKey := func(rateLimit string) map[string]string {
+		t.Helper()
+		resp := sendHeaders(t, app, "POST", "/api/v1/admin/keys", `{"scopes":["write"],"rate_limit":`+rateLimit+`}`, admin)
+		body := decode(t, resp)
+		if resp.StatusCode != fiber.StatusCreated || fmt.Sprint(body["rate_limit"]) != strings.Trim(rateLimit, `"`) {
+			t.Fatalf("create a key limited to %s: %d %v", rateLimit, resp.StatusCode, body)
+		}
+		return map[string]string{"X-API-Key": fmt.Sprint(body["key"])}
+	}
+	higher, unlimited := newKey("5"), newKey(`"unlimited"`)
+	shorten := func(header map[string]string) int {
+		t.Helper()
+		return sendHeaders(t, app, "POST", "/api/v1", `{"url":"https://example.com/"}`, header).StatusCode
+	}
+
+	// the key's own, higher limit applies instead of API_QUOTA
+	for i := 1; i <= 5; i++ {
+		if status := shorten(higher); status != fiber.StatusCreated {
+			t.Fatalf("shorten %d with a limit of 5: status = %d", i, status)
+		}
+	}
+	if status := shorten(higher); status != fiber.StatusServiceUnavailable {
+		t.Errorf("shorten 6 with a limit of 5: status = %d, want %d", status, fiber.StatusServiceUnavailable)
+	}
+	// and is counted apart from the caller's own IP
+	for i := 1; i <= 2; i++ {
+		if status := shorten(nil); status != fiber.StatusCreated {
+			t.Fatalf("anonymous shorten %d: status = %d", i, status)
+		}
+	}
+	if status := shorten(nil); status != fiber.StatusServiceUnavailable {
+		t.Errorf("anonymous shorten past API_QUOTA: status = %d, want %d", status, fiber.StatusServiceUnavailable)
+	}
+
+	// an unlimited key is never throttled, nor counted
+	for i := 1; i <= 10; i++ {
+		if status := shorten(unlimited); status != fiber.StatusCreated {
+			t.Fatalf("shorten %d with an unlimited key: status = %d", i, status)
+		}
+	}
+
+	for _, rateLimit := range []string{"0", "-1", `"lots"`, "1.5"} {
+		resp := sendHeaders(t, app, "POST", "/api/v1/admin/keys", `{"rate_limit":`+rateLimit+`}`, admin)
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("rate_limit %s: status = %d, want %d", rateLimit, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package routes
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// rateLimitIP returns the :ip parameter in the form c.IP() produces, which
+// the quota counter is keyed by.
+func rateLimitIP(c *fiber.Ctx) (string, bool) {
+	ip := net.ParseIP(c.Params("ip"))
+	if ip == nil {
+		return "", false
+	}
+	return ip.String(), true
+}
+
+// GetRateLimit reports a client's shorten quota counter for support staff.
+// Clients with no counter in the current window show the full API_QUOTA.
+func GetRateLimit(c *fiber.Ctx) error {
+	ip, ok := rateLimitIP(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid IP address"})
+	}
+
+	r := database.CreateClientContext(c.UserContext(), 1)
+	return sendRateLimit(c, r, ip)
+}
+
+// SetRateLimit sets a client's remaining shorten quota, keeping the current
+// window's reset time, or with no rate_limit in the body clears the counter
+// so the client starts a fresh window with the full API_QUOTA.
+func SetRateLimit(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	ip, ok := rateLimitIP(c)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid IP address"})
+	}
+	body := struct {
+		RateLimit *int `json:"rate_limit"`
+	}{}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&body); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+		}
+	}
+	if body.RateLimit != nil && *body.RateLimit < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "rate_limit cannot be negative"})
+	}
+
+	r := database.CreateClientContext(ctx, 1)
+
+	if body.RateLimit == nil {
+		if err := r.Del(ctx, ip).Err(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		return sendRateLimit(c, r, ip)
+	}
+
+	window, err := r.TTL(ctx, ip).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if window <= 0 {
+		// a new window lasts 30 minutes, as in ShortenURL
+		window = 30 * time.Minute
+	}
+	if err := r.Set(ctx, ip, *body.RateLimit, window).Err(); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return sendRateLimit(c, r, ip)
+}
+
+// sendRateLimit responds with ip's counter, in the shape Quota uses.
+func sendRateLimit(c *fiber.Ctx, r database.Store, ip string) error {
+	value, err := r.Get(c.UserContext(), ip).Result()
+	if err == redis.Nil {
+		quota, _ := strconv.Atoi(os.Getenv("API_QUOTA"))
+		return c.Status(fiber.StatusOK).JSON(fiber.Map{
+			"ip":               ip,
+			"rate_limit":       quota,
+			"rate_limit_reset": 30,
+		})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	remaining, _ := strconv.Atoi(value)
+	reset, _ := r.TTL(c.UserContext(), ip).Result()
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"ip":               ip,
+		"rate_limit":       remaining,
+		"rate_limit_reset": reset / time.Nanosecond / time.Minute,
+	})
+}
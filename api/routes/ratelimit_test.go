@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestAdminRateLimit(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	stats := database.CreateClient(1)
+	const path = "/api/v1/admin/ratelimit/0.0.0.0"
+	counter := func(method, body string) map[string]interface{} {
+		t.Helper()
+		resp := sendHeaders(t, app, method, path, body, admin)
+		got := decode(t, resp)
+		if resp.StatusCode != fiber.StatusOK || got["ip"] != "0.0.0.0" {
+			t.Fatalf("%s: %d %v, want %d for 0.0.0.0", method, resp.StatusCode, got, fiber.StatusOK)
+		}
+		return got
+	}
+	shorten := func() int {
+		t.Helper()
+		return send(t, app, "POST", "/api/v1", `{"url":"https://example.com/"}`, "").StatusCode
+	}
+
+	if got := counter("GET", ""); got["rate_limit"] != float64(1000) || got["rate_limit_reset"] != float64(30) {
+		t.Errorf("new client: %v, want the full quota", got)
+	}
+	if status := shorten(); status != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d", status)
+	}
+	if got := counter("GET", ""); got["rate_limit"] != float64(999) {
+		t.Errorf("after one shorten: rate_limit = %v, want 999", got["rate_limit"])
+	}
+
+	// topping down to one leaves a single shorten in this window
+	if got := counter("POST", `{"rate_limit":1}`); got["rate_limit"] != float64(1) {
+		t.Errorf("set: rate_limit = %v, want 1", got["rate_limit"])
+	}
+	if ttl, _ := stats.TTL(context.Background(), "0.0.0.0").Result(); ttl <= 0 {
+		t.Errorf("set: counter TTL = %v, want the current window kept", ttl)
+	}
+	if status := shorten(); status != fiber.StatusCreated {
+		t.Fatalf("shorten with one left: status = %d", status)
+	}
+	if status := shorten(); status != fiber.StatusServiceUnavailable {
+		t.Fatalf("shorten with none left: status = %d, want %d", status, fiber.StatusServiceUnavailable)
+	}
+
+	// resetting starts a fresh window
+	if got := counter("POST", ""); got["rate_limit"] != float64(1000) {
+		t.Errorf("reset: rate_limit = %v, want 1000", got["rate_limit"])
+	}
+	if n, _ := stats.Exists(context.Background(), "0.0.0.0").Result(); n != 0 {
+		t.Error("reset left the counter")
+	}
+	if status := shorten(); status != fiber.StatusCreated {
+		t.Errorf("shorten after the reset: status = %d, want %d", status, fiber.StatusCreated)
+	}
+
+	refused := []struct {
+		method, path, body string
+		header             map[string]string
+		status             int
+	}{
+		{"GET", path, "", nil, fiber.StatusUnauthorized},
+		{"POST", path, "", nil, fiber.StatusUnauthorized},
+		{"GET", "/api/v1/admin/ratelimit/not-an-ip", "", admin, fiber.StatusBadRequest},
+		{"POST", "/api/v1/admin/ratelimit/999.1.1.1", "", admin, fiber.StatusBadRequest},
+		{"POST", path, `{"rate_limit":-1}`, admin, fiber.StatusBadRequest},
+		{"POST", path, `{"rate_limit":"lots"}`, admin, fiber.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		if resp := sendHeaders(t, app, tt.method, tt.path, tt.body, tt.header); resp.StatusCode != tt.status {
+			t.Errorf("%s %s %s: status = %d, want %d", tt.method, tt.path, tt.body, resp.StatusCode, tt.status)
+		}
+	}
+	// IPv6 addresses are accepted in any spelling
+	resp := sendHeaders(t, app, "GET", "/api/v1/admin/ratelimit/2001:DB8:0::1", "", admin)
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusOK || body["ip"] != "2001:db8::1" {
+		t.Errorf("IPv6: %d %v, want %d for 2001:db8::1", resp.StatusCode, body, fiber.StatusOK)
+	}
+}
@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// maxReasonLen is the longest abuse report reason accepted, in characters.
+const maxReasonLen = 280
+
+// reporterWindow is how long one visitor's report of a link keeps them from
+// reporting it again, so nobody can push a link over the threshold alone.
+const reporterWindow = 30 * 24 * time.Hour
+
+// reportsListSize is how many links, and reasons per link, a page of the admin
+// list shows.
+const reportsListSize = 50
+
+// ReportLink files an abuse report against a short link. Each visitor counts
+// once per link, and visitors can file at most REPORT_LIMIT_PER_HOUR reports.
+// Once a link has REPORT_DISABLE_THRESHOLD reports it is disabled until an
+// admin re-enables it.
+func ReportLink(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	body := struct {
+		Reason string `json:"reason"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.Reason == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "reason is required"})
+	}
+	if utf8.RuneCountInString(body.Reason) > maxReasonLen {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("reason must be at most %d characters", maxReasonLen)})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	// reports on an alias are about the link behind it
+	if link.PrimaryKey != "" {
+		key = link.PrimaryKey
+	}
+
+	rReports := database.CreateClientContext(ctx, 1)
+
+	reporter := helpers.VisitorID(c.IP())
+	limitKey := "report_limit:" + reporter
+	filed, err := rReports.Incr(ctx, limitKey).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if filed == 1 {
+		rReports.Expire(ctx, limitKey, time.Hour)
+	}
+	if filed > int64(config.Int("REPORT_LIMIT_PER_HOUR", 10)) {
+		reset, _ := rReports.TTL(ctx, limitKey).Result()
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(reset/time.Second)))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "too many reports, try again later"})
+	}
+
+	reports, err := database.FileReport(ctx, rReports, key, reporter, body.Reason, reporterWindow)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if threshold := config.Int("REPORT_DISABLE_THRESHOLD", 0); threshold > 0 && reports >= int64(threshold) && !link.Disabled {
+		link.Disabled = true
+		if err := database.UpdateLink(ctx, r, key, link); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		recordEvent(ctx, r, key, eventDisabled, "reports")
+	}
+
+	// the same answer whether or not this visitor had reported it before
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{"short": id, "status": "reported"})
+}
+
+type reportInfo struct {
+	Short    string        `json:"short"`
+	Key      string        `json:"key"`
+	Reports  int64         `json:"reports"`
+	Reasons  []reasonCount `json:"reasons"`
+	Disabled bool          `json:"disabled"`
+	Exists   bool          `json:"exists"`
+}
+
+type reasonCount struct {
+	Reason string `json:"reason"`
+	Count  int64  `json:"count"`
+}
+
+// ListReports shows the most reported links and why they were reported, a
+// page at a time.
+func ListReports(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	offset, limit, ok := pageParams(c, reportsListSize)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid cursor or limit"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+	rReports := database.CreateClientContext(ctx, 1)
+
+	reports, err := database.TopReports(ctx, rReports, offset, limit, reportsListSize)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	total, err := database.CountReported(ctx, rReports)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	next := -1
+	if int64(offset+len(reports)) < total {
+		next = offset + len(reports)
+	}
+
+	out := make([]reportInfo, 0, len(reports))
+	for _, rep := range reports {
+		info := reportInfo{Short: database.LinkCode(rep.Key), Key: rep.Key, Reports: rep.Reports, Reasons: []reasonCount{}}
+		for _, z := range rep.Reasons {
+			info.Reasons = append(info.Reasons, reasonCount{Reason: z.Member.(string), Count: int64(z.Score)})
+		}
+		link, err := database.GetLink(ctx, r, rep.Key)
+		if err != nil && err != redis.Nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if err == nil {
+			info.Exists = true
+			info.Disabled = link.Disabled
+		}
+		out = append(out, info)
+	}
+	return sendPage(c, out, len(out), nextCursor(next))
+}
+
+// ClearReports dismisses the reports against a link once they've been
+// reviewed. It doesn't re-enable a disabled link.
+func ClearReports(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, shortCode(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	rReports := database.CreateClientContext(ctx, 1)
+
+	if err := database.ClearReports(ctx, rReports, key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
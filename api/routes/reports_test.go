@@ -0,0 +1,130 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// reportList is the admin list of reported links.
+type reportList struct {
+	Data []struct {
+		Short    string `json:"short"`
+		Reports  int64  `json:"reports"`
+		Disabled bool   `json:"disabled"`
+		Reasons  []struct {
+			Reason string `json:"reason"`
+			Count  int64  `json:"count"`
+		} `json:"reasons"`
+	} `json:"data"`
+}
+
+func TestReportLink(t *testing.T) {
+	t.Setenv("REPORT_DISABLE_THRESHOLD", "3")
+	admin := asAdmin(t)
+	app := newTestApp()
+	ctx := context.Background()
+	seedLink(t, "phish", &database.Link{URL: "https://example.com/login"})
+	list := func() reportList {
+		t.Helper()
+		var out reportList
+		resp := sendHeaders(t, app, "GET", "/api/v1/admin/reports", "", admin)
+		if err := json.NewDecoder(resp.Body).Decode(&out); err != nil || resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("list: status = %d, %v", resp.StatusCode, err)
+		}
+		return out
+	}
+
+	// every request here comes from one address, so the other visitors'
+	// reports are filed directly
+	rReports := database.CreateClient(1)
+	for _, reporter := range []string{"visitor-a", "visitor-b"} {
+		if _, err := database.FileReport(ctx, rReports, database.LinkKey("", "phish"), reporter, "phishing", time.Hour); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := list(); len(got.Data) != 1 || got.Data[0].Reports != 2 || got.Data[0].Disabled {
+		t.Fatalf("list = %+v, want phish with 2 reports, enabled", got)
+	}
+	if resp := send(t, app, "GET", "/phish", "", ""); resp.StatusCode != fiber.StatusFound {
+		t.Errorf("under the threshold: status = %d, want %d", resp.StatusCode, fiber.StatusFound)
+	}
+
+	resp := send(t, app, "POST", "/api/v1/report/phish", `{"reason":"  malware  "}`, "")
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusAccepted || body["status"] != "reported" {
+		t.Fatalf("report: %d %v", resp.StatusCode, body)
+	}
+	got := list()
+	if len(got.Data) != 1 || got.Data[0].Short != "phish" || got.Data[0].Reports != 3 || !got.Data[0].Disabled {
+		t.Fatalf("list = %+v, want phish with 3 reports, disabled", got)
+	}
+	if reasons := got.Data[0].Reasons; len(reasons) != 2 || reasons[0].Reason != "phishing" || reasons[0].Count != 2 || reasons[1].Reason != "malware" {
+		t.Errorf("reasons = %+v, want phishing twice then malware", reasons)
+	}
+	if body := decode(t, send(t, app, "GET", "/phish", "", "")); body["code"] != "link_disabled" {
+		t.Errorf("at the threshold: %v, want link_disabled", body)
+	}
+
+	// a visitor counts once, though they can't tell
+	resp = send(t, app, "POST", "/api/v1/report/phish", `{"reason":"malware"}`, "")
+	if resp.StatusCode != fiber.StatusAccepted {
+		t.Errorf("report again: status = %d, want %d", resp.StatusCode, fiber.StatusAccepted)
+	}
+	if got := list(); got.Data[0].Reports != 3 {
+		t.Errorf("after reporting again: %d reports, want 3", got.Data[0].Reports)
+	}
+
+	// dismissing the reports leaves the link disabled
+	if resp := sendHeaders(t, app, "DELETE", "/api/v1/admin/reports/phish", "", admin); resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("clear: status = %d", resp.StatusCode)
+	}
+	if got := list(); len(got.Data) != 0 {
+		t.Errorf("after clearing: %+v, want no reports", got)
+	}
+	if resp := send(t, app, "GET", "/phish", "", ""); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("after clearing: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	if resp := send(t, app, "GET", "/api/v1/admin/reports", "", ""); resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("list without the admin key: status = %d, want %d", resp.StatusCode, fiber.StatusUnauthorized)
+	}
+}
+
+func TestReportLinkLimits(t *testing.T) {
+	t.Setenv("REPORT_LIMIT_PER_HOUR", "2")
+	app := newTestApp()
+	for i := 1; i <= 3; i++ {
+		seedLink(t, fmt.Sprintf("link-%d", i), &database.Link{URL: "https://example.com/"})
+	}
+
+	tests := []struct {
+		name   string
+		short  string
+		body   string
+		status int
+	}{
+		{"no reason", "link-1", `{"reason":"  "}`, fiber.StatusBadRequest},
+		{"long reason", "link-1", fmt.Sprintf(`{"reason":%q}`, strings.Repeat("é", 281)), fiber.StatusBadRequest},
+		{"missing link", "nope", `{"reason":"spam"}`, fiber.StatusNotFound},
+		{"first", "link-1", `{"reason":"spam"}`, fiber.StatusAccepted},
+		{"second", "link-2", `{"reason":"spam"}`, fiber.StatusAccepted},
+		{"over the limit", "link-3", `{"reason":"spam"}`, fiber.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		resp := send(t, app, "POST", "/api/v1/report/"+tt.short, tt.body, "")
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+		if tt.status == fiber.StatusTooManyRequests {
+			if wait, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter)); err != nil || wait < 1 || wait > 3600 {
+				t.Errorf("%s: Retry-After = %q, want up to an hour", tt.name, resp.Header.Get(fiber.HeaderRetryAfter))
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package routes
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// reservationKey holds the hashed token of whoever reserved link key.
+func reservationKey(key string) string {
+	return "reserve:" + key
+}
+
+// ReserveShort puts a short-lived hold on a custom short so it can't be taken
+// between an availability check and the shorten request. The hold lasts
+// RESERVATION_TTL (default 2m) and only a shorten carrying the returned
+// reservation_token can claim the code while it's held.
+func ReserveShort(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	short := shortCode(c)
+	id := helpers.Namespaced(namespace(c), short)
+	if err := helpers.ValidateCustomShort(id); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if helpers.IsPremiumShort(short) && !canClaimPremium(c) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "custom short is reserved for premium use", "code": "custom_short_premium"})
+	}
+
+	// reserve the code as it will be stored
+	canonical := helpers.CanonicalCode(id)
+	signed := helpers.SignCode(canonical)
+	display := id + signed[len(canonical):]
+
+	r := database.CreateClientContext(ctx, 0)
+
+	taken, err := intoNamespace(c, r, short)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if taken {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "custom short is in another namespace", "code": "custom_short_namespace"})
+	}
+
+	key, err := tenantKey(c, r, signed)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	exists, err := r.Exists(ctx, key).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if exists > 0 {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "URL custom short is already in use", "code": "custom_short_taken"})
+	}
+	reclaimable, err := checkReclaim(ctx, r, key)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !reclaimable {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "URL custom short expired recently and can't be reused yet", "code": "custom_short_expired"})
+	}
+
+	token := uuid.New().String()
+	ttl := config.Duration("RESERVATION_TTL", 2*time.Minute)
+	held, err := r.SetNX(ctx, reservationKey(key), helpers.HashToken(token), ttl).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !held {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": "URL custom short is reserved", "code": "custom_short_reserved"})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"short":             display,
+		"reservation_token": token,
+		"expires_at":        time.Now().Add(ttl).UTC(),
+	})
+}
+
+// checkReservation reports whether token may claim link key: either nobody
+// holds it, or token is the one the hold was made with.
+func checkReservation(ctx context.Context, r database.Store, key, token string) (bool, error) {
+	hash, err := r.Get(ctx, reservationKey(key)).Result()
+	if err == redis.Nil {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	return token != "" && helpers.TokenMatches(token, hash), nil
+}
+
+// checkReclaim reports whether a code whose link expired may be given to a
+// new link at key. Codes come free the moment their link expires unless
+// RECLAIM_EXPIRED_CODES=false, which holds them until their tombstone lapses,
+// TOMBSTONE_TTL after the expiry, so old printed or shared links don't start
+// leading somewhere else right away.
+func checkReclaim(ctx context.Context, r database.Store, key string) (bool, error) {
+	if config.Bool("RECLAIM_EXPIRED_CODES", true) {
+		return true, nil
+	}
+	expired, err := database.Expired(ctx, r, key)
+	return !expired, err
+}
+
+// resetReclaimedStats clears the analytics left behind at key by an earlier
+// link with the same code, unless RECLAIM_ANALYTICS=carry keeps them for the
+// new one. Stats outlive their link with ANALYTICS_RETENTION or
+// PURGE_ANALYTICS=never.
+func resetReclaimedStats(ctx context.Context, r, rStats database.Store, key string) error {
+	if config.String("RECLAIM_ANALYTICS", "reset") == "carry" {
+		return nil
+	}
+	// only look for buckets when the code was used before
+	expired, err := database.Expired(ctx, r, key)
+	if err != nil {
+		return err
+	}
+	if !expired {
+		n, err := rStats.Exists(ctx, database.ClicksKey(key), database.VisitorsKey(key)).Result()
+		if err != nil || n == 0 {
+			return err
+		}
+	}
+	return database.DeleteClicks(ctx, rStats, key)
+}
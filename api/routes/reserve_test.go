@@ -0,0 +1,198 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestReserveCaseInsensitiveNamespace(t *testing.T) {
+	t.Setenv("CASE_INSENSITIVE_SHORTS", "true")
+	t.Setenv("DOMAIN", "localhost:3000")
+	app := newTestApp()
+	saveKey(t, "key-team", &database.APIKey{Namespace: "Team"})
+	header := map[string]string{"X-API-Key": "key-team"}
+
+	resp := sendHeaders(t, app, "POST", "/api/v1/reserve/Promo", "", header)
+	body := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("reserve: status = %d (%v), want %d", resp.StatusCode, body, fiber.StatusCreated)
+	}
+	if body["short"] != "Team/promo" {
+		t.Errorf("reserve: short = %v, want Team/promo", body["short"])
+	}
+
+	shorten := `{"url":"https://example.com/","short":"PROMO"%s}`
+	resp = sendHeaders(t, app, "POST", "/api/v1", fmt.Sprintf(shorten, ""), header)
+	if got := decode(t, resp); resp.StatusCode != fiber.StatusConflict || got["code"] != "custom_short_reserved" {
+		t.Errorf("shorten without the token: %d %v, want %d custom_short_reserved", resp.StatusCode, got["code"], fiber.StatusConflict)
+	}
+	resp = sendHeaders(t, app, "POST", "/api/v1", fmt.Sprintf(shorten, fmt.Sprintf(`,"reservation_token":%q`, body["reservation_token"])), header)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Errorf("shorten with the token: status = %d (%v), want %d", resp.StatusCode, decode(t, resp), fiber.StatusCreated)
+	}
+}
+
+func TestReserveThenClaim(t *testing.T) {
+	app := newTestApp()
+	reserve := func(short string) (int, map[string]interface{}) {
+		t.Helper()
+		resp := send(t, app, "POST", "/api/v1/reserve/"+short, "", "")
+		return resp.StatusCode, decode(t, resp)
+	}
+	claim := func(short, token string) (int, interface{}) {
+		t.Helper()
+		resp := send(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":"https://example.com/","short":%q,"reservation_token":%q}`, short, token), "")
+		return resp.StatusCode, decode(t, resp)["code"]
+	}
+
+	status, held := reserve("launch")
+	if status != fiber.StatusCreated || held["short"] != "launch" || held["reservation_token"] == "" {
+		t.Fatalf("reserve: %d %v", status, held)
+	}
+	token := fmt.Sprint(held["reservation_token"])
+	if at, err := time.Parse(time.RFC3339, fmt.Sprint(held["expires_at"])); err != nil || time.Until(at) > 2*time.Minute || time.Until(at) < time.Minute {
+		t.Errorf("expires_at = %v, want about 2 minutes from now", held["expires_at"])
+	}
+	if status, body := reserve("launch"); status != fiber.StatusConflict || body["code"] != "custom_short_reserved" {
+		t.Errorf("reserve again: %d %v, want %d custom_short_reserved", status, body["code"], fiber.StatusConflict)
+	}
+
+	_, other := reserve("other")
+	for _, wrong := range []string{"", "not-a-token", fmt.Sprint(other["reservation_token"])} {
+		if status, code := claim("launch", wrong); status != fiber.StatusConflict || code != "custom_short_reserved" {
+			t.Errorf("claim with token %q: %d %v, want %d custom_short_reserved", wrong, status, code, fiber.StatusConflict)
+		}
+	}
+	if status, code := claim("launch", token); status != fiber.StatusCreated || code != "launch" {
+		t.Fatalf("claim with the token: %d %v, want %d", status, code, fiber.StatusCreated)
+	}
+	if n, _ := database.CreateClient(0).Exists(context.Background(), reservationKey(database.LinkKey("", "launch"))).Result(); n != 0 {
+		t.Error("the hold outlived the claim")
+	}
+	if status, code := claim("launch", token); status != fiber.StatusConflict || code != "custom_short_taken" {
+		t.Errorf("claim twice: %d %v, want %d custom_short_taken", status, code, fiber.StatusConflict)
+	}
+	if status, body := reserve("launch"); status != fiber.StatusConflict || body["code"] != "custom_short_taken" {
+		t.Errorf("reserve a claimed code: %d %v, want %d custom_short_taken", status, body["code"], fiber.StatusConflict)
+	}
+
+	// once a hold lapses, anyone can have the code
+	database.CreateClient(0).Expire(context.Background(), reservationKey(database.LinkKey("", "other")), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if status, code := claim("other", ""); status != fiber.StatusCreated {
+		t.Errorf("claim after the hold lapsed: %d %v, want %d", status, code, fiber.StatusCreated)
+	}
+}
+
+func TestReserveSignedCode(t *testing.T) {
+	t.Setenv("CODE_SIGNING_KEY", "secret")
+	app := newTestApp()
+	signed := helpers.SignCode("launch")
+
+	resp := send(t, app, "POST", "/api/v1/reserve/launch", "", "")
+	held := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated || held["short"] != signed {
+		t.Fatalf("reserve: %d %v, want the short %s", resp.StatusCode, held, signed)
+	}
+	// the reservation holds the code as it will be stored
+	shorten := `{"url":"https://example.com/","short":"launch"%s}`
+	resp = send(t, app, "POST", "/api/v1", fmt.Sprintf(shorten, ""), "")
+	if got := decode(t, resp); resp.StatusCode != fiber.StatusConflict || got["code"] != "custom_short_reserved" {
+		t.Errorf("shorten without the token: %d %v, want %d custom_short_reserved", resp.StatusCode, got["code"], fiber.StatusConflict)
+	}
+	resp = send(t, app, "POST", "/api/v1", fmt.Sprintf(shorten, fmt.Sprintf(`,"reservation_token":%q`, held["reservation_token"])), "")
+	if got := decode(t, resp); resp.StatusCode != fiber.StatusCreated || got["code"] != signed {
+		t.Errorf("shorten with the token: %d %v, want %d %s", resp.StatusCode, got["code"], fiber.StatusCreated, signed)
+	}
+}
+
+// expireAndLapse creates the link code with a short expiry, clicks it twice
+// and then drops it as its TTL running out would, leaving its tombstone and,
+// with ANALYTICS_RETENTION, its analytics.
+func expireAndLapse(t *testing.T, app *fiber.App, code string) {
+	t.Helper()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/old","short":"`+code+`","expiry":"1h"}`, "")
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten %s: status = %d (%v)", code, resp.StatusCode, decode(t, resp))
+	}
+	for i := 0; i < 2; i++ {
+		sendHeaders(t, app, "GET", "/"+code, "", map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"})
+	}
+	if err := database.CreateClient(0).Del(context.Background(), database.LinkKey("", code)).Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReclaimExpiredCodes(t *testing.T) {
+	t.Setenv("ANALYTICS_RETENTION", "24h")
+	reuse := `{"url":"https://example.com/new","short":"reused"}`
+	tests := []struct {
+		name      string
+		reclaim   string
+		analytics string
+		status    int
+		clicks    float64
+	}{
+		{"defaults", "", "", fiber.StatusCreated, 0},
+		{"reset", "true", "reset", fiber.StatusCreated, 0},
+		{"carry", "true", "carry", fiber.StatusCreated, 2},
+		{"held", "false", "", fiber.StatusConflict, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("RECLAIM_EXPIRED_CODES", tt.reclaim)
+			t.Setenv("RECLAIM_ANALYTICS", tt.analytics)
+			app := newTestApp()
+			expireAndLapse(t, app, "reused")
+
+			resp := send(t, app, "POST", "/api/v1", reuse, "")
+			body := decode(t, resp)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("reuse: %d %v, want %d", resp.StatusCode, body, tt.status)
+			}
+			if tt.status == fiber.StatusConflict {
+				if body["code"] != "custom_short_expired" {
+					t.Errorf("reuse: code = %v, want custom_short_expired", body["code"])
+				}
+				return
+			}
+			if resp := send(t, app, "GET", "/reused", "", ""); resp.Header.Get(fiber.HeaderLocation) != "https://example.com/new" {
+				t.Errorf("GET /reused: to %q, want the new link", resp.Header.Get(fiber.HeaderLocation))
+			}
+			if got := decode(t, send(t, app, "GET", "/api/v1/stats/reused", "", ""))["clicks"]; got != tt.clicks {
+				t.Errorf("clicks = %v, want %v", got, tt.clicks)
+			}
+		})
+	}
+}
+
+func TestReclaimHeldCodes(t *testing.T) {
+	t.Setenv("RECLAIM_EXPIRED_CODES", "false")
+	app := newTestApp()
+	seedLink(t, "base", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+	expireAndLapse(t, app, "held")
+
+	// aliases and imports can't take a held code either
+	resp := sendHeaders(t, app, "POST", "/base/alias", `{"alias":"held"}`, map[string]string{"X-Edit-Token": "tok"})
+	if body := decode(t, resp); resp.StatusCode != fiber.StatusConflict || body["code"] != "custom_short_expired" {
+		t.Errorf("alias: %d %v, want %d custom_short_expired", resp.StatusCode, body, fiber.StatusConflict)
+	}
+	resp = sendHeaders(t, app, "POST", "/api/v1/admin/import", "url,short\nhttps://example.com/new,held\n", asAdmin(t))
+	if body := decode(t, resp); body["imported"] != float64(0) {
+		t.Errorf("import: %v, want the row refused", body)
+	}
+
+	// once the tombstone lapses the code is free
+	if err := database.CreateClient(0).Del(context.Background(), database.TombstoneKey(database.LinkKey("", "held"))).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/new","short":"held"}`, ""); resp.StatusCode != fiber.StatusCreated {
+		t.Errorf("after the tombstone: status = %d, want %d", resp.StatusCode, fiber.StatusCreated)
+	}
+}
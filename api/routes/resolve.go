@@ -1,26 +1,457 @@
 package routes
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"html/template"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
 	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/metrics"
 )
 
 func ResolveURL(c *fiber.Ctx) error {
-	url := c.Params("url")
-	r := database.CreateClient(0)
-	defer r.Close()
+	return resolveCode(c, shortCode(c), false)
+}
+
+// ResolveShort resolves the short in the request body, for codes too long
+// or awkward for a path, and returns where it goes instead of redirecting.
+// It's a visit like any other: it counts a click and uses up max_clicks.
+// hide_destination links are only resolved for their owner.
+func ResolveShort(c *fiber.Ctx) error {
+	body := struct {
+		Short string `json:"short"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	id := helpers.NormalizeCode(body.Short)
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "short is required"})
+	}
+	return resolveCode(c, id, true)
+}
+
+// sendDestination answers ResolveShort with where a redirect would have gone.
+func sendDestination(c *fiber.Ctx, link *database.Link, dest string, status int) error {
+	// each answer is a counted visit, never one to replay from a cache
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"destination": dest,
+		"permanent":   status == fiber.StatusMovedPermanently,
+	})
+}
 
-	value, err := r.Get(database.Ctx, url).Result()
+// resolveCode looks up the link for code url and, when it may be followed,
+// counts the visit and redirects to its destination, or with asJSON sends
+// the destination in the body. Each way a code can fail to resolve has its
+// own status and error code: invalid_code (400) for one that can't exist,
+// link_not_found (404), link_expired (410) and link_disabled (403).
+func resolveCode(c *fiber.Ctx, url string, asJSON bool) error {
+	ctx := c.UserContext()
+	send := redirect
+	if asJSON {
+		send = sendDestination
+	}
+	if !helpers.WellFormedCode(url) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "short code is malformed", "code": "invalid_code"})
+	}
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, url)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	// with signed codes only look up the parts of the code that are signed
+	var link *database.Link
+	err = redis.Nil
+	if helpers.VerifyCode(url) {
+		link, err = database.GetLink(ctx, r, key)
+	}
+	var suffix string
+	if err == redis.Nil {
+		// docs/installation can be the docs link with /installation appended
+		var prefixKey string
+		prefixKey, link, suffix, err = appendPathLink(c, r, url)
+		if err == nil {
+			key = prefixKey
+		}
+	}
 	if err == redis.Nil {
-		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+		if expired, _ := database.Expired(ctx, r, key); expired {
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "short link has expired", "code": "link_expired"})
+		}
+		metrics.ResolveMisses.Inc("")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database", "code": "link_not_found"})
 	} else if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
-	} 
+	}
+
+	if link.Disabled {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "this link has been disabled", "code": "link_disabled"})
+	}
+	if link.Expired {
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "short link has expired", "code": "link_expired"})
+	}
+	// a redirect reveals the destination to the visitor's browser alone; a
+	// body reveals it to whoever's asking
+	if asJSON && link.HideDestination && !isOwner(c, link) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "the destination of this link is hidden"})
+	}
+
+	// hotlink protection: only follow links from the owner's allowed sites
+	if len(link.AllowedReferrers) > 0 && !helpers.ReferrerAllowed(c.Get(fiber.HeaderReferer), link.AllowedReferrers) {
+		if fallback := config.String("REFERRER_FALLBACK_URL", ""); fallback != "" {
+			c.Set(fiber.HeaderCacheControl, "no-store")
+			return send(c, &database.Link{}, fallback, fiber.StatusFound)
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "this link can't be followed from here"})
+	}
+
+	rInr := database.CreateClientContext(ctx, 1)
+
+	if link.ResolveLimitPerMinute > 0 {
+		retryAfter, first, err := checkResolveLimit(ctx, rInr, key, link.ResolveLimitPerMinute)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if first {
+			primary := key
+			if link.PrimaryKey != "" {
+				primary = link.PrimaryKey
+			}
+			recordEvent(ctx, r, primary, eventThrottled, strconv.Itoa(link.ResolveLimitPerMinute)+"/min")
+		}
+		if retryAfter > 0 {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(retryAfter/time.Second)))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "this link is receiving too many requests"})
+		}
+	}
+
+	// link checkers probe with HEAD; only count those when asked to
+	counted := c.Method() != fiber.MethodHead || config.Bool("COUNT_HEAD_REQUESTS", false)
+
+	// every counted redirect, bots included, reveals the destination, so each
+	// one uses up a click; the one that uses the last expires the link
+	if link.MaxClicks > 0 && counted {
+		primary := key
+		if link.PrimaryKey != "" {
+			primary = link.PrimaryKey
+		}
+		used, err := database.UseLink(ctx, r, primary)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if used > int64(link.MaxClicks) {
+			return c.Status(fiber.StatusGone).JSON(fiber.Map{"error": "short link has expired", "code": "link_expired"})
+		}
+		if used == int64(link.MaxClicks) {
+			// the visitor still gets this last redirect if expiring fails
+			defer expireNow(ctx, r, primary, link, "max_clicks")
+		}
+	}
+
+	if counted {
+		recordClick(c, r, statsKey(key, link))
+	}
+	if grace := config.Duration("SLIDING_EXPIRY", 0); grace > 0 {
+		slideExpiry(c, r, key, link, grace)
+	}
+
+	dest := destination(c, link)
+	if link.FallbackURL != "" {
+		// health is recorded for the stored destinations, so check dest before
+		// a path is appended to it or it's upgraded to https
+		unhealthy, err := database.DestinationUnhealthy(ctx, rInr, dest)
+		if err == nil && unhealthy {
+			// temporary by nature, so never cached or permanent
+			if config.Bool("LOG_DESTINATIONS", false) {
+				c.Locals("destination", link.FallbackURL)
+			}
+			c.Set(fiber.HeaderCacheControl, "no-store")
+			return send(c, link, link.FallbackURL, fiber.StatusFound)
+		}
+	}
+	if suffix != "" {
+		if dest, err = appendPath(dest, suffix); err != nil {
+			return c.Status(validationStatus(err)).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+	dest = upgradeInsecure(c, rInr, dest)
+	if config.Bool("LOG_DESTINATIONS", false) {
+		c.Locals("destination", dest)
+	}
+
+	c.Set(fiber.HeaderCacheControl, cacheControl(link))
+	if link.Permanent {
+		return send(c, link, dest, fiber.StatusMovedPermanently)
+	}
+	return send(c, link, dest, fiber.StatusFound)
+}
+
+// shortLinkMethods are the methods a short link's own path accepts.
+const shortLinkMethods = "GET, HEAD, PATCH, OPTIONS"
+
+// ResolveOptions answers OPTIONS on a short link, as sent by CORS preflights
+// and monitors, with the methods it accepts. It doesn't look the code up, so
+// it neither counts a click nor a miss. API paths aren't short links and are
+// left to fall through.
+func ResolveOptions(c *fiber.Ctx) error {
+	if c.Path() == "/api" || strings.HasPrefix(c.Path(), "/api/") {
+		return c.Next()
+	}
+	c.Set(fiber.HeaderAllow, shortLinkMethods)
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// slideExpiry keeps a link that's in use alive: each visit pushes its expiry
+// back by grace, up to SLIDING_EXPIRY_MAX (MAX_EXPIRY by default) from now. The
+// link's index entries are kept alive with it. Failures only cost the
+// extension, so they don't fail the redirect.
+func slideExpiry(c *fiber.Ctx, r database.Store, key string, link *database.Link, grace time.Duration) {
+	ctx := c.UserContext()
+	if link.PrimaryKey != "" {
+		key = link.PrimaryKey
+	}
+	limit := config.Duration("SLIDING_EXPIRY_MAX", helpers.MaxExpiry())
+	ttl, err := database.ExtendLink(ctx, r, key, grace, limit)
+	if err != nil || ttl <= 0 {
+		return
+	}
+	alignAnalytics(ctx, r, ttl, key)
+
+	tenant, err := database.TenantForHost(ctx, r, c.Hostname())
+	if err != nil {
+		return
+	}
+	id := database.LinkCode(key)
+	_ = database.IndexDestination(ctx, r, tenant, link.URL, id, ttl)
+	_ = database.IndexTags(ctx, r, tenant, link.Tags, id, ttl)
+	if link.Owner != "" {
+		_ = database.IndexOwnerLink(ctx, r, link.Owner, key, ttl)
+	}
+}
+
+var (
+	redirectTemplate     = template.Must(template.ParseFS(templates, "templates/redirect.html"))
+	redirectBodyTemplate = template.Must(template.ParseFS(templates, "templates/redirect_body.html"))
+)
+
+// redirect sends the visitor to dest with status, or for a no_referrer link
+// through a page that redirects without sending a Referer. The link's custom
+// headers are set either way.
+func redirect(c *fiber.Ctx, link *database.Link, dest string, status int) error {
+	for name, value := range link.Headers {
+		c.Set(name, value)
+	}
+	if !link.NoReferrer {
+		if err := c.Redirect(dest, status); err != nil {
+			return err
+		}
+		return redirectBody(c, dest)
+	}
+
+	var buf bytes.Buffer
+	if err := redirectTemplate.Execute(&buf, dest); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot render redirect"})
+	}
+	c.Set(fiber.HeaderReferrerPolicy, "no-referrer")
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
+
+// redirectBody gives a redirect to dest the body REDIRECT_BODY asks for, for
+// minimal clients that show it instead of following Location: none, the
+// default, a line of text naming dest's host, or a small HTML page linking
+// to dest.
+func redirectBody(c *fiber.Ctx, dest string) error {
+	host := dest
+	if u, err := url.Parse(dest); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	switch config.String("REDIRECT_BODY", "none") {
+	case "text":
+		c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+		return c.SendString("Redirecting to " + host + "…\n")
+	case "html":
+		var buf bytes.Buffer
+		if err := redirectBodyTemplate.Execute(&buf, fiber.Map{"URL": dest, "Host": host}); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot render redirect"})
+		}
+		c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+		return c.Send(buf.Bytes())
+	}
+	return nil
+}
+
+// recordClick counts the visit to the link at key and its visitor. Bots are counted apart
+// from people (BOT_CLICKS=separate, the default), not at all (skip), or like
+// everyone else (count). What's recorded expires with the link, which is
+// looked up in r.
+func recordClick(c *fiber.Ctx, r database.Store, key string) {
+	ctx := c.UserContext()
+	mode := config.String("BOT_CLICKS", "separate")
+	bot := mode != "count" && helpers.IsBot(c.Get(fiber.HeaderUserAgent))
+	if bot && mode == "skip" {
+		return
+	}
+	// a failed lookup counts the click with no expiry rather than losing it
+	ttl, _ := r.TTL(ctx, key).Result()
+	if bot {
+		_ = database.RecordBotClick(ctx, key, ttl)
+		return
+	}
+	_ = database.RecordClick(ctx, key, ttl)
+	_ = database.RecordVisitor(ctx, key, helpers.VisitorID(c.IP()), ttl)
+}
+
+// destination is where link sends this visitor: the locale destination that
+// best matches their Accept-Language, else the one for their country when
+// GEOIP_DB_PATH is set, else the "default" locale, else the link's URL.
+func destination(c *fiber.Ctx, link *database.Link) string {
+	if len(link.Locales) == 0 {
+		return link.URL
+	}
+	// caches must not serve one visitor's language to another
+	c.Vary(fiber.HeaderAcceptLanguage)
+
+	tags := make([]string, 0, len(link.Locales))
+	for tag := range link.Locales {
+		if tag != "default" {
+			tags = append(tags, tag)
+		}
+	}
+	if tag := helpers.BestLocale(c.Get(fiber.HeaderAcceptLanguage), tags); tag != "" {
+		return link.Locales[tag]
+	}
+	// with a GeoIP database, the visitor's country picks a regional tag
+	if config.String("GEOIP_DB_PATH", "") != "" {
+		if tag := helpers.RegionLocale(helpers.VisitorCountry(c.IP()), tags); tag != "" {
+			return link.Locales[tag]
+		}
+	}
+	if dest, ok := link.Locales["default"]; ok {
+		return dest
+	}
+	return link.URL
+}
+
+// shortCode is the short code in the request path, normalized with
+// helpers.NormalizeCode: /abc/, //abc and /%61bc all resolve like /abc. Codes
+// themselves can't start or end with a slash or contain escapes, so this never
+// clashes with path-style codes.
+func shortCode(c *fiber.Ctx) string {
+	return helpers.NormalizeCode(c.Params("*"))
+}
+
+// appendPathLink finds the link a code with extra path segments belongs to:
+// the longest leading run of id's segments that is a link, when that link has
+// append_path set. It also returns the segments left over, with their leading
+// "/". It returns redis.Nil when there is no such link.
+func appendPathLink(c *fiber.Ctx, r database.Store, id string) (string, *database.Link, string, error) {
+	tenant, err := database.TenantForHost(c.UserContext(), r, c.Hostname())
+	if err != nil {
+		return "", nil, "", err
+	}
+	for i := strings.LastIndex(id, helpers.CustomShortSeparator); i > 0; i = strings.LastIndex(id[:i], helpers.CustomShortSeparator) {
+		// longer prefixes can't be codes
+		if i > helpers.CustomShortMaxPathLen || !helpers.VerifyCode(id[:i]) {
+			continue
+		}
+		key := database.LinkKey(tenant, id[:i])
+		link, err := database.GetLink(c.UserContext(), r, key)
+		if err == redis.Nil {
+			continue
+		} else if err != nil {
+			return "", nil, "", err
+		}
+		if !link.AppendPath {
+			break
+		}
+		return key, link, id[i:], nil
+	}
+	return "", nil, "", redis.Nil
+}
+
+// appendPath joins the extra path segments suffix onto dest and checks the
+// result is still a destination links may point at. Dot segments are
+// rejected: they would climb out of the destination's path.
+func appendPath(dest, suffix string) (string, error) {
+	for _, seg := range strings.Split(suffix, "/") {
+		if seg == "." || seg == ".." {
+			return "", errors.New("invalid path")
+		}
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return "", errors.New("invalid URL")
+	}
+	joined := u.JoinPath(suffix).String()
+	if err := helpers.ValidateURL(joined); err != nil {
+		return "", err
+	}
+	if err := helpers.CheckExtension(joined); err != nil {
+		return "", err
+	}
+	return joined, nil
+}
+
+// tenantKey maps the short code id to its DB 0 key, scoped to the tenant that
+// owns the request's host when it's a registered custom domain.
+func tenantKey(c *fiber.Ctx, r database.Store, id string) (string, error) {
+	tenant, err := database.TenantForHost(c.UserContext(), r, c.Hostname())
+	if err != nil {
+		return "", err
+	}
+	return database.LinkKey(tenant, id), nil
+}
+
+// checkResolveLimit counts a redirect of the link at key against its
+// per-minute limit. It returns how long to wait before retrying when the limit
+// is exceeded, or zero when the redirect may go ahead, and whether this is the
+// first redirect over the limit in its minute.
+func checkResolveLimit(ctx context.Context, rdb database.Store, key string, limit int) (time.Duration, bool, error) {
+	now := time.Now()
+	window := now.Truncate(time.Minute)
+	counter := "resolve:" + key + ":" + strconv.FormatInt(window.Unix(), 10)
+
+	count, err := rdb.Incr(ctx, counter).Result()
+	if err != nil {
+		return 0, false, err
+	}
+	if count == 1 {
+		rdb.Expire(ctx, counter, time.Minute)
+	}
+	if count <= int64(limit) {
+		return 0, false, nil
+	}
+	return window.Add(time.Minute).Sub(now).Round(time.Second) + time.Second, count == int64(limit)+1, nil
+}
 
-	rInr := database.CreateClient(1)
-	defer rInr.Close()
+// cacheControl picks the redirect's Cache-Control header. A link's own
+// cache_ttl_seconds wins; otherwise permanent links may be cached for
+// REDIRECT_CACHE_TTL and temporary links must not be cached at all, so that
+// later edits are picked up.
+func cacheControl(link *database.Link) string {
+	ttl := -1
+	if link.CacheTTLSeconds != nil {
+		ttl = *link.CacheTTLSeconds
+	} else if link.Permanent {
+		ttl = int(config.Duration("REDIRECT_CACHE_TTL", time.Hour) / time.Second)
+	}
 
-	_ = rInr.Incr(database.Ctx, "counter")
-	return c.Redirect(value, 301)
-}
\ No newline at end of file
+	if ttl <= 0 {
+		return "no-store"
+	}
+	return "max-age=" + strconv.Itoa(ttl)
+}
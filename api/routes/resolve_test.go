@@ -0,0 +1,924 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/etag"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/metrics"
+	"github.com/karthikbhandary2/url-shortener/middleware"
+)
+
+func TestMain(m *testing.M) {
+	os.Setenv("STORAGE_BACKEND", "memory")
+	// enough shortens for any one test; the stores are emptied between them
+	os.Setenv("API_QUOTA", "1000")
+	os.Exit(m.Run())
+}
+
+// newTestApp serves the routes setupRoutes in main.go does, on empty stores.
+func newTestApp() *fiber.App {
+	for _, dbNo := range []int{0, 1} {
+		database.CreateClient(dbNo).(*database.MemoryStore).Flush()
+	}
+	app := fiber.New(fiber.Config{JSONEncoder: helpers.MarshalJSON})
+	app.Use(middleware.Recover)
+	app.Use(middleware.RequireHTTPS)
+	app.Use(middleware.APIKeyAuth)
+	app.Get("/admin", middleware.AdminOnly, Dashboard)
+	app.Get("/metrics", middleware.AdminOnly, Metrics)
+	app.Get("/api/v1/admin/reverse", middleware.AdminOnly, ReverseLookup)
+	app.Get("/api/v1/admin/domains", middleware.AdminOnly, ListDomains)
+	app.Put("/api/v1/admin/domains/:domain", middleware.AdminOnly, middleware.RequireJSON, SetDomain)
+	app.Delete("/api/v1/admin/domains/:domain", middleware.AdminOnly, DeleteDomain)
+	app.Post("/api/v1/admin/keys", middleware.AdminOnly, middleware.RequireJSON, CreateAPIKey)
+	app.Delete("/api/v1/admin/keys/:id", middleware.AdminOnly, DeleteAPIKey)
+	app.Get("/api/v1/admin/reports", middleware.AdminOnly, ListReports)
+	app.Delete("/api/v1/admin/reports/*", middleware.AdminOnly, ClearReports)
+	app.Get("/api/v1/admin/ratelimit/:ip", middleware.AdminOnly, GetRateLimit)
+	app.Post("/api/v1/admin/ratelimit/:ip", middleware.AdminOnly, SetRateLimit)
+	app.Get("/api/v1/admin/read-only", middleware.AdminOnly, GetReadOnly)
+	app.Put("/api/v1/admin/read-only", middleware.AdminOnly, middleware.RequireJSON, SetReadOnly)
+	app.Put("/api/v1/admin/announcement", middleware.AdminOnly, middleware.RequireJSON, SetAnnouncement)
+	app.Delete("/api/v1/admin/announcement", middleware.AdminOnly, ClearAnnouncement)
+	app.Get("/api/v1/announcement", Announcement)
+	app.Get("/api/v1/rules", Rules)
+	app.Get("/api/v1/schema/shorten", ShortenSchema)
+	app.Get("/api/v1/features", Features)
+	app.Get("/api/v1/quota", Quota)
+	app.Post("/api/v1/collections", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, CreateCollection)
+	app.Post("/api/v1/collections/:id/links", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, AddCollectionLinks)
+	app.Get("/api/v1/collections/:id/stats", CollectionStats)
+	app.Get("/api/v1/admin/links/recent", middleware.AdminOnly, RecentLinks)
+	app.Post("/api/v1/admin/import", middleware.AdminOnly, middleware.BlockWrites, ImportLinks)
+	app.Get("/api/v1/links", middleware.AdminOnly, ListLinks)
+	app.Delete("/api/v1/links", middleware.AdminOnly, middleware.BlockWrites, DeleteLinks)
+	app.Patch("/api/v1/links/expiry", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, UpdateLinksExpiry)
+	// pollers send the ETag back in If-None-Match and get 304 until the
+	// link or its clicks change
+	conditional := etag.New()
+	app.Get("/api/v1/links/*/events", middleware.SignedCode, LinkEvents)
+	app.Get("/api/v1/links/*", middleware.SignedCode, conditional, LinkInfo)
+	app.Get("/api/v1/stats/*", middleware.SignedCode, conditional, LinkStats)
+	app.Get("/api/v1/peek/*", middleware.SignedCode, PeekURL)
+	app.Get("/api/v1/card/*", middleware.SignedCode, LinkCard)
+	app.Get("/api/v1/oembed", OEmbed)
+	app.Post("/api/v1/qr/bulk", middleware.RequireKey(database.ScopeRead), middleware.RequireJSON, BulkQR)
+	app.Get("/api/v1/preview/*/continue", middleware.SignedCode, ContinuePreview)
+	app.Get("/api/v1/preview/*", middleware.SignedCode, PreviewURL)
+	app.Get("/", Root)
+	app.Get("/favicon.ico", Favicon)
+	app.Get("/robots.txt", Robots)
+	// Get also registers HEAD, which link checkers use. The wildcard lets
+	// path-style codes like docs/getting-started resolve, so it has to stay
+	// behind every other GET route.
+	app.Get("/*", middleware.SignedCode, ResolveURL)
+	app.Patch("/*", middleware.SignedCode, middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, UpdateLink)
+	app.Options("/*", ResolveOptions)
+	app.Post("/api/v1", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, ShortenURL)
+	// test data for benchmarks; absent unless explicitly enabled
+	if config.Bool("ENABLE_SEED_ENDPOINT", false) {
+		app.Post("/api/v1/_seed", middleware.BlockWrites, middleware.RequireWrite, middleware.RequireJSON, Seed)
+	}
+	app.Post("/api/v1/resolve", middleware.RequireJSON, ResolveShort)
+	app.Post("/api/v1/reserve/*", middleware.BlockWrites, middleware.RequireWrite, ReserveShort)
+	app.Post("/api/v1/report/*", middleware.BlockWrites, middleware.SignedCode, middleware.RequireJSON, ReportLink)
+	app.Post("/*/alias", middleware.BlockWrites, middleware.RequireWrite, middleware.SignedCode, middleware.RequireJSON, CreateAlias)
+	app.Delete("/*/alias/:alias", middleware.BlockWrites, middleware.RequireWrite, middleware.SignedCode, DeleteAlias)
+	app.Post("/*/transfer", middleware.BlockWrites, middleware.RequireWrite, middleware.SignedCode, middleware.RequireJSON, TransferLink)
+	app.Post("/*/expire", middleware.BlockWrites, middleware.RequireWrite, middleware.SignedCode, ExpireLink)
+	app.Post("/*/verify-token", middleware.SignedCode, middleware.RequireJSON, VerifyEditToken)
+	return app
+}
+
+// seedLink saves link under code in the default tenant, failing t if it
+// can't. Call it after newTestApp, which empties the stores.
+func seedLink(t *testing.T, code string, link *database.Link) {
+	t.Helper()
+	r := database.CreateClient(0)
+	if err := database.SaveLink(context.Background(), r, database.LinkKey("", code), link, 0); err != nil {
+		t.Fatalf("seeding %s: %v", code, err)
+	}
+}
+
+func TestResolveGating(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "gate-live", &database.Link{URL: "https://example.com/live"})
+	seedLink(t, "gate-off", &database.Link{URL: "https://example.com/off", Disabled: true})
+	seedLink(t, "gate-gone", &database.Link{URL: "https://example.com/gone", Expired: true})
+	seedLink(t, "gate-refs", &database.Link{URL: "https://example.com/refs", AllowedReferrers: []string{"example.org"}})
+	seedLink(t, "gate-hide", &database.Link{URL: "https://example.com/hide", HideDestination: true})
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		body     string
+		referer  string
+		status   int
+		location string
+	}{
+		{"live", "GET", "/gate-live", "", "", fiber.StatusFound, "https://example.com/live"},
+		{"malformed", "GET", "/bad%20code", "", "", fiber.StatusBadRequest, ""},
+		{"unknown", "GET", "/gate-none", "", "", fiber.StatusNotFound, ""},
+		{"disabled", "GET", "/gate-off", "", "", fiber.StatusForbidden, ""},
+		{"expired", "GET", "/gate-gone", "", "", fiber.StatusGone, ""},
+		{"referrer allowed", "GET", "/gate-refs", "", "https://www.example.org/page", fiber.StatusFound, "https://example.com/refs"},
+		{"referrer refused", "GET", "/gate-refs", "", "https://evil.example.net/", fiber.StatusForbidden, ""},
+		{"no referrer", "GET", "/gate-refs", "", "", fiber.StatusForbidden, ""},
+		{"hidden redirect", "GET", "/gate-hide", "", "", fiber.StatusFound, "https://example.com/hide"},
+		{"hidden in body", "POST", "/api/v1/resolve", `{"short":"gate-hide"}`, "", fiber.StatusForbidden, ""},
+		{"disabled in body", "POST", "/api/v1/resolve", `{"short":"gate-off"}`, "", fiber.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := send(t, app, tt.method, tt.path, tt.body, tt.referer)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(fiber.HeaderLocation); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+		})
+	}
+}
+
+func TestResolveGeoLocales(t *testing.T) {
+	t.Cleanup(func() { helpers.SetGeoDB(nil) })
+	app := newTestApp()
+	seedLink(t, "geo", &database.Link{URL: "https://example.com/", Locales: map[string]string{
+		"de-AT":   "https://example.at/",
+		"de-CH":   "https://example.ch/",
+		"default": "https://example.com/world",
+	}})
+	// test requests come from 0.0.0.0
+	loadGeoDB := func(csv string) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "geoip.csv")
+		if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		db, err := helpers.LoadGeoDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		helpers.SetGeoDB(db)
+	}
+	resolve := func(accept string) string {
+		t.Helper()
+		var header map[string]string
+		if accept != "" {
+			header = map[string]string{fiber.HeaderAcceptLanguage: accept}
+		}
+		return sendHeaders(t, app, "GET", "/geo", "", header).Header.Get(fiber.HeaderLocation)
+	}
+
+	t.Setenv("GEOIP_DB_PATH", "/var/lib/geoip/country.csv")
+	tests := []struct {
+		name   string
+		csv    string
+		accept string
+		want   string
+		reason string
+	}{
+		{"placed", "0.0.0.0,0.0.0.255,AT\n", "", "https://example.at/", ""},
+		{"Accept-Language first", "0.0.0.0,0.0.0.255,AT\n", "de-CH", "https://example.ch/", ""},
+		{"no region tag", "0.0.0.0,0.0.0.255,US\n", "", "https://example.com/world", ""},
+		{"failed lookup", "5.0.0.0,5.255.255.255,AT\n", "", "https://example.com/world", "not_found"},
+		{"missing database", "", "", "https://example.com/world", "no_database"},
+	}
+	for _, tt := range tests {
+		if tt.csv != "" {
+			loadGeoDB(tt.csv)
+		} else {
+			helpers.SetGeoDB(nil)
+		}
+		var before uint64
+		if tt.reason != "" {
+			before = helpers.GeoLookupFailures.Value(tt.reason)
+		}
+		if got := resolve(tt.accept); got != tt.want {
+			t.Errorf("%s: redirected to %q, want %q", tt.name, got, tt.want)
+		}
+		if tt.reason != "" && helpers.GeoLookupFailures.Value(tt.reason) != before+1 {
+			t.Errorf("%s: %s failures counted %d, want 1", tt.name, tt.reason, helpers.GeoLookupFailures.Value(tt.reason)-before)
+		}
+	}
+
+	// without GEOIP_DB_PATH a loaded database isn't consulted
+	t.Setenv("GEOIP_DB_PATH", "")
+	loadGeoDB("0.0.0.0,0.0.0.255,AT\n")
+	if got := resolve(""); got != "https://example.com/world" {
+		t.Errorf("GEOIP_DB_PATH unset: redirected to %q, want the default", got)
+	}
+}
+
+func TestResolveAllowedReferrers(t *testing.T) {
+	tests := []struct {
+		name     string
+		allowed  string
+		fallback string
+		referer  string
+		status   int
+		location string
+	}{
+		{"unrestricted", `[]`, "", "https://anywhere.example/", fiber.StatusFound, "https://example.com/dest"},
+		{"unrestricted, no referrer", `[]`, "", "", fiber.StatusFound, "https://example.com/dest"},
+		{"allowed", `[" Example.ORG "]`, "", "https://example.org/post", fiber.StatusFound, "https://example.com/dest"},
+		{"allowed subdomain", `["example.org"]`, "", "http://blog.Example.org:8080/", fiber.StatusFound, "https://example.com/dest"},
+		{"lookalike", `["example.org"]`, "", "https://notexample.org/", fiber.StatusForbidden, ""},
+		{"refused", `["example.org"]`, "", "https://evil.example/?ref=example.org", fiber.StatusForbidden, ""},
+		{"no referrer", `["example.org"]`, "", "", fiber.StatusForbidden, ""},
+		{"refused, with fallback", `["example.org"]`, "https://example.com/hotlink", "https://evil.example/", fiber.StatusFound, "https://example.com/hotlink"},
+		{"allowed, with fallback", `["example.org"]`, "https://example.com/hotlink", "https://example.org/", fiber.StatusFound, "https://example.com/dest"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REFERRER_FALLBACK_URL", tt.fallback)
+			app := newTestApp()
+			resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/dest","short":"hotlink","permanent":false,"allowed_referrers":`+tt.allowed+`}`, "")
+			if resp.StatusCode != fiber.StatusCreated {
+				t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, decode(t, resp))
+			}
+			resp = send(t, app, "GET", "/hotlink", "", tt.referer)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(fiber.HeaderLocation); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+		})
+	}
+
+	app := newTestApp()
+	for _, hosts := range []string{`["https://example.org"]`, `["example.org/path"]`, `[""]`} {
+		resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","allowed_referrers":`+hosts+`}`, "")
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("allowed_referrers %s: status = %d, want %d", hosts, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
+
+func TestResolveLocales(t *testing.T) {
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"intl","permanent":false,"locales":{"EN":"https://example.com/en","de":"https://example.de/","default":"https://example.com/world"}}`, "")
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	seedLink(t, "plain", &database.Link{URL: "https://example.com/plain"})
+
+	tests := []struct {
+		path   string
+		accept string
+		want   string
+	}{
+		{"/intl", "de-AT,de;q=0.9,en;q=0.8", "https://example.de/"},
+		{"/intl", "fr;q=0.9, en;q=0.5", "https://example.com/en"},
+		{"/intl", "fr", "https://example.com/world"},
+		{"/intl", "", "https://example.com/world"},
+		{"/plain", "de", "https://example.com/plain"},
+	}
+	for _, tt := range tests {
+		var header map[string]string
+		if tt.accept != "" {
+			header = map[string]string{fiber.HeaderAcceptLanguage: tt.accept}
+		}
+		resp := sendHeaders(t, app, "GET", tt.path, "", header)
+		if got := resp.Header.Get(fiber.HeaderLocation); got != tt.want {
+			t.Errorf("GET %s, Accept-Language %q: %d to %q, want %q", tt.path, tt.accept, resp.StatusCode, got, tt.want)
+		}
+		if vary := resp.Header.Get(fiber.HeaderVary); strings.Contains(vary, fiber.HeaderAcceptLanguage) != (tt.path == "/intl") {
+			t.Errorf("GET %s: Vary = %q", tt.path, vary)
+		}
+	}
+
+	for _, locales := range []string{`{"en us":"https://example.com/"}`, `{"de":"not a url"}`} {
+		resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","locales":`+locales+`}`, "")
+		if resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("locales %s: status = %d, want %d", locales, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
+
+func TestResolveMaxClicks(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "gate-once", &database.Link{URL: "https://example.com/once", MaxClicks: 2})
+	for i, want := range []int{fiber.StatusFound, fiber.StatusFound, fiber.StatusGone, fiber.StatusGone} {
+		if resp := send(t, app, "GET", "/gate-once", "", ""); resp.StatusCode != want {
+			t.Errorf("visit %d: status = %d, want %d", i+1, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestResolveHeadMaxClicks(t *testing.T) {
+	tests := []struct {
+		name      string
+		countHead string
+		want      []int
+	}{
+		// the checks neither use up the link nor stop the visit after them
+		{"not counted", "", []int{fiber.StatusFound, fiber.StatusFound, fiber.StatusFound, fiber.StatusFound}},
+		{"counted", "true", []int{fiber.StatusFound, fiber.StatusGone, fiber.StatusGone, fiber.StatusGone}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("COUNT_HEAD_REQUESTS", tt.countHead)
+			app := newTestApp()
+			seedLink(t, "gate-head", &database.Link{URL: "https://example.com/head", MaxClicks: 1})
+			for i, want := range tt.want[:3] {
+				resp := send(t, app, "HEAD", "/gate-head", "", "")
+				if resp.StatusCode != want {
+					t.Errorf("HEAD %d: status = %d, want %d", i+1, resp.StatusCode, want)
+				}
+				if want == fiber.StatusFound && resp.Header.Get(fiber.HeaderLocation) != "https://example.com/head" {
+					t.Errorf("HEAD %d: Location = %q", i+1, resp.Header.Get(fiber.HeaderLocation))
+				}
+			}
+			if resp := send(t, app, "GET", "/gate-head", "", ""); resp.StatusCode != tt.want[3] {
+				t.Errorf("GET after the checks: status = %d, want %d", resp.StatusCode, tt.want[3])
+			}
+		})
+	}
+}
+
+func TestResolveSignedCodes(t *testing.T) {
+	t.Setenv("CODE_SIGNING_KEY", "secret")
+	app := newTestApp()
+	signed := helpers.SignCode("gate-sig")
+	seedLink(t, signed, &database.Link{URL: "https://example.com/signed"})
+	// a link stored under the bare code can't be reached either
+	seedLink(t, "gate-sig", &database.Link{URL: "https://example.com/unsigned"})
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/" + signed, fiber.StatusFound},
+		{"/gate-sig", fiber.StatusNotFound},
+		{"/gate-sig-aaaaaa", fiber.StatusNotFound},
+		{"/" + signed[:len(signed)-1], fiber.StatusNotFound},
+	}
+	for _, tt := range tests {
+		resp := send(t, app, "GET", tt.path, "", "")
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		} else if tt.status == fiber.StatusFound && resp.Header.Get(fiber.HeaderLocation) != "https://example.com/signed" {
+			t.Errorf("GET %s: Location = %q", tt.path, resp.Header.Get(fiber.HeaderLocation))
+		}
+	}
+}
+
+func TestResolveCacheControl(t *testing.T) {
+	t.Setenv("DOMAIN", "localhost:3000")
+	tests := []struct {
+		name   string
+		fields string
+		env    string
+		status int
+		cache  string
+	}{
+		{"permanent by default", ``, "", fiber.StatusMovedPermanently, "max-age=3600"},
+		{"permanent", `,"permanent":true`, "", fiber.StatusMovedPermanently, "max-age=3600"},
+		{"permanent, REDIRECT_CACHE_TTL", `,"permanent":true`, "10m", fiber.StatusMovedPermanently, "max-age=600"},
+		{"permanent, REDIRECT_CACHE_TTL off", `,"permanent":true`, "0s", fiber.StatusMovedPermanently, "no-store"},
+		{"temporary", `,"permanent":false`, "", fiber.StatusFound, "no-store"},
+		{"temporary, own TTL", `,"permanent":false,"cache_ttl_seconds":60`, "", fiber.StatusFound, "max-age=60"},
+		{"permanent, own TTL", `,"cache_ttl_seconds":86400`, "10m", fiber.StatusMovedPermanently, "max-age=86400"},
+		{"permanent, no caching", `,"cache_ttl_seconds":0`, "", fiber.StatusMovedPermanently, "no-store"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REDIRECT_CACHE_TTL", tt.env)
+			app := newTestApp()
+			resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"cached"`+tt.fields+`}`, "")
+			if resp.StatusCode != fiber.StatusCreated {
+				t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, decode(t, resp))
+			}
+			resp = send(t, app, "GET", "/cached", "", "")
+			if resp.StatusCode != tt.status {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(fiber.HeaderCacheControl); got != tt.cache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cache)
+			}
+		})
+	}
+
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","cache_ttl_seconds":-1}`, "")
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("negative cache_ttl_seconds: status = %d, want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+func TestResolveTrailingSlash(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "abc", &database.Link{URL: "https://example.com/abc"})
+	seedLink(t, "docs", &database.Link{URL: "https://example.com/docs"})
+	seedLink(t, "docs/start", &database.Link{URL: "https://example.com/docs/start"})
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/abc", "https://example.com/abc"},
+		{"/abc/", "https://example.com/abc"},
+		{"/docs", "https://example.com/docs"},
+		{"/docs/", "https://example.com/docs"},
+		{"/docs/start", "https://example.com/docs/start"},
+		{"/docs/start/", "https://example.com/docs/start"},
+	}
+	for _, tt := range tests {
+		resp := send(t, app, "GET", tt.path, "", "")
+		if got := resp.Header.Get(fiber.HeaderLocation); resp.StatusCode != fiber.StatusFound || got != tt.want {
+			t.Errorf("GET %s: %d to %q, want %d to %q", tt.path, resp.StatusCode, got, fiber.StatusFound, tt.want)
+		}
+	}
+	for _, path := range []string{"/api/v1/links/abc/", "/api/v1/peek/abc/", "/api/v1/stats/docs/start/"} {
+		if resp := send(t, app, "GET", path, "", ""); resp.StatusCode != fiber.StatusOK {
+			t.Errorf("GET %s: status = %d, want %d", path, resp.StatusCode, fiber.StatusOK)
+		}
+	}
+}
+
+func TestResolveLimitPerMinute(t *testing.T) {
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/busy","short":"gate-busy","resolve_limit_per_minute":2}`, "")
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	seedLink(t, "gate-quiet", &database.Link{URL: "https://example.com/quiet"})
+
+	for i := 1; i <= 2; i++ {
+		if resp := send(t, app, "GET", "/gate-busy", "", ""); resp.StatusCode != fiber.StatusMovedPermanently {
+			t.Fatalf("visit %d: status = %d, want %d", i, resp.StatusCode, fiber.StatusMovedPermanently)
+		}
+	}
+	resp = send(t, app, "GET", "/gate-busy", "", "")
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("visit 3: status = %d, want %d", resp.StatusCode, fiber.StatusTooManyRequests)
+	}
+	if wait, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter)); err != nil || wait < 1 || wait > 61 {
+		t.Errorf("visit 3: Retry-After = %q, want 1-61 seconds", resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+
+	// the limit is the link's own, not the visitor's
+	for i := 1; i <= 3; i++ {
+		if resp := send(t, app, "GET", "/gate-quiet", "", ""); resp.StatusCode != fiber.StatusFound {
+			t.Errorf("unrelated link, visit %d: status = %d, want %d", i, resp.StatusCode, fiber.StatusFound)
+		}
+	}
+
+	resp = send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","resolve_limit_per_minute":-1}`, "")
+	if resp.StatusCode != fiber.StatusBadRequest {
+		t.Errorf("negative resolve_limit_per_minute: status = %d, want %d", resp.StatusCode, fiber.StatusBadRequest)
+	}
+}
+
+func TestResolveFallbackURL(t *testing.T) {
+	app := newTestApp()
+	ctx := context.Background()
+	seedLink(t, "gate-fallback", &database.Link{URL: "https://example.com/primary", FallbackURL: "https://example.org/fallback"})
+	seedLink(t, "gate-nofallback", &database.Link{URL: "https://example.com/primary"})
+
+	tests := []struct {
+		name      string
+		unhealthy bool
+		path      string
+		location  string
+		cache     string
+	}{
+		{"healthy primary", false, "/gate-fallback", "https://example.com/primary", ""},
+		{"unhealthy primary", true, "/gate-fallback", "https://example.org/fallback", "no-store"},
+		{"unhealthy primary, no fallback", true, "/gate-nofallback", "https://example.com/primary", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := database.SetDestinationHealth(ctx, database.CreateClient(1), "https://example.com/primary", !tt.unhealthy, time.Hour); err != nil {
+				t.Fatal(err)
+			}
+			resp := send(t, app, "GET", tt.path, "", "")
+			if resp.StatusCode != fiber.StatusFound {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusFound)
+			}
+			if got := resp.Header.Get(fiber.HeaderLocation); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+			if got := resp.Header.Get(fiber.HeaderCacheControl); tt.cache != "" && got != tt.cache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cache)
+			}
+		})
+	}
+}
+
+func TestResolveExpiredTombstones(t *testing.T) {
+	admin := asAdmin(t)
+	app := newTestApp()
+	ctx := context.Background()
+	r := database.CreateClient(0)
+	save := func(code string, link *database.Link, ttl time.Duration) {
+		t.Helper()
+		if err := database.SaveLink(ctx, r, database.LinkKey("", code), link, ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("gone-expired", &database.Link{URL: "https://example.com/expired"}, 10*time.Millisecond)
+	save("gone-deleted", &database.Link{URL: "https://example.com/deleted", Tags: []string{"gone"}}, time.Hour)
+	save("gone-kept", &database.Link{URL: "https://example.com/kept"}, time.Hour)
+	if err := database.IndexTags(ctx, r, "", []string{"gone"}, "gone-deleted", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if resp := sendHeaders(t, app, "DELETE", "/api/v1/links?tag=gone", "", admin); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("delete: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/gone-expired", fiber.StatusGone},
+		{"/gone-never-was", fiber.StatusNotFound},
+		// deleted isn't expired
+		{"/gone-deleted", fiber.StatusNotFound},
+		{"/gone-kept", fiber.StatusFound},
+	}
+	for _, tt := range tests {
+		if resp := send(t, app, "GET", tt.path, "", ""); resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		}
+	}
+
+	// once the tombstone lapses, the link never existed as far as anyone knows
+	t.Setenv("TOMBSTONE_TTL", "1ms")
+	save("gone-lapsed", &database.Link{URL: "https://example.com/lapsed"}, 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	if resp := send(t, app, "GET", "/gone-lapsed", "", ""); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("GET /gone-lapsed: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+}
+
+func TestResolveNoReferrer(t *testing.T) {
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/private?a=1&b=2","short":"gate-noref","no_referrer":true}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	seedLink(t, "gate-plain", &database.Link{URL: "https://example.com/plain"})
+
+	resp = send(t, app, "GET", "/gate-noref", "", "")
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	if got := resp.Header.Get(fiber.HeaderLocation); got != "" {
+		t.Errorf("Location = %q, want none", got)
+	}
+	if got := resp.Header.Get(fiber.HeaderReferrerPolicy); got != "no-referrer" {
+		t.Errorf("Referrer-Policy = %q, want no-referrer", got)
+	}
+	if got := resp.Header.Get(fiber.HeaderContentType); got != fiber.MIMETextHTMLCharsetUTF8 {
+		t.Errorf("Content-Type = %q, want %q", got, fiber.MIMETextHTMLCharsetUTF8)
+	}
+	page, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		`<meta name="referrer" content="no-referrer">`,
+		`<meta http-equiv="refresh" content="0; url=https://example.com/private?a=1&amp;b=2">`,
+		`rel="noreferrer"`,
+		`window.location.replace("https://example.com/private?a=1\u0026b=2")`,
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("page is missing %s:\n%s", want, page)
+		}
+	}
+
+	// the default is still a plain redirect
+	if resp := send(t, app, "GET", "/gate-plain", "", ""); resp.StatusCode != fiber.StatusFound || resp.Header.Get(fiber.HeaderLocation) != "https://example.com/plain" {
+		t.Errorf("plain link: %d to %q", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+	owner := map[string]string{"X-Edit-Token": created["edit_token"].(string)}
+	if resp := sendHeaders(t, app, "PATCH", "/gate-noref", `{"no_referrer":false}`, owner); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update: status = %d", resp.StatusCode)
+	}
+	if resp := send(t, app, "GET", "/gate-noref", "", ""); resp.StatusCode != fiber.StatusMovedPermanently {
+		t.Errorf("after turning it off: status = %d, want %d", resp.StatusCode, fiber.StatusMovedPermanently)
+	}
+}
+
+func TestResolveAppendPath(t *testing.T) {
+	t.Setenv("BLOCKED_EXTENSIONS", ".exe")
+	app := newTestApp()
+	seedLink(t, "docs", &database.Link{URL: "https://example.com/guide?v=1", AppendPath: true})
+	seedLink(t, "docs/api", &database.Link{URL: "https://api.example.com/ref", AppendPath: true})
+	seedLink(t, "plain", &database.Link{URL: "https://example.com/plain"})
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/docs", fiber.StatusFound, "https://example.com/guide?v=1"},
+		{"/docs/installation", fiber.StatusFound, "https://example.com/guide/installation?v=1"},
+		{"/docs/installation/linux", fiber.StatusFound, "https://example.com/guide/installation/linux?v=1"},
+		// the longest code wins
+		{"/docs/api/users", fiber.StatusFound, "https://api.example.com/ref/users"},
+		{"/docs/setup.exe", fiber.StatusForbidden, ""},
+		{"/docs/%2e%2e/admin", fiber.StatusBadRequest, ""},
+		{"/plain", fiber.StatusFound, "https://example.com/plain"},
+		{"/plain/extra", fiber.StatusNotFound, ""},
+		{"/missing/extra", fiber.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		resp := send(t, app, "GET", tt.path, "", "")
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		} else if got := resp.Header.Get(fiber.HeaderLocation); tt.location != "" && got != tt.location {
+			t.Errorf("GET %s: Location = %q, want %q", tt.path, got, tt.location)
+		}
+	}
+
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/wiki","short":"wiki","append_path":true}`, "")
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	if resp := send(t, app, "GET", "/wiki/Main_Page", "", ""); resp.Header.Get(fiber.HeaderLocation) != "https://example.com/wiki/Main_Page" {
+		t.Errorf("GET /wiki/Main_Page: %d to %q", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+}
+
+func TestResolveEncodedCodes(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "abc", &database.Link{URL: "https://example.com/abc"})
+	seedLink(t, "docs/api", &database.Link{URL: "https://example.com/api"})
+
+	tests := []struct {
+		path     string
+		status   int
+		location string
+	}{
+		{"/%61bc", fiber.StatusFound, "https://example.com/abc"},
+		{"/%61%62%63/", fiber.StatusFound, "https://example.com/abc"},
+		{"//abc", fiber.StatusFound, "https://example.com/abc"},
+		{"/docs//api", fiber.StatusFound, "https://example.com/api"},
+		{"/docs%2Fapi", fiber.StatusFound, "https://example.com/api"},
+		// escapes are only decoded once, and a code left with a % is refused
+		{"/%2561bc", fiber.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		resp := send(t, app, "GET", tt.path, "", "")
+		if resp.StatusCode != tt.status {
+			t.Errorf("GET %s: status = %d, want %d", tt.path, resp.StatusCode, tt.status)
+		} else if got := resp.Header.Get(fiber.HeaderLocation); got != tt.location {
+			t.Errorf("GET %s: Location = %q, want %q", tt.path, got, tt.location)
+		}
+	}
+}
+
+func TestResolveLinkHeaders(t *testing.T) {
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"headed","headers":{"x-campaign-id":"spring"}}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	owner := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+	if got := send(t, app, "GET", "/headed", "", "").Header.Get("X-Campaign-Id"); got != "spring" {
+		t.Errorf("X-Campaign-Id = %q, want spring", got)
+	}
+	if got := fmt.Sprint(decode(t, sendHeaders(t, app, "GET", "/api/v1/links/headed", "", owner))["headers"]); got != "map[X-Campaign-Id:spring]" {
+		t.Errorf("owner's link info headers = %s", got)
+	}
+	if _, ok := decode(t, send(t, app, "GET", "/api/v1/links/headed", "", ""))["headers"]; ok {
+		t.Error("link info shows headers to someone other than the owner")
+	}
+
+	for _, headers := range []string{
+		`{"Campaign":"spring"}`,
+		`{"X-Forwarded-For":"1.2.3.4"}`,
+		`{"Location":"https://evil.example/"}`,
+		`{"X-Campaign":"a\r\nSet-Cookie: b=1"}`,
+	} {
+		if resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","headers":`+headers+`}`, ""); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("shorten with headers %s: status = %d, want %d", headers, resp.StatusCode, fiber.StatusBadRequest)
+		}
+		if resp := sendHeaders(t, app, "PATCH", "/headed", `{"headers":`+headers+`}`, owner); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("update with headers %s: status = %d, want %d", headers, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+
+	// an empty map removes them
+	if resp := sendHeaders(t, app, "PATCH", "/headed", `{"headers":{}}`, owner); resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("update: status = %d (%v)", resp.StatusCode, decode(t, resp))
+	}
+	if got := send(t, app, "GET", "/headed", "", "").Header.Get("X-Campaign-Id"); got != "" {
+		t.Errorf("after removing them, X-Campaign-Id = %q", got)
+	}
+}
+
+func TestResolveOptions(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "preflight", &database.Link{URL: "https://example.com/"})
+	misses := metrics.ResolveMisses.Value("")
+	monitor := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0", fiber.HeaderOrigin: "https://site.example"}
+
+	for _, path := range []string{"/preflight", "/docs/start", "/missing"} {
+		resp := sendHeaders(t, app, "OPTIONS", path, "", monitor)
+		if resp.StatusCode != fiber.StatusNoContent || resp.Header.Get(fiber.HeaderAllow) != "GET, HEAD, PATCH, OPTIONS" {
+			t.Errorf("OPTIONS %s: %d, Allow %q; want %d with GET, HEAD, PATCH, OPTIONS", path, resp.StatusCode, resp.Header.Get(fiber.HeaderAllow), fiber.StatusNoContent)
+		}
+	}
+	key := database.LinkKey("", "preflight")
+	if n, _ := database.CreateClient(1).Exists(context.Background(), database.ClicksKey(key)).Result(); n != 0 {
+		t.Error("OPTIONS counted a click")
+	}
+	if got := metrics.ResolveMisses.Value("") - misses; got != 0 {
+		t.Errorf("OPTIONS counted %d resolve misses", got)
+	}
+}
+
+func TestResolveSlidingExpiry(t *testing.T) {
+	t.Setenv("SLIDING_EXPIRY_MAX", "3h")
+	app := newTestApp()
+	ctx := context.Background()
+	r := database.CreateClient(0)
+	key := database.LinkKey("", "gate-slide")
+	if err := database.SaveLink(ctx, r, key, &database.Link{URL: "https://example.com/", Tags: []string{"slide"}}, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := database.IndexTags(ctx, r, "", []string{"slide"}, "gate-slide", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// TTLs are whole seconds, and a little time passes between visits
+	near := func(got, want time.Duration) bool { return got <= want && got > want-5*time.Second }
+
+	for i, tt := range []struct {
+		grace string
+		want  time.Duration
+	}{
+		{"", time.Hour},
+		{"1h", 2 * time.Hour},
+		{"1h", 3 * time.Hour},
+		{"1h", 3 * time.Hour},
+	} {
+		t.Setenv("SLIDING_EXPIRY", tt.grace)
+		if resp := send(t, app, "GET", "/gate-slide", "", ""); resp.StatusCode != fiber.StatusFound {
+			t.Fatalf("visit %d: status = %d", i+1, resp.StatusCode)
+		}
+		if got := r.TTL(ctx, key).Val(); !near(got, tt.want) {
+			t.Errorf("visit %d, SLIDING_EXPIRY=%q: TTL = %v, want %v", i+1, tt.grace, got, tt.want)
+		}
+		// the link stays findable by its tag for as long as it lives
+		if got := r.TTL(ctx, database.TagKey("", "slide")).Val(); got < tt.want-5*time.Second {
+			t.Errorf("visit %d: tag index TTL = %v, want at least %v", i+1, got, tt.want)
+		}
+	}
+}
+
+func TestResolveShort(t *testing.T) {
+	app := newTestApp()
+	long := "docs/" + strings.Repeat("section/", 20) + "getting-started"
+	seedLink(t, long, &database.Link{URL: "https://example.com/docs/start", Permanent: true, MaxClicks: 2})
+	seedLink(t, "hidden-dest", &database.Link{URL: "https://example.com/secret", HideDestination: true, EditTokenHash: helpers.HashToken("owner-token")})
+	resolve := func(body string, header map[string]string) (*http.Response, map[string]interface{}) {
+		t.Helper()
+		resp := sendHeaders(t, app, "POST", "/api/v1/resolve", body, header)
+		return resp, decode(t, resp)
+	}
+
+	for i := 0; i < 2; i++ {
+		// the slashes need no escaping, and extra ones are dropped as in a path
+		resp, got := resolve(`{"short":"/`+long+`/"}`, nil)
+		if resp.StatusCode != fiber.StatusOK || got["destination"] != "https://example.com/docs/start" || got["permanent"] != true {
+			t.Fatalf("resolve %d: %d %v", i, resp.StatusCode, got)
+		}
+		if resp.Header.Get(fiber.HeaderCacheControl) != "no-store" || resp.Header.Get(fiber.HeaderLocation) != "" {
+			t.Errorf("resolve %d: Cache-Control = %q, Location = %q, want no-store and no redirect", i, resp.Header.Get(fiber.HeaderCacheControl), resp.Header.Get(fiber.HeaderLocation))
+		}
+	}
+	// each was a visit, so max_clicks is used up
+	if resp, got := resolve(`{"short":"`+long+`"}`, nil); resp.StatusCode != fiber.StatusGone {
+		t.Errorf("past max_clicks: %d %v, want %d", resp.StatusCode, got, fiber.StatusGone)
+	}
+
+	// a hidden destination is told to its owner alone
+	if resp, _ := resolve(`{"short":"hidden-dest"}`, nil); resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("hidden, not owner: status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+	resp, got := resolve(`{"short":"hidden-dest"}`, map[string]string{"X-Edit-Token": "owner-token"})
+	if resp.StatusCode != fiber.StatusOK || got["destination"] != "https://example.com/secret" {
+		t.Errorf("hidden, owner: %d %v", resp.StatusCode, got)
+	}
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{
+		{`{"short":"no-such-code"}`, fiber.StatusNotFound},
+		{`{"short":" / "}`, fiber.StatusBadRequest},
+		{`{}`, fiber.StatusBadRequest},
+		{`{"short":`, fiber.StatusBadRequest},
+	} {
+		if resp, got := resolve(tt.body, nil); resp.StatusCode != tt.status {
+			t.Errorf("resolve %s: %d %v, want %d", tt.body, resp.StatusCode, got, tt.status)
+		}
+	}
+}
+
+func TestResolveRedirectBody(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "bodied", &database.Link{URL: "https://example.com/search?q=a&b=<c>"})
+	tests := []struct {
+		setting     string
+		contentType string
+		want        []string
+	}{
+		{"", "", nil},
+		{"none", "", nil},
+		{"text", fiber.MIMETextPlainCharsetUTF8, []string{"Redirecting to example.com…\n"}},
+		{"html", fiber.MIMETextHTMLCharsetUTF8, []string{
+			`<a href="https://example.com/search?q=a&amp;b=%3cc%3e">example.com</a>`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Setenv("REDIRECT_BODY", tt.setting)
+		resp := send(t, app, "GET", "/bodied", "", "")
+		body := readAll(t, resp)
+		if resp.StatusCode != fiber.StatusFound || resp.Header.Get(fiber.HeaderLocation) != "https://example.com/search?q=a&b=<c>" {
+			t.Errorf("REDIRECT_BODY=%q: %d to %q, want the redirect", tt.setting, resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+		}
+		if tt.want == nil {
+			if body != "" {
+				t.Errorf("REDIRECT_BODY=%q: body = %q, want none", tt.setting, body)
+			}
+			continue
+		}
+		if got := resp.Header.Get(fiber.HeaderContentType); got != tt.contentType {
+			t.Errorf("REDIRECT_BODY=%q: Content-Type = %q, want %q", tt.setting, got, tt.contentType)
+		}
+		for _, w := range tt.want {
+			if !strings.Contains(body, w) {
+				t.Errorf("REDIRECT_BODY=%q: body = %q, want it to contain %q", tt.setting, body, w)
+			}
+		}
+	}
+}
+
+// send makes one request to app, with a JSON body and a Referer if given.
+func send(t *testing.T, app *fiber.App, method, path, body, referer string) *http.Response {
+	t.Helper()
+	var header map[string]string
+	if referer != "" {
+		header = map[string]string{fiber.HeaderReferer: referer}
+	}
+	return sendHeaders(t, app, method, path, body, header)
+}
+
+// sendHeaders is send with any request headers, which override the JSON
+// Content-Type.
+func sendHeaders(t *testing.T, app *fiber.App, method, path, body string, header map[string]string) *http.Response {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	return resp
+}
+
+// decode reads resp's JSON body, failing t if it isn't JSON.
+func decode(t *testing.T, resp *http.Response) map[string]interface{} {
+	t.Helper()
+	var body map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding the response: %v", err)
+	}
+	return body
+}
+
+// saveKey stores key under the API key raw, failing t if it can't.
+func saveKey(t *testing.T, raw string, key *database.APIKey) {
+	t.Helper()
+	key.ID = helpers.HashToken(raw)
+	if err := database.SaveAPIKey(context.Background(), database.CreateClient(0), key.ID, key); err != nil {
+		t.Fatalf("saving %s: %v", raw, err)
+	}
+}
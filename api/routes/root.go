@@ -0,0 +1,21 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// Root handles GET /. With ROOT_SHORT_CODE set it resolves like that short
+// code, counting the visit as one of its clicks. Otherwise it sends visitors
+// to ROOT_REDIRECT (e.g. the marketing site) when configured, and reports that
+// the service is up when not.
+func Root(c *fiber.Ctx) error {
+	if code := helpers.NormalizeCode(config.String("ROOT_SHORT_CODE", "")); code != "" {
+		return resolveCode(c, code, false)
+	}
+	if target := config.String("ROOT_REDIRECT", ""); target != "" {
+		return c.Redirect(target, fiber.StatusFound)
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"status": "ok"})
+}
@@ -0,0 +1,81 @@
+package routes
+
+import (
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestRoot(t *testing.T) {
+	t.Run("without ROOT_REDIRECT", func(t *testing.T) {
+		app := newTestApp()
+		resp := send(t, app, "GET", "/", "", "")
+		if resp.StatusCode != fiber.StatusOK {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+		}
+		if body := decode(t, resp); body["status"] != "ok" {
+			t.Errorf("body = %v, want status ok", body)
+		}
+	})
+
+	t.Run("with ROOT_REDIRECT", func(t *testing.T) {
+		t.Setenv("ROOT_REDIRECT", "https://example.com/about")
+		app := newTestApp()
+		resp := send(t, app, "GET", "/", "", "")
+		if resp.StatusCode != fiber.StatusFound {
+			t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusFound)
+		}
+		if got := resp.Header.Get(fiber.HeaderLocation); got != "https://example.com/about" {
+			t.Errorf("Location = %q, want https://example.com/about", got)
+		}
+	})
+
+	t.Run("short codes", func(t *testing.T) {
+		t.Setenv("ROOT_REDIRECT", "https://example.com/about")
+		app := newTestApp()
+		seedLink(t, "abc", &database.Link{URL: "https://example.com/abc"})
+		if resp := send(t, app, "GET", "/abc", "", ""); resp.Header.Get(fiber.HeaderLocation) != "https://example.com/abc" {
+			t.Errorf("GET /abc: %d to %q, want its own link", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+		}
+		if resp := send(t, app, "GET", "/nope", "", ""); resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("GET /nope: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+		}
+	})
+	t.Run("with ROOT_SHORT_CODE", func(t *testing.T) {
+		// the code wins over ROOT_REDIRECT
+		t.Setenv("ROOT_REDIRECT", "https://example.com/about")
+		t.Setenv("ROOT_SHORT_CODE", "/campaigns/spring/")
+		app := newTestApp()
+		seedLink(t, "campaigns/spring", &database.Link{URL: "https://example.com/spring"})
+		seedLink(t, "abc", &database.Link{URL: "https://example.com/abc"})
+		browser := map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"}
+
+		resp := sendHeaders(t, app, "GET", "/", "", browser)
+		if resp.StatusCode != fiber.StatusFound || resp.Header.Get(fiber.HeaderLocation) != "https://example.com/spring" {
+			t.Fatalf("GET /: %d to %q, want the campaign", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+		}
+		sendHeaders(t, app, "GET", "/campaigns/spring", "", browser)
+		if got := decode(t, send(t, app, "GET", "/api/v1/stats/campaigns/spring", "", ""))["clicks"]; got != float64(2) {
+			t.Errorf("campaign clicks = %v, want 2 with the root visit", got)
+		}
+
+		// other codes and the service's own files are unaffected
+		if resp := send(t, app, "GET", "/abc", "", ""); resp.Header.Get(fiber.HeaderLocation) != "https://example.com/abc" {
+			t.Errorf("GET /abc: %d to %q, want its own link", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+		}
+		for _, path := range []string{"/favicon.ico", "/robots.txt"} {
+			if resp := send(t, app, "GET", path, "", ""); resp.StatusCode != fiber.StatusOK {
+				t.Errorf("GET %s: status = %d, want %d", path, resp.StatusCode, fiber.StatusOK)
+			}
+		}
+	})
+
+	t.Run("with ROOT_SHORT_CODE missing", func(t *testing.T) {
+		t.Setenv("ROOT_SHORT_CODE", "gone")
+		app := newTestApp()
+		if resp := send(t, app, "GET", "/", "", ""); resp.StatusCode != fiber.StatusNotFound {
+			t.Errorf("GET /: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+		}
+	})
+}
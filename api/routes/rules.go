@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// Rules publishes the constraints ShortenURL applies to custom shorts.
+func Rules(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"charset":  helpers.CustomShortCharset,
+		"min_len":  helpers.CustomShortMinLength(),
+		"max_len":  helpers.CustomShortMaxLen,
+		"reserved": helpers.ReservedShorts(),
+
+		"separator":    helpers.CustomShortSeparator,
+		"max_path_len": helpers.CustomShortMaxPathLen,
+	})
+}
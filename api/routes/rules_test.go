@@ -0,0 +1,55 @@
+package routes
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRules(t *testing.T) {
+	t.Setenv("RESERVED_SHORTS", "Login, help,api")
+	t.Setenv("MIN_CUSTOM_SHORT_LEN", "5")
+	app := newTestApp()
+
+	resp := send(t, app, "GET", "/api/v1/rules", "", "")
+	body := decode(t, resp)
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+	want := map[string]interface{}{
+		"charset":      "A-Za-z0-9_-",
+		"min_len":      float64(5),
+		"max_len":      float64(32),
+		"reserved":     []interface{}{"api", "admin", "login", "help"},
+		"separator":    "/",
+		"max_path_len": float64(128),
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("rules = %v, want %v", body, want)
+	}
+
+	// the server refuses what the rules rule out, and nothing else
+	tests := []struct {
+		short string
+		ok    bool
+	}{
+		{"hello", true},
+		{"help-me", true},
+		{"hell", false},
+		{"login", false},
+		{"HELP", false},
+		{"help/me", false},
+		{strings.Repeat("a", 32), true},
+		{strings.Repeat("a", 33), false},
+		{"hello world", false},
+	}
+	for _, tt := range tests {
+		resp := send(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":"https://example.com/","short":%q}`, tt.short), "")
+		if ok := resp.StatusCode == fiber.StatusCreated; ok != tt.ok {
+			t.Errorf("shorten %q: status = %d (%v), want ok = %v", tt.short, resp.StatusCode, decode(t, resp), tt.ok)
+		}
+	}
+}
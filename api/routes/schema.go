@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// ShortenSchema publishes a JSON Schema for the body of POST /api/v1, with the
+// same constraints ShortenURL enforces, so clients can validate locally.
+func ShortenSchema(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusOK).JSON(shortenSchema())
+}
+
+// shortenSchema builds the schema from the request struct, so every field the
+// endpoint accepts is described, and from the validation settings in effect.
+func shortenSchema() fiber.Map {
+	segment := fmt.Sprintf("[%s]{1,%d}", helpers.CustomShortCharset, helpers.CustomShortMaxLen)
+	sep := regexp.QuoteMeta(helpers.CustomShortSeparator)
+	reserved := make([]string, 0, len(helpers.ReservedShorts()))
+	for _, w := range helpers.ReservedShorts() {
+		reserved = append(reserved, anyCase(w))
+	}
+
+	url := fiber.Map{
+		"type":        "string",
+		"minLength":   1,
+		"description": "Destination URL. http:// is added when there is no scheme.",
+	}
+	if config.Bool("HTTPS_ONLY", false) {
+		url["pattern"] = "^" + anyCase("https") + "://"
+		url["description"] = "Destination URL; it must use https."
+	}
+
+	constraints := map[string]fiber.Map{
+		"url": url,
+		"short": {
+			"type":      "string",
+			"minLength": helpers.CustomShortMinLength(),
+			"maxLength": helpers.CustomShortMaxPathLen,
+			"pattern":   "^" + segment + "(" + sep + segment + ")*$",
+			"not":       fiber.Map{"pattern": "^(" + strings.Join(reserved, "|") + ")(" + sep + "|$)"},
+		},
+		"expiry": {
+			"oneOf": []fiber.Map{
+				{"type": "string", "pattern": "^(never|([0-9.]+[mhdw])+)$"},
+				{"type": "number", "minimum": 0, "description": "Hours, for older clients."},
+			},
+			"description": fmt.Sprintf("Defaults to 24h. Longer expiries are clamped to %s.", helpers.MaxExpiry()),
+		},
+		"cache_ttl_seconds":        {"type": "integer", "minimum": 0},
+		"resolve_limit_per_minute": {"type": "integer", "minimum": 0},
+		"max_clicks":               {"type": "integer", "minimum": 0, "description": "Expires the link after this many redirects; 0 is unlimited."},
+		"note":                     {"type": "string", "maxLength": maxNoteLen},
+		"allowed_referrers": {
+			"type":  "array",
+			"items": fiber.Map{"type": "string", "pattern": "^[^:/, ]+$"},
+		},
+		"tags": {
+			"type":     "array",
+			"maxItems": config.Int("MAX_TAGS_PER_LINK", helpers.MaxTags),
+			"items": fiber.Map{
+				"type":      "string",
+				"minLength": 1,
+				"maxLength": config.Int("MAX_TAG_LEN", helpers.MaxTagLen),
+				"pattern":   "^[" + helpers.CustomShortCharset + "]+$",
+			},
+		},
+		"locales": {
+			"type":                 "object",
+			"additionalProperties": fiber.Map{"type": "string", "minLength": 1},
+		},
+		"headers": {
+			"type":                 "object",
+			"maxProperties":        helpers.MaxLinkHeaders,
+			"propertyNames":        fiber.Map{"pattern": fmt.Sprintf("^[A-Za-z0-9-]{1,%d}$", helpers.MaxLinkHeaderNameLen)},
+			"additionalProperties": fiber.Map{"type": "string", "maxLength": helpers.MaxLinkHeaderValueLen},
+		},
+	}
+
+	props := fiber.Map{}
+	t := reflect.TypeOf(request{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		prop, ok := constraints[name]
+		if !ok {
+			prop = fiber.Map{"type": jsonType(t.Field(i).Type)}
+		}
+		props[name] = prop
+	}
+
+	return fiber.Map{
+		"$schema":    "https://json-schema.org/draft/2020-12/schema",
+		"title":      "Shorten request",
+		"type":       "object",
+		"required":   []string{"url"},
+		"properties": props,
+	}
+}
+
+// jsonType is the JSON Schema type a request field of type t is decoded from.
+func jsonType(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return "string"
+}
+
+// anyCase is a pattern matching s case-insensitively, since JSON Schema
+// patterns have no flags.
+func anyCase(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if lower, upper := unicode.ToLower(r), unicode.ToUpper(r); lower != upper {
+			b.WriteString("[" + string(lower) + string(upper) + "]")
+		} else {
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// property is the part of JSON Schema the shorten schema uses.
+type property struct {
+	Type      string               `json:"type"`
+	MinLength *int                 `json:"minLength"`
+	MaxLength *int                 `json:"maxLength"`
+	MaxItems  *int                 `json:"maxItems"`
+	Pattern   string               `json:"pattern"`
+	Not       *property            `json:"not"`
+	OneOf     []property           `json:"oneOf"`
+	Items     *property            `json:"items"`
+	Props     map[string]*property `json:"properties"`
+}
+
+// matches reports whether s satisfies p's string constraints.
+func (p *property) matches(t *testing.T, s string) bool {
+	t.Helper()
+	if p.MinLength != nil && len([]rune(s)) < *p.MinLength || p.MaxLength != nil && len([]rune(s)) > *p.MaxLength {
+		return false
+	}
+	if p.Pattern != "" && !regexp.MustCompile(p.Pattern).MatchString(s) {
+		return false
+	}
+	return p.Not == nil || !p.Not.matches(t, s)
+}
+
+func TestShortenSchema(t *testing.T) {
+	t.Setenv("RESERVED_SHORTS", "admin")
+	t.Setenv("MAX_TAGS_PER_LINK", "2")
+	app := newTestApp()
+	resp := send(t, app, "GET", "/api/v1/schema/shorten", "", "")
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	var schema struct {
+		Schema   string   `json:"$schema"`
+		Required []string `json:"required"`
+		property
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema.Schema != "https://json-schema.org/draft/2020-12/schema" || schema.Type != "object" || !reflect.DeepEqual(schema.Required, []string{"url"}) {
+		t.Errorf("schema = %s, type %s, required %v", schema.Schema, schema.Type, schema.Required)
+	}
+
+	// every field the endpoint decodes is described, with a type
+	fields := reflect.TypeOf(request{})
+	for i := 0; i < fields.NumField(); i++ {
+		name, _, _ := strings.Cut(fields.Field(i).Tag.Get("json"), ",")
+		prop := schema.Props[name]
+		if prop == nil {
+			t.Errorf("%s is missing", name)
+		} else if prop.Type == "" && prop.OneOf == nil {
+			t.Errorf("%s has no type", name)
+		}
+	}
+	if len(schema.Props) != fields.NumField() {
+		t.Errorf("%d properties for %d fields", len(schema.Props), fields.NumField())
+	}
+
+	// the constraints agree with what shorten accepts
+	short := schema.Props["short"]
+	for _, s := range []string{"promo", "docs/start", "Admin", "admin/x", "admins", "a b", "a:b", "é", "ab", strings.Repeat("a", 300)} {
+		resp := send(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":"https://example.com/","short":%q}`, s), "")
+		if accepted := resp.StatusCode == fiber.StatusCreated; accepted != short.matches(t, s) {
+			t.Errorf("short %q: schema matches = %v, shorten status %d", s, !accepted, resp.StatusCode)
+		}
+	}
+	tags := schema.Props["tags"]
+	if tags.MaxItems == nil || *tags.MaxItems != 2 || tags.Items == nil {
+		t.Fatalf("tags = %+v, want at most 2 items", tags)
+	}
+	for _, tag := range []string{"spring", "", "no spaces", strings.Repeat("t", *tags.Items.MaxLength+1)} {
+		resp := send(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":"https://example.com/","tags":[%q]}`, tag), "")
+		if accepted := resp.StatusCode == fiber.StatusCreated; accepted != tags.Items.matches(t, tag) {
+			t.Errorf("tag %q: schema matches = %v, shorten status %d", tag, !accepted, resp.StatusCode)
+		}
+	}
+	expiry := schema.Props["expiry"]
+	if len(expiry.OneOf) != 2 {
+		t.Fatalf("expiry = %+v, want a string or a number", expiry)
+	}
+	for _, e := range []string{"30m", "1d12h", "never", "7 days", "30s"} {
+		resp := send(t, app, "POST", "/api/v1", fmt.Sprintf(`{"url":"https://example.com/","expiry":%q}`, e), "")
+		if accepted := resp.StatusCode == fiber.StatusCreated; accepted != expiry.OneOf[0].matches(t, e) {
+			t.Errorf("expiry %q: schema matches = %v, shorten status %d", e, !accepted, resp.StatusCode)
+		}
+	}
+}
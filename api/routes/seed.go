@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// maxSeedLinks caps one seed request, so a typo can't fill the database.
+const maxSeedLinks = 10000
+
+// Seed bulk-creates count links for end-to-end and load tests. The links are
+// deterministic: link i has code "<prefix>-<i>" (signed when CODE_SIGNING_KEY
+// is set) and points at https://example.com/<prefix>/<i>, so a benchmark can
+// resolve known codes and reseeding replaces the same links. It's only
+// registered when ENABLE_SEED_ENDPOINT is set, and bypasses quotas and the
+// usual checks, so it must never be enabled in production.
+func Seed(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	body := struct {
+		Count  int    `json:"count"`
+		Prefix string `json:"prefix"`
+	}{Prefix: "seed"}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	if body.Count <= 0 || body.Count > maxSeedLinks {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("count must be between 1 and %d", maxSeedLinks)})
+	}
+	if err := helpers.ValidateCustomShort(fmt.Sprintf("%s-%d", body.Prefix, body.Count-1)); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "prefix: " + err.Error()})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	ttl, _ := helpers.ParseExpiry("")
+	now := time.Now()
+	var first, last string
+	for i := 0; i < body.Count; i++ {
+		id := helpers.SignCode(fmt.Sprintf("%s-%d", body.Prefix, i))
+		key := database.LinkKey("", id)
+		link := &database.Link{
+			URL:       fmt.Sprintf("https://example.com/%s/%d", body.Prefix, i),
+			Permanent: true,
+			CreatedAt: now,
+		}
+		// drop fields a previous run's link may have had
+		if err := r.Del(ctx, key).Err(); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if err := database.SaveLink(ctx, r, key, link, ttl); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		_ = database.IndexDestination(ctx, r, "", link.URL, id, ttl)
+		if i == 0 {
+			first = id
+		}
+		last = id
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"seeded": body.Count,
+		"first":  first,
+		"last":   last,
+	})
+}
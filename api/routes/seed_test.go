@@ -0,0 +1,57 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestSeed(t *testing.T) {
+	r := database.CreateClient(0)
+	ctx := context.Background()
+
+	t.Setenv("ENABLE_SEED_ENDPOINT", "")
+	app := newTestApp()
+	if resp := send(t, app, "POST", "/api/v1/_seed", `{"count":3}`, ""); resp.StatusCode == fiber.StatusCreated {
+		t.Errorf("with the flag off: status = %d, want the endpoint absent", resp.StatusCode)
+	}
+	if n, _ := r.Exists(ctx, database.LinkKey("", "seed-0")).Result(); n != 0 {
+		t.Fatal("with the flag off, a link was seeded")
+	}
+
+	t.Setenv("ENABLE_SEED_ENDPOINT", "true")
+	app = newTestApp()
+	resp := send(t, app, "POST", "/api/v1/_seed", `{"count":25,"prefix":"bench"}`, "")
+	body := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated || body["seeded"] != float64(25) || body["first"] != "bench-0" || body["last"] != "bench-24" {
+		t.Fatalf("seed: %d %v, want %d with bench-0 to bench-24", resp.StatusCode, body, fiber.StatusCreated)
+	}
+	var keys []string
+	for i := 0; i <= 25; i++ {
+		keys = append(keys, database.LinkKey("", fmt.Sprintf("bench-%d", i)))
+	}
+	if n, _ := r.Exists(ctx, keys...).Result(); n != 25 {
+		t.Errorf("%d of bench-0 to bench-25 stored, want 25", n)
+	}
+	resp = send(t, app, "GET", "/bench-7", "", "")
+	if resp.StatusCode != fiber.StatusMovedPermanently || resp.Header.Get(fiber.HeaderLocation) != "https://example.com/bench/7" {
+		t.Errorf("GET /bench-7: %d to %q, want %d to https://example.com/bench/7", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation), fiber.StatusMovedPermanently)
+	}
+
+	// reseeding replaces the same links
+	if resp := send(t, app, "POST", "/api/v1/_seed", `{"count":25,"prefix":"bench"}`, ""); resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("reseed: status = %d", resp.StatusCode)
+	}
+	if n, _ := r.Exists(ctx, keys...).Result(); n != 25 {
+		t.Errorf("after reseeding, %d of bench-0 to bench-25 stored, want 25", n)
+	}
+
+	for _, body := range []string{`{}`, `{"count":0}`, `{"count":10001}`, `{"count":2,"prefix":"bad prefix"}`} {
+		if resp := send(t, app, "POST", "/api/v1/_seed", body, ""); resp.StatusCode != fiber.StatusBadRequest {
+			t.Errorf("seed %s: status = %d, want %d", body, resp.StatusCode, fiber.StatusBadRequest)
+		}
+	}
+}
@@ -1,59 +1,201 @@
 package routes
 
 import (
-	"os"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
-	"github.com/asaskevich/govalidator"
 	"github.com/go-redis/redis/v8"
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	"github.com/karthikbhandary2/url-shortener/config"
 	"github.com/karthikbhandary2/url-shortener/database"
 	"github.com/karthikbhandary2/url-shortener/helpers"
+	"github.com/karthikbhandary2/url-shortener/metrics"
+	"github.com/karthikbhandary2/url-shortener/middleware"
 )
 
 type request struct {
-	URL         string        `json:"url"`
-	CustomShort string        `json:"short"`
-	Expiry      time.Duration `json:"expiry"`
+	URL         string `json:"url"`
+	CustomShort string `json:"short"`
+	Expiry      expiry `json:"expiry"`
+	// Permanent links redirect with 301, temporary ones with 302. Defaults to true.
+	Permanent       *bool `json:"permanent"`
+	CacheTTLSeconds *int  `json:"cache_ttl_seconds"`
+	// ResolveLimitPerMinute caps how often the short link can be followed.
+	ResolveLimitPerMinute int `json:"resolve_limit_per_minute"`
+	// MaxClicks expires the link after this many redirects, or at its expiry
+	// if that comes first.
+	MaxClicks int `json:"max_clicks"`
+	// HideDestination keeps the destination out of the peek endpoint.
+	HideDestination bool `json:"hide_destination"`
+	// Note is a description for the owner; it never affects redirects.
+	Note string `json:"note"`
+	// AllowedReferrers limits which sites the link can be followed from.
+	AllowedReferrers []string `json:"allowed_referrers"`
+	// Tags group the link for bulk operations such as deleting a campaign.
+	Tags []string `json:"tags"`
+	// Locales maps language tags (and "default") to locale-specific destinations.
+	Locales map[string]string `json:"locales"`
+	// Headers are extra response headers to set on redirects.
+	Headers map[string]string `json:"headers"`
+	// FallbackURL is used while the destination is failing health checks.
+	FallbackURL string `json:"fallback_url"`
+	// NoReferrer hides the short domain from the destination's Referer.
+	NoReferrer bool `json:"no_referrer"`
+	// AppendPath appends path segments after the code to the destination.
+	AppendPath bool `json:"append_path"`
+	// ReservationToken claims a custom short held with ReserveShort.
+	ReservationToken string `json:"reservation_token"`
+}
+
+// expiryError is a problem with the request's expiry. Its message is safe to
+// show the client.
+type expiryError struct{ error }
+
+// expiry is the requested lifetime of a link. It accepts a duration string
+// such as "30m", "48h" or "7d", "never", or a bare number of hours for older
+// clients.
+type expiry time.Duration
+
+func (e *expiry) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		d, err := helpers.ParseExpiry(s)
+		if err != nil {
+			return expiryError{err}
+		}
+		*e = expiry(d)
+		return nil
+	}
+
+	var hours float64
+	if err := json.Unmarshal(b, &hours); err != nil || hours < 0 {
+		return expiryError{errors.New("expiry must be a duration string or a number of hours")}
+	}
+	// converting an out of range float to a Duration would wrap around
+	if hours*float64(time.Hour) >= math.MaxInt64 {
+		return expiryError{errors.New("expiry is too large")}
+	}
+	*e = expiry(time.Duration(hours * float64(time.Hour)))
+	return nil
 }
 
 type response struct {
-	URL             string        `json:"url"`
-	CustomShort     string        `json:"short"`
-	Expiry          time.Duration `json:"expiry"`
+	URL string `json:"url"`
+	// Code is the short code alone, e.g. "abc123".
+	Code string `json:"code"`
+	// ShortPath is the code on its domain, e.g. "localhost:3000/abc123".
+	ShortPath string `json:"short_path"`
+	// ShortURL is ShortPath with the request's scheme, e.g.
+	// "https://localhost:3000/abc123".
+	ShortURL string `json:"short_url"`
+	// CustomShort repeats ShortPath for older clients.
+	//
+	// Deprecated: use ShortPath or Code; short will be removed in the next release.
+	CustomShort string        `json:"short"`
+	Expiry      time.Duration `json:"expiry"`
+	// ExpiresAt is when the link stops working; null if it never expires.
+	ExpiresAt *time.Time `json:"expires_at"`
+	// MaxClicks is how many redirects the link serves before it expires.
+	MaxClicks int  `json:"max_clicks,omitempty"`
+	Permanent bool `json:"permanent"`
+	// EditToken authorizes later changes to the link. It is only ever returned
+	// here, so clients must keep it.
+	EditToken       string        `json:"edit_token"`
 	XRateRemaining  int64         `json:"rate_limit"`
 	XRateLimitReset time.Duration `json:"rate_limit_reset"`
 }
 
 func ShortenURL(c *fiber.Ctx) error {
+	ctx := c.UserContext()
 	body := new(request)
+	fields, err := responseFields(c)
+	if err != nil {
+		return badRequest(c, "fields", err.Error())
+	}
+
 	if err := c.BodyParser(&body); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "cannot parse JSON"})
+		return badRequest(c, parseReason(err), parseError(err))
 	}
 
 	//rate limiting
-	redisClient := database.CreateClient(1)
-	defer redisClient.Close()
+	redisClient := database.CreateClientContext(ctx, 1)
 
-	value, err := redisClient.Get(database.Ctx, c.IP()).Result()
-	if err == redis.Nil {
-		_ = redisClient.Set(database.Ctx, c.IP(), os.Getenv("API_QUOTA"), 30*time.Minute).Err()
-	} else if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
-	} else {
-		value, _ = redisClient.Get(database.Ctx, c.IP()).Result()
-		val, _ := strconv.Atoi(value)
+	// a retried request with the same Idempotency-Key gets the original response
+	idemKey := c.Get("Idempotency-Key")
+	if idemKey != "" {
+		cached, err := redisClient.Get(ctx, idempotencyKey(c.IP(), idemKey)).Bytes()
+		if err == nil {
+			var prev response
+			_ = json.Unmarshal(cached, &prev)
+			// responses cached before short_url existed only carry short
+			return sendCreated(c, cached, prev.CustomShort, fields)
+		} else if err != redis.Nil {
+			if !rateLimitFailOpen(c, "checking Idempotency-Key", err) {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+			}
+			// a retry may create a second link, but the first isn't lost
+			idemKey = ""
+		}
+	}
+
+	counter, quotaTracked, ok, err := checkQuota(c, redisClient)
+	if !ok {
+		return err
+	}
+	_, _, unlimited := quotaCounter(c)
+	if body.CacheTTLSeconds != nil && *body.CacheTTLSeconds < 0 {
+		return badRequest(c, "cache_ttl", "cache_ttl_seconds cannot be negative")
+	}
+
+	if err := validateNote(body.Note); err != nil {
+		return badRequest(c, "note", err.Error())
+	}
+
+	if body.ResolveLimitPerMinute < 0 {
+		return badRequest(c, "resolve_limit", "resolve_limit_per_minute cannot be negative")
+	}
+	if body.MaxClicks < 0 {
+		return badRequest(c, "max_clicks", "max_clicks cannot be negative")
+	}
+
+	referrers, err := normalizeReferrers(body.AllowedReferrers)
+	if err != nil {
+		return badRequest(c, "allowed_referrers", err.Error())
+	}
+
+	tags, err := helpers.NormalizeTags(body.Tags)
+	if err != nil {
+		return badRequest(c, "tags", err.Error())
+	}
+
+	locales, err := normalizeLocales(body.Locales)
+	if err != nil {
+		return reject(c, validationStatus(err), "locales", err.Error())
+	}
+
+	headers, err := helpers.NormalizeHeaders(body.Headers)
+	if err != nil {
+		return badRequest(c, "headers", err.Error())
+	}
 
-		if val <= 0 {
-			limit, _ := redisClient.TTL(database.Ctx, c.IP()).Result()
-			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "rate limit exceeded", "rate_limit_reset": limit / time.Nanosecond / time.Minute})
+	if body.FallbackURL != "" {
+		if body.FallbackURL, err = normalizeDestination(body.FallbackURL); err != nil {
+			return reject(c, validationStatus(err), "fallback_url", "fallback_url: "+err.Error())
 		}
 	}
+
 	// check if the input is an actual url
-	if !govalidator.IsURL(body.URL) {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "invalid URL"})
+	if err := helpers.ValidateURL(body.URL); err != nil {
+		return badRequest(c, "url", err.Error())
 	}
 
 	//check for domain error
@@ -62,52 +204,423 @@ func ShortenURL(c *fiber.Ctx) error {
 	}
 
 	// enforce https, SSL
-	body.URL = helpers.EnforceHTTP(body.URL)
+	body.URL = canonicalDestination(body.URL)
+	if err := helpers.CheckHTTPS(body.URL); err != nil {
+		return badRequest(c, "https", err.Error())
+	}
+
+	if err := helpers.CheckExtension(body.URL); err != nil {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := helpers.CheckPrivateHost(body.URL); err != nil {
+		return reject(c, validationStatus(err), "private_host", err.Error())
+	}
 
 	// check if the custom short url is already in use
-	var id string
-	if body.CustomShort == "" {
-		id = uuid.New().String()[:6]
-	} else {
-		id = body.CustomShort
+	// codes made with a namespaced API key, generated or custom, go in its
+	// namespace, e.g. team-a/promo
+	var code string
+	if body.CustomShort != "" {
+		code = helpers.Namespaced(namespace(c), body.CustomShort)
+		if err := helpers.ValidateCustomShort(code); err != nil {
+			return badRequest(c, "custom_short", err.Error())
+		}
+		if helpers.IsPremiumShort(body.CustomShort) && !canClaimPremium(c) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "custom short is reserved for premium use", "code": "custom_short_premium"})
+		}
 	}
 
-	r := database.CreateClient(0)
-	defer r.Close()
+	r := database.CreateClientContext(ctx, 0)
+
+	if body.CustomShort != "" {
+		taken, err := intoNamespace(c, r, body.CustomShort)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if taken {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "custom short is in another namespace", "code": "custom_short_namespace"})
+		}
+	}
+
+	// links created on a tenant's custom domain live in that tenant's namespace
+	tenant, err := database.TenantForHost(ctx, r, c.Hostname())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	// a generated code that's taken, which gets likelier the shorter
+	// SHORT_CODE_LENGTH is, is swapped for another; only a custom short the
+	// client asked for is refused
+	var id, display, key string
+	for attempt := 1; ; attempt++ {
+		if body.CustomShort == "" {
+			code = helpers.Namespaced(namespace(c), helpers.GenerateCode())
+		}
+		// with CASE_INSENSITIVE_SHORTS the code is stored lowercased but
+		// shown as it was asked for; with CODE_SIGNING_KEY set it carries its
+		// check suffix
+		id = helpers.CanonicalCode(code)
+		signed := helpers.SignCode(id)
+		display, id = code+signed[len(id):], signed
+		key = database.LinkKey(tenant, id)
+
+		conflict, err := claimNewLink(ctx, r, key, body.ReservationToken)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if conflict == "" {
+			break
+		}
+		if body.CustomShort != "" {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": claimConflicts[conflict], "code": conflict})
+		}
+		if attempt == maxCodeAttempts {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"error": "no free short code could be generated, try again", "code": "code_space_exhausted"})
+		}
+	}
+	defer database.ReleaseLink(ctx, r, key)
 
-	value, _ = r.Get(database.Ctx, id).Result()
-	if value != "" {
-		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "URL custom short is already in use"})
+	// cap how many live links one IP can hold, on top of the request rate
+	if maxLinks := config.Int("MAX_LINKS_PER_IP", 0); maxLinks > 0 {
+		active, err := database.ActiveIPLinks(ctx, r, c.IP())
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+		}
+		if active >= maxLinks {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "too many active links from this IP", "code": "link_limit_reached"})
+		}
 	}
 
 	//set the default expiry time to 24 hours if user does not provide one
-	if body.Expiry == 0 {
-		body.Expiry = 24
+	ttl := time.Duration(body.Expiry)
+	if ttl == 0 {
+		ttl, _ = helpers.ParseExpiry("")
+	}
+	if limit := helpers.MaxExpiry(); ttl > limit {
+		ttl = limit
 	}
 
-	err = r.Set(database.Ctx, id, body.URL, body.Expiry*time.Hour).Err()
+	editToken := uuid.New().String()
+	link := &database.Link{
+		URL:             body.URL,
+		Permanent:       body.Permanent == nil || *body.Permanent,
+		CreatedAt:       time.Now(),
+		CacheTTLSeconds: body.CacheTTLSeconds,
+
+		ResolveLimitPerMinute: body.ResolveLimitPerMinute,
+		MaxClicks:             body.MaxClicks,
+		HideDestination:       body.HideDestination,
+		Note:                  body.Note,
+		EditTokenHash:         helpers.HashToken(editToken),
+		AllowedReferrers:      referrers,
+		Tags:                  tags,
+		Locales:               locales,
+		Headers:               headers,
+		FallbackURL:           body.FallbackURL,
+		NoReferrer:            body.NoReferrer,
+		AppendPath:            body.AppendPath,
+	}
+	if display != id {
+		link.Display = display
+	}
+	if apiKey := middleware.APIKey(c); apiKey != nil {
+		link.Owner = apiKey.ID
+	}
+	if config.Bool("STORE_CREATOR_INFO", false) {
+		link.CreatorIP = helpers.VisitorID(c.IP())
+		link.CreatorUserAgent = c.Get(fiber.HeaderUserAgent)
+		if len(link.CreatorUserAgent) > 512 {
+			link.CreatorUserAgent = link.CreatorUserAgent[:512]
+		}
+	}
+
+	if err := checkLinkSize(link); err != nil {
+		return badRequest(c, "link_size", err.Error())
+	}
+
+	if err := resetReclaimedStats(ctx, r, redisClient, key); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	err = database.SaveLink(ctx, r, key, link, ttl)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
 	}
+	_ = database.IndexDestination(ctx, r, tenant, body.URL, id, ttl)
+	_ = database.IndexTags(ctx, r, tenant, tags, id, ttl)
+	if config.Int("MAX_LINKS_PER_IP", 0) > 0 {
+		_ = database.IndexIPLink(ctx, r, c.IP(), key, ttl)
+	}
+	if link.Owner != "" {
+		_ = database.IndexOwnerLink(ctx, r, link.Owner, key, ttl)
+	}
+	_ = database.RankLink(ctx, redisClient, key)
+	recordEvent(ctx, r, key, eventCreated, "")
+	// the hold has done its job
+	r.Del(ctx, reservationKey(key))
 
 	// response
 	resp := response{
 		URL:             body.URL,
-		CustomShort:     "",
-		Expiry:          body.Expiry,
+		Code:            display,
+		Expiry:          ttl / time.Hour,
+		ExpiresAt:       expiresAt(link.CreatedAt, ttl),
+		MaxClicks:       link.MaxClicks,
+		Permanent:       link.Permanent,
+		EditToken:       editToken,
 		XRateRemaining:  10,
 		XRateLimitReset: 30,
 	}
 
 	//decrease the quota after func call
-	redisClient.Decr(database.Ctx, c.IP())
+	if quotaTracked {
+		redisClient.Decr(ctx, counter)
+
+		val, _ := redisClient.Get(ctx, counter).Result()
+		intVal, _ := strconv.Atoi(val)
+		resp.XRateRemaining = int64(intVal)
+		reset, _ := redisClient.TTL(ctx, counter).Result()
+		resp.XRateLimitReset = reset / time.Nanosecond / time.Minute
+	} else if unlimited {
+		resp.XRateRemaining, resp.XRateLimitReset = -1, 0
+	}
+
+	domain := helpers.ShortDomain(c.Hostname())
+	if tenant != "" {
+		domain = c.Hostname()
+	}
+	resp.ShortPath, resp.ShortURL = helpers.BuildShortURL(c.Protocol(), domain, display)
+	resp.CustomShort = resp.ShortPath
+
+	out, err := json.Marshal(resp)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot encode response"})
+	}
+	if idemKey != "" {
+		_ = redisClient.Set(ctx, idempotencyKey(c.IP(), idemKey), out, config.Duration("IDEMPOTENCY_TTL", 24*time.Hour)).Err()
+	}
+
+	return sendCreated(c, out, resp.ShortURL, fields)
+}
+
+// rateLimitFailOpen reports whether a shorten request may go ahead unlimited
+// after the rate-limit store (DB 1) failed with err while doing what. That's
+// only with RATE_LIMIT_FAIL_OPEN=true; by default such requests fail closed.
+// Each error let through is logged and counted in rate_limit_fail_open_total.
+func rateLimitFailOpen(c *fiber.Ctx, what string, err error) bool {
+	if !config.Bool("RATE_LIMIT_FAIL_OPEN", false) {
+		return false
+	}
+	log.Printf("shorten: %s for %s: %v; letting the request through", what, c.IP(), err)
+	metrics.RateLimitFailOpen.Inc("")
+	return true
+}
+
+// badRequest rejects a shorten request with 400, counting it in
+// shorten_bad_request_total under reason.
+func badRequest(c *fiber.Ctx, reason, msg string) error {
+	return reject(c, fiber.StatusBadRequest, reason, msg)
+}
+
+// reject is badRequest for checks whose status depends on the error. Only the
+// 400s are counted; a 403 for a blocked destination isn't a malformed request.
+func reject(c *fiber.Ctx, status int, reason, msg string) error {
+	if status == fiber.StatusBadRequest {
+		metrics.ShortenBadRequests.Inc(reason)
+	}
+	return c.Status(status).JSON(fiber.Map{"error": msg})
+}
+
+// parseReason is the shorten_bad_request_total reason for a body that failed
+// to parse, matching the cases parseError tells apart.
+func parseReason(err error) string {
+	var expErr expiryError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &expErr):
+		return "expiry"
+	case errors.As(err, &typeErr):
+		return "type"
+	case errors.As(err, &syntaxErr):
+		return "syntax"
+	}
+	return "body"
+}
+
+// parseError explains why a request body failed to parse, naming the field at
+// fault where the decoder knows it, instead of a blanket "cannot parse JSON".
+func parseError(err error) string {
+	var expErr expiryError
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &expErr):
+		return expErr.Error()
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Sprintf("invalid value for %s: expected %s, got %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return fmt.Sprintf("cannot parse JSON: malformed at byte %d", syntaxErr.Offset)
+	}
+	return "cannot parse JSON"
+}
+
+// sendCreated responds to a successful shorten with 201 Created and the new
+// short URL in Location. LEGACY_SHORTEN_STATUS=true keeps the old plain 200
+// for clients that haven't moved over yet. When fields is non-nil, only those
+// response fields are sent.
+func sendCreated(c *fiber.Ctx, body []byte, short string, fields []string) error {
+	if fields != nil {
+		var err error
+		if body, err = project(body, fields); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot encode response"})
+		}
+	}
+	// ?fields= names snake_case fields, so keys are renamed after projecting
+	if helpers.CamelCaseJSON() {
+		var err error
+		if body, err = helpers.CamelCaseKeys(body); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot encode response"})
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	if config.Bool("LEGACY_SHORTEN_STATUS", false) {
+		return c.Status(fiber.StatusOK).Send(body)
+	}
+
+	if short != "" && !strings.Contains(short, "://") {
+		short = c.Protocol() + "://" + short
+	}
+	c.Set(fiber.HeaderLocation, short)
+	return c.Status(fiber.StatusCreated).Send(body)
+}
+
+// canClaimPremium reports whether the request may claim PREMIUM_SHORTS: it
+// comes from an admin or an API key with the premium scope.
+func canClaimPremium(c *fiber.Ctx) bool {
+	return middleware.IsAdmin(c) || middleware.HasScope(c, "premium")
+}
+
+// responseFields reads the response fields a client asked for with ?fields=
+// or the X-Response-Fields header, as a comma-separated list of JSON names,
+// which may also be camelCase under JSON_NAMING=camel. It returns nil,
+// meaning the full response, when neither is given.
+func responseFields(c *fiber.Ctx) ([]string, error) {
+	raw := c.Query("fields")
+	if raw == "" {
+		raw = c.Get("X-Response-Fields")
+	}
+	if raw == "" {
+		return nil, nil
+	}
+
+	// the names a client may use, mapped to the response's own
+	known := map[string]string{}
+	t := reflect.TypeOf(response{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		known[name] = name
+		if helpers.CamelCaseJSON() {
+			known[helpers.CamelCase(name)] = name
+		}
+	}
+
+	var fields []string
+	for _, f := range strings.Split(raw, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		name, ok := known[f]
+		if !ok {
+			return nil, fmt.Errorf("unknown response field %q", f)
+		}
+		fields = append(fields, name)
+	}
+	return fields, nil
+}
+
+// project keeps only fields of an encoded response.
+func project(body []byte, fields []string) ([]byte, error) {
+	var full map[string]json.RawMessage
+	if err := json.Unmarshal(body, &full); err != nil {
+		return nil, err
+	}
+	out := make(map[string]json.RawMessage, len(fields))
+	for _, f := range fields {
+		if v, ok := full[f]; ok {
+			out[f] = v
+		}
+	}
+	return json.Marshal(out)
+}
+
+// expiresAt is when a link created at created with ttl expires, or nil if it
+// never does.
+func expiresAt(created time.Time, ttl time.Duration) *time.Time {
+	if ttl == helpers.NeverExpires {
+		return nil
+	}
+	t := created.Add(ttl).UTC()
+	return &t
+}
+
+// idempotencyKey scopes an Idempotency-Key to the caller so one client can't
+// replay another's response.
+func idempotencyKey(ip, key string) string {
+	return "idempotency:" + ip + ":" + key
+}
+
+// maxCodeAttempts is how many generated codes shorten tries before giving up.
+const maxCodeAttempts = 10
+
+// claimConflicts are the errors for the codes claimNewLink reports.
+var claimConflicts = map[string]string{
+	"custom_short_taken":    "URL custom short is already in use",
+	"custom_short_expired":  "URL custom short expired recently and can't be reused yet",
+	"custom_short_reserved": "URL custom short is reserved",
+}
 
-	val, _ := redisClient.Get(database.Ctx, c.IP()).Result()
-	intVal, _ := strconv.Atoi(val)
-	resp.XRateRemaining = int64(intVal)
-	ttl, _ := redisClient.TTL(database.Ctx, c.IP()).Result()
-	resp.XRateLimitReset = ttl/ time.Nanosecond / time.Minute
+// claimNewLink claims key for a new link made with reservation token token.
+// It returns "" once the key is claimed, to be released with
+// database.ReleaseLink, or the code of the claimConflicts entry saying why it
+// can't be used.
+func claimNewLink(ctx context.Context, r database.Store, key, token string) (string, error) {
+	// a request for the same code that's still being created loses here
+	// rather than overwriting it
+	claimed, err := database.ClaimLink(ctx, r, key)
+	if err != nil {
+		return "", err
+	}
+	if !claimed {
+		return "custom_short_taken", nil
+	}
 
-	resp.CustomShort = os.Getenv("DOMAIN") + "/" + id
-	return c.Status(fiber.StatusOK).JSON(resp)
+	conflict, err := linkConflict(ctx, r, key, token)
+	if conflict != "" || err != nil {
+		database.ReleaseLink(ctx, r, key)
+	}
+	return conflict, err
+}
+
+// linkConflict is claimNewLink's check of a claimed key.
+func linkConflict(ctx context.Context, r database.Store, key, token string) (string, error) {
+	exists, _ := r.Exists(ctx, key).Result()
+	if exists > 0 {
+		return "custom_short_taken", nil
+	}
+	reclaimable, err := checkReclaim(ctx, r, key)
+	if err != nil {
+		return "", err
+	}
+	if !reclaimable {
+		return "custom_short_expired", nil
+	}
+	claimable, err := checkReservation(ctx, r, key, token)
+	if err != nil {
+		return "", err
+	}
+	if !claimable {
+		return "custom_short_reserved", nil
+	}
+	return "", nil
 }
@@ -0,0 +1,37 @@
+package routes
+
+import (
+	_ "embed"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+)
+
+//go:embed static/favicon.ico
+var favicon []byte
+
+// Favicon handles GET /favicon.ico, which browsers request on their own, so
+// it never reaches the short-code lookup or counts as a resolve miss. It
+// redirects to FAVICON_URL when configured, and otherwise serves a small
+// built-in icon.
+func Favicon(c *fiber.Ctx) error {
+	if target := config.String("FAVICON_URL", ""); target != "" {
+		return c.Redirect(target, fiber.StatusFound)
+	}
+	c.Set(fiber.HeaderContentType, "image/x-icon")
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Status(fiber.StatusOK).Send(favicon)
+}
+
+// Robots handles GET /robots.txt. With ROBOTS_POLICY=allow, the default,
+// crawlers may follow short links but not the API; disallow keeps them out
+// entirely.
+func Robots(c *fiber.Ctx) error {
+	policy := "User-agent: *\nDisallow: /api/\n"
+	if config.String("ROBOTS_POLICY", "allow") == "disallow" {
+		policy = "User-agent: *\nDisallow: /\n"
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextPlainCharsetUTF8)
+	c.Set(fiber.HeaderCacheControl, "public, max-age=86400")
+	return c.Status(fiber.StatusOK).SendString(policy)
+}
@@ -0,0 +1,47 @@
+package routes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/metrics"
+)
+
+func TestStaticPaths(t *testing.T) {
+	app := newTestApp()
+	misses := metrics.ResolveMisses.Value("")
+
+	resp := send(t, app, "GET", "/favicon.ico", "", "")
+	if resp.StatusCode != fiber.StatusOK || resp.Header.Get(fiber.HeaderContentType) != "image/x-icon" {
+		t.Errorf("favicon: %d %s, want %d image/x-icon", resp.StatusCode, resp.Header.Get(fiber.HeaderContentType), fiber.StatusOK)
+	}
+	if body := readAll(t, resp); !bytes.Equal([]byte(body), favicon) {
+		t.Errorf("favicon: %d bytes, want the built-in %d", len(body), len(favicon))
+	}
+	t.Setenv("FAVICON_URL", "https://cdn.example.com/icon.png")
+	if resp := send(t, app, "GET", "/favicon.ico", "", ""); resp.StatusCode != fiber.StatusFound || resp.Header.Get(fiber.HeaderLocation) != "https://cdn.example.com/icon.png" {
+		t.Errorf("favicon with FAVICON_URL: %d to %q", resp.StatusCode, resp.Header.Get(fiber.HeaderLocation))
+	}
+
+	for policy, want := range map[string]string{
+		"":         "User-agent: *\nDisallow: /api/\n",
+		"allow":    "User-agent: *\nDisallow: /api/\n",
+		"disallow": "User-agent: *\nDisallow: /\n",
+	} {
+		t.Setenv("ROBOTS_POLICY", policy)
+		resp := send(t, app, "GET", "/robots.txt", "", "")
+		if body := readAll(t, resp); resp.StatusCode != fiber.StatusOK || body != want {
+			t.Errorf("ROBOTS_POLICY=%q: %d %q, want %d %q", policy, resp.StatusCode, body, fiber.StatusOK, want)
+		}
+	}
+
+	if got := metrics.ResolveMisses.Value("") - misses; got != 0 {
+		t.Errorf("resolve_miss_total moved by %d, want 0", got)
+	}
+	// while a real miss is still counted
+	send(t, app, "GET", "/no-such-code", "", "")
+	if got := metrics.ResolveMisses.Value("") - misses; got != 1 {
+		t.Errorf("after a miss, resolve_miss_total moved by %d, want 1", got)
+	}
+}
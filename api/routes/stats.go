@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"bytes"
+	"html/template"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// statsHours is how many hourly buckets the stats endpoint returns.
+const statsHours = 24
+
+var statsTemplate = template.Must(template.New("stats.html").Funcs(template.FuncMap{
+	"barHeight": func(clicks, peak int64) int64 {
+		if peak == 0 {
+			return 0
+		}
+		return clicks * 100 / peak
+	},
+}).ParseFS(templates, "templates/stats.html"))
+
+// LinkStats reports a link's total clicks and its clicks per hour over the
+// last day. It serves JSON by default and an HTML page with a click chart to
+// clients that prefer text/html.
+func LinkStats(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	rStats := database.CreateClientContext(ctx, 1)
+
+	key = statsKey(key, link)
+
+	clicks, series, err := database.LinkClicks(ctx, rStats, key, statsHours)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	botClicks, err := database.LinkBotClicks(ctx, rStats, key)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	visitors, err := database.LinkVisitors(ctx, rStats, key)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	stats := fiber.Map{
+		"short":           displayCode(id, link),
+		"url":             link.URL,
+		"clicks":          clicks,
+		"bot_clicks":      botClicks,
+		"unique_visitors": visitors,
+		"series":          series,
+	}
+	if !link.CreatedAt.IsZero() {
+		stats["created_at"] = link.CreatedAt
+	}
+
+	// caches must not serve the page to API clients or the JSON to browsers
+	c.Vary(fiber.HeaderAccept)
+	if c.Accepts(fiber.MIMEApplicationJSON, fiber.MIMETextHTML) != fiber.MIMETextHTML {
+		return c.Status(fiber.StatusOK).JSON(stats)
+	}
+
+	var peak int64
+	for _, h := range series {
+		if h.Clicks > peak {
+			peak = h.Clicks
+		}
+	}
+	stats["peak"] = peak
+
+	var buf bytes.Buffer
+	if err := statsTemplate.Execute(&buf, stats); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot render stats"})
+	}
+	c.Set(fiber.HeaderContentType, fiber.MIMETextHTMLCharsetUTF8)
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
@@ -0,0 +1,174 @@
+package routes
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestLinkStatsNegotiation(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "stats", &database.Link{URL: "https://example.com/stats"})
+	for i := 0; i < 3; i++ {
+		sendHeaders(t, app, "GET", "/stats", "", map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"})
+	}
+
+	tests := []struct {
+		accept string
+		html   bool
+	}{
+		{"", false},
+		{"*/*", false},
+		{fiber.MIMEApplicationJSON, false},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", true},
+		{fiber.MIMETextHTML, true},
+		{"application/json;q=0.5, text/html", true},
+		{"text/html;q=0.5, application/json", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			var header map[string]string
+			if tt.accept != "" {
+				header = map[string]string{fiber.HeaderAccept: tt.accept}
+			}
+			resp := sendHeaders(t, app, "GET", "/api/v1/stats/stats", "", header)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+			}
+			if got := resp.Header.Get(fiber.HeaderVary); !strings.Contains(got, fiber.HeaderAccept) {
+				t.Errorf("Vary = %q, want it to include Accept", got)
+			}
+			body := readAll(t, resp)
+			if tt.html {
+				if got := resp.Header.Get(fiber.HeaderContentType); got != fiber.MIMETextHTMLCharsetUTF8 {
+					t.Errorf("Content-Type = %q, want %q", got, fiber.MIMETextHTMLCharsetUTF8)
+				}
+				for _, want := range []string{"<h1>stats</h1>", "<b>3</b> clicks", `class="bar" style="height: 100%"`} {
+					if !strings.Contains(body, want) {
+						t.Errorf("page is missing %q:\n%s", want, body)
+					}
+				}
+				return
+			}
+			if got := resp.Header.Get(fiber.HeaderContentType); got != fiber.MIMEApplicationJSON {
+				t.Errorf("Content-Type = %q, want %q", got, fiber.MIMEApplicationJSON)
+			}
+			var stats struct {
+				Short  string `json:"short"`
+				Clicks int64  `json:"clicks"`
+				Series []struct {
+					Clicks int64 `json:"clicks"`
+				} `json:"series"`
+			}
+			if err := json.Unmarshal([]byte(body), &stats); err != nil {
+				t.Fatalf("decoding %q: %v", body, err)
+			}
+			if stats.Short != "stats" || stats.Clicks != 3 || len(stats.Series) != 24 || stats.Series[23].Clicks != 3 {
+				t.Errorf("stats = %+v, want 3 clicks in the last of 24 hours", stats)
+			}
+		})
+	}
+}
+
+func TestBotClicks(t *testing.T) {
+	const (
+		human = "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0"
+		bot   = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	)
+	tests := []struct {
+		mode      string
+		clicks    float64
+		botClicks float64
+	}{
+		{"", 2, 3},
+		{"separate", 2, 3},
+		{"skip", 2, 0},
+		{"count", 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			t.Setenv("BOT_CLICKS", tt.mode)
+			app := newTestApp()
+			seedLink(t, "bots", &database.Link{URL: "https://example.com/bots"})
+			for _, ua := range []string{human, bot, bot, human, ""} {
+				// bots are still redirected, whether or not they're counted
+				resp := sendHeaders(t, app, "GET", "/bots", "", map[string]string{fiber.HeaderUserAgent: ua})
+				if resp.StatusCode != fiber.StatusFound {
+					t.Fatalf("User-Agent %q: status = %d, want %d", ua, resp.StatusCode, fiber.StatusFound)
+				}
+			}
+			body := decode(t, send(t, app, "GET", "/api/v1/stats/bots", "", ""))
+			if body["clicks"] != tt.clicks || body["bot_clicks"] != tt.botClicks {
+				t.Errorf("clicks = %v, bot_clicks = %v; want %v, %v", body["clicks"], body["bot_clicks"], tt.clicks, tt.botClicks)
+			}
+		})
+	}
+}
+
+func TestUniqueVisitorsSalt(t *testing.T) {
+	t.Setenv("ANALYTICS_SALT", "first")
+	app := newTestApp()
+	seedLink(t, "salted", &database.Link{URL: "https://example.com/salted"})
+	visit := func() {
+		t.Helper()
+		sendHeaders(t, app, "GET", "/salted", "", map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"})
+	}
+	visitors := func() interface{} {
+		t.Helper()
+		return decode(t, send(t, app, "GET", "/api/v1/stats/salted", "", ""))["unique_visitors"]
+	}
+
+	for i := 0; i < 3; i++ {
+		visit()
+	}
+	if got := visitors(); got != float64(1) {
+		t.Errorf("one visitor three times: unique_visitors = %v, want 1", got)
+	}
+	// a rotated salt makes everyone a new visitor
+	t.Setenv("ANALYTICS_SALT", "second")
+	visit()
+	visit()
+	if got := visitors(); got != float64(2) {
+		t.Errorf("after rotating the salt: unique_visitors = %v, want 2", got)
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	app := newTestApp()
+	seedLink(t, "polled", &database.Link{URL: "https://example.com/", EditTokenHash: helpers.HashToken("tok")})
+	changes := map[string]func(){
+		"/api/v1/stats/polled": func() {
+			sendHeaders(t, app, "GET", "/polled", "", map[string]string{fiber.HeaderUserAgent: "Mozilla/5.0"})
+		},
+		"/api/v1/links/polled": func() {
+			sendHeaders(t, app, "PATCH", "/polled", `{"note":"changed"}`, map[string]string{"X-Edit-Token": "tok"})
+		},
+	}
+	for path, change := range changes {
+		t.Run(path, func(t *testing.T) {
+			resp := send(t, app, "GET", path, "", "")
+			tag := resp.Header.Get(fiber.HeaderETag)
+			if resp.StatusCode != fiber.StatusOK || tag == "" {
+				t.Fatalf("status = %d, ETag = %q", resp.StatusCode, tag)
+			}
+			conditional := map[string]string{fiber.HeaderIfNoneMatch: tag}
+			resp = sendHeaders(t, app, "GET", path, "", conditional)
+			if body := readAll(t, resp); resp.StatusCode != fiber.StatusNotModified || body != "" {
+				t.Errorf("unchanged: %d %q, want %d with no body", resp.StatusCode, body, fiber.StatusNotModified)
+			}
+
+			change()
+			resp = sendHeaders(t, app, "GET", path, "", conditional)
+			if resp.StatusCode != fiber.StatusOK {
+				t.Fatalf("changed: status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+			}
+			if got := resp.Header.Get(fiber.HeaderETag); got == "" || got == tag {
+				t.Errorf("changed: ETag = %q, want a new one", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package routes
+
+import (
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+type transferRequest struct {
+	APIKeyID string `json:"api_key_id"`
+}
+
+// TransferLink hands a link over to the API key with the given ID. The link's
+// edit token is replaced, so the previous owner's token stops working; the
+// new one is returned once, for the new owner.
+func TransferLink(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	body := new(transferRequest)
+	if err := c.BodyParser(body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	if body.APIKeyID == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "api_key_id is required"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if !isOwner(c, link) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid edit token"})
+	}
+	// transferring through an alias transfers the link it points at
+	if link.PrimaryKey != "" {
+		key = link.PrimaryKey
+		id = database.LinkCode(key)
+	}
+
+	if _, err := database.GetAPIKey(ctx, r, body.APIKeyID); err == redis.Nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "unknown api_key_id"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	previous := link.Owner
+	editToken := uuid.New().String()
+	link.Owner = body.APIKeyID
+	link.EditTokenHash = helpers.HashToken(editToken)
+	if err := database.UpdateLink(ctx, r, key, link); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if previous != "" && previous != link.Owner {
+		r.SRem(ctx, database.OwnerLinksKey(previous), key)
+	}
+	ttl, _ := r.TTL(ctx, key).Result()
+	_ = database.IndexOwnerLink(ctx, r, link.Owner, key, ttl)
+	recordEvent(ctx, r, key, eventTransferred, link.Owner)
+
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{
+		"code":       id,
+		"owner":      link.Owner,
+		"edit_token": editToken,
+	})
+}
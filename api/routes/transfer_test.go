@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+func TestTransferLink(t *testing.T) {
+	app := newTestApp()
+	ctx := context.Background()
+	r := database.CreateClient(0)
+	write := []string{database.ScopeWrite}
+	saveKey(t, "key-alice", &database.APIKey{Scopes: write})
+	saveKey(t, "key-bob", &database.APIKey{Scopes: write})
+	alice, bob := helpers.HashToken("key-alice"), helpers.HashToken("key-bob")
+	asAlice := map[string]string{"X-API-Key": "key-alice"}
+	asBob := map[string]string{"X-API-Key": "key-bob"}
+
+	resp := sendHeaders(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"handoff"}`, asAlice)
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	oldToken := map[string]string{"X-Edit-Token": fmt.Sprint(created["edit_token"])}
+	transfer := fmt.Sprintf(`{"api_key_id":%q}`, bob)
+
+	refused := []struct {
+		name   string
+		body   string
+		header map[string]string
+		status int
+	}{
+		{"as someone else", transfer, asBob, fiber.StatusForbidden},
+		{"anonymously", transfer, nil, fiber.StatusForbidden},
+		{"without a target", `{}`, asAlice, fiber.StatusBadRequest},
+		{"to an unknown key", `{"api_key_id":"nobody"}`, asAlice, fiber.StatusBadRequest},
+	}
+	for _, tt := range refused {
+		if resp := sendHeaders(t, app, "POST", "/handoff/transfer", tt.body, tt.header); resp.StatusCode != tt.status {
+			t.Errorf("transfer %s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+	if resp := sendHeaders(t, app, "POST", "/missing/transfer", transfer, asAlice); resp.StatusCode != fiber.StatusNotFound {
+		t.Errorf("transfer a missing link: status = %d, want %d", resp.StatusCode, fiber.StatusNotFound)
+	}
+
+	// the edit token works as well as the owner's key
+	resp = sendHeaders(t, app, "POST", "/handoff/transfer", transfer, oldToken)
+	moved := decode(t, resp)
+	if resp.StatusCode != fiber.StatusOK || moved["owner"] != bob || moved["code"] != "handoff" {
+		t.Fatalf("transfer: %d %v", resp.StatusCode, moved)
+	}
+	newToken := fmt.Sprint(moved["edit_token"])
+	if newToken == "" || newToken == oldToken["X-Edit-Token"] {
+		t.Fatalf("transfer: edit_token = %q, want a fresh one", newToken)
+	}
+
+	update := `{"note":"changed"}`
+	for _, tt := range []struct {
+		name   string
+		header map[string]string
+		status int
+	}{
+		{"old edit token", oldToken, fiber.StatusForbidden},
+		{"old owner's key", asAlice, fiber.StatusForbidden},
+		{"new owner's key", asBob, fiber.StatusOK},
+		{"new edit token", map[string]string{"X-Edit-Token": newToken}, fiber.StatusOK},
+	} {
+		if resp := sendHeaders(t, app, "PATCH", "/handoff", update, tt.header); resp.StatusCode != tt.status {
+			t.Errorf("update with the %s: status = %d, want %d", tt.name, resp.StatusCode, tt.status)
+		}
+	}
+
+	for owner, want := range map[string][]string{alice: {}, bob: {database.LinkKey("", "handoff")}} {
+		if keys, err := r.SMembers(ctx, database.OwnerLinksKey(owner)).Result(); err != nil || fmt.Sprint(keys) != fmt.Sprint(want) {
+			t.Errorf("links owned by %s = %v, %v; want %v", owner, keys, err, want)
+		}
+	}
+}
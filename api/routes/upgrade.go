@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// probeClient can't reach private addresses, since visitors' clicks decide
+// what it probes; each probe is bounded by HTTPS_PROBE_TIMEOUT instead of a
+// client timeout.
+var probeClient = func() *http.Client {
+	client := helpers.PublicClient(0)
+	// any answer over TLS will do, so redirects aren't followed
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	return client
+}()
+
+// upgradeInsecure returns the https:// version of an http:// dest for visitors
+// who ask for secure pages with Upgrade-Insecure-Requests, when
+// UPGRADE_INSECURE_ON_RESOLVE is on and the destination answers over HTTPS.
+// Anything else gets dest unchanged.
+func upgradeInsecure(c *fiber.Ctx, rdb database.Store, dest string) string {
+	if !config.Bool("UPGRADE_INSECURE_ON_RESOLVE", false) || !strings.HasPrefix(strings.ToLower(dest), "http://") {
+		return dest
+	}
+	// the redirect depends on the header, so caches must too
+	c.Vary("Upgrade-Insecure-Requests")
+	if c.Get("Upgrade-Insecure-Requests") != "1" {
+		return dest
+	}
+
+	secure := "https://" + dest[len("http://"):]
+	if !cachedHTTPS(c.UserContext(), rdb, secure) {
+		return dest
+	}
+	return secure
+}
+
+// cachedHTTPS reports whether secure answers over HTTPS, probing it at most
+// once per HTTPS_PROBE_TTL (default 24h) so clicks don't wait on it.
+func cachedHTTPS(ctx context.Context, rdb database.Store, secure string) bool {
+	cacheKey := "https_probe:" + secure
+	if v, err := rdb.Get(ctx, cacheKey).Result(); err == nil {
+		return v == "1"
+	}
+	ok := probeHTTPS(ctx, secure)
+	// failures are cached too, so an http-only site isn't probed every click
+	v := "0"
+	if ok {
+		v = "1"
+	}
+	rdb.Set(ctx, cacheKey, v, config.Duration("HTTPS_PROBE_TTL", 24*time.Hour))
+	return ok
+}
+
+// probeHTTPS sends one HEAD request to secure within HTTPS_PROBE_TIMEOUT
+// (default 2s). Any response with a valid certificate counts, whatever its
+// status; timeouts and TLS errors don't.
+func probeHTTPS(ctx context.Context, secure string) bool {
+	ctx, cancel := context.WithTimeout(ctx, config.Duration("HTTPS_PROBE_TIMEOUT", 2*time.Second))
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, secure, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return true
+}
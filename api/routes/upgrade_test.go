@@ -0,0 +1,93 @@
+package routes
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+// stubProbes makes probeClient answer HTTPS probes to secure.example and fail
+// TLS for every other host, for the rest of t, and returns how many probes
+// were made.
+func stubProbes(t *testing.T) *atomic.Int32 {
+	var probes atomic.Int32
+	saved := probeClient
+	probeClient = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		probes.Add(1)
+		if req.URL.Scheme != "https" || req.Method != http.MethodHead {
+			t.Errorf("probe %s %s, want a HEAD over https", req.Method, req.URL)
+		}
+		if req.URL.Hostname() != "secure.example" {
+			return nil, errors.New("tls: handshake failure")
+		}
+		return &http.Response{StatusCode: http.StatusNotFound, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})}
+	t.Cleanup(func() { probeClient = saved })
+	return &probes
+}
+
+func TestResolveUpgradeInsecure(t *testing.T) {
+	t.Setenv("UPGRADE_INSECURE_ON_RESOLVE", "true")
+	app := newTestApp()
+	probes := stubProbes(t)
+	seedLink(t, "upgradable", &database.Link{URL: "http://secure.example/page?q=1"})
+	seedLink(t, "plain-only", &database.Link{URL: "http://plain.example/page"})
+	seedLink(t, "already", &database.Link{URL: "https://secure.example/page"})
+	secure := map[string]string{"Upgrade-Insecure-Requests": "1"}
+
+	tests := []struct {
+		path     string
+		header   map[string]string
+		location string
+	}{
+		{"/upgradable", secure, "https://secure.example/page?q=1"},
+		{"/plain-only", secure, "http://plain.example/page"},
+		{"/already", secure, "https://secure.example/page"},
+		// visitors who don't ask for secure pages get the destination as stored
+		{"/upgradable", nil, "http://secure.example/page?q=1"},
+	}
+	for _, tt := range tests {
+		resp := sendHeaders(t, app, "GET", tt.path, "", tt.header)
+		if got := resp.Header.Get(fiber.HeaderLocation); resp.StatusCode != fiber.StatusFound || got != tt.location {
+			t.Errorf("GET %s with %v: %d to %q, want %d to %q", tt.path, tt.header, resp.StatusCode, got, fiber.StatusFound, tt.location)
+		}
+		if tt.path != "/already" && !strings.Contains(resp.Header.Get(fiber.HeaderVary), "Upgrade-Insecure-Requests") {
+			t.Errorf("GET %s: Vary = %q, want Upgrade-Insecure-Requests", tt.path, resp.Header.Get(fiber.HeaderVary))
+		}
+	}
+	if n := probes.Load(); n != 2 {
+		t.Errorf("%d probes, want one for each http destination", n)
+	}
+
+	// the results, failures included, are cached
+	for i := 0; i < 3; i++ {
+		sendHeaders(t, app, "GET", "/upgradable", "", secure)
+		sendHeaders(t, app, "GET", "/plain-only", "", secure)
+	}
+	if n := probes.Load(); n != 2 {
+		t.Errorf("after more clicks, %d probes, want still 2", n)
+	}
+
+	t.Setenv("UPGRADE_INSECURE_ON_RESOLVE", "")
+	if got := sendHeaders(t, app, "GET", "/upgradable", "", secure).Header.Get(fiber.HeaderLocation); got != "http://secure.example/page?q=1" {
+		t.Errorf("switched off: Location = %q, want the stored http destination", got)
+	}
+}
+
+func TestProbeHTTPSPrivate(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { hits.Add(1) }))
+	defer srv.Close()
+	// visitors' clicks trigger probes, so they can't reach internal services
+	if probeHTTPS(context.Background(), srv.URL) || hits.Load() != 0 {
+		t.Errorf("probing %s succeeded or reached it", srv.URL)
+	}
+}
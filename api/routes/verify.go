@@ -0,0 +1,64 @@
+package routes
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"github.com/karthikbhandary2/url-shortener/database"
+	"github.com/karthikbhandary2/url-shortener/helpers"
+)
+
+// VerifyEditToken reports whether edit_token is a link's edit token, so an
+// editor can check a stored token before showing its form. Nothing changes
+// either way. Each visitor can make VERIFY_LIMIT_PER_HOUR attempts, hits and
+// misses alike, so tokens can't be guessed through it.
+func VerifyEditToken(c *fiber.Ctx) error {
+	ctx := c.UserContext()
+	id := shortCode(c)
+	body := struct {
+		EditToken string `json:"edit_token"`
+	}{}
+	if err := c.BodyParser(&body); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": parseError(err)})
+	}
+	if body.EditToken == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "edit_token is required"})
+	}
+
+	rLimit := database.CreateClientContext(ctx, 1)
+
+	limitKey := "verify_limit:" + helpers.VisitorID(c.IP())
+	tries, err := rLimit.Incr(ctx, limitKey).Result()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	if tries == 1 {
+		rLimit.Expire(ctx, limitKey, time.Hour)
+	}
+	if tries > int64(config.Int("VERIFY_LIMIT_PER_HOUR", 30)) {
+		reset, _ := rLimit.TTL(ctx, limitKey).Result()
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(reset/time.Second)))
+		return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{"error": "too many attempts, try again later"})
+	}
+
+	r := database.CreateClientContext(ctx, 0)
+
+	key, err := tenantKey(c, r, id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+	link, err := database.GetLink(ctx, r, key)
+	if err == redis.Nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "short not found in the database"})
+	} else if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": "cannot connect to the DB"})
+	}
+
+	if !helpers.TokenMatches(body.EditToken, link.EditTokenHash) {
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{"error": "invalid edit token", "valid": false})
+	}
+	return c.Status(fiber.StatusOK).JSON(fiber.Map{"valid": true})
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/database"
+)
+
+func TestVerifyEditToken(t *testing.T) {
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"editable"}`, "")
+	created := decode(t, resp)
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("shorten: status = %d (%v)", resp.StatusCode, created)
+	}
+	token := fmt.Sprint(created["edit_token"])
+	// an imported link has no edit token at all
+	seedLink(t, "tokenless", &database.Link{URL: "https://example.com/"})
+
+	tests := []struct {
+		name, path, body string
+		status           int
+		valid            interface{}
+	}{
+		{"valid", "/editable/verify-token", `{"edit_token":"` + token + `"}`, fiber.StatusOK, true},
+		{"invalid", "/editable/verify-token", `{"edit_token":"guess"}`, fiber.StatusForbidden, false},
+		{"no token on the link", "/tokenless/verify-token", `{"edit_token":"guess"}`, fiber.StatusForbidden, false},
+		{"missing link", "/missing/verify-token", `{"edit_token":"` + token + `"}`, fiber.StatusNotFound, nil},
+		{"no token", "/editable/verify-token", `{}`, fiber.StatusBadRequest, nil},
+		{"bad JSON", "/editable/verify-token", `{"edit_token":`, fiber.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		resp := send(t, app, "POST", tt.path, tt.body, "")
+		body := decode(t, resp)
+		if resp.StatusCode != tt.status || body["valid"] != tt.valid {
+			t.Errorf("%s: %d %v, want %d with valid %v", tt.name, resp.StatusCode, body, tt.status, tt.valid)
+		}
+	}
+
+	// checking changes nothing
+	if resp := send(t, app, "GET", "/editable", "", ""); resp.StatusCode != fiber.StatusMovedPermanently {
+		t.Errorf("GET /editable: status = %d, want %d", resp.StatusCode, fiber.StatusMovedPermanently)
+	}
+	if resp := send(t, app, "POST", "/editable/verify-token", `{"edit_token":"`+token+`"}`, ""); resp.StatusCode != fiber.StatusOK {
+		t.Errorf("verifying again: status = %d, want %d", resp.StatusCode, fiber.StatusOK)
+	}
+}
+
+func TestVerifyEditTokenLimit(t *testing.T) {
+	t.Setenv("VERIFY_LIMIT_PER_HOUR", "3")
+	app := newTestApp()
+	resp := send(t, app, "POST", "/api/v1", `{"url":"https://example.com/","short":"guarded"}`, "")
+	token := fmt.Sprint(decode(t, resp)["edit_token"])
+
+	// misses and hits count alike
+	for i, guess := range []string{"a", "b", token} {
+		if resp := send(t, app, "POST", "/guarded/verify-token", `{"edit_token":"`+guess+`"}`, ""); resp.StatusCode == fiber.StatusTooManyRequests {
+			t.Fatalf("attempt %d limited", i+1)
+		}
+	}
+	resp = send(t, app, "POST", "/guarded/verify-token", `{"edit_token":"`+token+`"}`, "")
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("4th attempt: status = %d, want %d", resp.StatusCode, fiber.StatusTooManyRequests)
+	}
+	if retry, err := strconv.Atoi(resp.Header.Get(fiber.HeaderRetryAfter)); err != nil || retry <= 0 || retry > 3600 {
+		t.Errorf("Retry-After = %q, want the seconds until the hour is up", resp.Header.Get(fiber.HeaderRetryAfter))
+	}
+}
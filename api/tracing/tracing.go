@@ -0,0 +1,112 @@
+// Package tracing exports OpenTelemetry traces of requests and the Redis
+// commands they run. It is off unless OTEL_TRACING=true, in which case the
+// OTLP/HTTP exporter is configured by the standard OTEL_EXPORTER_OTLP_*
+// variables and the service name by OTEL_SERVICE_NAME.
+package tracing
+
+import (
+	"context"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/karthikbhandary2/url-shortener/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Name is the instrumentation name spans are recorded under.
+const Name = "github.com/karthikbhandary2/url-shortener"
+
+// Enabled reports whether tracing is switched on with OTEL_TRACING.
+func Enabled() bool {
+	return config.Bool("OTEL_TRACING", false)
+}
+
+// Start installs the OTLP exporter as the global tracer provider. The
+// returned function flushes and stops it; it's a no-op when tracing is off.
+func Start(ctx context.Context) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+	Install(tp)
+	return tp.Shutdown, nil
+}
+
+// Install makes tp the global tracer provider and propagates W3C trace
+// context and baggage.
+func Install(tp trace.TracerProvider) {
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Tracer returns the service's tracer from the global provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(Name)
+}
+
+// Middleware starts a server span for each request, continuing any trace
+// context sent by the caller, and makes it the request's user context so the
+// handler's Redis commands are recorded beneath it.
+func Middleware(c *fiber.Ctx) error {
+	if !Enabled() {
+		return c.Next()
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), headerCarrier{c})
+	ctx, span := Tracer().Start(ctx, c.Method(), trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+	c.SetUserContext(ctx)
+
+	err := c.Next()
+
+	// the route is only known once the router has matched it
+	route := c.Route().Path
+	span.SetName(c.Method() + " " + route)
+	status := c.Response().StatusCode()
+	if err != nil {
+		if fe, ok := err.(*fiber.Error); ok {
+			status = fe.Code
+		}
+		span.RecordError(err)
+	}
+	span.SetAttributes(
+		attribute.String("http.request.method", c.Method()),
+		attribute.String("http.route", route),
+		attribute.Int("http.response.status_code", status),
+	)
+	if status >= 500 {
+		span.SetStatus(codes.Error, "")
+	}
+	return err
+}
+
+// headerCarrier reads trace context from the request headers.
+type headerCarrier struct {
+	c *fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h headerCarrier) Set(key, value string) {
+	h.c.Set(key, value)
+}
+
+func (h headerCarrier) Keys() []string {
+	var keys []string
+	h.c.Request().Header.VisitAll(func(k, _ []byte) {
+		keys = append(keys, string(k))
+	})
+	return keys
+}